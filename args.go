@@ -0,0 +1,45 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// ArgsMutator instances can replace the arguments of the queries and
+// statements before they reach the driver, to encrypt, hash or coerce them
+// transparently. The arguments returned by BeforeWithArgs are the ones
+// passed to the driver, and the ones the Before, After and OnError callbacks
+// of every hook observe, in Event.Args too: BeforeWithArgs runs before any
+// of them. An error fails the operation, as an error of Before does.
+//
+// Returning a nil context keeps the one passed to BeforeWithArgs, returning
+// nil arguments keeps the original ones: an empty, non-nil slice removes
+// them.
+type ArgsMutator interface {
+	BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error)
+}
+
+// mutateArgs replaces the arguments of event with those returned by the
+// ArgsMutator hooks
+func (conn *Conn) mutateArgs(ctx context.Context, event *Event, query string, args []driver.NamedValue) (mutatedCtx context.Context, mutated []driver.NamedValue, err error) {
+	m := conn.ext.args
+	if m == nil {
+		return ctx, args, nil
+	}
+	mutatedCtx, mutated = ctx, args
+	defer conn.recoverHook(ctx, query, event.Args, &err)
+	c, margs, err := m.BeforeWithArgs(ctx, query, args)
+	if err != nil {
+		return ctx, nil, err
+	}
+	// As Compose does, a nil context keeps the caller's, and nil arguments
+	// the original ones
+	if c != nil {
+		ctx = c
+	}
+	if margs != nil {
+		args = margs
+	}
+	event.Args = namedToInterface(event.Args[:0], args)
+	return ctx, args, nil
+}
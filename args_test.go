@@ -0,0 +1,129 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doubler doubles the int64 arguments
+type doubler struct {
+	*testHooks
+	err error
+}
+
+func (d *doubler) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	if d.err != nil {
+		return ctx, nil, d.err
+	}
+	mutated := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		if n, ok := arg.Value.(int64); ok {
+			arg.Value = n * 2
+		}
+		mutated[i] = arg
+	}
+	return ctx, mutated, nil
+}
+
+func TestArgsMutator(t *testing.T) {
+	var observed [][]interface{}
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		observed = append(observed, args)
+		assert.Equal(t, args, EventFromContext(ctx).Args)
+		return ctx, nil
+	}
+	d := &doubler{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-args-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(d, hooks)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT ?", 2).Scan(&n))
+	assert.Equal(t, 4, n, "the driver receives the mutated arguments")
+
+	stmt, err := db.Prepare("SELECT ? + 1")
+	require.NoError(t, err)
+	defer stmt.Close()
+	require.NoError(t, stmt.QueryRow(3).Scan(&n))
+	assert.Equal(t, 7, n)
+	assert.Equal(t, [][]interface{}{{int64(4)}, {int64(6)}}, observed)
+
+	// Errors fail the operation before the hooks run
+	d.err = errors.New("cannot encrypt")
+	_, err = db.Exec("SELECT ?", 1)
+	assert.Equal(t, d.err, err)
+	assert.Len(t, observed, 2)
+}
+
+func TestArgsMutatorRouting(t *testing.T) {
+	for name, wrap := range map[string]func(*doubler) Hooks{
+		"matrix": func(d *doubler) Hooks { return NewMatrix().Add(d, OpQuery) },
+		"when": func(d *doubler) Hooks {
+			return When(func(e *Event) bool { return e.Op == OpQuery }, d)
+		},
+		"events": func(d *doubler) Hooks {
+			return When(func(e *Event) bool { return e.Op == OpQuery }, FromEventHooks(ToEventHooks(d)))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			driverName := fmt.Sprintf("sqlhooks-args-%s-%s", name, time.Now().String())
+			sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, wrap(&doubler{testHooks: newTestHooks()})))
+			db, err := sql.Open(driverName, ":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			_, err = db.Exec("CREATE TABLE t (n INTEGER)")
+			require.NoError(t, err)
+			_, err = db.Exec("INSERT INTO t VALUES (?)", 1)
+			require.NoError(t, err)
+			var n int
+			require.NoError(t, db.QueryRow("SELECT n + ? FROM t", 1).Scan(&n))
+			assert.Equal(t, 3, n, "only the query arguments are doubled")
+		})
+	}
+}
+
+// nilMutator returns a nil context and nil arguments
+type nilMutator struct {
+	*testHooks
+}
+
+func (nilMutator) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	return nil, nil, nil
+}
+
+func TestArgsMutatorNil(t *testing.T) {
+	for name, wrap := range map[string]func(Hooks) Hooks{
+		"single":  func(h Hooks) Hooks { return h },
+		"compose": func(h Hooks) Hooks { return Compose(h) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := nilMutator{testHooks: newTestHooks()}
+			var observed []interface{}
+			m.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+				require.NotNil(t, ctx, "the caller's context is kept")
+				observed = args
+				return ctx, nil
+			}
+			db := openWithHooks(t, wrap(m))
+			defer db.Close()
+
+			var n int
+			require.NoError(t, db.QueryRow("SELECT ?", 2).Scan(&n))
+			assert.Equal(t, 2, n, "the original arguments are kept")
+			assert.Equal(t, []interface{}{int64(2)}, observed)
+		})
+	}
+}
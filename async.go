@@ -0,0 +1,200 @@
+package sqlhooks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DetachValues returns a context holding the values of ctx under keys, and
+// none of its other values, deadline or cancellation. Hooks handing work to
+// other goroutines pass it the values they need, such as trace or tenant
+// IDs, without retaining the request scoped values of ctx nor failing as
+// the request is cancelled.
+func DetachValues(ctx context.Context, keys ...interface{}) context.Context {
+	detached := context.Background()
+	for _, key := range keys {
+		if value := ctx.Value(key); value != nil {
+			detached = context.WithValue(detached, key, value)
+		}
+	}
+	return detached
+}
+
+// AsyncConfig configures Async
+type AsyncConfig struct {
+	// Keys are the context keys whose values the asynchronous hooks see,
+	// see DetachValues.
+	Keys []interface{}
+	// Workers is the number of goroutines running the asynchronous hooks.
+	// Defaults to 1, which runs them in order.
+	Workers int
+	// QueueSize is the number of calls waiting for a worker. Defaults to
+	// 1024.
+	QueueSize int
+	// Block makes the statements wait for room in a full queue. By default,
+	// the calls that do not fit are dropped and counted, see Dropped, so
+	// that a slow exporter never slows the statements down.
+	Block bool
+}
+
+// AsyncHooks runs the After and OnError hooks of other hooks on a pool of
+// workers, see Async
+type AsyncHooks struct {
+	hooks   Hooks
+	keys    []interface{}
+	block   bool
+	queue   chan func()
+	workers sync.WaitGroup
+	dropped int64
+
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	closed  bool
+}
+
+// Async returns hooks running the Before hooks of hooks synchronously, and
+// their After and OnError hooks on the workers configured by cfg, for the
+// exporters whose latency must not add up to the statements. The
+// asynchronous hooks get a context from DetachValues with cfg.Keys, and a
+// copy of the Event of the operation, that they can keep. Their errors are
+// ignored: After returns no error and OnError returns the error of the
+// operation.
+//
+// The values the Before hooks add to the context must be allowlisted in
+// cfg.Keys for the asynchronous hooks to see them. Only the Hooks interface
+// of hooks is run. Close stops the workers once the queued calls ran.
+func Async(hooks Hooks, cfg AsyncConfig) *AsyncHooks {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	h := &AsyncHooks{hooks: hooks, keys: cfg.Keys, block: cfg.Block, queue: make(chan func(), cfg.QueueSize)}
+	h.idle = sync.NewCond(&h.mu)
+	h.workers.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go h.work()
+	}
+	return h
+}
+
+func (h *AsyncHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return h.hooks.Before(ctx, query, args...)
+}
+
+func (h *AsyncHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	detached, args := h.detach(ctx, args)
+	h.enqueue(func() {
+		_, _ = h.hooks.After(detached, query, args...)
+	})
+	return ctx, nil
+}
+
+func (h *AsyncHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	detached, args := h.detach(ctx, args)
+	h.enqueue(func() {
+		_ = handlerErr(detached, h.hooks, err, query, args...)
+	})
+	return err
+}
+
+// Dropped returns the number of calls dropped because the queue was full,
+// or the hooks closed
+func (h *AsyncHooks) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+// Flush waits for the queued calls to run
+func (h *AsyncHooks) Flush() {
+	h.mu.Lock()
+	for h.pending > 0 {
+		h.idle.Wait()
+	}
+	h.mu.Unlock()
+}
+
+// Close waits for the queued calls to run and stops the workers, such as
+// before the process exits. The calls made afterwards are dropped.
+func (h *AsyncHooks) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+
+	h.Flush()
+	close(h.queue)
+	h.workers.Wait()
+	return nil
+}
+
+// enqueue queues call for the workers, or drops it if the queue is full and
+// the hooks do not block
+func (h *AsyncHooks) enqueue(call func()) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		atomic.AddInt64(&h.dropped, 1)
+		return
+	}
+	// Counted before sending, so that Close does not close the queue
+	// under a blocked sender
+	h.pending++
+	h.mu.Unlock()
+
+	if h.block {
+		h.queue <- call
+		return
+	}
+	select {
+	case h.queue <- call:
+	default:
+		atomic.AddInt64(&h.dropped, 1)
+		h.done()
+	}
+}
+
+func (h *AsyncHooks) work() {
+	defer h.workers.Done()
+	for call := range h.queue {
+		call()
+		h.done()
+	}
+}
+
+func (h *AsyncHooks) done() {
+	h.mu.Lock()
+	h.pending--
+	if h.pending == 0 {
+		h.idle.Broadcast()
+	}
+	h.mu.Unlock()
+}
+
+// detach returns the context and arguments passed to the asynchronous hooks
+func (h *AsyncHooks) detach(ctx context.Context, args []interface{}) (context.Context, []interface{}) {
+	detached := DetachValues(ctx, h.keys...)
+	if event := EventFromContext(ctx); event != nil {
+		detached = contextWithEvent(detached, event.clone())
+	}
+	return detached, append([]interface{}(nil), args...)
+}
+
+// clone returns a copy of e that outlives its operation, with pooling
+func (e *Event) clone() *Event {
+	c := *e
+	c.Args = append([]interface{}(nil), e.Args...)
+	if e.annotations != nil {
+		c.annotations = make(map[string]interface{}, len(e.annotations))
+		for k, v := range e.annotations {
+			c.annotations[k] = v
+		}
+	}
+	c.attempts = nil
+	return &c
+}
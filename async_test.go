@@ -0,0 +1,140 @@
+package sqlhooks
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetachValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.WithValue(context.Background(),
+		ctxKey("trace"), "abc"), ctxKey("request"), "large"))
+	cancel()
+
+	detached := DetachValues(ctx, ctxKey("trace"), ctxKey("tenant"))
+	assert.NoError(t, detached.Err())
+	assert.Equal(t, "abc", detached.Value(ctxKey("trace")))
+	assert.Nil(t, detached.Value(ctxKey("request")))
+	assert.Nil(t, detached.Value(ctxKey("tenant")))
+}
+
+func TestAsyncHooks(t *testing.T) {
+	type call struct {
+		query  string
+		trace  interface{}
+		ctxErr error
+		event  *Event
+		err    error
+	}
+	calls := make(chan call, 2)
+	hooks := newTestHooks()
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		calls <- call{query: query, trace: ctx.Value(ctxKey("trace")), ctxErr: ctx.Err(), event: EventFromContext(ctx)}
+		return ctx, errors.New("ignored")
+	}
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		calls <- call{query: query, err: err}
+		return errors.New("ignored")
+	}
+
+	async := Async(hooks, AsyncConfig{Keys: []interface{}{ctxKey("trace")}})
+	defer async.Close()
+	db := openWithHooks(t, async)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("trace"), "abc"))
+	_, err := db.ExecContext(ctx, "SELECT ?", 1)
+	require.NoError(t, err)
+	cancel()
+	async.Flush()
+	_, err = db.Exec("SELECT * FROM missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no such table", "OnError returns the error of the operation")
+	async.Flush()
+	close(calls)
+
+	after := <-calls
+	assert.Equal(t, "SELECT ?", after.query)
+	assert.Equal(t, "abc", after.trace)
+	assert.NoError(t, after.ctxErr, "the context is detached from the request")
+	require.NotNil(t, after.event)
+	assert.Equal(t, []interface{}{int64(1)}, after.event.Args)
+	assert.Equal(t, OpExec, after.event.Op)
+
+	onError := <-calls
+	assert.Equal(t, "SELECT * FROM missing", onError.query)
+	assert.Error(t, onError.err)
+}
+
+// blockingHooks runs After once release is closed, signaling started as it
+// begins
+func blockingHooks(started chan<- struct{}, release <-chan struct{}, ran *int64) Hooks {
+	hooks := newTestHooks()
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		atomic.AddInt64(ran, 1)
+		return ctx, nil
+	}
+	return hooks
+}
+
+func TestAsyncHooksDrop(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var ran int64
+	async := Async(blockingHooks(started, release, &ran), AsyncConfig{QueueSize: 1})
+	ctx := context.Background()
+
+	afterCtx, err := async.After(ctx, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, ctx, afterCtx, "After returns the context it is passed")
+	<-started
+	_, _ = async.After(ctx, "SELECT 2") // queued
+	_, _ = async.After(ctx, "SELECT 3") // dropped, the queue is full
+	assert.Equal(t, int64(1), async.Dropped())
+
+	close(release)
+	require.NoError(t, async.Close())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&ran), "Close waits for the queued calls")
+
+	_, _ = async.After(ctx, "SELECT 4")
+	assert.Equal(t, int64(2), atomic.LoadInt64(&ran))
+	assert.Equal(t, int64(2), async.Dropped(), "the calls made after Close are dropped")
+	require.NoError(t, async.Close())
+}
+
+func TestAsyncHooksBlock(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var ran int64
+	async := Async(blockingHooks(started, release, &ran), AsyncConfig{QueueSize: 1, Block: true})
+	ctx := context.Background()
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 3; i++ {
+			_, _ = async.After(ctx, "SELECT 1")
+		}
+	}()
+	<-started
+	select {
+	case <-sent:
+		t.Fatal("After returned with a full queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-sent
+	async.Flush()
+	assert.Equal(t, int64(3), atomic.LoadInt64(&ran))
+	assert.Zero(t, async.Dropped())
+	require.NoError(t, async.Close())
+}
@@ -0,0 +1,85 @@
+package sqlhooks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Attempt describes the execution of a statement among the attempts to run
+// it, see WithAttempts
+type Attempt struct {
+	// ID identifies the statement, the same for all of its attempts, so that
+	// hooks can derive an idempotency key from it. It is zero for the
+	// statements run without WithAttempts.
+	ID uint64
+	// Number is the number of the attempt, starting at 1, or zero without
+	// WithAttempts
+	Number int
+	// PriorErrors are the errors the previous attempts failed with, in
+	// order, as returned by the underlying driver
+	PriorErrors []error
+}
+
+// IsRetry reports whether the statement was attempted before
+func (a Attempt) IsRetry() bool {
+	return a.Number > 1
+}
+
+var attemptIDs uint64
+
+type attemptsKey struct{}
+
+// attempts counts the attempts of the statement of a context
+type attempts struct {
+	mu   sync.Mutex
+	id   uint64
+	n    int
+	errs []error
+}
+
+// WithAttempts returns a context in which the queries and statements run are
+// the attempts of a same statement: the Event of every attempt carries its
+// number and the errors of the previous ones in Event.Attempt, so that hooks
+// can add idempotency keys or skip the side effects already done. Retry loops
+// pass the same context to every attempt, as database/sql does when it
+// retries a statement on another connection after driver.ErrBadConn:
+//
+//	ctx = sqlhooks.WithAttempts(ctx)
+//	for i := 0; i < 3; i++ {
+//		if _, err = db.ExecContext(ctx, "INSERT INTO payments ..."); err == nil {
+//			break
+//		}
+//	}
+//
+// The context must not be shared by different statements. Interceptors
+// calling next more than once run within a single attempt.
+func WithAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptsKey{}, &attempts{id: atomic.AddUint64(&attemptIDs, 1)})
+}
+
+// beginAttempt returns the Attempt of an operation run with ctx
+func beginAttempt(ctx context.Context) (Attempt, *attempts) {
+	a, ok := ctx.Value(attemptsKey{}).(*attempts)
+	if !ok {
+		return Attempt{}, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.n++
+	attempt := Attempt{ID: a.id, Number: a.n}
+	if len(a.errs) > 0 {
+		attempt.PriorErrors = append([]error(nil), a.errs...)
+	}
+	return attempt, a
+}
+
+// fail records the error of an attempt
+func (a *attempts) fail(err error) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.errs = append(a.errs, err)
+	a.mu.Unlock()
+}
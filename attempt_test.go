@@ -0,0 +1,60 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttempts(t *testing.T) {
+	var attempts []Attempt
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		attempts = append(attempts, EventFromContext(ctx).Attempt)
+		return ctx, nil
+	}
+	driverName := fmt.Sprintf("sqlhooks-attempts-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, Attempt{}, attempts[0])
+	assert.False(t, attempts[0].IsRetry())
+
+	attempts = nil
+	ctx := WithAttempts(context.Background())
+	var errs []error
+	for i := 0; i < 2; i++ {
+		_, err = db.ExecContext(ctx, "INSERT INTO payments VALUES (1)")
+		require.Error(t, err)
+		errs = append(errs, err)
+	}
+	_, err = db.ExecContext(ctx, "CREATE TABLE payments (id INTEGER)")
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	id := attempts[0].ID
+	assert.NotZero(t, id)
+	assert.Equal(t, []Attempt{
+		{ID: id, Number: 1},
+		{ID: id, Number: 2, PriorErrors: errs[:1]},
+		{ID: id, Number: 3, PriorErrors: errs},
+	}, attempts)
+	assert.True(t, attempts[2].IsRetry())
+
+	attempts = nil
+	rows, err := db.QueryContext(WithAttempts(context.Background()), "SELECT 1")
+	require.NoError(t, err)
+	rows.Close()
+	assert.NotEqual(t, id, attempts[0].ID, "every scope has its own ID")
+	assert.Equal(t, 1, attempts[0].Number)
+}
@@ -1,7 +1,9 @@
 package sqlhooks
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"os"
 	"testing"
 
@@ -74,3 +76,32 @@ func BenchmarkPostgres(b *testing.B) {
 		benchmark(b, "postgres-benchmark", dsn)
 	})
 }
+
+func BenchmarkExecAllocs(b *testing.B) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: "gus"}}
+	nop := func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		return driver.ResultNoRows, nil
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Without Pooling", nil},
+		{"With Pooling", []Option{WithPooling()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			conn := &Conn{hooks: &testHooks{}, opts: newOptions(bc.opts)}
+			conn.hooks.(*testHooks).reset()
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := execWithHooks(ctx, conn, "INSERT INTO t VALUES (?, ?)", args, true, nop); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
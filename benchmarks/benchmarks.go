@@ -0,0 +1,144 @@
+// Package benchmarks measures the overhead of the wrapper and of the bundled
+// hooks, so that the cost of a hook stack can be weighed before deploying
+// it, and regressions caught before releasing one:
+//
+//	func BenchmarkStacks(b *testing.B) {
+//		benchmarks.Run(b, &pq.Driver{}, dsn)
+//	}
+//
+//	go test -bench=. -benchmem ./benchmarks
+//
+// Run benchmarks every Stack against the same driver, the bare driver first,
+// so that the difference between two results is the overhead of the stack.
+// The benchmarks of this package run against sqlite3, the in-memory driver of
+// sqlhookstest, which isolates the overhead from the database, and MySQL
+// and PostgreSQL when SQLHOOKS_MYSQL_DSN and SQLHOOKS_POSTGRES_DSN are set.
+package benchmarks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/hooks/export"
+	"github.com/qustavo/sqlhooks/v2/hooks/otelhooks"
+	"github.com/qustavo/sqlhooks/v2/hooks/prometheus"
+)
+
+// Query is the query run by the benchmarks
+const Query = "SELECT 1"
+
+// Stack is a stack of hooks to benchmark
+type Stack struct {
+	Name string
+	// New returns the hooks wrapping the driver, nil for the bare driver.
+	New func() (sqlhooks.Hooks, error)
+}
+
+// Stacks returns the stacks benchmarked by Run:
+//
+//   - bare: the driver, unwrapped
+//   - noop: hooks doing nothing, the overhead of the wrapper itself
+//   - metrics: the prometheus hooks, with a registry of their own
+//   - tracing: the otelhooks, sampling every span
+//   - full: metrics, tracing and export, encoding JSON records to nowhere
+func Stacks() []Stack {
+	return []Stack{
+		{Name: "bare"},
+		{Name: "noop", New: func() (sqlhooks.Hooks, error) { return noop{}, nil }},
+		{Name: "metrics", New: newMetrics},
+		{Name: "tracing", New: newTracing},
+		{Name: "full", New: func() (sqlhooks.Hooks, error) {
+			metrics, err := newMetrics()
+			if err != nil {
+				return nil, err
+			}
+			tracing, err := newTracing()
+			if err != nil {
+				return nil, err
+			}
+			exp := export.New(export.NewJSONEncoder(ioutil.Discard), export.Config{})
+			return sqlhooks.Compose(metrics, tracing, exp), nil
+		}},
+	}
+}
+
+func newMetrics() (sqlhooks.Hooks, error) {
+	return prometheus.New(prometheus.Config{Registerer: prom.NewRegistry()})
+}
+
+func newTracing() (sqlhooks.Hooks, error) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	return otelhooks.New(otelhooks.Config{TracerProvider: tp}), nil
+}
+
+// noop hooks do nothing
+type noop struct{}
+
+func (noop) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (noop) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+// Run runs a sub-benchmark per Stack, querying drv opened with dsn
+func Run(b *testing.B, drv driver.Driver, dsn string) {
+	for _, stack := range Stacks() {
+		stack := stack
+		b.Run(stack.Name, func(b *testing.B) {
+			RunStack(b, stack, drv, dsn)
+		})
+	}
+}
+
+// RunStack benchmarks Query against drv opened with dsn and wrapped with
+// stack
+func RunStack(b *testing.B, stack Stack, drv driver.Driver, dsn string) {
+	if stack.New != nil {
+		hooks, err := stack.New()
+		if err != nil {
+			b.Fatal(err)
+		}
+		drv = sqlhooks.Wrap(drv, hooks)
+	}
+	db := sql.OpenDB(dsnConnector{drv: drv, dsn: dsn})
+	defer db.Close()
+
+	// Open the connection beforehand
+	if err := db.Ping(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.Query(Query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := rows.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// dsnConnector opens drv with dsn, without registering it
+type dsnConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
@@ -0,0 +1,61 @@
+package benchmarks
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+)
+
+// maxWrapperAllocs is the number of allocations the wrapper may add to a
+// query, a regression failing TestWrapperAllocs
+const maxWrapperAllocs = 4
+
+func TestWrapperAllocs(t *testing.T) {
+	allocs := func(db *sql.DB) float64 {
+		defer db.Close()
+		return testing.AllocsPerRun(100, func() {
+			rows, err := db.Query(Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+		})
+	}
+	drv := &sqlhookstest.Driver{}
+	bare := allocs(sql.OpenDB(dsnConnector{drv: drv}))
+	wrapped := allocs(sql.OpenDB(dsnConnector{drv: sqlhooks.Wrap(drv, noop{})}))
+	if wrapped-bare > maxWrapperAllocs {
+		t.Errorf("the wrapper adds %v allocations per query, want at most %d", wrapped-bare, maxWrapperAllocs)
+	}
+}
+
+func BenchmarkInMemory(b *testing.B) {
+	Run(b, &sqlhookstest.Driver{}, "")
+}
+
+func BenchmarkSQLite3(b *testing.B) {
+	Run(b, &sqlite3.SQLiteDriver{}, ":memory:")
+}
+
+func BenchmarkMySQL(b *testing.B) {
+	dsn := os.Getenv("SQLHOOKS_MYSQL_DSN")
+	if dsn == "" {
+		b.Skipf("SQLHOOKS_MYSQL_DSN not set")
+	}
+	Run(b, &mysql.MySQLDriver{}, dsn)
+}
+
+func BenchmarkPostgres(b *testing.B) {
+	dsn := os.Getenv("SQLHOOKS_POSTGRES_DSN")
+	if dsn == "" {
+		b.Skipf("SQLHOOKS_POSTGRES_DSN not set")
+	}
+	Run(b, &pq.Driver{}, dsn)
+}
@@ -2,6 +2,7 @@ package sqlhooks
 
 import (
 	"context"
+	"database/sql/driver"
 	"fmt"
 )
 
@@ -16,6 +17,14 @@ func Compose(hooks ...Hooks) Hooks {
 
 type composed []Hooks
 
+func (c composed) components() []interface{} {
+	hooks := make([]interface{}, len(c))
+	for i, h := range c {
+		hooks[i] = h
+	}
+	return hooks
+}
+
 func (c composed) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
 	var errors []error
 	for _, hook := range c {
@@ -57,6 +66,325 @@ func (c composed) OnError(ctx context.Context, cause error, query string, args .
 	return wrapErrors(cause, errors)
 }
 
+func (c composed) OnConnOpen(event ConnEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(ConnHooks); ok {
+			h.OnConnOpen(event)
+		}
+	}
+}
+
+func (c composed) OnConnClose(event ConnEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(ConnHooks); ok {
+			h.OnConnClose(event)
+		}
+	}
+}
+
+func (c composed) OnConnCloseError(event ConnEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(ConnCloseErrorer); ok {
+			h.OnConnCloseError(event)
+		}
+	}
+}
+
+func (c composed) OnConnCreated(event PoolEvent) {
+	for _, hook := range c {
+		if o, ok := hook.(PoolObserver); ok {
+			o.OnConnCreated(event)
+		}
+	}
+}
+
+func (c composed) OnConnReused(event PoolEvent) {
+	for _, hook := range c {
+		if o, ok := hook.(PoolObserver); ok {
+			o.OnConnReused(event)
+		}
+	}
+}
+
+func (c composed) OnConnRetired(event PoolEvent) {
+	for _, hook := range c {
+		if o, ok := hook.(PoolObserver); ok {
+			o.OnConnRetired(event)
+		}
+	}
+}
+
+func (c composed) OnDiagnostic(ctx context.Context, diagnostic Diagnostic) {
+	for _, hook := range c {
+		if d, ok := hook.(Diagnoser); ok {
+			d.OnDiagnostic(ctx, diagnostic)
+		}
+	}
+}
+
+// AfterExec runs every hook, and combines their errors as After does
+func (c composed) AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error) {
+	var errors []error
+	for _, hook := range c {
+		h, ok := hook.(ExecResultHooks)
+		if !ok {
+			continue
+		}
+		c, err := h.AfterExec(ctx, result, query, args...)
+		if err != nil {
+			errors = append(errors, err)
+		}
+		if c != nil {
+			ctx = c
+		}
+	}
+	return ctx, wrapErrors(nil, errors)
+}
+
+// OnResult runs every hook, and combines their errors as OnError does
+func (c composed) OnResult(ctx context.Context, event ResultEvent) error {
+	var errors []error
+	for _, hook := range c {
+		if h, ok := hook.(ResultHooks); ok {
+			if err := h.OnResult(ctx, event); err != nil && err != event.Err {
+				errors = append(errors, err)
+			}
+		}
+	}
+	return wrapErrors(event.Err, errors)
+}
+
+// OnRowsNext runs every hook, and combines their errors as OnError does
+func (c composed) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	var errors []error
+	for _, hook := range c {
+		if h, ok := hook.(RowsHooks); ok {
+			if err := h.OnRowsNext(ctx, event); err != nil && err != event.Err {
+				errors = append(errors, err)
+			}
+		}
+	}
+	return wrapErrors(event.Err, errors)
+}
+
+// OnRowsClose runs every hook, and combines their errors as OnError does
+func (c composed) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	var errors []error
+	for _, hook := range c {
+		if h, ok := hook.(RowsHooks); ok {
+			if err := h.OnRowsClose(ctx, event); err != nil && err != event.Err {
+				errors = append(errors, err)
+			}
+		}
+	}
+	return wrapErrors(event.Err, errors)
+}
+
+func (c composed) OnSessionDrift(ctx context.Context, drift SessionDrift) {
+	for _, hook := range c {
+		if h, ok := hook.(SessionHooks); ok {
+			h.OnSessionDrift(ctx, drift)
+		}
+	}
+}
+
+func (c composed) BeforeBegin(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.BeforeBegin(ctx, event)
+		}
+	}
+}
+
+func (c composed) AfterBegin(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.AfterBegin(ctx, event)
+		}
+	}
+}
+
+func (c composed) BeforeCommit(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.BeforeCommit(ctx, event)
+		}
+	}
+}
+
+func (c composed) AfterCommit(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.AfterCommit(ctx, event)
+		}
+	}
+}
+
+func (c composed) BeforeRollback(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.BeforeRollback(ctx, event)
+		}
+	}
+}
+
+func (c composed) AfterRollback(ctx context.Context, event TxEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(TxHooks); ok {
+			h.AfterRollback(ctx, event)
+		}
+	}
+}
+
+func (c composed) BeforePrepare(ctx context.Context, event PrepareEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(PrepareHooks); ok {
+			h.BeforePrepare(ctx, event)
+		}
+	}
+}
+
+func (c composed) AfterPrepare(ctx context.Context, event PrepareEvent) {
+	for _, hook := range c {
+		if h, ok := hook.(PrepareHooks); ok {
+			h.AfterPrepare(ctx, event)
+		}
+	}
+}
+
+// BeforeWithArgs chains the mutators in argument order, each one receiving
+// the context and arguments returned by the previous one. The first error is
+// returned.
+func (c composed) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	for _, hook := range c {
+		if m, ok := hook.(ArgsMutator); ok {
+			c, mutated, err := m.BeforeWithArgs(ctx, query, args)
+			if err != nil {
+				return ctx, nil, err
+			}
+			if c != nil {
+				ctx = c
+			}
+			if mutated != nil {
+				args = mutated
+			}
+		}
+	}
+	return ctx, args, nil
+}
+
+// RewriteQuery chains the rewriters in argument order, each one rewriting
+// the query returned by the previous one. The first error is returned.
+func (c composed) RewriteQuery(ctx context.Context, query string) (string, error) {
+	for _, hook := range c {
+		if rw, ok := hook.(QueryRewriter); ok {
+			var err error
+			if query, err = rw.RewriteQuery(ctx, query); err != nil {
+				return "", err
+			}
+		}
+	}
+	return query, nil
+}
+
+// InterceptQuery chains the interceptors in argument order, the first one
+// being the outermost.
+func (c composed) InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		if interceptor, ok := c[i].(Interceptor); ok {
+			next = chainQuery(interceptor, next)
+		}
+	}
+	return next(ctx, query, args)
+}
+
+// InterceptExec chains the interceptors in argument order, the first one
+// being the outermost.
+func (c composed) InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		if interceptor, ok := c[i].(Interceptor); ok {
+			next = chainExec(interceptor, next)
+		}
+	}
+	return next(ctx, query, args)
+}
+
+// Nest composes hooks like middlewares, the first one wrapping the others:
+// Before runs in argument order, threading the context as Compose does,
+// while After and OnError run in reverse order, so that the first hook sees
+// the operation start first and end last, as a tracing hook wrapping metrics
+// and logging hooks expects. Every callback runs on every hook, errors being
+// combined as with Compose. The other callbacks, such as those of TxHooks,
+// run in argument order, and Interceptors are chained the first one
+// outermost.
+func Nest(hooks ...Hooks) Hooks {
+	return nested{composed(hooks)}
+}
+
+type nested struct {
+	composed
+}
+
+func (n nested) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return n.reversed().After(ctx, query, args...)
+}
+
+func (n nested) OnError(ctx context.Context, cause error, query string, args ...interface{}) error {
+	return n.reversed().OnError(ctx, cause, query, args...)
+}
+
+// reversed returns the hooks in reverse order
+func (n nested) reversed() composed {
+	r := make(composed, len(n.composed))
+	for i, hook := range n.composed {
+		r[len(r)-1-i] = hook
+	}
+	return r
+}
+
+// ComposeErrorHooks returns an ErrorHook calling hooks in order, each one
+// receiving the error returned by the previous one, so that errors can be
+// translated, then reported, then counted. A hook returning nil passes the
+// error it received along. A hook can end the chain by returning StopChain(err),
+// the composed hook then returns err.
+//
+// Methods of OnErrorer implementations can be passed as ErrorHooks:
+//
+//	ComposeErrorHooks(translate, logger.OnError, metrics.OnError)
+func ComposeErrorHooks(hooks ...ErrorHook) ErrorHook {
+	return func(ctx context.Context, err error, query string, args ...interface{}) error {
+		for _, hook := range hooks {
+			next := hook(ctx, err, query, args...)
+			if stop, ok := next.(stopChain); ok {
+				return stop.err
+			}
+			if next != nil {
+				err = next
+			}
+		}
+		return err
+	}
+}
+
+// StopChain wraps the error returned by a hook composed with
+// ComposeErrorHooks to skip the hooks after it
+func StopChain(err error) error {
+	return stopChain{err}
+}
+
+type stopChain struct {
+	err error
+}
+
+func (s stopChain) Error() string {
+	if s.err == nil {
+		return "sqlhooks: stop chain"
+	}
+	return s.err.Error()
+}
+
+func (s stopChain) Unwrap() error { return s.err }
+
 func wrapErrors(def error, errors []error) error {
 	switch len(errors) {
 	case 0:
@@ -72,6 +72,54 @@ func TestCompose(t *testing.T) {
 	}
 }
 
+func TestNest(t *testing.T) {
+	var calls []string
+	hook := func(name string) *testHooks {
+		return &testHooks{
+			before: func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+				calls = append(calls, "before "+name)
+				return context.WithValue(ctx, name, true), nil
+			},
+			after: func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+				if ctx.Value(name) == nil {
+					t.Errorf("context of %s not threaded", name)
+				}
+				calls = append(calls, "after "+name)
+				return ctx, nil
+			},
+			onError: func(ctx context.Context, err error, query string, args ...interface{}) error {
+				calls = append(calls, "onError "+name)
+				return oops
+			},
+		}
+	}
+	hooks := Nest(hook("tracing"), hook("metrics"), hook("logging"))
+
+	ctx, err := hooks.Before(context.Background(), "query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hooks.After(ctx, "query"); err != nil {
+		t.Fatal(err)
+	}
+	err = hooks.(OnErrorer).OnError(ctx, errors.New("crikey"), "query")
+	if want := MultipleErrors([]error{oops, oops, oops}); !reflect.DeepEqual(want, err) {
+		t.Errorf("unexpected error. want: %q, got: %q", want, err)
+	}
+
+	want := []string{
+		"before tracing", "before metrics", "before logging",
+		"after logging", "after metrics", "after tracing",
+		"onError logging", "onError metrics", "onError tracing",
+	}
+	if !reflect.DeepEqual(want, calls) {
+		t.Errorf("unexpected calls. want: %q, got: %q", want, calls)
+	}
+	if _, ok := hooks.(TxHooks); !ok {
+		t.Error("optional interfaces are not forwarded")
+	}
+}
+
 func TestWrapErrors(t *testing.T) {
 	var (
 		err1 = errors.New("oops")
@@ -94,3 +142,46 @@ func TestWrapErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestComposeErrorHooks(t *testing.T) {
+	var (
+		cause      = errors.New("duplicate key")
+		translated = errors.New("already exists")
+		reported   []error
+	)
+	translate := func(ctx context.Context, err error, query string, args ...interface{}) error {
+		if err == cause {
+			return translated
+		}
+		return nil
+	}
+	report := func(ctx context.Context, err error, query string, args ...interface{}) error {
+		reported = append(reported, err)
+		return nil
+	}
+	stop := func(ctx context.Context, err error, query string, args ...interface{}) error {
+		return StopChain(err)
+	}
+
+	for _, it := range []struct {
+		name     string
+		hooks    []ErrorHook
+		want     error
+		reported []error
+	}{
+		{"no hooks", nil, cause, nil},
+		{"translate then report", []ErrorHook{translate, report}, translated, []error{translated}},
+		{"report then translate", []ErrorHook{report, translate}, translated, []error{cause}},
+		{"stop", []ErrorHook{translate, stop, report}, translated, nil},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+			reported = nil
+			if got := ComposeErrorHooks(it.hooks...)(context.Background(), cause, "SELECT 1"); got != it.want {
+				t.Errorf("unexpected error. want: %q, got %q", it.want, got)
+			}
+			if !reflect.DeepEqual(it.reported, reported) {
+				t.Errorf("unexpected reported errors. want: %q, got %q", it.reported, reported)
+			}
+		})
+	}
+}
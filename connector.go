@@ -0,0 +1,79 @@
+//go:build go1.10
+// +build go1.10
+
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// Connector implements a database/sql/driver.Connector
+type Connector struct {
+	connector driver.Connector
+	drv       *Driver
+}
+
+// WrapConnector is the equivalent of Wrap for connectors, such as the ones
+// of pgx's stdlib or mysql.NewConnector, to be used with sql.OpenDB without
+// registering a driver:
+//
+//	db := sql.OpenDB(sqlhooks.WrapConnector(connector, hooks))
+//
+// The connections returned by Connect, and those opened by the Driver, are
+// hooked like the ones of a wrapped driver.
+func WrapConnector(connector driver.Connector, hooks Hooks, opts ...Option) driver.Connector {
+	return &Connector{
+		connector: connector,
+		drv:       Wrap(connector.Driver(), hooks, opts...).(*Driver),
+	}
+}
+
+// Connect opens a connection with the wrapped connector
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.drv.open(ctx, func() (driver.Conn, error) {
+		return c.connector.Connect(ctx)
+	})
+}
+
+// Driver returns the wrapped driver of the connector
+func (c *Connector) Driver() driver.Driver {
+	return c.drv
+}
+
+// Close closes the wrapped connector if it implements io.Closer, which
+// sql.DB.Close calls as of Go 1.17
+func (c *Connector) Close() error {
+	if closer, ok := c.connector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// OpenConnector implements driver.DriverContext, which database/sql uses
+// instead of Open for every connection. If the wrapped driver implements
+// driver.DriverContext, name is parsed once by its OpenConnector, and the
+// connector returned is wrapped; otherwise every connection is opened with
+// Open, as database/sql does.
+func (drv *Driver) OpenConnector(name string) (driver.Connector, error) {
+	dc, ok := drv.Driver.(driver.DriverContext)
+	if !ok {
+		return &dsnConnector{name: name, drv: drv}, nil
+	}
+	connector, err := dc.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{connector: connector, drv: drv}, nil
+}
+
+// dsnConnector is the connector of drivers that don't implement
+// driver.DriverContext
+type dsnConnector struct {
+	name string
+	drv  *Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.name) }
+func (c *dsnConnector) Driver() driver.Driver                        { return c.drv }
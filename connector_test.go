@@ -0,0 +1,112 @@
+//go:build go1.10
+// +build go1.10
+
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sqliteConnector struct {
+	dsn    string
+	ctx    context.Context
+	closed bool
+}
+
+func (c *sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.ctx = ctx
+	return c.Driver().Open(c.dsn)
+}
+
+func (c *sqliteConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+func (c *sqliteConnector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWrapConnector(t *testing.T) {
+	var queries []string
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		queries = append(queries, query)
+		return ctx, nil
+	}
+
+	connector := &sqliteConnector{dsn: ":memory:"}
+	wrapped := WrapConnector(connector, hooks)
+	assert.Equal(t, sqlutil.SQLite, wrapped.Driver().(*Driver).Dialect())
+
+	db := sql.OpenDB(wrapped)
+	db.SetMaxOpenConns(1)
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	_, err := db.ExecContext(ctx, "CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM t").Scan(&n))
+
+	assert.Equal(t, []string{"CREATE TABLE t(id int)", "SELECT count(*) FROM t"}, queries)
+	assert.Equal(t, "value", connector.ctx.Value(key{}))
+
+	// The driver of the connector is hooked too
+	conn, err := wrapped.Driver().Open(":memory:")
+	require.NoError(t, err)
+	_, err = conn.(driver.ExecerContext).ExecContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Len(t, queries, 3)
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, db.Close())
+	assert.True(t, connector.closed)
+}
+
+type sqliteContextDriver struct {
+	sqlite3.SQLiteDriver
+	connectors int
+}
+
+func (d *sqliteContextDriver) OpenConnector(name string) (driver.Connector, error) {
+	d.connectors++
+	return &sqliteConnector{dsn: name}, nil
+}
+
+func TestOpenConnector(t *testing.T) {
+	var queries int
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		queries++
+		return ctx, nil
+	}
+
+	for _, drv := range []driver.Driver{&sqliteContextDriver{}, &fakeDriver{}} {
+		connector, err := Wrap(drv, hooks).(driver.DriverContext).OpenConnector(":memory:")
+		require.NoError(t, err)
+		assert.IsType(t, &Driver{}, connector.Driver())
+	}
+
+	// database/sql opens the connector once, and every connection with it
+	drv := &sqliteContextDriver{}
+	driverName := fmt.Sprintf("sqlhooks-connector-%s", time.Now().String())
+	sql.Register(driverName, Wrap(drv, hooks))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, drv.connectors)
+	assert.Equal(t, 3, queries)
+}
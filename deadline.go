@@ -0,0 +1,125 @@
+package sqlhooks
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDeadlineBuckets are the buckets of the histograms recorded with
+// WithDeadlineStats when none are given
+var DefaultDeadlineBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// DeadlineHistogram is the distribution of the time left before the
+// deadline of the contexts of queries and statements
+type DeadlineHistogram struct {
+	// Buckets are the upper bounds of the buckets, in increasing order.
+	// Counts[i] is the number of operations with at most Buckets[i] left,
+	// and more than Buckets[i-1]; the last count is that of the operations
+	// with more than the last bucket left.
+	Buckets []time.Duration
+	Counts  []int64
+	// NoDeadline is the number of operations whose context had no deadline,
+	// and Expired the number of those whose deadline had passed.
+	NoDeadline int64
+	Expired    int64
+}
+
+// DeadlineStats is the distribution of the time left before their deadline
+// to the queries and statements run through a Driver, as they start and as
+// they complete. A query starting with little time left was delayed by the
+// work done before it, one completing with much time left has a budget
+// that could be tightened.
+type DeadlineStats struct {
+	Start, End DeadlineHistogram
+}
+
+// WithDeadlineStats records the time left before the deadline of the
+// contexts of the queries and statements, as they start and as they
+// complete, in histograms with the given bucket upper bounds, or
+// DefaultDeadlineBuckets. They are read with Driver.DeadlineStats.
+func WithDeadlineStats(buckets ...time.Duration) Option {
+	return func(o *options) {
+		if len(buckets) == 0 {
+			buckets = DefaultDeadlineBuckets
+		}
+		buckets = append([]time.Duration(nil), buckets...)
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+		o.deadlines = &deadlineRecorder{
+			buckets: buckets,
+			start:   &deadlineCounts{buckets: make([]int64, len(buckets)+1)},
+			end:     &deadlineCounts{buckets: make([]int64, len(buckets)+1)},
+		}
+	}
+}
+
+// DeadlineStats returns the histograms recorded with WithDeadlineStats, and
+// false if the option is not set
+func (drv *Driver) DeadlineStats() (DeadlineStats, bool) {
+	r := drv.opts.deadlines
+	if r == nil {
+		return DeadlineStats{}, false
+	}
+	return DeadlineStats{Start: r.start.snapshot(r.buckets), End: r.end.snapshot(r.buckets)}, true
+}
+
+// deadlineCounts are the counts of a DeadlineHistogram, accessed atomically.
+// The int64 fields come first, and deadlineCounts are allocated on their own,
+// to be 64-bit aligned on 32-bit platforms.
+type deadlineCounts struct {
+	noDeadline, expired int64
+	buckets             []int64
+}
+
+func (c *deadlineCounts) observe(buckets []time.Duration, ctx context.Context, now time.Time) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		atomic.AddInt64(&c.noDeadline, 1)
+		return
+	}
+	left := deadline.Sub(now)
+	if left <= 0 {
+		atomic.AddInt64(&c.expired, 1)
+		return
+	}
+	i := sort.Search(len(buckets), func(i int) bool { return left <= buckets[i] })
+	atomic.AddInt64(&c.buckets[i], 1)
+}
+
+func (c *deadlineCounts) snapshot(buckets []time.Duration) DeadlineHistogram {
+	h := DeadlineHistogram{
+		Buckets:    buckets,
+		Counts:     make([]int64, len(c.buckets)),
+		NoDeadline: atomic.LoadInt64(&c.noDeadline),
+		Expired:    atomic.LoadInt64(&c.expired),
+	}
+	for i := range c.buckets {
+		h.Counts[i] = atomic.LoadInt64(&c.buckets[i])
+	}
+	return h
+}
+
+// deadlineRecorder records the DeadlineStats of a Driver
+type deadlineRecorder struct {
+	buckets    []time.Duration
+	start, end *deadlineCounts
+}
+
+// observeStart records the time left to an operation run with ctx as it
+// starts, observeEnd as it completes. They do nothing on a nil recorder.
+func (r *deadlineRecorder) observeStart(ctx context.Context, now time.Time) {
+	if r != nil {
+		r.start.observe(r.buckets, ctx, now)
+	}
+}
+
+func (r *deadlineRecorder) observeEnd(ctx context.Context, now time.Time) {
+	if r != nil {
+		r.end.observe(r.buckets, ctx, now)
+	}
+}
@@ -0,0 +1,54 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineStats(t *testing.T) {
+	drv := Wrap(&sqlite3.SQLiteDriver{}, newTestHooks(), WithDeadlineStats(time.Minute, time.Second)).(*Driver)
+	driverName := fmt.Sprintf("sqlhooks-deadlines-%s", time.Now().String())
+	sql.Register(driverName, drv)
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	stats, ok := drv.DeadlineStats()
+	require.True(t, ok)
+	want := DeadlineHistogram{
+		Buckets:    []time.Duration{time.Second, time.Minute},
+		Counts:     []int64{0, 1, 1},
+		NoDeadline: 1,
+	}
+	assert.Equal(t, want, stats.Start)
+	assert.Equal(t, want, stats.End)
+
+	// Operations whose deadline passed while they ran are expired
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	drv.opts.deadlines.observeEnd(ctx, time.Now().Add(2*time.Hour))
+	stats, _ = drv.DeadlineStats()
+	assert.Equal(t, int64(1), stats.End.Expired)
+
+	_, ok = Wrap(&sqlite3.SQLiteDriver{}, newTestHooks()).(*Driver).DeadlineStats()
+	assert.False(t, ok)
+}
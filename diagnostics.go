@@ -0,0 +1,156 @@
+package sqlhooks
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DiagnosticKind identifies a misuse detected by the wrapper
+type DiagnosticKind int
+
+const (
+	// NumInputMismatch is reported when a prepared statement is executed
+	// with a number of arguments different from its placeholders.
+	NumInputMismatch DiagnosticKind = iota + 1
+	// ClosedStmtReuse is reported when a statement is used after Close.
+	ClosedStmtReuse
+	// UnboundContext is reported, with WithContextAudit, when a query or
+	// statement is run with context.Background() or context.TODO().
+	UnboundContext
+	// LeakedRows and LeakedStmt are reported, with WithLeakDetection, when
+	// rows or a statement are garbage collected without being closed.
+	LeakedRows
+	LeakedStmt
+)
+
+var diagnosticKindNames = [...]string{"unknown", "num input mismatch", "closed stmt reuse", "unbound context", "leaked rows", "leaked stmt"}
+
+func (k DiagnosticKind) String() string {
+	if k < 0 || int(k) >= len(diagnosticKindNames) {
+		return diagnosticKindNames[0]
+	}
+	return diagnosticKindNames[k]
+}
+
+// Diagnostic describes a misuse detected by the wrapper
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Query   string
+	Message string
+	// CallSite is the "file:line" where the statement was prepared. It is
+	// only recorded when WithStmtDiagnostics is enabled. For UnboundContext,
+	// it is where the query or statement was run, for LeakedRows and
+	// LeakedStmt where the rows or statement were created.
+	CallSite string
+	// Stack is the stack trace of the creation of the leaked rows or
+	// statement, for LeakedRows and LeakedStmt.
+	Stack string
+}
+
+// Diagnoser instances will be notified of the misuses detected by the wrapper
+type Diagnoser interface {
+	OnDiagnostic(ctx context.Context, diagnostic Diagnostic)
+}
+
+// WithStmtDiagnostics records where each statement is prepared and checks
+// the number of arguments of prepared statements in the wrapper rather than
+// in database/sql, so that mismatches are reported to Diagnoser hooks along
+// with the Prepare call site. The error returned to the caller is unchanged.
+func WithStmtDiagnostics() Option {
+	return func(o *options) {
+		o.stmtDiagnostics = true
+	}
+}
+
+// WithContextAudit reports the queries and statements run with
+// context.Background() or context.TODO(), which carry no deadline and no
+// values, to Diagnoser hooks as UnboundContext diagnostics along with their
+// call site. This is what the methods of sql.DB without a context, such as
+// Query and Exec, do; auditing helps teams make sure their data layer
+// propagates the contexts of the requests it serves. Finding call sites
+// costs a stack walk per unbound query, the mode is meant for development
+// and tests.
+func WithContextAudit() Option {
+	return func(o *options) {
+		o.contextAudit = true
+	}
+}
+
+func diagnose(ctx context.Context, hooks Hooks, diagnostic Diagnostic) {
+	if d, ok := hooks.(Diagnoser); ok {
+		d.OnDiagnostic(ctx, diagnostic)
+	}
+}
+
+// checkArgs reports misuses of stmt, and returns the error database/sql
+// would have returned for a wrong number of arguments. Nothing is checked
+// when the hooks are disabled.
+func (stmt *Stmt) checkArgs(ctx context.Context, n int) error {
+	if HooksDisabled(ctx) {
+		return nil
+	}
+	if stmt.closed {
+		diagnose(ctx, stmt.hooks, Diagnostic{
+			Kind:     ClosedStmtReuse,
+			Query:    stmt.query,
+			Message:  "statement used after Close",
+			CallSite: stmt.callSite,
+		})
+	}
+
+	if !stmt.conn.opts.stmtDiagnostics {
+		return nil
+	}
+	if want := stmt.Stmt.NumInput(); want >= 0 && want != n {
+		err := fmt.Errorf("sql: expected %d arguments, got %d", want, n)
+		diagnose(ctx, stmt.hooks, Diagnostic{
+			Kind:     NumInputMismatch,
+			Query:    stmt.query,
+			Message:  err.Error(),
+			CallSite: stmt.callSite,
+		})
+		return err
+	}
+	return nil
+}
+
+// auditContext reports query if it is run with an unbound context
+func (conn *Conn) auditContext(ctx context.Context, query string) {
+	if !conn.opts.contextAudit || ctx != context.Background() && ctx != context.TODO() {
+		return
+	}
+	diagnose(ctx, conn.hooks, Diagnostic{
+		Kind:     UnboundContext,
+		Query:    query,
+		Message:  "query run without a deadline or values, with context.Background() or context.TODO()",
+		CallSite: callSite(),
+	})
+}
+
+// packageDir is the directory of the sqlhooks sources, whose frames are
+// skipped when looking for call sites
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callSite returns the "file:line" of the first caller outside of
+// database/sql and sqlhooks
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "database/sql.") ||
+			(filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go"))
+		if !internal {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diagnosticRecorder struct {
+	*testHooks
+	diagnostics []Diagnostic
+}
+
+func (r *diagnosticRecorder) OnDiagnostic(ctx context.Context, d Diagnostic) {
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                                    { return nil }
+func (fakeStmt) NumInput() int                                   { return 0 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.ResultNoRows, nil }
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error)  { return nil, driver.ErrSkip }
+
+func TestStmtDiagnostics(t *testing.T) {
+	hooks := &diagnosticRecorder{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(hooks), WithStmtDiagnostics()))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT ?, ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	_, err = stmt.Query(1)
+	require.EqualError(t, err, "sql: expected 2 arguments, got 1")
+
+	require.Len(t, hooks.diagnostics, 1)
+	d := hooks.diagnostics[0]
+	assert.Equal(t, NumInputMismatch, d.Kind)
+	assert.Equal(t, "SELECT ?, ?", d.Query)
+	assert.True(t, strings.Contains(d.CallSite, "diagnostics_test.go:"), d.CallSite)
+
+	rows, err := stmt.Query(1, 2)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.Len(t, hooks.diagnostics, 1)
+}
+
+func TestStmtNumInputPassthrough(t *testing.T) {
+	db := openWithHooks(t, newTestHooks())
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT ?, ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	_, err = stmt.Query(1)
+	assert.EqualError(t, err, "sql: expected 2 arguments, got 1")
+}
+
+func TestClosedStmtReuse(t *testing.T) {
+	hooks := &diagnosticRecorder{testHooks: newTestHooks()}
+	conn := &Conn{hooks: hooks, opts: newOptions(nil)}
+	stmt := &Stmt{Stmt: &fakeStmt{}, hooks: hooks, query: "SELECT 1", conn: conn}
+
+	require.NoError(t, stmt.Close())
+	_, _ = stmt.ExecContext(context.Background(), nil)
+
+	require.Len(t, hooks.diagnostics, 1)
+	assert.Equal(t, ClosedStmtReuse, hooks.diagnostics[0].Kind)
+	assert.Equal(t, "closed stmt reuse", ClosedStmtReuse.String())
+}
+
+func TestContextAudit(t *testing.T) {
+	hooks := &diagnosticRecorder{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-audit-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(hooks), WithContextAudit()))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(context.TODO(), "SELECT 2")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "SELECT 3")
+	require.NoError(t, err)
+
+	require.Len(t, hooks.diagnostics, 2)
+	assert.Equal(t, UnboundContext, hooks.diagnostics[0].Kind)
+	assert.Equal(t, "SELECT 1", hooks.diagnostics[0].Query)
+	assert.Equal(t, "SELECT 2", hooks.diagnostics[1].Query)
+	assert.True(t, strings.Contains(hooks.diagnostics[0].CallSite, "diagnostics_test.go:"), hooks.diagnostics[0].CallSite)
+
+	// Without the option, nothing is reported
+	hooks.diagnostics = nil
+	db = openWithHooks(t, Compose(hooks))
+	defer db.Close()
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Empty(t, hooks.diagnostics)
+}
+
+func TestContextAuditEnriched(t *testing.T) {
+	hooks := &diagnosticRecorder{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-audit-enriched-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(hooks),
+		WithContextAudit(), WithEnrichers(EnrichValue(ctxKey("service"), "api"))))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(context.Background(), "SELECT 2")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	require.Len(t, hooks.diagnostics, 2, "the caller's context is audited, not the enriched one")
+	assert.Equal(t, UnboundContext, hooks.diagnostics[0].Kind)
+	assert.Equal(t, "SELECT 2", hooks.diagnostics[1].Query)
+}
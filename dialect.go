@@ -0,0 +1,63 @@
+package sqlhooks
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// WithDialect sets the dialect of the wrapped driver, for drivers that
+// DetectDialect does not know or to override its guess. It is passed to
+// the hooks in Event.Dialect.
+func WithDialect(d sqlutil.Dialect) Option {
+	return func(o *options) {
+		o.dialect = d
+	}
+}
+
+// driverPackages maps the import path prefixes of well-known drivers to the
+// name of their dialect
+var driverPackages = []struct {
+	prefix, dialect string
+}{
+	{"github.com/lib/pq", "postgres"},
+	{"github.com/jackc/pgx", "postgres"},
+	{"github.com/go-sql-driver/mysql", "mysql"},
+	{"github.com/mattn/go-sqlite3", "sqlite"},
+	{"modernc.org/sqlite", "sqlite"},
+	{"github.com/glebarez/go-sqlite", "sqlite"},
+	{"github.com/denisenkom/go-mssqldb", "sqlserver"},
+	{"github.com/microsoft/go-mssqldb", "sqlserver"},
+}
+
+// DetectDialect returns the dialect of a well-known driver, found from the
+// package of its type so that the drivers do not have to be imported, or the
+// dialect of the driver a Driver wraps
+func DetectDialect(drv driver.Driver) (sqlutil.Dialect, bool) {
+	if wrapped, ok := drv.(*Driver); ok {
+		return wrapped.opts.dialect, wrapped.opts.dialect != nil
+	}
+
+	t := reflect.TypeOf(drv)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil, false
+	}
+	pkg := t.PkgPath()
+	for _, p := range driverPackages {
+		if pkg == p.prefix || strings.HasPrefix(pkg, p.prefix+"/") {
+			return sqlutil.LookupDialect(p.dialect)
+		}
+	}
+	return nil, false
+}
+
+// Dialect returns the dialect of the wrapped driver, set with WithDialect or
+// detected by DetectDialect, or nil if it is unknown
+func (drv *Driver) Dialect() sqlutil.Dialect {
+	return drv.opts.dialect
+}
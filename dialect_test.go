@@ -0,0 +1,29 @@
+package sqlhooks
+
+import (
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDialect(t *testing.T) {
+	d, ok := DetectDialect(&sqlite3.SQLiteDriver{})
+	assert.True(t, ok)
+	assert.Equal(t, sqlutil.SQLite, d)
+
+	_, ok = DetectDialect(&fakeDriver{})
+	assert.False(t, ok)
+
+	drv := Wrap(&sqlite3.SQLiteDriver{}, &testHooks{}).(*Driver)
+	assert.Equal(t, sqlutil.SQLite, drv.Dialect())
+	drv = Wrap(&fakeDriver{}, &testHooks{}, WithDialect(sqlutil.MySQL)).(*Driver)
+	assert.Equal(t, sqlutil.MySQL, drv.Dialect())
+
+	// Wrapped drivers keep their dialect
+	d, ok = DetectDialect(drv)
+	assert.True(t, ok)
+	assert.Equal(t, sqlutil.MySQL, d)
+	assert.Nil(t, Wrap(&fakeDriver{}, &testHooks{}).(*Driver).Dialect())
+}
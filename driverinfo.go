@@ -0,0 +1,79 @@
+package sqlhooks
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// modulePath is the path of the sqlhooks module, looked up in the build
+// information of the binary
+const modulePath = "github.com/qustavo/sqlhooks/v2"
+
+// DriverInfo describes the driver wrapped by a Driver, for inventories of the
+// drivers and versions run across a fleet. Hooks find it in Event.Driver.
+type DriverInfo struct {
+	// Name is the type of the driver, such as "*pq.Driver", and Package the
+	// import path of its package
+	Name    string
+	Package string
+	// Module and Version are the path and version of the module providing
+	// the driver, found in the build information of the binary. They are
+	// empty when it is unknown, such as for binaries built without module
+	// support.
+	Module  string
+	Version string
+	// SQLHooksVersion is the version of sqlhooks, found the same way,
+	// "(devel)" when sqlhooks is the main module
+	SQLHooksVersion string
+}
+
+// Info returns the description of the wrapped driver
+func (drv *Driver) Info() DriverInfo {
+	return *drv.info
+}
+
+var (
+	buildInfoOnce sync.Once
+	buildInfo     *debug.BuildInfo
+)
+
+// newDriverInfo describes drv, or the driver a Driver wraps
+func newDriverInfo(drv driver.Driver) *DriverInfo {
+	if wrapped, ok := drv.(*Driver); ok {
+		return wrapped.info
+	}
+	info := &DriverInfo{}
+	t := reflect.TypeOf(drv)
+	if t == nil {
+		return info
+	}
+	info.Name = t.String()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	info.Package = t.PkgPath()
+
+	buildInfoOnce.Do(func() {
+		buildInfo, _ = debug.ReadBuildInfo()
+	})
+	if buildInfo == nil {
+		return info
+	}
+	for _, m := range append([]*debug.Module{&buildInfo.Main}, buildInfo.Deps...) {
+		version := m.Version
+		if m.Replace != nil {
+			version = m.Replace.Version
+		}
+		if m.Path == modulePath {
+			info.SQLHooksVersion = version
+		}
+		// The longest module path wins, for nested modules
+		if (info.Package == m.Path || strings.HasPrefix(info.Package, m.Path+"/")) && len(m.Path) > len(info.Module) {
+			info.Module, info.Version = m.Path, version
+		}
+	}
+	return info
+}
@@ -0,0 +1,21 @@
+package sqlhooks
+
+import (
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverInfo(t *testing.T) {
+	drv := Wrap(&sqlite3.SQLiteDriver{}, newTestHooks()).(*Driver)
+	info := drv.Info()
+	assert.Equal(t, "*sqlite3.SQLiteDriver", info.Name)
+	assert.Equal(t, "github.com/mattn/go-sqlite3", info.Package)
+	assert.Equal(t, "github.com/mattn/go-sqlite3", info.Module)
+	assert.Equal(t, "v1.10.0", info.Version)
+	assert.NotEmpty(t, info.SQLHooksVersion)
+
+	// Wrapping a Driver describes the driver it wraps
+	assert.Equal(t, info, Wrap(drv, newTestHooks()).(*Driver).Info())
+}
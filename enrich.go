@@ -0,0 +1,39 @@
+package sqlhooks
+
+import "context"
+
+// Enricher adds metadata to the context of an operation, such as the region,
+// the pod or the build of the process, so that every hook finds it without
+// each of them injecting it
+type Enricher func(ctx context.Context) context.Context
+
+// WithEnrichers registers enrichers, run in registration order once per
+// operation before any of its hooks, QueryRewriter and ArgsMutator callbacks
+// included. The context they return is the one the hooks and the driver
+// receive. WithEnrichers can be passed several times, the enrichers adding
+// up.
+func WithEnrichers(enrichers ...Enricher) Option {
+	return func(o *options) {
+		o.enrichers = append(o.enrichers, enrichers...)
+	}
+}
+
+// EnrichValue returns an Enricher storing value under key in the contexts,
+// for metadata known when the driver is wrapped:
+//
+//	sqlhooks.Wrap(drv, hooks, sqlhooks.WithEnrichers(sqlhooks.EnrichValue(regionKey{}, os.Getenv("REGION"))))
+func EnrichValue(key, value interface{}) Enricher {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key, value)
+	}
+}
+
+// enrich runs the enrichers on ctx
+func (o *options) enrich(ctx context.Context) context.Context {
+	for _, enrich := range o.enrichers {
+		if enriched := enrich(ctx); enriched != nil {
+			ctx = enriched
+		}
+	}
+	return ctx
+}
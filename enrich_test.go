@@ -0,0 +1,49 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichers(t *testing.T) {
+	var (
+		calls    int
+		observed []interface{}
+	)
+	counter := func(ctx context.Context) context.Context {
+		calls++
+		return context.WithValue(ctx, ctxKey("call"), calls)
+	}
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		observed = append(observed, ctx.Value(ctxKey("region")), ctx.Value(ctxKey("call")))
+		return ctx, nil
+	}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		observed = append(observed, ctx.Value(ctxKey("call")))
+		return ctx, nil
+	}
+
+	driverName := fmt.Sprintf("sqlhooks-enrich-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks,
+		WithEnrichers(EnrichValue(ctxKey("region"), "eu-west-1")),
+		WithEnrichers(counter, func(ctx context.Context) context.Context { return nil }),
+		WithOps(OpQuery, OpExec, OpPing),
+	))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, db.PingContext(context.Background()))
+
+	assert.Equal(t, []interface{}{"eu-west-1", 1, 1, "eu-west-1", 2, 2}, observed, "enrichers run once per operation")
+}
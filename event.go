@@ -0,0 +1,294 @@
+package sqlhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Op identifies the driver operation being hooked
+type Op int
+
+const (
+	// OpUnknown is used for events that were not created by the wrapper
+	OpUnknown Op = iota
+	// OpQuery is a query returning rows
+	OpQuery
+	// OpExec is a statement execution
+	OpExec
+	// OpPrepare is the preparation of a statement, its Query is the
+	// statement prepared
+	OpPrepare
+	// OpBegin, OpCommit and OpRollback delimit a transaction, their Query
+	// is BEGIN, COMMIT and ROLLBACK respectively
+	OpBegin
+	OpCommit
+	OpRollback
+	// OpPing is a connection check, its Query is empty
+	OpPing
+	// OpConnect stands for the ConnHooks callbacks in a Matrix, it is
+	// never the Op of an Event
+	OpConnect
+)
+
+var opNames = [...]string{"unknown", "query", "exec", "prepare", "begin", "commit", "rollback", "ping", "connect"}
+
+func (op Op) String() string {
+	if op < 0 || int(op) >= len(opNames) {
+		return opNames[OpUnknown]
+	}
+	return opNames[op]
+}
+
+// Event describes a hooked driver operation. The wrapper creates an Event for
+// every operation and stores it in the context passed to the hooks, where it
+// can be retrieved with EventFromContext.
+type Event struct {
+	Op Op
+	// Query is the query or statement run, see Op for the operations not
+	// running one.
+	Query string
+	// OriginalQuery is the query before the QueryRewriter hooks rewrote it
+	// into Query, empty if they did not.
+	OriginalQuery string
+	Args          []interface{}
+	// ConnID identifies the connection running the operation, see ConnEvent.
+	ConnID uint64
+	// ConnAge is how long the connection running the operation has been
+	// open, and ConnUses how many operations it has served, this one
+	// included. Comparing them with latencies helps tuning
+	// sql.DB.SetConnMaxLifetime.
+	ConnAge  time.Duration
+	ConnUses int64
+	// ConnIdle is, for a statement reusing a connection database/sql took
+	// back from its pool (see PoolObserver), the time elapsed since the
+	// previous statement on the connection started; it is zero otherwise.
+	// The first statement after a long idle period often pays for TCP, TLS
+	// or session revalidation.
+	ConnIdle time.Duration
+	// InTx reports whether the operation runs in a transaction
+	InTx bool
+	// Duration is how long the operation took, from the end of the Before
+	// hooks to the start of the After or OnError hooks, Interceptors
+	// included. It is zero in the Before hooks.
+	Duration time.Duration
+	// PreparedFallback reports that the driver declined to run the query
+	// or statement directly, returning driver.ErrSkip or lacking
+	// driver.Execer or driver.Queryer, and that it was prepared and run as
+	// a statement instead. The hooks run once either
+	// way; PreparedFallback is set by the time After or OnError run.
+	PreparedFallback bool
+	// Dialect is the dialect of the wrapped driver, see WithDialect. It is
+	// nil when the dialect is unknown.
+	Dialect sqlutil.Dialect
+	// Driver describes the wrapped driver, see Driver.Info. It must not be
+	// modified.
+	Driver *DriverInfo
+	// IDs are the identifiers of the connection, transaction and operation,
+	// see WithIDGenerator. Operations beginning, committing or rolling back
+	// a transaction carry its ID.
+	IDs IDs
+	// Attempt tells the queries and statements retried with the context
+	// returned by WithAttempts apart from their first attempt. It is zero for
+	// the other operations.
+	Attempt Attempt
+
+	fingerprint string
+	kind        sqlutil.Kind
+	classified  bool
+	cache       *sqlutil.Cache
+	annotations map[string]interface{}
+	attempts    *attempts
+}
+
+// Annotate attaches value to the event under key, replacing the previous
+// value if any, so that the hooks running after the caller in the chain can
+// read it with Annotation: a caching hook can mark a cache hit, a retrying
+// one the attempt number, a rewriting one the original query. The
+// annotations live as long as the operation; the callbacks of an operation
+// run sequentially, so that they need no locking.
+//
+// Annotate does nothing on a nil Event, so that hooks can annotate
+// EventFromContext(ctx) without checking that ctx was created by the
+// wrapper.
+func (e *Event) Annotate(key string, value interface{}) {
+	if e == nil {
+		return
+	}
+	if e.annotations == nil {
+		e.annotations = make(map[string]interface{})
+	}
+	e.annotations[key] = value
+}
+
+// Annotation returns the value attached to the event under key by Annotate
+func (e *Event) Annotation(key string) (interface{}, bool) {
+	if e == nil {
+		return nil, false
+	}
+	value, ok := e.annotations[key]
+	return value, ok
+}
+
+// Annotations returns a copy of the annotations of the event, nil if there
+// are none
+func (e *Event) Annotations() map[string]interface{} {
+	if e == nil || len(e.annotations) == 0 {
+		return nil
+	}
+	annotations := make(map[string]interface{}, len(e.annotations))
+	for k, v := range e.annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// Kind returns the kind of the statement, so that hooks can label the
+// operations without parsing the query: sqlutil.Classify of the query,
+// computed once per event or looked up in the cache set with WithQueryCache,
+// and sqlutil.Transaction for OpBegin, OpCommit and OpRollback, whose query
+// is BEGIN, COMMIT or ROLLBACK.
+func (e *Event) Kind() sqlutil.Kind {
+	switch {
+	case e.classified:
+	case e.Op == OpBegin || e.Op == OpCommit || e.Op == OpRollback:
+		e.kind = sqlutil.Transaction
+	case e.cache != nil:
+		e.kind = e.cache.Parse(e.Query).Kind
+	default:
+		e.kind = sqlutil.Classify(e.Query)
+	}
+	e.classified = true
+	return e.kind
+}
+
+// Fingerprint returns sqlutil.Fingerprint of the query, computed once per
+// event, or looked up in the cache set with WithQueryCache
+func (e *Event) Fingerprint() string {
+	switch {
+	case e.fingerprint != "":
+	case e.cache != nil:
+		e.fingerprint = e.cache.Parse(e.Query).Fingerprint
+	default:
+		e.fingerprint = sqlutil.Fingerprint(e.Query)
+	}
+	return e.fingerprint
+}
+
+// Parsed returns sqlutil.Parse of the query, looked up in the cache set with
+// WithQueryCache if any. The result must not be modified.
+func (e *Event) Parsed() *sqlutil.Parsed {
+	return e.cache.Parse(e.Query)
+}
+
+// WithQueryCache makes Event.Fingerprint and Event.Parsed look queries up in
+// cache instead of parsing them for every operation. A cache can be shared
+// by several drivers.
+func WithQueryCache(cache *sqlutil.Cache) Option {
+	return func(o *options) {
+		o.queryCache = cache
+	}
+}
+
+type eventKey struct{}
+
+// DurationFromContext returns Event.Duration, the duration of the operation
+// whose After or OnError hooks are running with ctx, so that hooks don't
+// have to record the time in Before
+func DurationFromContext(ctx context.Context) (time.Duration, bool) {
+	event := EventFromContext(ctx)
+	if event == nil {
+		return 0, false
+	}
+	return event.Duration, true
+}
+
+// EventFromContext returns the Event of the operation being hooked, or nil
+// if ctx was not created by the wrapper.
+func EventFromContext(ctx context.Context) *Event {
+	event, _ := ctx.Value(eventKey{}).(*Event)
+	return event
+}
+
+func contextWithEvent(ctx context.Context, event *Event) context.Context {
+	return context.WithValue(ctx, eventKey{}, event)
+}
+
+// EventHooks is the structured counterpart of Hooks: callbacks receive the
+// Event describing the operation instead of the bare query and arguments.
+// Use FromEventHooks to pass EventHooks to Wrap.
+type EventHooks interface {
+	BeforeEvent(ctx context.Context, event *Event) (context.Context, error)
+	AfterEvent(ctx context.Context, event *Event) (context.Context, error)
+}
+
+// EventOnErrorer is the structured counterpart of OnErrorer
+type EventOnErrorer interface {
+	OnErrorEvent(ctx context.Context, event *Event, err error) error
+}
+
+// FromEventHooks adapts EventHooks to the Hooks interface. The result
+// implements OnErrorer, passing the errors to hooks if it implements
+// EventOnErrorer and returning them unchanged otherwise.
+func FromEventHooks(hooks EventHooks) Hooks {
+	return fromEventHooks{forward{hooks}, hooks}
+}
+
+// fromEventHooks forwards the optional interfaces implemented by hooks
+type fromEventHooks struct {
+	forward
+	hooks EventHooks
+}
+
+// event returns the Event stored in ctx, or a new one when called outside
+// of the wrapper, e.g. from a test or another hooks library.
+func (h fromEventHooks) event(ctx context.Context, query string, args []interface{}) *Event {
+	if event := EventFromContext(ctx); event != nil && event.Query == query {
+		return event
+	}
+	return &Event{Query: query, Args: args}
+}
+
+func (h fromEventHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return h.hooks.BeforeEvent(ctx, h.event(ctx, query, args))
+}
+
+func (h fromEventHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return h.hooks.AfterEvent(ctx, h.event(ctx, query, args))
+}
+
+func (h fromEventHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if onErrorer, ok := h.hooks.(EventOnErrorer); ok {
+		return onErrorer.OnErrorEvent(ctx, h.event(ctx, query, args), err)
+	}
+	return err
+}
+
+// ToEventHooks adapts Hooks to the EventHooks interface. The result
+// implements EventOnErrorer, passing the errors to hooks if it implements
+// OnErrorer and returning them unchanged otherwise.
+func ToEventHooks(hooks Hooks) EventHooks {
+	return toEventHooks{forward{hooks}, hooks}
+}
+
+// toEventHooks forwards the optional interfaces implemented by hooks
+type toEventHooks struct {
+	forward
+	hooks Hooks
+}
+
+func (h toEventHooks) BeforeEvent(ctx context.Context, event *Event) (context.Context, error) {
+	return h.hooks.Before(ctx, event.Query, event.Args...)
+}
+
+func (h toEventHooks) AfterEvent(ctx context.Context, event *Event) (context.Context, error) {
+	return h.hooks.After(ctx, event.Query, event.Args...)
+}
+
+func (h toEventHooks) OnErrorEvent(ctx context.Context, event *Event, err error) error {
+	if onErrorer, ok := h.hooks.(OnErrorer); ok {
+		return onErrorer.OnError(ctx, err, event.Query, event.Args...)
+	}
+	return err
+}
@@ -0,0 +1,225 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eventRecorder struct {
+	before, after, errors []Event
+}
+
+func (r *eventRecorder) BeforeEvent(ctx context.Context, event *Event) (context.Context, error) {
+	r.before = append(r.before, *event)
+	return ctx, nil
+}
+
+func (r *eventRecorder) AfterEvent(ctx context.Context, event *Event) (context.Context, error) {
+	r.after = append(r.after, *event)
+	return ctx, nil
+}
+
+func (r *eventRecorder) OnErrorEvent(ctx context.Context, event *Event, err error) error {
+	r.errors = append(r.errors, *event)
+	return err
+}
+
+func TestFromEventHooks(t *testing.T) {
+	rec := &eventRecorder{}
+	driverName := fmt.Sprintf("sqlhooks-events-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, FromEventHooks(rec)))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	rows, err := db.Query("SELECT id FROM t WHERE id = ?", 1)
+	require.NoError(t, err)
+	rows.Close()
+	_, err = db.Query("SELECT nope")
+	require.Error(t, err)
+
+	connID := rec.after[0].ConnID
+	assert.NotZero(t, connID)
+	for i := range rec.after {
+		assert.True(t, rec.after[i].ConnAge > 0)
+		assert.Equal(t, connID, rec.after[i].ConnID)
+		assert.Equal(t, strconv.FormatUint(connID, 10), rec.after[i].IDs.Conn)
+		assert.NotEmpty(t, rec.after[i].IDs.Op)
+		rec.after[i].ConnAge, rec.after[i].ConnID, rec.after[i].ConnIdle = 0, 0, 0
+		rec.after[i].IDs = IDs{}
+		require.NotNil(t, rec.after[i].Driver)
+		assert.Equal(t, "*sqlite3.SQLiteDriver", rec.after[i].Driver.Name)
+		rec.after[i].Driver = nil
+		assert.True(t, rec.after[i].Duration > 0)
+		rec.after[i].Duration = 0
+	}
+	assert.Equal(t, []Event{
+		{Op: OpExec, Query: "CREATE TABLE t(id int)", Args: []interface{}{}, ConnUses: 1, Dialect: sqlutil.SQLite},
+		{Op: OpQuery, Query: "SELECT id FROM t WHERE id = ?", Args: []interface{}{int64(1)}, ConnUses: 2, Dialect: sqlutil.SQLite},
+	}, rec.after)
+	require.Len(t, rec.before, 3)
+	require.Len(t, rec.errors, 1)
+	assert.Equal(t, OpQuery, rec.errors[0].Op)
+
+	// Outside of the wrapper, events are built from the arguments
+	rec.before = nil
+	_, err = FromEventHooks(rec).Before(context.Background(), "SELECT 1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Op: OpUnknown, Query: "SELECT 1", Args: []interface{}{1}}}, rec.before)
+}
+
+func TestToEventHooks(t *testing.T) {
+	var got []string
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		got = append(got, fmt.Sprint("before ", query, args))
+		return ctx, nil
+	}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		got = append(got, fmt.Sprint("after ", query, args))
+		return ctx, nil
+	}
+	boom := errors.New("boom")
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		return boom
+	}
+
+	eventHooks := ToEventHooks(hooks)
+	event := &Event{Op: OpQuery, Query: "SELECT ?", Args: []interface{}{1}}
+	_, err := eventHooks.BeforeEvent(context.Background(), event)
+	require.NoError(t, err)
+	_, err = eventHooks.AfterEvent(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before SELECT ?[1]", "after SELECT ?[1]"}, got)
+
+	err = eventHooks.(EventOnErrorer).OnErrorEvent(context.Background(), event, errors.New("cause"))
+	assert.Equal(t, boom, err)
+}
+
+func TestOpString(t *testing.T) {
+	assert.Equal(t, "query", OpQuery.String())
+	assert.Equal(t, "exec", OpExec.String())
+	assert.Equal(t, "unknown", Op(42).String())
+}
+
+func TestDurationFromContext(t *testing.T) {
+	var durations []time.Duration
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		d, ok := DurationFromContext(ctx)
+		assert.True(t, ok)
+		assert.Zero(t, d)
+		return ctx, nil
+	}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		d, _ := DurationFromContext(ctx)
+		durations = append(durations, d)
+		return ctx, nil
+	}
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		d, _ := DurationFromContext(ctx)
+		durations = append(durations, d)
+		return err
+	}
+	driverName := fmt.Sprintf("sqlhooks-duration-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithOps(OpBegin)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = db.Query("SELECT nope")
+	require.Error(t, err)
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	require.Len(t, durations, 3)
+	for _, d := range durations {
+		assert.True(t, d > 0)
+	}
+	_, ok := DurationFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestAnnotations(t *testing.T) {
+	var got []interface{}
+	first := newTestHooks()
+	first.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		EventFromContext(ctx).Annotate("cache", "miss")
+		return ctx, nil
+	}
+	first.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		EventFromContext(ctx).Annotate("rows", 1)
+		return ctx, nil
+	}
+	second := newTestHooks()
+	second.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		value, ok := EventFromContext(ctx).Annotation("cache")
+		assert.True(t, ok)
+		got = append(got, value)
+		return ctx, nil
+	}
+	second.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		got = append(got, EventFromContext(ctx).Annotations())
+		return ctx, nil
+	}
+	driverName := fmt.Sprintf("sqlhooks-annotations-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(first, second)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"miss", map[string]interface{}{"cache": "miss", "rows": 1}}, got)
+
+	// Annotations do not leak to the next operation
+	got = nil
+	second.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		got = append(got, EventFromContext(ctx).Annotations())
+		return ctx, nil
+	}
+	first.before = second.before
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Nil(t, got[0])
+
+	var event *Event
+	event.Annotate("key", "value")
+	_, ok := event.Annotation("key")
+	assert.False(t, ok)
+}
+
+func TestEventKind(t *testing.T) {
+	for _, tc := range []struct {
+		event Event
+		kind  sqlutil.Kind
+	}{
+		{Event{Op: OpQuery, Query: "/* report */ SELECT 1"}, sqlutil.Select},
+		{Event{Op: OpExec, Query: "WITH x AS (SELECT 1) DELETE FROM t"}, sqlutil.Delete},
+		{Event{Op: OpPrepare, Query: "INSERT INTO t VALUES (?)"}, sqlutil.Insert},
+		{Event{Op: OpBegin}, sqlutil.Transaction},
+		{Event{Op: OpCommit}, sqlutil.Transaction},
+		{Event{Op: OpPing}, sqlutil.Unknown},
+		{Event{Op: OpQuery, Query: "SELECT 1", cache: sqlutil.NewCache(sqlutil.CacheConfig{})}, sqlutil.Select},
+	} {
+		assert.Equal(t, tc.kind, tc.event.Kind(), tc.event.Query)
+		assert.Equal(t, tc.kind, tc.event.Kind(), "the kind is computed once")
+	}
+}
@@ -0,0 +1,236 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+)
+
+// extensions holds the optional interfaces of the hooks passed to Wrap, nil
+// for those that neither the hooks nor the hooks they combine implement.
+// Compose, Nest, When, Matrix and the event adapters implement every
+// optional interface to forward it, the wrapper checks their components
+// instead so that it does not wrap the rows and results, run the
+// Interceptor chain or build the connection and transaction events when no
+// hook uses them.
+type extensions struct {
+	conn         ConnHooks
+	closeErrorer ConnCloseErrorer
+	pool         PoolObserver
+	session      SessionHooks
+	tx           TxHooks
+	prepare      PrepareHooks
+	interceptor  Interceptor
+	execResult   ExecResultHooks
+	result       ResultHooks
+	rows         RowsHooks
+	args         ArgsMutator
+	rewriter     QueryRewriter
+}
+
+func newExtensions(hooks Hooks) extensions {
+	var ext extensions
+	ext.conn, _ = extension(hooks, (*ConnHooks)(nil)).(ConnHooks)
+	ext.closeErrorer, _ = extension(hooks, (*ConnCloseErrorer)(nil)).(ConnCloseErrorer)
+	ext.pool, _ = extension(hooks, (*PoolObserver)(nil)).(PoolObserver)
+	ext.session, _ = extension(hooks, (*SessionHooks)(nil)).(SessionHooks)
+	ext.tx, _ = extension(hooks, (*TxHooks)(nil)).(TxHooks)
+	ext.prepare, _ = extension(hooks, (*PrepareHooks)(nil)).(PrepareHooks)
+	ext.interceptor, _ = extension(hooks, (*Interceptor)(nil)).(Interceptor)
+	ext.execResult, _ = extension(hooks, (*ExecResultHooks)(nil)).(ExecResultHooks)
+	ext.result, _ = extension(hooks, (*ResultHooks)(nil)).(ResultHooks)
+	ext.rows, _ = extension(hooks, (*RowsHooks)(nil)).(RowsHooks)
+	ext.args, _ = extension(hooks, (*ArgsMutator)(nil)).(ArgsMutator)
+	ext.rewriter, _ = extension(hooks, (*QueryRewriter)(nil)).(QueryRewriter)
+	return ext
+}
+
+// combinator is implemented by the hooks of this package forwarding the
+// optional interfaces to other hooks
+type combinator interface {
+	components() []interface{}
+}
+
+// extension returns hooks if it, or one of the hooks it combines,
+// implements the interface iface points to, nil otherwise
+func extension(hooks interface{}, iface interface{}) interface{} {
+	if extends(hooks, reflect.TypeOf(iface).Elem()) {
+		return hooks
+	}
+	return nil
+}
+
+func extends(hooks interface{}, iface reflect.Type) bool {
+	if c, ok := hooks.(combinator); ok {
+		for _, h := range c.components() {
+			if extends(h, iface) {
+				return true
+			}
+		}
+		return false
+	}
+	return hooks != nil && reflect.TypeOf(hooks).Implements(iface)
+}
+
+// forward implements the optional interfaces by forwarding them to hooks,
+// when it implements them. It is embedded by the adapters of a single
+// hooks, which override the callbacks they filter or translate.
+type forward struct {
+	hooks interface{}
+}
+
+func (f forward) components() []interface{} {
+	return []interface{}{f.hooks}
+}
+
+func (f forward) OnConnOpen(event ConnEvent) {
+	if h, ok := f.hooks.(ConnHooks); ok {
+		h.OnConnOpen(event)
+	}
+}
+
+func (f forward) OnConnClose(event ConnEvent) {
+	if h, ok := f.hooks.(ConnHooks); ok {
+		h.OnConnClose(event)
+	}
+}
+
+func (f forward) OnConnCloseError(event ConnEvent) {
+	if h, ok := f.hooks.(ConnCloseErrorer); ok {
+		h.OnConnCloseError(event)
+	}
+}
+
+func (f forward) OnConnCreated(event PoolEvent) {
+	if o, ok := f.hooks.(PoolObserver); ok {
+		o.OnConnCreated(event)
+	}
+}
+
+func (f forward) OnConnReused(event PoolEvent) {
+	if o, ok := f.hooks.(PoolObserver); ok {
+		o.OnConnReused(event)
+	}
+}
+
+func (f forward) OnConnRetired(event PoolEvent) {
+	if o, ok := f.hooks.(PoolObserver); ok {
+		o.OnConnRetired(event)
+	}
+}
+
+func (f forward) OnDiagnostic(ctx context.Context, diagnostic Diagnostic) {
+	if d, ok := f.hooks.(Diagnoser); ok {
+		d.OnDiagnostic(ctx, diagnostic)
+	}
+}
+
+func (f forward) OnSessionDrift(ctx context.Context, drift SessionDrift) {
+	if h, ok := f.hooks.(SessionHooks); ok {
+		h.OnSessionDrift(ctx, drift)
+	}
+}
+
+func (f forward) BeforeBegin(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.BeforeBegin(ctx, event)
+	}
+}
+
+func (f forward) AfterBegin(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.AfterBegin(ctx, event)
+	}
+}
+
+func (f forward) BeforeCommit(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.BeforeCommit(ctx, event)
+	}
+}
+
+func (f forward) AfterCommit(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.AfterCommit(ctx, event)
+	}
+}
+
+func (f forward) BeforeRollback(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.BeforeRollback(ctx, event)
+	}
+}
+
+func (f forward) AfterRollback(ctx context.Context, event TxEvent) {
+	if h, ok := f.hooks.(TxHooks); ok {
+		h.AfterRollback(ctx, event)
+	}
+}
+
+func (f forward) BeforePrepare(ctx context.Context, event PrepareEvent) {
+	if h, ok := f.hooks.(PrepareHooks); ok {
+		h.BeforePrepare(ctx, event)
+	}
+}
+
+func (f forward) AfterPrepare(ctx context.Context, event PrepareEvent) {
+	if h, ok := f.hooks.(PrepareHooks); ok {
+		h.AfterPrepare(ctx, event)
+	}
+}
+
+func (f forward) InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if interceptor, ok := f.hooks.(Interceptor); ok {
+		return interceptor.InterceptQuery(ctx, next, query, args)
+	}
+	return next(ctx, query, args)
+}
+
+func (f forward) InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	if interceptor, ok := f.hooks.(Interceptor); ok {
+		return interceptor.InterceptExec(ctx, next, query, args)
+	}
+	return next(ctx, query, args)
+}
+
+func (f forward) AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error) {
+	if h, ok := f.hooks.(ExecResultHooks); ok {
+		return h.AfterExec(ctx, result, query, args...)
+	}
+	return ctx, nil
+}
+
+func (f forward) OnResult(ctx context.Context, event ResultEvent) error {
+	if h, ok := f.hooks.(ResultHooks); ok {
+		return h.OnResult(ctx, event)
+	}
+	return event.Err
+}
+
+func (f forward) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	if h, ok := f.hooks.(RowsHooks); ok {
+		return h.OnRowsNext(ctx, event)
+	}
+	return event.Err
+}
+
+func (f forward) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	if h, ok := f.hooks.(RowsHooks); ok {
+		return h.OnRowsClose(ctx, event)
+	}
+	return event.Err
+}
+
+func (f forward) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	if m, ok := f.hooks.(ArgsMutator); ok {
+		return m.BeforeWithArgs(ctx, query, args)
+	}
+	return ctx, args, nil
+}
+
+func (f forward) RewriteQuery(ctx context.Context, query string) (string, error) {
+	if rw, ok := f.hooks.(QueryRewriter); ok {
+		return rw.RewriteQuery(ctx, query)
+	}
+	return query, nil
+}
@@ -0,0 +1,60 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensions(t *testing.T) {
+	always := func(*Event) bool { return true }
+
+	for name, hooks := range map[string]Hooks{
+		"plain":   newTestHooks(),
+		"compose": Compose(newTestHooks(), newTestHooks()),
+		"nest":    Nest(newTestHooks()),
+		"when":    When(always, newTestHooks()),
+		"matrix":  NewMatrix().Add(newTestHooks(), OpQuery).Add(newTestHooks()),
+		"events":  FromEventHooks(ToEventHooks(newTestHooks())),
+	} {
+		assert.Equal(t, extensions{}, newExtensions(hooks), "%s enables no optional interface", name)
+	}
+
+	for name, hooks := range map[string]Hooks{
+		"plain":   &rowsRecorder{testHooks: newTestHooks()},
+		"compose": Compose(newTestHooks(), &rowsRecorder{testHooks: newTestHooks()}),
+		"nested":  Compose(When(always, FromEventHooks(ToEventHooks(&rowsRecorder{testHooks: newTestHooks()})))),
+		"matrix":  NewMatrix().Add(newTestHooks()).Add(&rowsRecorder{testHooks: newTestHooks()}, OpQuery),
+	} {
+		ext := newExtensions(hooks)
+		assert.Equal(t, hooks, ext.rows, "%s enables RowsHooks", name)
+		ext.rows = nil
+		assert.Equal(t, extensions{}, ext, "%s enables RowsHooks only", name)
+	}
+}
+
+func TestExtensionsWrapper(t *testing.T) {
+	for name, tc := range map[string]struct {
+		hooks  Hooks
+		hooked bool
+	}{
+		"without rows hooks": {Compose(newTestHooks()), false},
+		"with rows hooks":    {Compose(newTestHooks(), When(func(*Event) bool { return true }, &rowsRecorder{testHooks: newTestHooks()})), true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := Wrap(&sqlite3.SQLiteDriver{}, tc.hooks).Open(":memory:")
+			require.NoError(t, err)
+			defer conn.Close()
+
+			rows, err := conn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+			require.NoError(t, err)
+			defer rows.Close()
+			_, hooked := rows.(*hookedRows)
+			assert.Equal(t, tc.hooked, hooked)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Predicate selects the operations a hook runs for
+type Predicate func(event *Event) bool
+
+// When returns hooks running only for the operations matching pred, so that
+// filtering does not have to be re-implemented inside every hook of a
+// Compose or a Matrix:
+//
+//	sqlhooks.Compose(
+//		tracer,
+//		sqlhooks.When(func(e *sqlhooks.Event) bool { return e.Op == sqlhooks.OpExec }, auditor),
+//		sqlhooks.When(func(e *sqlhooks.Event) bool { return e.Fingerprint() == hot }, profiler),
+//	)
+//
+// pred is evaluated once per operation, before Before; After, OnError and the
+// Interceptor, ExecResultHooks, ResultHooks and RowsHooks callbacks follow its
+// decision. It is evaluated beforehand for QueryRewriter callbacks, with the
+// query as written, and for ArgsMutator callbacks. Outside of the wrapper,
+// pred receives an Event built from the query and arguments. The ConnHooks,
+// ConnCloseErrorer, PoolObserver, Diagnoser, SessionHooks, TxHooks and
+// PrepareHooks callbacks are not tied to an operation and always run.
+func When(pred Predicate, hooks Hooks) Hooks {
+	return &filtered{forward: forward{hooks}, pred: pred, hooks: hooks}
+}
+
+// filtered forwards the optional interfaces not tied to an operation as is
+type filtered struct {
+	forward
+	pred  Predicate
+	hooks Hooks
+}
+
+// filteredKey marks the contexts of the operations matching a filtered
+type filteredKey struct {
+	f *filtered
+}
+
+func (f *filtered) matches(ctx context.Context) bool {
+	return ctx.Value(filteredKey{f}) != nil
+}
+
+func (f *filtered) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	event := EventFromContext(ctx)
+	if event == nil || event.Query != query {
+		event = &Event{Query: query, Args: args}
+	}
+	if !f.pred(event) {
+		return ctx, nil
+	}
+	return f.hooks.Before(context.WithValue(ctx, filteredKey{f}, true), query, args...)
+}
+
+func (f *filtered) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if !f.matches(ctx) {
+		return ctx, nil
+	}
+	return f.hooks.After(ctx, query, args...)
+}
+
+func (f *filtered) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if !f.matches(ctx) {
+		return err
+	}
+	return handlerErr(ctx, f.hooks, err, query, args...)
+}
+
+// BeforeWithArgs mutates the arguments of the operations matching pred,
+// which is evaluated for them before Before
+func (f *filtered) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	m, ok := f.hooks.(ArgsMutator)
+	if !ok {
+		return ctx, args, nil
+	}
+	event := EventFromContext(ctx)
+	if event == nil || event.Query != query {
+		event = &Event{Query: query, Args: namedToInterface(nil, args)}
+	}
+	if !f.pred(event) {
+		return ctx, args, nil
+	}
+	return m.BeforeWithArgs(ctx, query, args)
+}
+
+// RewriteQuery rewrites the queries of the operations matching pred, which
+// is evaluated for them with the query as written
+func (f *filtered) RewriteQuery(ctx context.Context, query string) (string, error) {
+	rw, ok := f.hooks.(QueryRewriter)
+	if !ok {
+		return query, nil
+	}
+	event := EventFromContext(ctx)
+	if event == nil || event.Query != query {
+		event = &Event{Query: query}
+	}
+	if !f.pred(event) {
+		return query, nil
+	}
+	return rw.RewriteQuery(ctx, query)
+}
+
+func (f *filtered) InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !f.matches(ctx) {
+		return next(ctx, query, args)
+	}
+	return f.forward.InterceptQuery(ctx, next, query, args)
+}
+
+func (f *filtered) InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !f.matches(ctx) {
+		return next(ctx, query, args)
+	}
+	return f.forward.InterceptExec(ctx, next, query, args)
+}
+
+func (f *filtered) AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error) {
+	if !f.matches(ctx) {
+		return ctx, nil
+	}
+	return f.forward.AfterExec(ctx, result, query, args...)
+}
+
+func (f *filtered) OnResult(ctx context.Context, event ResultEvent) error {
+	if !f.matches(ctx) {
+		return event.Err
+	}
+	return f.forward.OnResult(ctx, event)
+}
+
+func (f *filtered) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	if !f.matches(ctx) {
+		return event.Err
+	}
+	return f.forward.OnRowsNext(ctx, event)
+}
+
+func (f *filtered) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	if !f.matches(ctx) {
+		return event.Err
+	}
+	return f.forward.OnRowsClose(ctx, event)
+}
@@ -0,0 +1,68 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhen(t *testing.T) {
+	var calls []string
+	record := func(name string) *testHooks {
+		hooks := newTestHooks()
+		hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+			calls = append(calls, name+" before "+query)
+			return ctx, nil
+		}
+		hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+			calls = append(calls, name+" after "+query)
+			return ctx, nil
+		}
+		hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+			calls = append(calls, name+" error "+query)
+			return err
+		}
+		return hooks
+	}
+	intercepted := 0
+	interceptor := &Interceptors{
+		Exec: func(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			intercepted++
+			return next(ctx, query, args)
+		},
+	}
+
+	insert := sqlutil.Fingerprint("INSERT INTO t VALUES (1)")
+	db := openWithHooks(t, Compose(
+		When(func(e *Event) bool { return e.Op == OpExec }, record("exec")),
+		When(func(e *Event) bool { return e.Fingerprint() == insert }, record("insert")),
+		When(func(e *Event) bool { return e.Op == OpExec }, interceptor),
+	))
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES (42)")
+	require.NoError(t, err)
+	rows, err := db.Query("SELECT id FROM t")
+	require.NoError(t, err)
+	rows.Close()
+	_, err = db.Exec("INSERT INTO nope VALUES (1)")
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		"exec before CREATE TABLE t(id int)",
+		"exec after CREATE TABLE t(id int)",
+		"exec before INSERT INTO t VALUES (42)",
+		"insert before INSERT INTO t VALUES (42)",
+		"exec after INSERT INTO t VALUES (42)",
+		"insert after INSERT INTO t VALUES (42)",
+		"exec before INSERT INTO nope VALUES (1)",
+		"exec error INSERT INTO nope VALUES (1)",
+	}, calls)
+	assert.Equal(t, 3, intercepted)
+}
@@ -0,0 +1,39 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+)
+
+func FuzzNamedValueToValue(f *testing.F) {
+	f.Add("", 1, int64(1), "a")
+	f.Add("name", 0, int64(-1), "")
+	f.Add("", -7, int64(0), "b")
+	f.Fuzz(func(t *testing.T, name string, ordinal int, n int64, s string) {
+		named := []driver.NamedValue{
+			{Ordinal: ordinal, Value: n},
+			{Name: name, Ordinal: ordinal + 1, Value: s},
+		}
+		values, err := namedValueToValue(nil, named)
+		if name != "" {
+			if err == nil {
+				t.Fatalf("named parameter %q accepted", name)
+			}
+			return
+		}
+		if err != nil || len(values) != len(named) || values[0] != n || values[1] != s {
+			t.Fatalf("namedValueToValue(%v) = %v, %v", named, values, err)
+		}
+
+		// Statements without context support convert their arguments the
+		// same way, whatever the ordinals
+		stmt := &Stmt{Stmt: fakeStmt{}, conn: &Conn{opts: newOptions(nil)}}
+		if _, err := stmt.execContext(context.Background(), named); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
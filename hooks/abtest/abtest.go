@@ -0,0 +1,202 @@
+// Package abtest validates query rewrites, such as a new index hint or a
+// reworded join, against production traffic: selected queries run both as
+// written and rewritten, and the latency and rows of both runs are reported.
+//
+//	h := abtest.New(abtest.Config{
+//		Rewrite: func(query string) (string, bool) {
+//			if !strings.HasPrefix(query, "SELECT * FROM orders WHERE customer_id") {
+//				return "", false
+//			}
+//			return strings.Replace(query, "FROM orders", "FROM orders USE INDEX (orders_customer)", 1), true
+//		},
+//		Sampler:   sqlutil.NewSampler(10, 100),
+//		OnCompare: func(ctx context.Context, c abtest.Comparison) { ... },
+//	})
+//
+// Callers get the rows of the original query, unless UseRewrite returns true
+// for their context, which flips the rewrite on once it proved itself. Only
+// reads are compared; writes are never run twice. Compared queries are read
+// into memory before being returned, which suits the selective queries
+// rewrites are usually about.
+package abtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Run describes one execution of a compared query
+type Run struct {
+	Duration time.Duration
+	Rows     int
+	Err      error
+}
+
+// Comparison is reported for every compared query
+type Comparison struct {
+	Query     string
+	Rewritten string
+	Original  Run
+	Candidate Run
+	// SameRows reports whether both runs returned the same rows, in any
+	// order
+	SameRows bool
+}
+
+// Config configures a Hook
+type Config struct {
+	// Rewrite returns the rewritten version of query, or false if query is
+	// not concerned.
+	Rewrite func(query string) (string, bool)
+	// UseRewrite, if set, returns the rows of the rewritten query to the
+	// callers whose context it returns true for.
+	UseRewrite func(ctx context.Context) bool
+	// Sampler selects the queries compared, the others only run the
+	// version returned to the caller. A nil Sampler compares every query.
+	Sampler *sqlutil.Sampler
+	// OnCompare is called synchronously with every comparison
+	OnCompare func(ctx context.Context, c Comparison)
+}
+
+// Hook is a sqlhooks.Interceptor comparing queries with their rewrites
+type Hook struct {
+	cfg Config
+	seq uint64
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	return &Hook{cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return next(ctx, query, args)
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if h.cfg.Rewrite == nil || sqlutil.Classify(query) != sqlutil.Select {
+		return next(ctx, query, args)
+	}
+	rewritten, ok := h.cfg.Rewrite(query)
+	if !ok {
+		return next(ctx, query, args)
+	}
+	useRewrite := h.cfg.UseRewrite != nil && h.cfg.UseRewrite(ctx)
+	if !h.cfg.Sampler.Sample(query) {
+		if useRewrite {
+			return next(ctx, rewritten, args)
+		}
+		return next(ctx, query, args)
+	}
+
+	// Alternate the order of the runs, so that neither version
+	// consistently benefits from the caches warmed by the other
+	var original, candidate *result
+	if atomic.AddUint64(&h.seq, 1)%2 == 0 {
+		original = run(ctx, next, query, args)
+		candidate = run(ctx, next, rewritten, args)
+	} else {
+		candidate = run(ctx, next, rewritten, args)
+		original = run(ctx, next, query, args)
+	}
+
+	if h.cfg.OnCompare != nil {
+		h.cfg.OnCompare(ctx, Comparison{
+			Query:     query,
+			Rewritten: rewritten,
+			Original:  original.run,
+			Candidate: candidate.run,
+			SameRows: original.run.Err == nil && candidate.run.Err == nil &&
+				original.run.Rows == candidate.run.Rows && original.checksum == candidate.checksum,
+		})
+	}
+
+	served := original
+	if useRewrite {
+		served = candidate
+	}
+	if served.run.Err != nil {
+		return nil, served.run.Err
+	}
+	return &rows{res: served}, nil
+}
+
+// result holds the rows of a run in memory
+type result struct {
+	run      Run
+	columns  []string
+	rows     [][]driver.Value
+	checksum uint64
+}
+
+// run runs query and reads its rows into memory
+func run(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) *result {
+	res := &result{}
+	start := time.Now()
+	res.run.Err = res.read(next(ctx, query, args))
+	res.run.Duration = time.Since(start)
+	res.run.Rows = len(res.rows)
+	return res
+}
+
+func (res *result) read(r driver.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	res.columns = r.Columns()
+	for {
+		dest := make([]driver.Value, len(res.columns))
+		if err := r.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			r.Close()
+			return err
+		}
+		h := fnv.New64a()
+		for i, v := range dest {
+			// Drivers may reuse the memory of []byte values on the next call
+			if b, ok := v.([]byte); ok && b != nil {
+				dest[i] = append([]byte(nil), b...)
+			}
+			fmt.Fprintf(h, "%T:%v\x00", dest[i], dest[i])
+		}
+		// Summing the hashes of the rows ignores their order
+		res.checksum += h.Sum64()
+		res.rows = append(res.rows, dest)
+	}
+	return r.Close()
+}
+
+// rows iterates over a result
+type rows struct {
+	res *result
+	i   int
+}
+
+func (r *rows) Columns() []string { return r.res.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.i])
+	r.i++
+	return nil
+}
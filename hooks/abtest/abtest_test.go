@@ -0,0 +1,72 @@
+package abtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rewriteKey struct{}
+
+func TestCompare(t *testing.T) {
+	var comparisons []Comparison
+	h := New(Config{
+		Rewrite: func(query string) (string, bool) {
+			if !strings.HasPrefix(query, "SELECT id FROM t") {
+				return "", false
+			}
+			return strings.Replace(query, "ORDER BY id", "ORDER BY id DESC", 1), true
+		},
+		UseRewrite: func(ctx context.Context) bool { return ctx.Value(rewriteKey{}) != nil },
+		OnCompare: func(ctx context.Context, c Comparison) {
+			comparisons = append(comparisons, c)
+		},
+	})
+	driverName := fmt.Sprintf("abtest-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES (1), (2), (3)")
+	require.NoError(t, err)
+
+	ids := func(ctx context.Context, query string) []int {
+		rows, err := db.QueryContext(ctx, query)
+		require.NoError(t, err)
+		defer rows.Close()
+		var ids []int
+		for rows.Next() {
+			var id int
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		require.NoError(t, rows.Err())
+		return ids
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ids(context.Background(), "SELECT id FROM t ORDER BY id"))
+	assert.Equal(t, []int{3, 2, 1}, ids(context.WithValue(context.Background(), rewriteKey{}, true), "SELECT id FROM t ORDER BY id"))
+	assert.Equal(t, []int{1}, ids(context.Background(), "SELECT id FROM t ORDER BY id LIMIT 1"))
+	assert.Equal(t, []int{1, 2, 3}, ids(context.Background(), "SELECT * FROM t ORDER BY id"))
+
+	require.Len(t, comparisons, 3)
+	assert.True(t, comparisons[0].SameRows)
+	assert.Equal(t, "SELECT id FROM t ORDER BY id DESC", comparisons[0].Rewritten)
+	assert.Equal(t, 3, comparisons[0].Original.Rows)
+	assert.True(t, comparisons[1].SameRows)
+	assert.False(t, comparisons[2].SameRows)
+	assert.Equal(t, 1, comparisons[2].Original.Rows)
+	assert.Equal(t, 1, comparisons[2].Candidate.Rows)
+}
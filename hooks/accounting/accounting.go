@@ -0,0 +1,309 @@
+// Package accounting aggregates the database time and rows used by every
+// team, endpoint, tenant or any other tag set in the context of the
+// statements, for chargeback and showback of database usage:
+//
+//	h := accounting.New(accounting.Config{
+//		Keys:     []string{"team", "endpoint"},
+//		Interval: time.Hour,
+//		Sink:     func(r accounting.Report) { store.Save(r) },
+//	})
+//	sql.Register("postgres-accounting", sqlhooks.Wrap(&pq.Driver{}, h))
+//	go h.Run(ctx)
+//
+//	ctx = accounting.WithTag(ctx, "team", "billing")
+//	ctx = accounting.WithTag(ctx, "endpoint", "/invoices")
+//	db.QueryContext(ctx, "SELECT * FROM invoices")
+//
+// The time of a statement is the time the driver took to run it; the time
+// spent reading the rows of a query is not accounted. The rows read are
+// counted as the application reads them, and the rows affected by a write
+// are counted when the driver reports them.
+package accounting
+
+import (
+	"context"
+	"database/sql/driver"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Overflow is the value of every tag of the usage of the tag combinations
+// beyond Config.MaxGroups
+const Overflow = "__overflow__"
+
+type tagsKey struct{}
+
+// WithTag returns a context whose statements are accounted to value for
+// the tag key
+func WithTag(ctx context.Context, key, value string) context.Context {
+	parent, _ := ctx.Value(tagsKey{}).(map[string]string)
+	tags := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		tags[k] = v
+	}
+	tags[key] = value
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// Tag returns the value of the tag key set with WithTag
+func Tag(ctx context.Context, key string) (string, bool) {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	value, ok := tags[key]
+	return value, ok
+}
+
+// Usage is the database usage of a combination of tags
+type Usage struct {
+	// Tags are the values of the Config.Keys, the keys without a value in
+	// the context of the statements being left out.
+	Tags   map[string]string
+	Calls  int64
+	Errors int64
+	Time   time.Duration
+	// RowsRead is the number of rows read from the results of queries,
+	// and RowsAffected the number of rows affected by writes.
+	RowsRead, RowsAffected int64
+}
+
+func (u *Usage) add(o Usage) {
+	u.Calls += o.Calls
+	u.Errors += o.Errors
+	u.Time += o.Time
+	u.RowsRead += o.RowsRead
+	u.RowsAffected += o.RowsAffected
+}
+
+// Report is the database usage of every combination of tags over a period,
+// by decreasing time
+type Report struct {
+	Start, End time.Time
+	Usage      []Usage
+}
+
+// Config configures a Hook
+type Config struct {
+	// Keys are the tags usage is aggregated by.
+	Keys []string
+	// Tag returns the value of a tag for the statements run with ctx.
+	// Defaults to the function Tag, reading the tags set with WithTag.
+	Tag func(ctx context.Context, key string) (string, bool)
+	// MaxGroups caps the number of tag combinations tracked, the usage of
+	// the combinations seen after the first MaxGroups ones being
+	// accounted to Overflow. Defaults to 1000.
+	MaxGroups int
+	// Interval is the period of the reports flushed to Sink by Run.
+	// Defaults to one minute.
+	Interval time.Duration
+	// Sink receives the report of every period.
+	Sink func(Report)
+}
+
+// Hook is a sqlhooks.Interceptor accounting the usage of every statement
+type Hook struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	total  map[string]*Usage
+	period map[string]*Usage
+	// periodStart is the start of the current period
+	periodStart time.Time
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Tag == nil {
+		cfg.Tag = Tag
+	}
+	if cfg.MaxGroups <= 0 {
+		cfg.MaxGroups = 1000
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	h := &Hook{cfg: cfg, now: time.Now, total: make(map[string]*Usage), period: make(map[string]*Usage)}
+	h.start = h.now()
+	h.periodStart = h.start
+	return h
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+// InterceptQuery accounts the time of the query, and the rows read from its
+// result when it is closed
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := h.now()
+	rows, err := next(ctx, query, args)
+	usage := Usage{Calls: 1, Time: h.now().Sub(start)}
+	if err != nil {
+		usage.Errors = 1
+	}
+	tags := h.tags(ctx)
+	h.add(tags, usage)
+	if err != nil {
+		return rows, err
+	}
+	return &countingRows{Rows: sqlhooks.NewRows(rows), hook: h, tags: tags}, nil
+}
+
+// InterceptExec accounts the time of the statement and the rows it affected
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := h.now()
+	result, err := next(ctx, query, args)
+	usage := Usage{Calls: 1, Time: h.now().Sub(start)}
+	if err != nil {
+		usage.Errors = 1
+	} else if result != nil {
+		if n, err := result.RowsAffected(); err == nil {
+			usage.RowsAffected = n
+		}
+	}
+	h.add(h.tags(ctx), usage)
+	return result, err
+}
+
+// tags returns the tags of the statements run with ctx
+func (h *Hook) tags(ctx context.Context) map[string]string {
+	tags := make(map[string]string, len(h.cfg.Keys))
+	for _, key := range h.cfg.Keys {
+		if value, ok := h.cfg.Tag(ctx, key); ok {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// groupKey identifies a combination of tags
+func (h *Hook) groupKey(tags map[string]string) string {
+	var b strings.Builder
+	for _, key := range h.cfg.Keys {
+		value, ok := tags[key]
+		if ok {
+			b.WriteByte('=')
+			b.WriteString(value)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func (h *Hook) add(tags map[string]string, usage Usage) {
+	key := h.groupKey(tags)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	total, ok := h.total[key]
+	if !ok {
+		if len(h.total) >= h.cfg.MaxGroups {
+			tags = make(map[string]string, len(h.cfg.Keys))
+			for _, k := range h.cfg.Keys {
+				tags[k] = Overflow
+			}
+			key = h.groupKey(tags)
+			total, ok = h.total[key]
+		}
+		if !ok {
+			total = &Usage{Tags: tags}
+			h.total[key] = total
+		}
+	}
+	total.add(usage)
+
+	period, ok := h.period[key]
+	if !ok {
+		period = &Usage{Tags: total.Tags}
+		h.period[key] = period
+	}
+	period.add(usage)
+}
+
+// Report returns the cumulative usage since the Hook was created
+func (h *Hook) Report() Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return report(h.start, h.now(), h.total)
+}
+
+// Flush sends the usage since the previous flush to Config.Sink, and
+// returns it
+func (h *Hook) Flush() Report {
+	h.mu.Lock()
+	now := h.now()
+	r := report(h.periodStart, now, h.period)
+	h.period = make(map[string]*Usage)
+	h.periodStart = now
+	h.mu.Unlock()
+
+	if h.cfg.Sink != nil {
+		h.cfg.Sink(r)
+	}
+	return r
+}
+
+// Run flushes the usage every Config.Interval until ctx is done, and a last
+// time then. It is usually run in its own goroutine.
+func (h *Hook) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.Flush()
+			return
+		case <-ticker.C:
+			h.Flush()
+		}
+	}
+}
+
+func report(start, end time.Time, groups map[string]*Usage) Report {
+	r := Report{Start: start, End: end, Usage: make([]Usage, 0, len(groups))}
+	for _, u := range groups {
+		r.Usage = append(r.Usage, *u)
+	}
+	sort.Slice(r.Usage, func(i, j int) bool {
+		if r.Usage[i].Time != r.Usage[j].Time {
+			return r.Usage[i].Time > r.Usage[j].Time
+		}
+		return r.Usage[i].Calls > r.Usage[j].Calls
+	})
+	return r
+}
+
+// countingRows accounts the rows read from the result of a query when it is
+// closed
+type countingRows struct {
+	*sqlhooks.Rows
+	hook *Hook
+	tags map[string]string
+	n    int64
+	done bool
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.n++
+	}
+	return err
+}
+
+func (r *countingRows) Close() error {
+	if !r.done {
+		r.done = true
+		r.hook.add(r.tags, Usage{RowsRead: r.n})
+	}
+	return r.Rows.Close()
+}
@@ -0,0 +1,88 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccounting(t *testing.T) {
+	var flushed []Report
+	h := New(Config{
+		Keys:      []string{"team", "endpoint"},
+		MaxGroups: 3,
+		Sink:      func(r Report) { flushed = append(flushed, r) },
+	})
+	driverName := fmt.Sprintf("accounting-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE t(id int)")
+	require.NoError(t, err)
+
+	billing := WithTag(WithTag(ctx, "team", "billing"), "endpoint", "/invoices")
+	_, err = db.ExecContext(billing, "INSERT INTO t VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(billing, "SELECT id FROM t")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.QueryContext(billing, "SELECT nope")
+	require.Error(t, err)
+
+	search := WithTag(ctx, "team", "search")
+	_, err = db.ExecContext(search, "DELETE FROM t WHERE id = 1")
+	require.NoError(t, err)
+	// Beyond MaxGroups
+	_, err = db.ExecContext(WithTag(ctx, "team", "ads"), "DELETE FROM t")
+	require.NoError(t, err)
+
+	usage := make(map[string]Usage)
+	for _, u := range h.Report().Usage {
+		assert.True(t, u.Time > 0)
+		u.Time = 0
+		usage[u.Tags["team"]+u.Tags["endpoint"]] = u
+	}
+	assert.Equal(t, map[string]Usage{
+		"": {Tags: map[string]string{}, Calls: 1},
+		"billing/invoices": {
+			Tags:  map[string]string{"team": "billing", "endpoint": "/invoices"},
+			Calls: 3, Errors: 1, RowsRead: 3, RowsAffected: 3,
+		},
+		"search": {Tags: map[string]string{"team": "search"}, Calls: 1, RowsAffected: 1},
+		Overflow + Overflow: {
+			Tags:  map[string]string{"team": Overflow, "endpoint": Overflow},
+			Calls: 1, RowsAffected: 2,
+		},
+	}, usage)
+
+	r := h.Flush()
+	require.Len(t, flushed, 1)
+	assert.Equal(t, r, flushed[0])
+	assert.Len(t, r.Usage, 4)
+	assert.Empty(t, h.Flush().Usage)
+	assert.Len(t, h.Report().Usage, 4)
+}
+
+func TestTags(t *testing.T) {
+	ctx := WithTag(context.Background(), "team", "billing")
+	child := WithTag(ctx, "team", "search")
+	v, _ := Tag(ctx, "team")
+	assert.Equal(t, "billing", v)
+	v, _ = Tag(child, "team")
+	assert.Equal(t, "search", v)
+	_, ok := Tag(ctx, "tenant")
+	assert.False(t, ok)
+}
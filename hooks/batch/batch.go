@@ -0,0 +1,343 @@
+// Package batch splits very large multi-row INSERT statements into chunks of
+// a configurable number of rows, so that they stay below packet size limits
+// such as MySQL's max_allowed_packet and do not hold locks for the whole
+// import at once.
+//
+// The chunks run in a transaction: the one of the statement if it runs in a
+// transaction, or one the Hook opens on the connection for the duration of
+// the chunks otherwise, so the INSERT stays atomic.
+//
+//	h := batch.New(batch.Config{ChunkRows: 500, OnChunk: func(ctx context.Context, c batch.Chunk) {
+//		log.Printf("chunk %d/%d: %d rows in %s", c.Index+1, c.Count, c.Rows, c.Duration)
+//	}})
+//	sql.Register("mysql-batch", sqlhooks.Wrap(&mysql.MySQLDriver{}, sqlhooks.Compose(h, metrics)))
+//
+// Interceptors composed after the Hook, such as metrics above, see every
+// chunk as a statement of its own; the Before and After hooks run once for
+// the whole INSERT.
+package batch
+
+import (
+	"context"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Chunk describes a chunk of an INSERT that was split
+type Chunk struct {
+	Query string
+	// Index is the position of the chunk among the Count chunks of the
+	// INSERT, starting at 0.
+	Index, Count int
+	// Rows is the number of rows inserted by the chunk.
+	Rows     int
+	Duration time.Duration
+	Err      error
+}
+
+// Config configures a Hook
+type Config struct {
+	// ChunkRows is the maximum number of rows of a chunk. Defaults to
+	// 1000.
+	ChunkRows int
+	// MaxBytes, if positive, also limits the length of the query of a
+	// chunk, rows being kept whole. It should be set a little below the
+	// packet size limit of the database.
+	MaxBytes int
+	// Begin, Commit and Rollback delimit the transaction of the chunks of
+	// an INSERT run outside of a transaction. They default to BEGIN,
+	// COMMIT and ROLLBACK.
+	Begin, Commit, Rollback string
+	// OnChunk is called synchronously after every chunk
+	OnChunk func(ctx context.Context, chunk Chunk)
+}
+
+// Hook is a sqlhooks.Interceptor splitting large multi-row INSERTs
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.ChunkRows <= 0 {
+		cfg.ChunkRows = 1000
+	}
+	if cfg.Begin == "" {
+		cfg.Begin = "BEGIN"
+	}
+	if cfg.Commit == "" {
+		cfg.Commit = "COMMIT"
+	}
+	if cfg.Rollback == "" {
+		cfg.Rollback = "ROLLBACK"
+	}
+	return &Hook{cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return next(ctx, query, args)
+}
+
+// InterceptExec runs the INSERTs with more rows than Config.ChunkRows, or
+// longer than Config.MaxBytes, as a sequence of smaller INSERTs
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	chunks, ok := Split(query, args, h.cfg.ChunkRows, h.cfg.MaxBytes)
+	if !ok || len(chunks) < 2 {
+		return next(ctx, query, args)
+	}
+
+	event := sqlhooks.EventFromContext(ctx)
+	inTx := event != nil && event.InTx
+	if !inTx {
+		if _, err := next(ctx, h.cfg.Begin, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(result, 0, len(chunks))
+	for i, c := range chunks {
+		start := time.Now()
+		r, err := next(ctx, c.Query, c.Args)
+		if h.cfg.OnChunk != nil {
+			h.cfg.OnChunk(ctx, Chunk{
+				Query: c.Query, Index: i, Count: len(chunks), Rows: c.Rows,
+				Duration: time.Since(start), Err: err,
+			})
+		}
+		if err != nil {
+			if !inTx {
+				_, _ = next(ctx, h.cfg.Rollback, nil)
+			}
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	if !inTx {
+		if _, err := next(ctx, h.cfg.Commit, nil); err != nil {
+			_, _ = next(ctx, h.cfg.Rollback, nil)
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// result is the driver.Result of a split INSERT: RowsAffected adds up the
+// chunks and LastInsertId is the one of the last chunk
+type result []driver.Result
+
+func (r result) LastInsertId() (int64, error) {
+	return r[len(r)-1].LastInsertId()
+}
+
+func (r result) RowsAffected() (int64, error) {
+	var total int64
+	for _, res := range r {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Statement is a chunk of an INSERT, as returned by Split
+type Statement struct {
+	Query string
+	Args  []driver.NamedValue
+	// Rows is the number of rows of the VALUES list.
+	Rows int
+}
+
+// Split splits the multi-row INSERT in query into statements of at most
+// rows rows and, if maxBytes is positive, at most maxBytes long, a single
+// row exceeding it making a statement of its own. The arguments are
+// distributed among the statements and $N placeholders renumbered.
+//
+// Split reports false for statements it cannot split: other statements than
+// INSERT ... VALUES, statements with named placeholders or arguments, and
+// statements with placeholders after the VALUES list, such as in an
+// ON CONFLICT clause.
+func Split(query string, args []driver.NamedValue, rows, maxBytes int) ([]Statement, bool) {
+	tokens := sqlutil.Tokenize(query)
+	if sqlutil.Classify(query) != sqlutil.Insert {
+		return nil, false
+	}
+	for _, arg := range args {
+		if arg.Name != "" {
+			return nil, false
+		}
+	}
+
+	// Find the VALUES list at depth 0 and its rows
+	var (
+		depth, values int
+		tuples        [][2]int // token ranges of the rows, ( and ) included
+	)
+	values = -1
+	for i, t := range tokens {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && t.IsKeyword("VALUES"):
+			values = i
+		}
+		if values >= 0 {
+			break
+		}
+	}
+	if values < 0 {
+		return nil, false
+	}
+	end := values + 1
+	for {
+		i := skipSpace(tokens, end)
+		if i >= len(tokens) || tokens[i].Text != "(" {
+			break
+		}
+		j, depth := i, 0
+		for ; j < len(tokens); j++ {
+			if tokens[j].Text == "(" {
+				depth++
+			} else if tokens[j].Text == ")" {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if j == len(tokens) {
+			return nil, false
+		}
+		tuples = append(tuples, [2]int{i, j + 1})
+		end = j + 1
+		if k := skipSpace(tokens, end); k < len(tokens) && tokens[k].Text == "," {
+			end = k + 1
+			continue
+		}
+		break
+	}
+	if len(tuples) == 0 {
+		return nil, false
+	}
+
+	// Map every placeholder to the index of its argument
+	var (
+		arg      = make(map[int]int)
+		position int
+	)
+	for i, t := range tokens {
+		if t.Type != sqlutil.Placeholder {
+			continue
+		}
+		if t.Text == "?" {
+			arg[i] = position
+			position++
+			continue
+		}
+		n, err := strconv.Atoi(t.Text[1:])
+		if err != nil || n < 1 || t.Text[0] != '$' {
+			return nil, false
+		}
+		arg[i] = n - 1
+	}
+	for i, a := range arg {
+		if i >= end || a >= len(args) {
+			return nil, false
+		}
+	}
+
+	// Group the rows into statements
+	prefix, tail := tokens[:tuples[0][0]], tokens[end:]
+	length := func(tokens []sqlutil.Token) int {
+		n := 0
+		for _, t := range tokens {
+			n += len(t.Text)
+		}
+		return n
+	}
+	fixed := length(prefix) + length(tail)
+	var (
+		stmts []Statement
+		first int
+		size  = fixed
+	)
+	for i, tuple := range tuples {
+		n := length(tokens[tuple[0]:tuple[1]])
+		if i > first {
+			n += len(", ")
+			if i-first >= rows || (maxBytes > 0 && size+n > maxBytes) {
+				stmts = append(stmts, statement(tokens, arg, args, prefix, tuples[first:i], tail))
+				first, size, n = i, fixed, n-len(", ")
+			}
+		}
+		size += n
+	}
+	stmts = append(stmts, statement(tokens, arg, args, prefix, tuples[first:], tail))
+	return stmts, true
+}
+
+// statement builds the INSERT of the rows in tuples
+func statement(tokens []sqlutil.Token, arg map[int]int, args []driver.NamedValue, prefix []sqlutil.Token, tuples [][2]int, tail []sqlutil.Token) Statement {
+	var (
+		b    strings.Builder
+		stmt = Statement{Rows: len(tuples)}
+		// renumbered holds the new number of the $N placeholders
+		renumbered = make(map[int]int)
+	)
+	write := func(from, to int) {
+		for i := from; i < to; i++ {
+			t := tokens[i]
+			a, ok := arg[i]
+			if !ok {
+				b.WriteString(t.Text)
+				continue
+			}
+			if t.Text == "?" {
+				stmt.Args = append(stmt.Args, driver.NamedValue{Ordinal: len(stmt.Args) + 1, Value: args[a].Value})
+				b.WriteString(t.Text)
+				continue
+			}
+			n, ok := renumbered[a]
+			if !ok {
+				stmt.Args = append(stmt.Args, driver.NamedValue{Ordinal: len(stmt.Args) + 1, Value: args[a].Value})
+				n = len(stmt.Args)
+				renumbered[a] = n
+			}
+			b.WriteString("$" + strconv.Itoa(n))
+		}
+	}
+	write(0, len(prefix))
+	for i, tuple := range tuples {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		write(tuple[0], tuple[1])
+	}
+	write(len(tokens)-len(tail), len(tokens))
+	stmt.Query = b.String()
+	return stmt
+}
+
+func skipSpace(tokens []sqlutil.Token, i int) int {
+	for i < len(tokens) && (tokens[i].Type == sqlutil.Space || tokens[i].Type == sqlutil.Comment) {
+		i++
+	}
+	return i
+}
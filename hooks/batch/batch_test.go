@@ -0,0 +1,104 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func values(vs ...interface{}) []driver.NamedValue {
+	args := make([]driver.NamedValue, len(vs))
+	for i, v := range vs {
+		args[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return args
+}
+
+func TestSplit(t *testing.T) {
+	stmts, ok := Split("INSERT INTO t (a, b) VALUES (?, ?), (?, ?), (?, ?)", values(1, 2, 3, 4, 5, 6), 2, 0)
+	require.True(t, ok)
+	assert.Equal(t, []Statement{
+		{Query: "INSERT INTO t (a, b) VALUES (?, ?), (?, ?)", Args: values(1, 2, 3, 4), Rows: 2},
+		{Query: "INSERT INTO t (a, b) VALUES (?, ?)", Args: values(5, 6), Rows: 1},
+	}, stmts)
+
+	stmts, ok = Split("INSERT INTO t VALUES ($1, now()), ($2, now()), ($3, now()) RETURNING id", values(1, 2, 3), 2, 0)
+	require.True(t, ok)
+	assert.Equal(t, []Statement{
+		{Query: "INSERT INTO t VALUES ($1, now()), ($2, now()) RETURNING id", Args: values(1, 2), Rows: 2},
+		{Query: "INSERT INTO t VALUES ($1, now()) RETURNING id", Args: values(3), Rows: 1},
+	}, stmts)
+
+	stmts, ok = Split("INSERT INTO t VALUES (1), (22), (333)", nil, 100, 30)
+	require.True(t, ok)
+	assert.Equal(t, []Statement{
+		{Query: "INSERT INTO t VALUES (1), (22)", Rows: 2},
+		{Query: "INSERT INTO t VALUES (333)", Rows: 1},
+	}, stmts)
+
+	for _, query := range []string{
+		"UPDATE t SET a = 1",
+		"INSERT INTO t SELECT * FROM u",
+		"INSERT INTO t VALUES (?), (?) ON CONFLICT (a) DO UPDATE SET b = ?",
+		"INSERT INTO t VALUES (:a), (:b)",
+	} {
+		_, ok := Split(query, values(1, 2, 3), 1, 0)
+		assert.False(t, ok, query)
+	}
+}
+
+func TestHook(t *testing.T) {
+	var chunks []Chunk
+	h := New(Config{ChunkRows: 2, OnChunk: func(ctx context.Context, c Chunk) {
+		chunks = append(chunks, c)
+	}})
+	driverName := fmt.Sprintf("batch-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int PRIMARY KEY)")
+	require.NoError(t, err)
+	count := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT count(*) FROM t").Scan(&n))
+		return n
+	}
+
+	res, err := db.Exec("INSERT INTO t VALUES (?), (?), (?), (?), (?)", 1, 2, 3, 4, 5)
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, 5, count())
+	require.Len(t, chunks, 3)
+	assert.Equal(t, Chunk{Query: "INSERT INTO t VALUES (?)", Index: 2, Count: 3, Rows: 1}, Chunk{
+		Query: chunks[2].Query, Index: chunks[2].Index, Count: chunks[2].Count, Rows: chunks[2].Rows,
+	})
+
+	// A failing chunk rolls the previous ones back
+	chunks = nil
+	_, err = db.Exec("INSERT INTO t VALUES (6), (7), (1)")
+	require.Error(t, err)
+	require.Len(t, chunks, 2)
+	assert.Error(t, chunks[1].Err)
+	assert.Equal(t, 5, count())
+
+	// In a transaction, the chunks run in it
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO t VALUES (6), (7), (8)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, 5, count())
+}
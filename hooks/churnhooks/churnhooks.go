@@ -0,0 +1,128 @@
+// Package churnhooks detects connection churn: bursts of connections being
+// opened and closed, which usually means the server is killing connections
+// or its idle timeout (e.g. MySQL's wait_timeout) is shorter than the pool's.
+package churnhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// maxReasons is the number of recent close reasons kept for alerts
+const maxReasons = 10
+
+// Alert is passed to the alert callback when churn exceeds the threshold
+type Alert struct {
+	// Window is the observation window the counts refer to.
+	Window time.Duration
+	// Opened and Closed count the connections opened and closed in the window.
+	Opened int
+	Closed int
+	// Reasons holds the most recent close reasons, newest last.
+	Reasons []string
+}
+
+// Hook counts connection opens and closes over a sliding window and calls
+// the alert callback when the number of opened connections exceeds the
+// threshold. It fires at most once per window.
+type Hook struct {
+	threshold int
+	window    time.Duration
+	alert     func(Alert)
+	now       func() time.Time
+
+	mu        sync.Mutex
+	opened    []time.Time
+	closed    []time.Time
+	reasons   []string
+	lastAlert time.Time
+}
+
+// New returns a Hook that calls alert when more than threshold connections
+// are opened within window.
+func New(threshold int, window time.Duration, alert func(Alert)) *Hook {
+	return &Hook{
+		threshold: threshold,
+		window:    window,
+		alert:     alert,
+		now:       time.Now,
+	}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) OnConnOpen(event sqlhooks.ConnEvent) {
+	if event.Err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	now := h.now()
+	h.opened = append(prune(h.opened, now.Add(-h.window)), now)
+	alert, ok := h.check(now)
+	h.mu.Unlock()
+
+	if ok {
+		h.alert(alert)
+	}
+}
+
+func (h *Hook) OnConnClose(event sqlhooks.ConnEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.closed = append(prune(h.closed, now.Add(-h.window)), now)
+	h.reasons = append(h.reasons, closeReason(event))
+	if len(h.reasons) > maxReasons {
+		h.reasons = h.reasons[len(h.reasons)-maxReasons:]
+	}
+}
+
+// check must be called with h.mu held
+func (h *Hook) check(now time.Time) (Alert, bool) {
+	if len(h.opened) <= h.threshold {
+		return Alert{}, false
+	}
+	if !h.lastAlert.IsZero() && now.Sub(h.lastAlert) < h.window {
+		return Alert{}, false
+	}
+	h.lastAlert = now
+
+	h.closed = prune(h.closed, now.Add(-h.window))
+	return Alert{
+		Window:  h.window,
+		Opened:  len(h.opened),
+		Closed:  len(h.closed),
+		Reasons: append([]string(nil), h.reasons...),
+	}, true
+}
+
+// prune drops the timestamps older than since, which are kept sorted
+func prune(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	return append(times[:0], times[i:]...)
+}
+
+func closeReason(event sqlhooks.ConnEvent) string {
+	switch {
+	case event.Err != nil:
+		return "close error: " + event.Err.Error()
+	case event.LastErr != nil:
+		return event.LastErr.Error()
+	default:
+		return "closed by pool"
+	}
+}
@@ -0,0 +1,52 @@
+package churnhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChurnAlert(t *testing.T) {
+	var alerts []Alert
+	h := New(2, time.Minute, func(a Alert) { alerts = append(alerts, a) })
+
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	h.OnConnClose(sqlhooks.ConnEvent{LastErr: errors.New("invalid connection")})
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	h.OnConnClose(sqlhooks.ConnEvent{})
+	require.Empty(t, alerts)
+
+	// Failed opens are not counted
+	h.OnConnOpen(sqlhooks.ConnEvent{Err: errors.New("refused")})
+	require.Empty(t, alerts)
+
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	require.Len(t, alerts, 1)
+	assert.Equal(t, Alert{
+		Window:  time.Minute,
+		Opened:  3,
+		Closed:  2,
+		Reasons: []string{"invalid connection", "closed by pool"},
+	}, alerts[0])
+
+	// Only one alert per window
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	require.Len(t, alerts, 1)
+
+	// Old opens fall out of the window
+	now = now.Add(2 * time.Minute)
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	require.Len(t, alerts, 1)
+	h.OnConnOpen(sqlhooks.ConnEvent{})
+	require.Len(t, alerts, 2)
+	assert.Equal(t, 3, alerts[1].Opened)
+	assert.Equal(t, 0, alerts[1].Closed)
+}
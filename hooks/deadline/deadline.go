@@ -0,0 +1,241 @@
+// Package deadline passes the remaining time of the context deadline of every
+// statement on to the database server, so that the server abandons the
+// statement when the client stops waiting for it instead of running it to
+// completion.
+//
+// In Hint mode, SELECT statements get a MySQL optimizer hint:
+//
+//	SELECT /*+ MAX_EXECUTION_TIME(950) */ * FROM orders WHERE ...
+//
+// In Session mode, the statements are left untouched and the timeout of the
+// connection is set before running them, with SET statement_timeout by
+// default, and reset before the next statement without a deadline. The
+// timeout of every connection is tracked, so that it is only reset when it
+// was set.
+//
+// The timeout is the time left before the deadline minus a safety margin
+// covering the network round trip. Statements whose deadline is closer than
+// the margin fail with context.DeadlineExceeded without being run.
+//
+//	h := deadline.New(deadline.Config{Mode: deadline.Session})
+//	sql.Register("postgres-deadline", sqlhooks.Wrap(&pq.Driver{}, h))
+package deadline
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Mode selects how the timeout is passed to the server
+type Mode int
+
+const (
+	// Hint adds an optimizer hint to SELECT statements. Other statements
+	// are left alone.
+	Hint Mode = iota
+	// Session sets the timeout of the connection before running the
+	// statements
+	Session
+)
+
+// Config configures a Hook
+type Config struct {
+	Mode Mode
+	// Margin is subtracted from the time left before the deadline.
+	// Defaults to 20ms.
+	Margin time.Duration
+	// Hint is the hint added after the SELECT keyword in Hint mode, with a
+	// %d verb for the timeout in milliseconds. Defaults to
+	// "/*+ MAX_EXECUTION_TIME(%d) */".
+	Hint string
+	// SetTimeout is the statement setting the timeout of the connection in
+	// Session mode, with a %d verb for the timeout in milliseconds.
+	// Defaults to "SET statement_timeout = %d"; use
+	// "SET SESSION MAX_EXECUTION_TIME = %d" with MySQL.
+	SetTimeout string
+	// ResetTimeout is the statement restoring the timeout of the connection
+	// in Session mode. Defaults to "SET statement_timeout = DEFAULT".
+	ResetTimeout string
+	// Dialect, if set, provides the defaults of Hint, SetTimeout and
+	// ResetTimeout instead of the dialect of the driver, see
+	// sqlhooks.WithDialect. The defaults above apply when neither is
+	// known. Statements are left alone when the dialect has no timeout for
+	// the mode, but their deadline is still checked.
+	Dialect sqlutil.Dialect
+}
+
+// Hook is a sqlhooks.Interceptor deriving server-side timeouts from the
+// context deadline of the statements
+type Hook struct {
+	cfg Config
+
+	mu    sync.Mutex
+	conns map[uint64]bool // whether the timeout of each connection is set, in Session mode
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Margin <= 0 {
+		cfg.Margin = 20 * time.Millisecond
+	}
+	return &Hook{cfg: cfg, conns: make(map[uint64]bool)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) OnConnOpen(event sqlhooks.ConnEvent) {}
+
+// OnConnClose forgets the timeout of the connection
+func (h *Hook) OnConnClose(event sqlhooks.ConnEvent) {
+	h.mu.Lock()
+	delete(h.conns, event.ConnID)
+	h.mu.Unlock()
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, err := h.limit(ctx, query, func(set string) error {
+		rows, err := next(ctx, set, nil)
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, query, args)
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, err := h.limit(ctx, query, func(set string) error {
+		_, err := next(ctx, set, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, query, args)
+}
+
+// Timeout returns the timeout to give the server for a statement run with
+// ctx, or false if ctx has no deadline. It returns
+// context.DeadlineExceeded if the deadline is closer than margin.
+func Timeout(ctx context.Context, margin time.Duration) (time.Duration, bool, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false, nil
+	}
+	timeout := time.Until(deadline) - margin
+	if timeout < time.Millisecond {
+		// Servers take 0 as no timeout at all
+		return 0, true, context.DeadlineExceeded
+	}
+	return timeout, true, nil
+}
+
+// defaultTimeout is used when the dialect is unknown
+var defaultTimeout = sqlutil.Timeout{
+	Hint:  "/*+ MAX_EXECUTION_TIME(%d) */",
+	Set:   "SET statement_timeout = %d",
+	Reset: "SET statement_timeout = DEFAULT",
+}
+
+// timeout returns the configured hint and statements, with defaults taken
+// from the dialect of the configuration or of event
+func (h *Hook) timeout(event *sqlhooks.Event) sqlutil.Timeout {
+	defaults := defaultTimeout
+	if h.cfg.Dialect != nil {
+		defaults = h.cfg.Dialect.Timeout()
+	} else if event != nil && event.Dialect != nil {
+		defaults = event.Dialect.Timeout()
+	}
+	t := sqlutil.Timeout{Hint: h.cfg.Hint, Set: h.cfg.SetTimeout, Reset: h.cfg.ResetTimeout}
+	if t.Hint == "" {
+		t.Hint = defaults.Hint
+	}
+	if t.Set == "" {
+		t.Set = defaults.Set
+	}
+	if t.Reset == "" {
+		t.Reset = defaults.Reset
+	}
+	return t
+}
+
+// limit returns the statement to run for query, after setting or resetting
+// the connection timeout with exec if needed
+func (h *Hook) limit(ctx context.Context, query string, exec func(set string) error) (string, error) {
+	timeout, ok, err := Timeout(ctx, h.cfg.Margin)
+	if err != nil {
+		return "", err
+	}
+	ms := timeout.Milliseconds()
+	event := sqlhooks.EventFromContext(ctx)
+	stmts := h.timeout(event)
+
+	if h.cfg.Mode == Hint {
+		if !ok || stmts.Hint == "" {
+			return query, nil
+		}
+		return AddHint(query, fmt.Sprintf(stmts.Hint, ms)), nil
+	}
+
+	if stmts.Set == "" {
+		return query, nil
+	}
+	if event == nil {
+		return "", errors.New("deadline: Session mode requires the sqlhooks driver")
+	}
+	h.mu.Lock()
+	set := h.conns[event.ConnID]
+	h.mu.Unlock()
+	if !ok && !set {
+		return query, nil
+	}
+
+	stmt := stmts.Reset
+	if ok {
+		stmt = fmt.Sprintf(stmts.Set, ms)
+	}
+	if err := exec(stmt); err != nil {
+		// The timeout of the connection is unknown, reset it next time
+		h.mu.Lock()
+		h.conns[event.ConnID] = true
+		h.mu.Unlock()
+		return "", err
+	}
+	h.mu.Lock()
+	h.conns[event.ConnID] = ok
+	h.mu.Unlock()
+	return query, nil
+}
+
+// AddHint returns query with hint inserted after its leading SELECT keyword.
+// Statements that do not start with SELECT are returned unchanged.
+func AddHint(query, hint string) string {
+	offset := 0
+	for _, t := range sqlutil.Tokenize(query) {
+		offset += len(t.Text)
+		if t.Type == sqlutil.Space || t.Type == sqlutil.Comment {
+			continue
+		}
+		if !t.IsKeyword("SELECT") {
+			return query
+		}
+		return query[:offset] + " " + hint + query[offset:]
+	}
+	return query
+}
@@ -0,0 +1,134 @@
+package deadline
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddHint(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT * FROM t":              "SELECT /*+ X */ * FROM t",
+		"/* c */ select 1":             "/* c */ select /*+ X */ 1",
+		"UPDATE t SET a = 1":           "UPDATE t SET a = 1",
+		"WITH c AS (SELECT 1) TABLE c": "WITH c AS (SELECT 1) TABLE c",
+	} {
+		assert.Equal(t, want, AddHint(query, "/*+ X */"), query)
+	}
+}
+
+func open(t *testing.T, h *Hook) (*sql.DB, *[]string) {
+	var queries []string
+	record := func(query string) { queries = append(queries, query) }
+	driverName := fmt.Sprintf("deadline-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, sqlhooks.Compose(h, &sqlhooks.Interceptors{
+		Query: func(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+			record(query)
+			return next(ctx, query, args)
+		},
+		Exec: func(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			record(query)
+			return next(ctx, query, args)
+		},
+	})))
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return db, &queries
+}
+
+func TestSession(t *testing.T) {
+	db, queries := open(t, New(Config{Mode: Session}))
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	for _, ctx := range []context.Context{ctx, ctx, context.Background(), context.Background()} {
+		_, err := db.ExecContext(ctx, "UPDATE t SET a = 1")
+		require.NoError(t, err)
+	}
+
+	var normalized []string
+	for _, query := range *queries {
+		normalized = append(normalized, sqlutil.Normalize(query))
+	}
+	assert.Equal(t, []string{
+		"SET statement_timeout = ?",
+		"UPDATE t SET a = ?",
+		"SET statement_timeout = ?",
+		"UPDATE t SET a = ?",
+		"SET statement_timeout = DEFAULT",
+		"UPDATE t SET a = ?",
+		"UPDATE t SET a = ?",
+	}, normalized)
+}
+
+func TestHint(t *testing.T) {
+	db, queries := open(t, New(Config{Margin: time.Second}))
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.Len(t, *queries, 1)
+	assert.True(t, strings.HasPrefix((*queries)[0], "SELECT /*+ MAX_EXECUTION_TIME(3598"), (*queries)[0])
+
+	// Statements that cannot complete in time are not run
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = db.QueryContext(ctx, "SELECT 1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Len(t, *queries, 1)
+}
+
+func TestDialect(t *testing.T) {
+	db, queries := open(t, New(Config{Mode: Session, Dialect: sqlutil.MySQL}))
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	_, err := db.ExecContext(ctx, "UPDATE t SET a = 1")
+	require.NoError(t, err)
+	require.Len(t, *queries, 2)
+	assert.True(t, strings.HasPrefix((*queries)[0], "SET SESSION MAX_EXECUTION_TIME = "), (*queries)[0])
+
+	// SQLite has no server-side timeout
+	db, queries = open(t, New(Config{Mode: Session, Dialect: sqlutil.SQLite}))
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "UPDATE t SET a = 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"UPDATE t SET a = 1"}, *queries)
+}
+
+func TestDriverDialect(t *testing.T) {
+	var queries []string
+	driverName := fmt.Sprintf("deadline-dialect-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, sqlhooks.Compose(New(Config{Mode: Session}), &sqlhooks.Interceptors{
+		Exec: func(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			queries = append(queries, query)
+			return next(ctx, query, args)
+		},
+	}), sqlhooks.WithDialect(sqlutil.MySQL)))
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "UPDATE t SET a = 1")
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.True(t, strings.HasPrefix(queries[0], "SET SESSION MAX_EXECUTION_TIME = "), queries[0])
+}
@@ -0,0 +1,454 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Batch is a batch of encoded records sent to a Sink
+type Batch struct {
+	// Data is the output of the Encoder for the records, compressed if
+	// Encoding is set.
+	Data    []byte
+	Records int
+	// Encoding is the Compression.Name of the data, empty if it is not
+	// compressed. It is suitable as an HTTP Content-Encoding.
+	Encoding string
+}
+
+// Sink receives the batches of a Batcher
+type Sink interface {
+	Send(ctx context.Context, batch Batch) error
+}
+
+// SinkFunc adapts a function to the Sink interface, such as one producing
+// the batches to a Kafka topic:
+//
+//	export.SinkFunc(func(ctx context.Context, b export.Batch) error {
+//		return writer.WriteMessages(ctx, kafka.Message{Value: b.Data})
+//	})
+type SinkFunc func(ctx context.Context, batch Batch) error
+
+func (f SinkFunc) Send(ctx context.Context, batch Batch) error {
+	return f(ctx, batch)
+}
+
+// WriterSink returns a Sink writing the batches to w, such as a file. The
+// gzip batches of a file form a valid gzip stream.
+func WriterSink(w io.Writer) Sink {
+	return SinkFunc(func(ctx context.Context, batch Batch) error {
+		_, err := w.Write(batch.Data)
+		return err
+	})
+}
+
+// HTTPSink returns a Sink POSTing every batch to url with client, or
+// http.DefaultClient if nil, with the given Content-Type and a
+// Content-Encoding matching the compression. Responses other than 2xx are
+// errors.
+func HTTPSink(client *http.Client, url, contentType string) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return SinkFunc(func(ctx context.Context, batch Batch) error {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(batch.Data))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", contentType)
+		if batch.Encoding != "" {
+			req.Header.Set("Content-Encoding", batch.Encoding)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("export: %s responded %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// Compression compresses batches. Compressions other than Gzip plug in
+// through their writer, such as zstd with github.com/klauspost/compress:
+//
+//	export.Compression{Name: "zstd", NewWriter: func(w io.Writer) io.WriteCloser {
+//		enc, _ := zstd.NewWriter(w)
+//		return enc
+//	}}
+type Compression struct {
+	// Name is the name of the compression, see Batch.Encoding.
+	Name string
+	// NewWriter returns a writer compressing to w, whose Close flushes the
+	// compressed data without closing w.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// Gzip compresses the batches with gzip
+var Gzip = &Compression{
+	Name:      "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+}
+
+// BatchConfig configures a Batcher
+type BatchConfig struct {
+	// NewEncoder returns the Encoder writing the records of a batch.
+	// Defaults to NewJSONEncoder.
+	NewEncoder func(w io.Writer) Encoder
+	// Compression, if set, compresses the batches.
+	Compression *Compression
+	// MaxRecords and MaxBytes, the size of the encoded records before
+	// compression, cap the size of a batch. They default to 1000 records and
+	// 1 MiB.
+	MaxRecords int
+	MaxBytes   int
+	// Interval is the maximum time a record waits for its batch to fill up.
+	// Defaults to one second.
+	Interval time.Duration
+	// QueueSize is the number of records waiting to be batched above which
+	// records are dropped, or Encode blocks with Block. Defaults to 10000.
+	QueueSize int
+	// Block makes Encode wait for room in the queue rather than dropping
+	// the record, slowing the statements down to the pace of the Sink.
+	Block bool
+	// OnError is called with the errors of the Sink, and those losing
+	// records. They are ignored if nil.
+	OnError func(error)
+
+	// MaxPendingBytes, if positive, keeps the batches the Sink failed to
+	// receive, up to that many bytes, to send them again every
+	// RetryInterval, oldest first, until it receives them: the delivery
+	// is at least once, a batch being sent again if the Sink failed after
+	// receiving it. While batches are pending, new batches queue up behind
+	// them rather than being sent, so an outage of the Sink costs one
+	// attempt per RetryInterval. Batches beyond MaxPendingBytes are lost,
+	// as every failed batch is by default.
+	MaxPendingBytes int64
+	// RetryInterval defaults to five seconds.
+	RetryInterval time.Duration
+	// SpillDir, if set, keeps the pending batches as files in that
+	// directory rather than in memory, so that they survive restarts: Run
+	// sends the batches left by a previous Run first. MaxPendingBytes
+	// defaults to 256 MiB with SpillDir.
+	SpillDir string
+}
+
+// BatchStats are the counters of a Batcher
+type BatchStats struct {
+	// Sent is the number of records sent, in Batches batches.
+	Sent    int64
+	Batches int64
+	// Dropped is the number of records dropped because the queue was full,
+	// and Failed the number of those lost to the errors of the Sink.
+	Dropped int64
+	Failed  int64
+	// Pending is the number of records of the pending batches, see
+	// BatchConfig.MaxPendingBytes.
+	Pending int64
+}
+
+// Batcher is an Encoder queuing the records, to encode them in batches sent
+// to a Sink from Run, so that statements do not wait for the network:
+//
+//	b := export.NewBatcher(export.HTTPSink(nil, url, "application/x-ndjson"), export.BatchConfig{
+//		Compression: export.Gzip,
+//	})
+//	go b.Run(ctx)
+//	h := export.New(b, export.Config{})
+//
+// When the Sink cannot keep up, the queue fills up and records are dropped,
+// and counted in BatchStats.Dropped, unless BatchConfig.Block is set. When it
+// fails, the batches are lost unless BatchConfig.MaxPendingBytes keeps them,
+// in memory or in BatchConfig.SpillDir, to send them again.
+type Batcher struct {
+	sink  Sink
+	cfg   BatchConfig
+	queue chan *Record
+	// pending is only used by Run
+	pending pendingStore
+
+	sent, batches, dropped, failed, pendingRecords int64
+}
+
+// NewBatcher returns a Batcher sending the batches to sink
+func NewBatcher(sink Sink, cfg BatchConfig) *Batcher {
+	if cfg.NewEncoder == nil {
+		cfg.NewEncoder = NewJSONEncoder
+	}
+	if cfg.MaxRecords <= 0 {
+		cfg.MaxRecords = 1000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.SpillDir != "" && cfg.MaxPendingBytes <= 0 {
+		cfg.MaxPendingBytes = 256 << 20
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = 5 * time.Second
+	}
+	return &Batcher{sink: sink, cfg: cfg, queue: make(chan *Record, cfg.QueueSize)}
+}
+
+// Encode queues r, dropping it if the queue is full unless
+// BatchConfig.Block is set. It never returns an error.
+func (b *Batcher) Encode(r *Record) error {
+	if b.cfg.Block {
+		b.queue <- r
+		return nil
+	}
+	select {
+	case b.queue <- r:
+	default:
+		atomic.AddInt64(&b.dropped, 1)
+	}
+	return nil
+}
+
+// Stats returns the counters of the Batcher
+func (b *Batcher) Stats() BatchStats {
+	return BatchStats{
+		Sent:    atomic.LoadInt64(&b.sent),
+		Batches: atomic.LoadInt64(&b.batches),
+		Dropped: atomic.LoadInt64(&b.dropped),
+		Failed:  atomic.LoadInt64(&b.failed),
+		Pending: atomic.LoadInt64(&b.pendingRecords),
+	}
+}
+
+// Run batches the queued records and sends them until ctx is done, then
+// sends the records still queued. It is usually run in its own goroutine.
+//
+// The pending batches Run fails to send as it returns are lost, unless they
+// are kept in BatchConfig.SpillDir for the next Run.
+func (b *Batcher) Run(ctx context.Context) {
+	b.openPending()
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	var retries <-chan time.Time
+	if b.pending != nil {
+		retry := time.NewTicker(b.cfg.RetryInterval)
+		defer retry.Stop()
+		retries = retry.C
+		b.retry(ctx)
+	}
+
+	w := b.newBatchWriter()
+	for {
+		select {
+		case r := <-b.queue:
+			if w.add(r) {
+				b.send(ctx, w)
+				w = b.newBatchWriter()
+			}
+		case <-ticker.C:
+			if w.records > 0 {
+				b.send(ctx, w)
+				w = b.newBatchWriter()
+			}
+		case <-retries:
+			b.retry(ctx)
+		case <-ctx.Done():
+			// The context of the last batches is done, the Sink may
+			// still honour its values
+			flush := context.Background()
+			for {
+				select {
+				case r := <-b.queue:
+					if w.add(r) {
+						b.send(flush, w)
+						w = b.newBatchWriter()
+					}
+				default:
+					if w.records > 0 {
+						b.send(flush, w)
+					}
+					b.close(flush)
+					return
+				}
+			}
+		}
+	}
+}
+
+// openPending opens the store of the pending batches, if any
+func (b *Batcher) openPending() {
+	if b.pending != nil || b.cfg.MaxPendingBytes <= 0 {
+		return
+	}
+	if b.cfg.SpillDir == "" {
+		b.pending = &memoryStore{max: b.cfg.MaxPendingBytes}
+		return
+	}
+	store, err := openDiskStore(b.cfg.SpillDir, b.cfg.MaxPendingBytes)
+	if err != nil {
+		// The batches are kept in memory rather than lost
+		b.onError(err)
+		b.pending = &memoryStore{max: b.cfg.MaxPendingBytes}
+		return
+	}
+	b.pending = store
+	atomic.StoreInt64(&b.pendingRecords, store.records())
+}
+
+func (b *Batcher) send(ctx context.Context, w *batchWriter) {
+	batch, err := w.close()
+	if err != nil {
+		b.lose(w.records, err)
+		return
+	}
+	if b.pending != nil && b.pending.len() > 0 {
+		// Keep the batches in order, and spare the Sink
+		b.keep(batch, nil)
+		return
+	}
+	if err := b.sink.Send(ctx, batch); err != nil {
+		b.keep(batch, err)
+		return
+	}
+	b.delivered(batch)
+}
+
+// keep keeps batch pending, after the err of the Sink if not nil
+func (b *Batcher) keep(batch Batch, err error) {
+	if err != nil {
+		b.onError(err)
+	}
+	if b.pending == nil {
+		atomic.AddInt64(&b.failed, int64(batch.Records))
+		return
+	}
+	if err := b.pending.push(batch); err != nil {
+		b.lose(batch.Records, err)
+		return
+	}
+	atomic.AddInt64(&b.pendingRecords, int64(batch.Records))
+}
+
+// retry sends the pending batches, oldest first, until the Sink fails
+func (b *Batcher) retry(ctx context.Context) {
+	for b.pending.len() > 0 {
+		batch, err := b.pending.peek()
+		if err == nil {
+			err = b.sink.Send(ctx, batch)
+			if err != nil {
+				b.onError(err)
+				return
+			}
+			b.delivered(batch)
+		} else {
+			// The batch cannot be read back
+			b.lose(batch.Records, err)
+		}
+		if err := b.pending.pop(); err != nil {
+			b.onError(err)
+		}
+		atomic.AddInt64(&b.pendingRecords, -int64(batch.Records))
+	}
+}
+
+// close makes a last attempt at the pending batches, losing those kept in
+// memory
+func (b *Batcher) close(ctx context.Context) {
+	if b.pending == nil {
+		return
+	}
+	b.retry(ctx)
+	if _, ok := b.pending.(*memoryStore); ok && b.pending.len() > 0 {
+		atomic.AddInt64(&b.failed, b.pending.records())
+		atomic.AddInt64(&b.pendingRecords, -b.pending.records())
+		b.pending = &memoryStore{max: b.cfg.MaxPendingBytes}
+	}
+}
+
+func (b *Batcher) delivered(batch Batch) {
+	atomic.AddInt64(&b.sent, int64(batch.Records))
+	atomic.AddInt64(&b.batches, 1)
+}
+
+func (b *Batcher) lose(records int, err error) {
+	atomic.AddInt64(&b.failed, int64(records))
+	b.onError(err)
+}
+
+func (b *Batcher) onError(err error) {
+	if b.cfg.OnError != nil {
+		b.cfg.OnError(err)
+	}
+}
+
+// batchWriter encodes the records of a batch
+type batchWriter struct {
+	b       *Batcher
+	buf     bytes.Buffer
+	zw      io.WriteCloser
+	size    countingWriter
+	enc     Encoder
+	records int
+	err     error
+}
+
+func (b *Batcher) newBatchWriter() *batchWriter {
+	w := &batchWriter{b: b}
+	var out io.Writer = &w.buf
+	if b.cfg.Compression != nil {
+		w.zw = b.cfg.Compression.NewWriter(&w.buf)
+		out = w.zw
+	}
+	w.size.w = out
+	w.enc = b.cfg.NewEncoder(&w.size)
+	return w
+}
+
+// add encodes r, and reports whether the batch is full
+func (w *batchWriter) add(r *Record) bool {
+	if err := w.enc.Encode(r); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.records++
+	return w.records >= w.b.cfg.MaxRecords || w.size.n >= int64(w.b.cfg.MaxBytes)
+}
+
+func (w *batchWriter) close() (Batch, error) {
+	if w.err != nil {
+		return Batch{}, w.err
+	}
+	batch := Batch{Records: w.records}
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			return Batch{}, err
+		}
+		batch.Encoding = w.b.cfg.Compression.Name
+	}
+	batch.Data = w.buf.Bytes()
+	return batch, nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
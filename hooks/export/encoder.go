@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// NewJSONEncoder returns an Encoder writing records to w as JSON, one per
+// line. It is safe for concurrent use.
+func NewJSONEncoder(w io.Writer) Encoder {
+	return &jsonEncoder{enc: json.NewEncoder(w)}
+}
+
+type jsonEncoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) Encode(r *Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(r)
+}
+
+// NewProtoEncoder returns an Encoder writing records to w in the protobuf
+// wire format of record.proto, each one prefixed with its length as a
+// varint, as Java's writeDelimitedTo and Go's protodelim do. It is safe for
+// concurrent use.
+func NewProtoEncoder(w io.Writer) Encoder {
+	return &protoEncoder{w: w}
+}
+
+type protoEncoder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+func (e *protoEncoder) Encode(r *Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	msg := MarshalProto(r)
+	e.buf = appendUvarint(e.buf[:0], uint64(len(msg)))
+	e.buf = append(e.buf, msg...)
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// MarshalProto returns r in the protobuf wire format of record.proto
+func MarshalProto(r *Record) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(r.Version))
+	b = appendVarint(b, 2, uint64(r.TimeUnixNano))
+	b = appendString(b, 3, r.Op)
+	b = appendString(b, 4, r.Query)
+	b = appendString(b, 5, r.Fingerprint)
+	for _, arg := range r.Args {
+		b = appendBytes(b, 6, arg)
+	}
+	b = appendVarint(b, 7, r.ConnID)
+	b = appendVarint(b, 8, uint64(r.ConnAgeNs))
+	b = appendVarint(b, 9, uint64(r.ConnUses))
+	if r.InTx {
+		b = appendVarint(b, 10, 1)
+	}
+	b = appendVarint(b, 11, uint64(r.DurationNs))
+	b = appendString(b, 12, r.Error)
+	b = appendString(b, 13, r.ErrorClass)
+	b = appendString(b, 14, r.Dialect)
+	return b
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// appendVarint appends a varint field, omitted when zero as in proto3.
+// Negative int32 and int64 values are encoded on ten bytes, as their
+// uint64 conversion.
+func appendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendUvarint(b, uint64(field)<<3|wireVarint)
+	return appendUvarint(b, v)
+}
+
+// appendString appends a string field, omitted when empty as in proto3
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, field, s)
+}
+
+// appendBytes appends a length-delimited field, even if empty, as repeated
+// fields must
+func appendBytes(b []byte, field int, s string) []byte {
+	b = appendUvarint(b, uint64(field)<<3|wireBytes)
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
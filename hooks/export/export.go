@@ -0,0 +1,147 @@
+// Package export publishes every hooked operation as a Record, a flat and
+// versioned representation of sqlhooks.Event with stable JSON names and a
+// protobuf definition (record.proto), so that the sinks of different teams,
+// such as Kafka topics, OTLP log pipelines or files, share one documented
+// schema.
+//
+//	h := export.New(export.NewJSONEncoder(f), export.Config{})
+//	sql.Register("postgres-export", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// Encoders are called synchronously after every operation; a Batcher queues
+// the records to send them in batches, optionally compressed, from another
+// goroutine, for sinks talking to the network such as Kafka or HTTP, and
+// can keep the batches an unavailable sink missed, in memory or on disk, to
+// deliver them at least once.
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// SchemaVersion is the version of the Record schema. Fields may be added
+// within a version; removing or changing one bumps it.
+const SchemaVersion = 1
+
+// Record describes a hooked operation. The JSON names are the field names
+// of record.proto.
+type Record struct {
+	// Version is the SchemaVersion the record was produced with.
+	Version int32 `json:"version"`
+	// TimeUnixNano is the start time of the operation.
+	TimeUnixNano int64 `json:"time_unix_nano"`
+	// Op is the name of the operation, see sqlhooks.Op.
+	Op          string `json:"op"`
+	Query       string `json:"query"`
+	Fingerprint string `json:"fingerprint"`
+	// Args are the SQL literals of the arguments, only exported when
+	// Config.Args is set.
+	Args       []string `json:"args,omitempty"`
+	ConnID     uint64   `json:"conn_id"`
+	ConnAgeNs  int64    `json:"conn_age_ns"`
+	ConnUses   int64    `json:"conn_uses"`
+	InTx       bool     `json:"in_tx"`
+	DurationNs int64    `json:"duration_ns"`
+	// Error is the message of the error the operation failed with, and
+	// ErrorClass its sqlutil.ErrorClass when the dialect is known.
+	Error      string `json:"error,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Dialect    string `json:"dialect,omitempty"`
+}
+
+// Encoder writes records to a sink
+type Encoder interface {
+	Encode(r *Record) error
+}
+
+// Config configures a Hook
+type Config struct {
+	// Args exports the arguments of the statements. They often hold
+	// personal data, so they are left out by default.
+	Args bool
+	// OnError is called with the errors returned by the Encoder. They are
+	// ignored if nil.
+	OnError func(error)
+}
+
+// Hook encodes a Record for every operation
+type Hook struct {
+	enc Encoder
+	cfg Config
+}
+
+// New returns a Hook encoding records with enc
+func New(enc Encoder, cfg Config) *Hook {
+	return &Hook{enc: enc, cfg: cfg}
+}
+
+type startKey struct{}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.export(ctx, nil, query, args)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.export(ctx, err, query, args)
+	return err
+}
+
+func (h *Hook) export(ctx context.Context, err error, query string, args []interface{}) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil || event.Query != query {
+		event = &sqlhooks.Event{Query: query, Args: args}
+	}
+	if !h.cfg.Args && len(event.Args) > 0 {
+		e := *event
+		e.Args = nil
+		event = &e
+	}
+	r := NewRecord(event, start, time.Since(start), err)
+	if err := h.enc.Encode(r); err != nil && h.cfg.OnError != nil {
+		h.cfg.OnError(err)
+	}
+}
+
+// NewRecord returns the Record of event, started at start and run for
+// duration, failing with err if not nil
+func NewRecord(event *sqlhooks.Event, start time.Time, duration time.Duration, err error) *Record {
+	r := &Record{
+		Version:      SchemaVersion,
+		TimeUnixNano: start.UnixNano(),
+		Op:           event.Op.String(),
+		Query:        event.Query,
+		Fingerprint:  event.Fingerprint(),
+		ConnID:       event.ConnID,
+		ConnAgeNs:    int64(event.ConnAge),
+		ConnUses:     event.ConnUses,
+		InTx:         event.InTx,
+		DurationNs:   int64(duration),
+	}
+	literal := sqlutil.Literal
+	if event.Dialect != nil {
+		r.Dialect = event.Dialect.Name()
+		literal = event.Dialect.Literal
+	}
+	for _, arg := range event.Args {
+		r.Args = append(r.Args, literal(arg))
+	}
+	if err != nil {
+		r.Error = err.Error()
+		if event.Dialect != nil {
+			r.ErrorClass = event.Dialect.ClassifyError(err).String()
+		}
+	}
+	return r
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	driverName := fmt.Sprintf("export-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(NewJSONEncoder(&buf), Config{Args: true})))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT ?, ?", 1, "a")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT nope")
+	require.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var records [2]Record
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &records[i]))
+	}
+	r := records[0]
+	assert.Equal(t, int32(SchemaVersion), r.Version)
+	assert.Equal(t, "exec", r.Op)
+	assert.Equal(t, "SELECT ?, ?", r.Query)
+	assert.Equal(t, []string{"1", "'a'"}, r.Args)
+	assert.Equal(t, "sqlite", r.Dialect)
+	assert.NotZero(t, r.ConnID)
+	assert.NotZero(t, r.TimeUnixNano)
+	assert.Empty(t, r.Error)
+	assert.Contains(t, lines[0], `"time_unix_nano":`)
+
+	assert.Contains(t, records[1].Error, "no such column")
+	assert.Equal(t, "syntax", records[1].ErrorClass)
+}
+
+func TestProto(t *testing.T) {
+	r := &Record{Version: 1, Op: "query", Args: []string{""}, InTx: true, DurationNs: 300}
+	want := []byte{
+		0x08, 0x01, // version
+		0x1a, 0x05, 'q', 'u', 'e', 'r', 'y', // op
+		0x32, 0x00, // args
+		0x50, 0x01, // in_tx
+		0x58, 0xac, 0x02, // duration_ns
+	}
+	assert.Equal(t, want, MarshalProto(r))
+
+	var buf bytes.Buffer
+	require.NoError(t, NewProtoEncoder(&buf).Encode(r))
+	assert.Equal(t, append([]byte{byte(len(want))}, want...), buf.Bytes())
+}
+
+func TestBatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches []Batch
+	)
+	sink := SinkFunc(func(ctx context.Context, b Batch) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, b)
+		return nil
+	})
+	b := NewBatcher(sink, BatchConfig{Compression: Gzip, MaxRecords: 2, Interval: time.Hour})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, b.Encode(&Record{Version: 1, Query: fmt.Sprintf("SELECT %d", i)}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Run(ctx)
+
+	require.Len(t, batches, 3)
+	assert.Equal(t, []int{2, 2, 1}, []int{batches[0].Records, batches[1].Records, batches[2].Records})
+	assert.Equal(t, "gzip", batches[0].Encoding)
+	zr, err := gzip.NewReader(bytes.NewReader(batches[1].Data))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"query":"SELECT 2"`)
+	assert.Equal(t, BatchStats{Sent: 5, Batches: 3}, b.Stats())
+}
+
+func TestBatcherBackpressure(t *testing.T) {
+	boom := errors.New("boom")
+	var errs []error
+	b := NewBatcher(SinkFunc(func(ctx context.Context, b Batch) error { return boom }), BatchConfig{
+		QueueSize: 2,
+		OnError:   func(err error) { errs = append(errs, err) },
+	})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Encode(&Record{}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Run(ctx)
+
+	assert.Equal(t, BatchStats{Dropped: 1, Failed: 2}, b.Stats())
+	assert.Equal(t, []error{boom}, errs)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	err := HTTPSink(nil, srv.URL+"/records", "application/x-ndjson").Send(context.Background(), Batch{Data: []byte("data"), Encoding: "gzip"})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "application/x-ndjson", got.Header.Get("Content-Type"))
+	assert.Equal(t, "gzip", got.Header.Get("Content-Encoding"))
+	assert.Equal(t, "data", string(body))
+
+	err = HTTPSink(srv.Client(), srv.URL+"/fail", "application/x-ndjson").Send(context.Background(), Batch{})
+	assert.Error(t, err)
+}
+
+func TestBatcherPending(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		down = true
+		sent []string
+	)
+	sink := SinkFunc(func(ctx context.Context, b Batch) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("down")
+		}
+		sent = append(sent, strings.TrimSpace(string(b.Data)))
+		return nil
+	})
+	b := NewBatcher(sink, BatchConfig{MaxRecords: 1, Interval: time.Hour, RetryInterval: time.Millisecond, MaxPendingBytes: 1 << 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	require.NoError(t, b.Encode(&Record{Query: "SELECT 1"}))
+	require.NoError(t, b.Encode(&Record{Query: "SELECT 2"}))
+	require.Eventually(t, func() bool { return b.Stats().Pending == 2 }, time.Second, time.Millisecond)
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	require.Eventually(t, func() bool { return b.Stats().Sent == 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	require.Len(t, sent, 2)
+	assert.Contains(t, sent[0], `"query":"SELECT 1"`, "the pending batches are sent in order")
+	assert.Contains(t, sent[1], `"query":"SELECT 2"`)
+	assert.Equal(t, BatchStats{Sent: 2, Batches: 2}, b.Stats())
+}
+
+func TestBatcherSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var errs []error
+	failing := SinkFunc(func(ctx context.Context, b Batch) error { return errors.New("down") })
+	cfg := BatchConfig{
+		Compression: Gzip,
+		MaxRecords:  2,
+		SpillDir:    dir,
+		OnError:     func(err error) { errs = append(errs, err) },
+	}
+	b := NewBatcher(failing, cfg)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Encode(&Record{Query: fmt.Sprintf("SELECT %d", i)}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Run(ctx)
+	assert.Equal(t, BatchStats{Pending: 3}, b.Stats(), "the batches are kept on disk")
+	assert.NotEmpty(t, errs)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// The next Batcher sends them first
+	var batches []Batch
+	b = NewBatcher(SinkFunc(func(ctx context.Context, b Batch) error {
+		batches = append(batches, b)
+		return nil
+	}), cfg)
+	require.NoError(t, b.Encode(&Record{Query: "SELECT 3"}))
+	b.Run(ctx)
+	require.Len(t, batches, 3)
+	assert.Equal(t, []int{2, 1, 1}, []int{batches[0].Records, batches[1].Records, batches[2].Records})
+	assert.Equal(t, "gzip", batches[0].Encoding)
+	zr, err := gzip.NewReader(bytes.NewReader(batches[0].Data))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"query":"SELECT 0"`)
+	assert.Equal(t, BatchStats{Sent: 4, Batches: 3}, b.Stats())
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Batches beyond MaxPendingBytes are lost
+	errs = nil
+	cfg.MaxPendingBytes = 1
+	b = NewBatcher(failing, cfg)
+	require.NoError(t, b.Encode(&Record{}))
+	b.Run(ctx)
+	assert.Equal(t, BatchStats{Failed: 1}, b.Stats())
+	assert.Contains(t, errs, ErrPendingFull)
+}
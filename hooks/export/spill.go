@@ -0,0 +1,175 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPendingFull is reported to BatchConfig.OnError when a failed batch is
+// lost because BatchConfig.MaxPendingBytes is reached
+var ErrPendingFull = errors.New("export: too many pending batches")
+
+// pendingStore keeps the batches the Sink failed to receive, in order
+type pendingStore interface {
+	len() int
+	records() int64
+	push(batch Batch) error
+	// peek returns the oldest batch, with its Records even on error
+	peek() (Batch, error)
+	pop() error
+}
+
+// memoryStore keeps the pending batches in memory
+type memoryStore struct {
+	batches []Batch
+	size    int64
+	max     int64
+	n       int64
+}
+
+func (s *memoryStore) len() int       { return len(s.batches) }
+func (s *memoryStore) records() int64 { return s.n }
+
+func (s *memoryStore) push(batch Batch) error {
+	if s.size+int64(len(batch.Data)) > s.max {
+		return ErrPendingFull
+	}
+	s.batches = append(s.batches, batch)
+	s.size += int64(len(batch.Data))
+	s.n += int64(batch.Records)
+	return nil
+}
+
+func (s *memoryStore) peek() (Batch, error) {
+	return s.batches[0], nil
+}
+
+func (s *memoryStore) pop() error {
+	batch := s.batches[0]
+	s.batches[0] = Batch{}
+	s.batches = s.batches[1:]
+	s.size -= int64(len(batch.Data))
+	s.n -= int64(batch.Records)
+	return nil
+}
+
+// diskStore keeps the pending batches as files of a directory, named after
+// their sequence number, records and encoding so that they are read back in
+// order, as they were sent
+type diskStore struct {
+	dir   string
+	files []spillFile
+	size  int64
+	max   int64
+	n     int64
+	seq   uint64
+}
+
+type spillFile struct {
+	name     string
+	size     int64
+	records  int
+	encoding string
+}
+
+const spillExt = ".batch"
+
+// openDiskStore opens the batches left in dir, creating it if needed
+func openDiskStore(dir string, max int64) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &diskStore{dir: dir, max: max}
+	// ReadDir sorts the entries by name, hence by sequence number
+	for _, info := range infos {
+		f, seq, ok := parseSpillFile(info.Name())
+		if !ok {
+			if strings.HasSuffix(info.Name(), spillExt+".tmp") {
+				// Left by an interrupted push
+				_ = os.Remove(filepath.Join(dir, info.Name()))
+			}
+			continue
+		}
+		f.size = info.Size()
+		s.files = append(s.files, f)
+		s.size += f.size
+		s.n += int64(f.records)
+		s.seq = seq + 1
+	}
+	return s, nil
+}
+
+func parseSpillFile(name string) (spillFile, uint64, bool) {
+	if !strings.HasSuffix(name, spillExt) {
+		return spillFile{}, 0, false
+	}
+	f := spillFile{name: name}
+	parts := strings.SplitN(strings.TrimSuffix(name, spillExt), ".", 2)
+	if len(parts) == 2 {
+		f.encoding = parts[1]
+	}
+	var seq uint64
+	if _, err := fmt.Sscanf(parts[0], "%016x-%d", &seq, &f.records); err != nil {
+		return spillFile{}, 0, false
+	}
+	return f, seq, true
+}
+
+func (s *diskStore) len() int       { return len(s.files) }
+func (s *diskStore) records() int64 { return s.n }
+
+func (s *diskStore) push(batch Batch) error {
+	if s.size+int64(len(batch.Data)) > s.max {
+		return ErrPendingFull
+	}
+	f := spillFile{
+		name:     fmt.Sprintf("%016x-%d", s.seq, batch.Records),
+		size:     int64(len(batch.Data)),
+		records:  batch.Records,
+		encoding: batch.Encoding,
+	}
+	if f.encoding != "" {
+		f.name += "." + f.encoding
+	}
+	f.name += spillExt
+	path := filepath.Join(s.dir, f.name)
+	// The batch is renamed once written, not to be read back truncated
+	if err := ioutil.WriteFile(path+".tmp", batch.Data, 0600); err != nil {
+		_ = os.Remove(path + ".tmp")
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	s.seq++
+	s.files = append(s.files, f)
+	s.size += f.size
+	s.n += int64(f.records)
+	return nil
+}
+
+func (s *diskStore) peek() (Batch, error) {
+	f := s.files[0]
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, f.name))
+	return Batch{Data: data, Records: f.records, Encoding: f.encoding}, err
+}
+
+func (s *diskStore) pop() error {
+	f := s.files[0]
+	s.files = s.files[1:]
+	s.size -= f.size
+	s.n -= int64(f.records)
+	err := os.Remove(filepath.Join(s.dir, f.name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
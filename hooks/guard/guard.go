@@ -0,0 +1,158 @@
+// Package guard rejects dangerous statements before they reach the database,
+// as a cheap safety net against catastrophic mistakes:
+//
+//	sql.Register("postgres-guarded", sqlhooks.Wrap(&pq.Driver{}, guard.New(guard.Config{RequireWhere: true, BlockDDL: true})))
+//
+// Rejected statements fail with an error wrapping ErrRejected. Intentional
+// operations are allowed with a context override:
+//
+//	db.ExecContext(guard.AllowFullTable(ctx), "DELETE FROM sessions")
+//	db.ExecContext(guard.AllowDDL(ctx), "ALTER TABLE users ADD COLUMN age int")
+//
+// Policies restrict statements to, or keep them out of, time windows, see
+// Policy.
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+var (
+	// ErrRejected is wrapped by the errors of the rejected statements
+	ErrRejected = errors.New("guard: statement rejected")
+	// ErrNoWhere is returned for UPDATE and DELETE statements without a
+	// WHERE clause when Config.RequireWhere is set
+	ErrNoWhere = fmt.Errorf("%w: UPDATE or DELETE without a WHERE clause", ErrRejected)
+	// ErrReadOnly is returned for writes when Config.ReadOnly is set
+	ErrReadOnly = fmt.Errorf("%w: write on a read-only database", ErrRejected)
+	// ErrDDL is returned for schema changes when Config.BlockDDL is set
+	ErrDDL = fmt.Errorf("%w: schema change", ErrRejected)
+)
+
+// Config selects the guards of a Hook
+type Config struct {
+	// RequireWhere rejects UPDATE and DELETE statements without a WHERE
+	// clause, unless their context was returned by AllowFullTable.
+	RequireWhere bool
+	// ReadOnly rejects the statements that may write, as classified by
+	// sqlutil.IsWrite. Transaction statements are allowed.
+	ReadOnly bool
+	// BlockDDL rejects schema changes, unless their context was returned
+	// by AllowDDL, e.g. in migrations.
+	BlockDDL bool
+	// Policies reject statements depending on the time of day, such as
+	// heavy reports during peak hours or schema changes outside of a
+	// maintenance window, unless their context was returned by
+	// AllowSchedule.
+	Policies []Policy
+	// Now returns the current time the policies are evaluated at.
+	// Defaults to time.Now.
+	Now func() time.Time
+}
+
+type allowFullTableKey struct{}
+
+type allowDDLKey struct{}
+
+// AllowDDL returns a context whose schema changes are allowed
+func AllowDDL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowDDLKey{}, true)
+}
+
+// AllowFullTable returns a context whose UPDATE and DELETE statements may
+// affect whole tables
+func AllowFullTable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowFullTableKey{}, true)
+}
+
+// Hook rejects the statements forbidden by its Config in Before
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	return &Hook{cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, h.check(ctx, query)
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) check(ctx context.Context, query string) error {
+	if err := h.checkSchedule(ctx, query); err != nil {
+		return err
+	}
+
+	requireWhere := h.cfg.RequireWhere && ctx.Value(allowFullTableKey{}) == nil
+	blockDDL := h.cfg.BlockDDL && ctx.Value(allowDDLKey{}) == nil
+	if !requireWhere && !blockDDL && !h.cfg.ReadOnly {
+		return nil
+	}
+
+	for _, stmt := range statements(sqlutil.Tokenize(query)) {
+		var b strings.Builder
+		for _, t := range stmt {
+			b.WriteString(t.Text)
+		}
+		switch kind := sqlutil.Classify(b.String()); {
+		case kind == sqlutil.Transaction || kind == sqlutil.Unknown:
+		case blockDDL && kind == sqlutil.DDL:
+			return ErrDDL
+		case h.cfg.ReadOnly && kind != sqlutil.Select:
+			return ErrReadOnly
+		case requireWhere && (kind == sqlutil.Update || kind == sqlutil.Delete) && !hasWhere(stmt):
+			return ErrNoWhere
+		}
+	}
+	return nil
+}
+
+// statements splits tokens on the semicolons separating statements
+func statements(tokens []sqlutil.Token) [][]sqlutil.Token {
+	var stmts [][]sqlutil.Token
+	depth, start := 0, 0
+	for i, t := range tokens {
+		if t.Type != sqlutil.Punct {
+			continue
+		}
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case t.Text == ";" && depth == 0:
+			stmts = append(stmts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(stmts, tokens[start:])
+}
+
+// hasWhere reports whether the statement in tokens has a top-level WHERE
+// clause
+func hasWhere(tokens []sqlutil.Token) bool {
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.Type != sqlutil.Punct && t.Type != sqlutil.Word:
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && t.IsKeyword("WHERE"):
+			return true
+		}
+	}
+	return false
+}
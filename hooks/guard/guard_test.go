@@ -0,0 +1,129 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireWhere(t *testing.T) {
+	h := New(Config{RequireWhere: true})
+	ctx := context.Background()
+
+	for query, rejected := range map[string]bool{
+		"UPDATE t SET a = 1 WHERE id = ?":                            false,
+		"delete from t where id in (select id from u)":               false,
+		"SELECT * FROM t":                                            false,
+		"INSERT INTO t SELECT * FROM u":                              false,
+		"UPDATE t SET a = 1":                                         true,
+		"DELETE FROM t":                                              true,
+		"DELETE FROM t LIMIT 10 -- WHERE id = 1":                     true,
+		"UPDATE t SET a = (SELECT b FROM u WHERE u.id = t.id)":       true,
+		"WITH old AS (SELECT id FROM t WHERE a < 1) DELETE FROM old": true,
+		"UPDATE t SET a = 1 WHERE id = 1; DELETE FROM t":             true,
+		"UPDATE t SET a = 'x; DELETE FROM t' WHERE id = 1":           false,
+	} {
+		_, err := h.Before(ctx, query)
+		if rejected {
+			assert.True(t, errors.Is(err, ErrNoWhere), query)
+			assert.True(t, errors.Is(err, ErrRejected), query)
+		} else {
+			assert.NoError(t, err, query)
+		}
+	}
+
+	_, err := h.Before(AllowFullTable(ctx), "DELETE FROM sessions")
+	assert.NoError(t, err)
+	_, err = New(Config{}).Before(ctx, "DELETE FROM sessions")
+	assert.NoError(t, err)
+}
+
+func TestReadOnlyAndBlockDDL(t *testing.T) {
+	ctx := context.Background()
+	h := New(Config{ReadOnly: true, BlockDDL: true})
+	for query, want := range map[string]error{
+		"SELECT * FROM t":                nil,
+		"BEGIN":                          nil,
+		"INSERT INTO t VALUES (1)":       ErrReadOnly,
+		"SELECT 1; DELETE FROM t":        ErrReadOnly,
+		"CALL cleanup()":                 ErrReadOnly,
+		"ALTER TABLE t ADD COLUMN a int": ErrDDL,
+	} {
+		_, err := h.Before(ctx, query)
+		assert.Equal(t, want, err, query)
+	}
+
+	h = New(Config{BlockDDL: true})
+	_, err := h.Before(ctx, "DROP TABLE t")
+	assert.Equal(t, ErrDDL, err)
+	_, err = h.Before(AllowDDL(ctx), "DROP TABLE t")
+	assert.NoError(t, err)
+}
+
+func TestWindow(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		// 2024-01-01 is a Monday
+		return time.Date(2024, 1, day, hour, min, 0, 0, time.UTC)
+	}
+	weekdays := Window{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      18 * time.Hour,
+		Location: time.UTC,
+	}
+	assert.True(t, weekdays.Contains(at(1, 9, 0)))
+	assert.True(t, weekdays.Contains(at(5, 17, 59)))
+	assert.False(t, weekdays.Contains(at(1, 18, 0)))
+	assert.False(t, weekdays.Contains(at(1, 8, 59)))
+	assert.False(t, weekdays.Contains(at(6, 12, 0)), "Saturday")
+
+	// Saturday 22:00 to Sunday 02:00
+	night := Window{Days: []time.Weekday{time.Saturday}, Start: 22 * time.Hour, End: 2 * time.Hour, Location: time.UTC}
+	assert.True(t, night.Contains(at(6, 23, 0)))
+	assert.True(t, night.Contains(at(7, 1, 0)))
+	assert.False(t, night.Contains(at(7, 23, 0)))
+	assert.False(t, night.Contains(at(6, 1, 0)))
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err == nil {
+		w := Window{Start: 9 * time.Hour, End: 10 * time.Hour, Location: paris}
+		assert.True(t, w.Contains(at(1, 8, 30)))
+	}
+	assert.True(t, Window{}.Contains(at(3, 4, 5)), "whole day")
+}
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	peak := []Window{{Start: 9 * time.Hour, End: 18 * time.Hour, Location: time.UTC}}
+	maintenance := []Window{{Start: 2 * time.Hour, End: 4 * time.Hour, Location: time.UTC}}
+	h := New(Config{
+		Policies: []Policy{
+			{Name: "peak", Fingerprints: []string{sqlutil.Fingerprint("SELECT * FROM report(?)")}, Windows: peak},
+			{Name: "maintenance", Kinds: []sqlutil.Kind{sqlutil.DDL}, Windows: maintenance, Outside: true},
+		},
+		Now: func() time.Time { return now },
+	})
+
+	_, err := h.Before(ctx, "SELECT * FROM report(2024)")
+	assert.True(t, errors.Is(err, ErrSchedule))
+	assert.True(t, errors.Is(err, ErrRejected))
+	assert.Contains(t, err.Error(), `policy "peak"`)
+	_, err = h.Before(ctx, "SELECT 1; CREATE INDEX i ON t(a)")
+	assert.True(t, errors.Is(err, ErrSchedule))
+	assert.Contains(t, err.Error(), `policy "maintenance"`)
+	_, err = h.Before(ctx, "SELECT * FROM t")
+	assert.NoError(t, err)
+	_, err = h.Before(AllowSchedule(ctx), "CREATE INDEX i ON t(a)")
+	assert.NoError(t, err)
+
+	now = now.Add(-9 * time.Hour) // 03:00
+	_, err = h.Before(ctx, "SELECT * FROM report(2024)")
+	assert.NoError(t, err)
+	_, err = h.Before(ctx, "CREATE INDEX i ON t(a)")
+	assert.NoError(t, err)
+}
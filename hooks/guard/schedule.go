@@ -0,0 +1,166 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// ErrSchedule is returned for the statements blocked by a Policy
+var ErrSchedule = fmt.Errorf("%w: blocked by schedule", ErrRejected)
+
+// Window is a recurring time window, such as weekdays from 9:00 to 18:00:
+//
+//	guard.Window{
+//		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//		Start: 9 * time.Hour,
+//		End:   18 * time.Hour,
+//	}
+type Window struct {
+	// Days are the days the window starts on, every day if empty.
+	Days []time.Weekday
+	// Start and End are the offsets of the window from midnight. A window
+	// ending before it starts spans midnight, and one ending when it starts
+	// lasts the whole day.
+	Start, End time.Duration
+	// Location is the time zone of the window. Defaults to time.Local.
+	Location *time.Location
+}
+
+const day = 24 * time.Hour
+
+// Contains reports whether t is in the window
+func (w Window) Contains(t time.Time) bool {
+	loc := w.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+
+	length := (w.End - w.Start + day) % day
+	if length == 0 {
+		length = day
+	}
+	// The window may have started today or, spanning midnight, yesterday
+	if offset >= w.Start && offset-w.Start < length {
+		return w.startsOn(t.Weekday())
+	}
+	if offset < w.Start && offset+day-w.Start < length {
+		return w.startsOn((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+func (w Window) startsOn(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy blocks the statements it matches while it is in effect, that is
+// during its windows or, with Outside, outside of them:
+//
+//	// No reports during peak hours
+//	guard.Policy{Name: "peak", Fingerprints: reports, Windows: peakHours}
+//	// Schema changes only during the maintenance window
+//	guard.Policy{Name: "maintenance", Kinds: []sqlutil.Kind{sqlutil.DDL}, Windows: maintenance, Outside: true}
+type Policy struct {
+	// Name identifies the policy in the errors of the statements it
+	// blocks.
+	Name string
+	// Kinds and Fingerprints select the statements of the policy: those of
+	// one of Kinds, as classified by sqlutil.Classify, and those whose
+	// sqlutil.Fingerprint is one of Fingerprints.
+	Kinds        []sqlutil.Kind
+	Fingerprints []string
+	Windows      []Window
+	// Outside puts the policy in effect outside of its windows.
+	Outside bool
+}
+
+// active reports whether p is in effect at t
+func (p *Policy) active(t time.Time) bool {
+	in := false
+	for _, w := range p.Windows {
+		if w.Contains(t) {
+			in = true
+			break
+		}
+	}
+	return in != p.Outside
+}
+
+type allowScheduleKey struct{}
+
+// AllowSchedule returns a context whose statements are not subject to the
+// policies of Config.Policies
+func AllowSchedule(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowScheduleKey{}, true)
+}
+
+// checkSchedule returns an error wrapping ErrSchedule if a policy in effect
+// blocks query
+func (h *Hook) checkSchedule(ctx context.Context, query string) error {
+	if len(h.cfg.Policies) == 0 || ctx.Value(allowScheduleKey{}) != nil {
+		return nil
+	}
+	now := time.Now
+	if h.cfg.Now != nil {
+		now = h.cfg.Now
+	}
+	t := now()
+
+	var (
+		fingerprint string
+		kinds       []sqlutil.Kind
+	)
+	for i := range h.cfg.Policies {
+		p := &h.cfg.Policies[i]
+		if !p.active(t) {
+			continue
+		}
+		if len(p.Fingerprints) > 0 && fingerprint == "" {
+			fingerprint = sqlutil.Fingerprint(query)
+		}
+		for _, fp := range p.Fingerprints {
+			if fp == fingerprint {
+				return fmt.Errorf("%w: policy %q", ErrSchedule, p.Name)
+			}
+		}
+		if len(p.Kinds) > 0 && kinds == nil {
+			kinds = statementKinds(query)
+		}
+		for _, kind := range kinds {
+			for _, k := range p.Kinds {
+				if k == kind {
+					return fmt.Errorf("%w: policy %q", ErrSchedule, p.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// statementKinds returns the kinds of the statements of query
+func statementKinds(query string) []sqlutil.Kind {
+	var kinds []sqlutil.Kind
+	for _, stmt := range statements(sqlutil.Tokenize(query)) {
+		var b strings.Builder
+		for _, t := range stmt {
+			b.WriteString(t.Text)
+		}
+		kinds = append(kinds, sqlutil.Classify(b.String()))
+	}
+	return kinds
+}
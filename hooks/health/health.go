@@ -0,0 +1,141 @@
+// Package health observes the pings of the connections, as run by
+// sql.DB.PingContext in the readiness probes of orchestrators, so that their
+// latency and failures show up in the monitoring built on sqlhooks. Pings
+// are only hooked with sqlhooks.WithOps(sqlhooks.OpPing); their failures
+// also reach the OnError callbacks of the other hooks:
+//
+//	h := health.New(health.Config{OnChange: func(s health.Status) {
+//		log.Printf("database healthy: %t, last error: %v", s.Healthy, s.LastErr)
+//	}})
+//	sql.Register("postgres-health", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(h, metrics), sqlhooks.WithOps(sqlhooks.OpPing)))
+//	http.Handle("/healthz", h)
+//
+// The database is unhealthy after FailureThreshold consecutive failed
+// pings, and healthy again after the first successful one.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Status is the health of the database, as observed through the pings
+type Status struct {
+	Healthy bool
+	// Pings is the number of pings, Failures the number of failed ones.
+	Pings    int64
+	Failures int64
+	// ConsecutiveFailures is the number of failed pings since the last
+	// successful one.
+	ConsecutiveFailures int
+	// LastPing is when the last ping started, LastLatency how long it
+	// took, and LastErr its error, if it failed.
+	LastPing    time.Time
+	LastLatency time.Duration
+	LastErr     error
+	// MaxLatency is the longest ping.
+	MaxLatency time.Duration
+}
+
+// Config configures a Hook
+type Config struct {
+	// FailureThreshold is the number of consecutive failed pings after
+	// which the database is unhealthy. Defaults to 3.
+	FailureThreshold int
+	// OnChange, if set, is called synchronously after the ping turning the
+	// database healthy or unhealthy.
+	OnChange func(Status)
+}
+
+// Hook records the outcome of the pings
+type Hook struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns a new Hook. The database is healthy until pings fail.
+func New(cfg Config) *Hook {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	return &Hook{cfg: cfg, now: time.Now, status: Status{Healthy: true}}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.observe(ctx, nil)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.observe(ctx, err)
+	return err
+}
+
+func (h *Hook) observe(ctx context.Context, err error) {
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil || event.Op != sqlhooks.OpPing {
+		return
+	}
+
+	h.mu.Lock()
+	s := &h.status
+	s.Pings++
+	s.LastPing = h.now().Add(-event.Duration)
+	s.LastLatency = event.Duration
+	s.LastErr = err
+	if event.Duration > s.MaxLatency {
+		s.MaxLatency = event.Duration
+	}
+	healthy := s.Healthy
+	if err != nil {
+		s.Failures++
+		s.ConsecutiveFailures++
+		if s.ConsecutiveFailures >= h.cfg.FailureThreshold {
+			s.Healthy = false
+		}
+	} else {
+		s.ConsecutiveFailures = 0
+		s.Healthy = true
+	}
+	changed := healthy != s.Healthy
+	status := *s
+	h.mu.Unlock()
+
+	if changed && h.cfg.OnChange != nil {
+		h.cfg.OnChange(status)
+	}
+}
+
+// Status returns the current health of the database
+func (h *Hook) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// ServeHTTP responds 200 while the database is healthy and 503 otherwise,
+// with the status as text
+func (h *Hook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.Status()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !s.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintf(w, "healthy: %t\npings: %d\nfailures: %d\nconsecutive failures: %d\nlast latency: %s\n",
+		s.Healthy, s.Pings, s.Failures, s.ConsecutiveFailures, s.LastLatency)
+	if s.LastErr != nil {
+		fmt.Fprintf(w, "last error: %v\n", s.LastErr)
+	}
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorRecorder records the errors routed to OnError
+type errorRecorder struct {
+	errs []error
+}
+
+func (r *errorRecorder) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (r *errorRecorder) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (r *errorRecorder) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	r.errs = append(r.errs, err)
+	return err
+}
+
+func TestHook(t *testing.T) {
+	var changes []Status
+	h := New(Config{FailureThreshold: 2, OnChange: func(s Status) { changes = append(changes, s) }})
+	errs := &errorRecorder{}
+	drv := &sqlhookstest.Driver{}
+	driverName := fmt.Sprintf("health-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(drv, sqlhooks.Compose(h, errs), sqlhooks.WithOps(sqlhooks.OpPing)))
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Queries are not pings
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+	require.NoError(t, db.Ping())
+	assert.Equal(t, int64(1), h.Status().Pings)
+	assert.False(t, h.Status().LastPing.IsZero())
+
+	drv.FailPing = true
+	assert.Error(t, db.Ping())
+	assert.True(t, h.Status().Healthy, "one failure is below the threshold")
+	assert.Error(t, db.Ping())
+	s := h.Status()
+	assert.False(t, s.Healthy)
+	assert.Equal(t, int64(2), s.Failures)
+	assert.Equal(t, 2, s.ConsecutiveFailures)
+	assert.Equal(t, sqlhookstest.ErrFail, s.LastErr)
+	assert.Equal(t, []error{sqlhookstest.ErrFail, sqlhookstest.ErrFail}, errs.errs, "the failures reach OnError")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "last error: sqlhookstest: statement failed")
+
+	drv.FailPing = false
+	require.NoError(t, db.Ping())
+	assert.True(t, h.Status().Healthy)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	require.Len(t, changes, 2)
+	assert.False(t, changes[0].Healthy)
+	assert.True(t, changes[1].Healthy)
+}
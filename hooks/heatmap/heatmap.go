@@ -0,0 +1,291 @@
+// Package heatmap measures the time spent in every statement fingerprint and
+// exposes the hottest ones as Prometheus gauges, so that dashboards can show
+// the statements loading the database the most.
+//
+// Time is accumulated over intervals. At the end of each interval the top N
+// fingerprints by cumulative time are published and the counters restart, so
+// that only N series exist at any time, however many statements the
+// application runs:
+//
+//	h := heatmap.New(heatmap.Config{TopN: 20, Interval: time.Minute})
+//	sql.Register("postgres-heatmap", sqlhooks.Wrap(&pq.Driver{}, h))
+//	http.Handle("/metrics/sql", h)
+//
+// Hook serves the Prometheus text format itself, it does not depend on a
+// client library. Use Top to feed another exporter.
+//
+// Multi-tenant platforms can attribute the time to the tenant of every
+// statement, found in its context by Config.Tenant, to break the hottest
+// statements and the total time down by tenant:
+//
+//	h := heatmap.New(heatmap.Config{Tenant: tenant.FromContext, MaxTenants: 500})
+package heatmap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Config configures a Hook
+type Config struct {
+	// TopN is the number of fingerprints published. Defaults to 10.
+	TopN int
+	// Interval is the period over which time is accumulated. Defaults to
+	// one minute.
+	Interval time.Duration
+	// MaxQueryLen truncates the query label. Defaults to 200 bytes.
+	MaxQueryLen int
+	// Tenant, if set, returns the tenant of the statements run with ctx,
+	// which partitions the entries and labels the series. Statements
+	// without a tenant have an empty one.
+	Tenant func(ctx context.Context) (string, bool)
+	// MaxTenants caps the number of tenants, and thus of series: the
+	// statements of the tenants seen after the first MaxTenants ones are
+	// attributed to OverflowTenant. Defaults to 100.
+	MaxTenants int
+}
+
+// OverflowTenant is the tenant of the statements of the tenants beyond
+// Config.MaxTenants
+const OverflowTenant = "__overflow__"
+
+// Entry is the time spent in a fingerprint during an interval
+type Entry struct {
+	// Tenant is the tenant of the statements, when Config.Tenant is set.
+	Tenant      string
+	Fingerprint string
+	// Query is the normalized statement
+	Query string
+	Calls int64
+	Time  time.Duration
+}
+
+// TenantEntry is the time spent in the statements of a tenant during an
+// interval
+type TenantEntry struct {
+	Tenant string
+	Calls  int64
+	Time   time.Duration
+}
+
+type entryKey struct {
+	tenant, fingerprint string
+}
+
+// Hook accumulates the time spent in every fingerprint
+type Hook struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	start     time.Time
+	current   map[entryKey]*Entry
+	published []Entry
+	// tenants are the tenants admitted under Config.MaxTenants, and
+	// tenantsPublished the totals of the last complete interval
+	tenants          map[string]bool
+	tenantsPublished []TenantEntry
+}
+
+type startKey struct{}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.TopN <= 0 {
+		cfg.TopN = 10
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.MaxQueryLen <= 0 {
+		cfg.MaxQueryLen = 200
+	}
+	if cfg.MaxTenants <= 0 {
+		cfg.MaxTenants = 100
+	}
+	return &Hook{cfg: cfg, now: time.Now, current: make(map[entryKey]*Entry), tenants: make(map[string]bool)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, h.now()), nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.observe(ctx, query)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.observe(ctx, query)
+	return err
+}
+
+func (h *Hook) observe(ctx context.Context, query string) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	now := h.now()
+	key := entryKey{fingerprint: sqlutil.Fingerprint(query)}
+	if h.cfg.Tenant != nil {
+		key.tenant, _ = h.cfg.Tenant(ctx)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotate(now)
+	if h.cfg.Tenant != nil {
+		key.tenant = h.admit(key.tenant)
+	}
+	e, ok := h.current[key]
+	if !ok {
+		e = &Entry{Tenant: key.tenant, Fingerprint: key.fingerprint, Query: sqlutil.Normalize(query)}
+		h.current[key] = e
+	}
+	e.Calls++
+	e.Time += now.Sub(start)
+}
+
+// admit returns tenant if it is under the cap, OverflowTenant otherwise. It
+// must be called with h.mu held.
+func (h *Hook) admit(tenant string) string {
+	if h.tenants[tenant] {
+		return tenant
+	}
+	if len(h.tenants) >= h.cfg.MaxTenants {
+		return OverflowTenant
+	}
+	h.tenants[tenant] = true
+	return tenant
+}
+
+// rotate publishes the top entries of the current interval if it is over.
+// It must be called with h.mu held.
+func (h *Hook) rotate(now time.Time) {
+	if h.start.IsZero() {
+		h.start = now
+	}
+	elapsed := now.Sub(h.start)
+	if elapsed < h.cfg.Interval {
+		return
+	}
+
+	h.published = h.published[:0]
+	h.tenantsPublished = h.tenantsPublished[:0]
+	if elapsed < 2*h.cfg.Interval {
+		totals := make(map[string]*TenantEntry)
+		for _, e := range h.current {
+			h.published = append(h.published, *e)
+			if h.cfg.Tenant == nil {
+				continue
+			}
+			t, ok := totals[e.Tenant]
+			if !ok {
+				t = &TenantEntry{Tenant: e.Tenant}
+				totals[e.Tenant] = t
+			}
+			t.Calls += e.Calls
+			t.Time += e.Time
+		}
+		for _, t := range totals {
+			h.tenantsPublished = append(h.tenantsPublished, *t)
+		}
+		sort.Slice(h.tenantsPublished, func(i, j int) bool {
+			if h.tenantsPublished[i].Time != h.tenantsPublished[j].Time {
+				return h.tenantsPublished[i].Time > h.tenantsPublished[j].Time
+			}
+			return h.tenantsPublished[i].Tenant < h.tenantsPublished[j].Tenant
+		})
+		sort.Slice(h.published, func(i, j int) bool {
+			if h.published[i].Time != h.published[j].Time {
+				return h.published[i].Time > h.published[j].Time
+			}
+			if h.published[i].Fingerprint != h.published[j].Fingerprint {
+				return h.published[i].Fingerprint < h.published[j].Fingerprint
+			}
+			return h.published[i].Tenant < h.published[j].Tenant
+		})
+		if len(h.published) > h.cfg.TopN {
+			h.published = h.published[:h.cfg.TopN]
+		}
+	}
+	// Otherwise nothing ran during the last interval
+	h.current = make(map[entryKey]*Entry)
+	h.start = now.Add(-elapsed % h.cfg.Interval)
+}
+
+// Top returns the hottest fingerprints of the last complete interval, by
+// decreasing time
+func (h *Hook) Top() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotate(h.now())
+	return append([]Entry(nil), h.published...)
+}
+
+// Tenants returns the time spent in the statements of every tenant during
+// the last complete interval, by decreasing time. It is empty unless
+// Config.Tenant is set.
+func (h *Hook) Tenants() []TenantEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotate(h.now())
+	return append([]TenantEntry(nil), h.tenantsPublished...)
+}
+
+// ServeHTTP writes the hottest fingerprints in the Prometheus text format
+func (h *Hook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	top, tenants := h.Top(), h.Tenants()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var b strings.Builder
+	b.WriteString("# HELP sql_heatmap_seconds Time spent in the hottest statements during the last interval.\n")
+	b.WriteString("# TYPE sql_heatmap_seconds gauge\n")
+	for _, e := range top {
+		fmt.Fprintf(&b, "sql_heatmap_seconds{%s} %g\n", h.labels(e), e.Time.Seconds())
+	}
+	b.WriteString("# HELP sql_heatmap_calls Executions of the hottest statements during the last interval.\n")
+	b.WriteString("# TYPE sql_heatmap_calls gauge\n")
+	for _, e := range top {
+		fmt.Fprintf(&b, "sql_heatmap_calls{%s} %d\n", h.labels(e), e.Calls)
+	}
+	if h.cfg.Tenant != nil {
+		b.WriteString("# HELP sql_heatmap_tenant_seconds Time spent in the statements of every tenant during the last interval.\n")
+		b.WriteString("# TYPE sql_heatmap_tenant_seconds gauge\n")
+		for _, t := range tenants {
+			fmt.Fprintf(&b, "sql_heatmap_tenant_seconds{tenant=\"%s\"} %g\n", labelEscaper.Replace(t.Tenant), t.Time.Seconds())
+		}
+		b.WriteString("# HELP sql_heatmap_tenant_calls Statements of every tenant during the last interval.\n")
+		b.WriteString("# TYPE sql_heatmap_tenant_calls gauge\n")
+		for _, t := range tenants {
+			fmt.Fprintf(&b, "sql_heatmap_tenant_calls{tenant=\"%s\"} %d\n", labelEscaper.Replace(t.Tenant), t.Calls)
+		}
+	}
+	_, _ = w.Write([]byte(b.String()))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (h *Hook) labels(e Entry) string {
+	query := e.Query
+	if len(query) > h.cfg.MaxQueryLen {
+		n := h.cfg.MaxQueryLen
+		for n > 0 && !utf8.RuneStart(query[n]) {
+			n--
+		}
+		query = query[:n]
+	}
+	labels := fmt.Sprintf(`fingerprint="%s",query="%s"`, e.Fingerprint, labelEscaper.Replace(query))
+	if h.cfg.Tenant != nil {
+		labels = fmt.Sprintf(`tenant="%s",`, labelEscaper.Replace(e.Tenant)) + labels
+	}
+	return labels
+}
@@ -0,0 +1,94 @@
+package heatmap
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeatmap(t *testing.T) {
+	h := New(Config{TopN: 2, Interval: time.Minute})
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+
+	run := func(query string, took time.Duration) {
+		ctx, _ := h.Before(context.Background(), query)
+		now = now.Add(took)
+		h.After(ctx, query)
+	}
+	for i := 0; i < 3; i++ {
+		run(fmt.Sprintf("SELECT * FROM a WHERE id = %d", i), time.Second)
+	}
+	run("SELECT * FROM b", 2*time.Second)
+	run("SELECT * FROM \"c\"", 4*time.Second)
+	assert.Empty(t, h.Top())
+
+	now = now.Add(time.Minute)
+	top := h.Top()
+	require.Len(t, top, 2)
+	assert.Equal(t, `SELECT * FROM "c"`, top[0].Query)
+	assert.Equal(t, "SELECT * FROM a WHERE id = ?", top[1].Query)
+	assert.Equal(t, int64(3), top[1].Calls)
+	assert.Equal(t, 3*time.Second, top[1].Time)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, fmt.Sprintf("sql_heatmap_seconds{fingerprint=%q,query=\"SELECT * FROM \\\"c\\\"\"} 4\n", top[0].Fingerprint))
+	assert.Contains(t, body, fmt.Sprintf("sql_heatmap_calls{fingerprint=%q,query=\"SELECT * FROM a WHERE id = ?\"} 3\n", top[1].Fingerprint))
+	assert.NotContains(t, body, "FROM b")
+
+	// Idle intervals publish nothing
+	now = now.Add(3 * time.Minute)
+	assert.Empty(t, h.Top())
+}
+
+type tenantKey struct{}
+
+func TestTenants(t *testing.T) {
+	h := New(Config{
+		TopN:     10,
+		Interval: time.Minute,
+		Tenant: func(ctx context.Context) (string, bool) {
+			tenant, ok := ctx.Value(tenantKey{}).(string)
+			return tenant, ok
+		},
+		MaxTenants: 2,
+	})
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+
+	run := func(tenant, query string, took time.Duration) {
+		ctx := context.WithValue(context.Background(), tenantKey{}, tenant)
+		ctx, _ = h.Before(ctx, query)
+		now = now.Add(took)
+		h.After(ctx, query)
+	}
+	run("acme", "SELECT * FROM invoices", 3*time.Second)
+	run("globex", "SELECT * FROM invoices", time.Second)
+	run("initech", "SELECT * FROM invoices", time.Second)
+	run("umbrella", "SELECT * FROM users", time.Second)
+	run("acme", "SELECT * FROM users", time.Second)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, []TenantEntry{
+		{Tenant: "acme", Calls: 2, Time: 4 * time.Second},
+		{Tenant: OverflowTenant, Calls: 2, Time: 2 * time.Second},
+		{Tenant: "globex", Calls: 1, Time: time.Second},
+	}, h.Tenants())
+	top := h.Top()
+	require.Len(t, top, 5)
+	assert.Equal(t, "acme", top[0].Tenant)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, fmt.Sprintf("sql_heatmap_seconds{tenant=\"acme\",fingerprint=%q,query=\"SELECT * FROM invoices\"} 3\n", top[0].Fingerprint))
+	assert.Contains(t, body, "sql_heatmap_tenant_seconds{tenant=\"__overflow__\"} 2\n")
+	assert.Contains(t, body, "sql_heatmap_tenant_calls{tenant=\"acme\"} 2\n")
+}
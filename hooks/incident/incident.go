@@ -0,0 +1,189 @@
+// Package incident switches the hooks of an application to a degraded mode
+// during a database incident, in one call. While the Switch is on:
+//
+//   - the Hook rejects the low priority statements, those of the
+//     partition.Batch workload or run with WithLowPriority, to leave the
+//     database to the critical traffic;
+//   - the diagnostics hooks wrapped with Switch.Diagnostics see every
+//     statement instead of a sample of them;
+//   - the listeners registered with Switch.OnChange are notified, e.g. for a
+//     cache to serve its stale entries rather than querying the database.
+//
+// The Switch is flipped by hand, such as from an admin endpoint, or follows
+// the health of the database as observed by the health package:
+//
+//	sw := incident.NewSwitch()
+//	sw.OnChange(func(s incident.State) { cache.ServeStale(s.Active) })
+//	hooks := sqlhooks.Compose(
+//		health.New(health.Config{OnChange: sw.FollowHealth}),
+//		incident.New(sw, incident.Config{}),
+//		sw.Diagnostics(sqlutil.NewSampler(10, 100), slowquery.New(slowquery.Config{})),
+//	)
+//	sql.Register("postgres-degradable", sqlhooks.Wrap(&pq.Driver{}, hooks, sqlhooks.WithOps(sqlhooks.OpPing)))
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/hooks/health"
+	"github.com/qustavo/sqlhooks/v2/partition"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// ErrShed is wrapped by the errors of the statements rejected during an
+// incident
+var ErrShed = errors.New("incident: low priority statement shed")
+
+// State is the state of a Switch
+type State struct {
+	Active bool
+	// Reason is the reason given to Enable
+	Reason string
+	// Since is when the switch was last flipped
+	Since time.Time
+}
+
+// Switch turns the incident mode on and off. It is safe for concurrent use.
+type Switch struct {
+	now func() time.Time
+
+	mu        sync.RWMutex
+	state     State
+	listeners []func(State)
+}
+
+// NewSwitch returns a Switch turned off
+func NewSwitch() *Switch {
+	return &Switch{now: time.Now}
+}
+
+// Enable turns the incident mode on, notifying the listeners if it was off
+func (s *Switch) Enable(reason string) {
+	s.set(true, reason)
+}
+
+// Disable turns the incident mode off, notifying the listeners if it was on
+func (s *Switch) Disable() {
+	s.set(false, "")
+}
+
+func (s *Switch) set(active bool, reason string) {
+	s.mu.Lock()
+	if s.state.Active == active {
+		s.mu.Unlock()
+		return
+	}
+	s.state = State{Active: active, Reason: reason, Since: s.now()}
+	state, listeners := s.state, s.listeners
+	s.mu.Unlock()
+	for _, l := range listeners {
+		l(state)
+	}
+}
+
+// FollowHealth turns the incident mode on while the database is unhealthy,
+// to be used as the health.Config.OnChange callback
+func (s *Switch) FollowHealth(status health.Status) {
+	if status.Healthy {
+		s.Disable()
+		return
+	}
+	reason := "database unhealthy"
+	if status.LastErr != nil {
+		reason = fmt.Sprintf("%s: %v", reason, status.LastErr)
+	}
+	s.Enable(reason)
+}
+
+// State returns the state of the switch
+func (s *Switch) State() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Active reports whether the incident mode is on
+func (s *Switch) Active() bool {
+	return s.State().Active
+}
+
+// OnChange registers a listener called synchronously, by Enable or
+// Disable, as the switch is flipped
+func (s *Switch) OnChange(listener func(State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Diagnostics returns hooks running for the statements sampled by sampler,
+// and for every statement during an incident
+func (s *Switch) Diagnostics(sampler *sqlutil.Sampler, hooks sqlhooks.Hooks) sqlhooks.Hooks {
+	return sqlhooks.When(func(event *sqlhooks.Event) bool {
+		return s.Active() || sampler.Sample(event.Query)
+	}, hooks)
+}
+
+type lowPriorityKey struct{}
+
+// WithLowPriority returns a context whose statements are shed during an
+// incident
+func WithLowPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lowPriorityKey{}, true)
+}
+
+// LowPriority reports whether the statements run with ctx are of low
+// priority: ctx was returned by WithLowPriority or has the partition.Batch
+// workload
+func LowPriority(ctx context.Context) bool {
+	if low, _ := ctx.Value(lowPriorityKey{}).(bool); low {
+		return true
+	}
+	workload, _ := partition.WorkloadFromContext(ctx)
+	return workload == partition.Batch
+}
+
+// Config configures a Hook
+type Config struct {
+	// LowPriority reports whether the statements run with ctx are shed
+	// during an incident. Defaults to the LowPriority function.
+	LowPriority func(ctx context.Context) bool
+}
+
+// Hook sheds the low priority statements while its Switch is on. The
+// operations other than queries and execs, such as pings or commits, are
+// never shed.
+type Hook struct {
+	sw  *Switch
+	cfg Config
+}
+
+// New returns a Hook shedding statements while sw is on
+func New(sw *Switch, cfg Config) *Hook {
+	if cfg.LowPriority == nil {
+		cfg.LowPriority = LowPriority
+	}
+	return &Hook{sw: sw, cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if !h.sw.Active() || !h.cfg.LowPriority(ctx) {
+		return ctx, nil
+	}
+	if event := sqlhooks.EventFromContext(ctx); event != nil && event.Op != sqlhooks.OpQuery && event.Op != sqlhooks.OpExec {
+		return ctx, nil
+	}
+	return ctx, fmt.Errorf("%w: %s", ErrShed, h.sw.State().Reason)
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return err
+}
@@ -0,0 +1,87 @@
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/hooks/health"
+	"github.com/qustavo/sqlhooks/v2/partition"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the queries it sees
+type recorder struct {
+	queries []string
+}
+
+func (r *recorder) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	r.queries = append(r.queries, query)
+	return ctx, nil
+}
+
+func (r *recorder) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (r *recorder) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return err
+}
+
+func TestIncidentMode(t *testing.T) {
+	sw := NewSwitch()
+	var states []State
+	sw.OnChange(func(s State) { states = append(states, s) })
+	diagnostics := &recorder{}
+
+	driverName := fmt.Sprintf("incident-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlhooks.Compose(
+		New(sw, Config{}),
+		sw.Diagnostics(sqlutil.NewSampler(1, 0), diagnostics),
+	), sqlhooks.WithOps(sqlhooks.OpQuery, sqlhooks.OpExec, sqlhooks.OpPing)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	batch := partition.WithWorkload(ctx, partition.Batch)
+	exec := func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, "SELECT 1")
+		return err
+	}
+
+	require.NoError(t, exec(batch))
+	require.NoError(t, exec(ctx))
+	assert.Equal(t, []string{"SELECT 1"}, diagnostics.queries, "only the first statement is sampled")
+
+	sw.Enable("maintenance")
+	assert.True(t, sw.Active())
+	assert.True(t, errors.Is(exec(batch), ErrShed))
+	assert.True(t, errors.Is(exec(WithLowPriority(ctx)), ErrShed))
+	require.NoError(t, exec(ctx))
+	require.NoError(t, db.PingContext(batch), "pings are not shed")
+	assert.Len(t, diagnostics.queries, 5, "every operation is diagnosed, the shed ones included")
+
+	sw.Enable("ignored")
+	sw.Disable()
+	require.NoError(t, exec(batch))
+	require.Len(t, states, 2)
+	assert.True(t, states[0].Active)
+	assert.Equal(t, "maintenance", states[0].Reason)
+	assert.False(t, states[1].Active)
+}
+
+func TestFollowHealth(t *testing.T) {
+	sw := NewSwitch()
+	sw.FollowHealth(health.Status{Healthy: false, LastErr: errors.New("connection refused")})
+	assert.Equal(t, "database unhealthy: connection refused", sw.State().Reason)
+	sw.FollowHealth(health.Status{Healthy: true})
+	assert.False(t, sw.Active())
+}
@@ -0,0 +1,250 @@
+// Package inlist measures the size of the IN (...) lists of the statements,
+// per fingerprint, and optionally rejects the lists beyond a maximum size:
+// giant IN lists, usually built from the ids of a previous query, make the
+// planning and the execution of a statement slower as they grow, and are
+// better replaced by a join, a temporary table or an array parameter.
+//
+//	h := inlist.New(inlist.Config{MaxSize: 1000})
+//	sql.Register("postgres-inlist", sqlhooks.Wrap(&pq.Driver{}, h))
+//	http.Handle("/metrics/sql-in-lists", h)
+//
+// Statements with a larger list fail with a *TooLargeError, unless their
+// context was returned by Allow. Fingerprints collapse IN lists, so that
+// the statements differing only by the size of their lists share their
+// distribution.
+//
+// Rather than rejecting them, a Splitter runs the SELECT statements with an
+// oversized list as several queries of chunks of the list, merging their
+// rows.
+package inlist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// TooLargeError is returned for the statements with an IN list larger than
+// Config.MaxSize
+type TooLargeError struct {
+	Query string
+	Size  int
+	Max   int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("inlist: IN list of %d values, more than %d", e.Size, e.Max)
+}
+
+// Sizes returns the number of values of the IN lists of query, in order.
+// Subqueries, IN (SELECT ...), are not lists; tuples count as one value.
+func Sizes(query string) []int {
+	var toks []sqlutil.Token
+	for _, tok := range sqlutil.Tokenize(query) {
+		if tok.Type != sqlutil.Space && tok.Type != sqlutil.Comment {
+			toks = append(toks, tok)
+		}
+	}
+	var sizes []int
+	for i := 0; i+2 < len(toks); i++ {
+		if !toks[i].IsKeyword("IN") || toks[i+1].Text != "(" {
+			continue
+		}
+		if toks[i+2].IsKeyword("SELECT") || toks[i+2].IsKeyword("WITH") || toks[i+2].IsKeyword("VALUES") {
+			continue
+		}
+		size, depth := 1, 0
+		for _, tok := range toks[i+2:] {
+			if tok.Type != sqlutil.Punct {
+				continue
+			}
+			if tok.Text == "(" {
+				depth++
+			} else if tok.Text == ")" {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if tok.Text == "," && depth == 0 {
+				size++
+			}
+		}
+		if toks[i+2].Text == ")" {
+			size = 0
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+type allowKey struct{}
+
+// Allow returns a context whose statements are not rejected, whatever the
+// size of their lists
+func Allow(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowKey{}, true)
+}
+
+// Config configures a Hook
+type Config struct {
+	// Buckets are the upper bounds of the size buckets. Defaults to 10,
+	// 100, 1000 and 10000.
+	Buckets []int
+	// MaxSize, if positive, rejects the statements with a larger list.
+	MaxSize int
+	// MaxFingerprints caps the number of fingerprints recorded, the lists
+	// of the others being ignored. Defaults to 1000.
+	MaxFingerprints int
+}
+
+// Distribution is the distribution of the sizes of the IN lists of a
+// fingerprint
+type Distribution struct {
+	Fingerprint string
+	// Query is the normalized statement
+	Query string
+	// Counts are the number of lists per bucket: Counts[i] is the number of
+	// lists of up to Buckets[i] values and more than Buckets[i-1], the last
+	// one counting the lists beyond the last bucket.
+	Buckets []int
+	Counts  []int64
+	// Lists is the number of lists, of Sum values in total.
+	Lists int64
+	Sum   int64
+	Max   int
+	// Rejected is the number of statements rejected.
+	Rejected int64
+}
+
+// Hook records the sizes of the IN lists of every fingerprint, and rejects
+// the lists larger than Config.MaxSize in Before
+type Hook struct {
+	cfg Config
+
+	mu    sync.Mutex
+	dists map[string]*Distribution
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = []int{10, 100, 1000, 10000}
+	}
+	if cfg.MaxFingerprints <= 0 {
+		cfg.MaxFingerprints = 1000
+	}
+	return &Hook{cfg: cfg, dists: make(map[string]*Distribution)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	sizes := Sizes(query)
+	if len(sizes) == 0 {
+		return ctx, nil
+	}
+	var fp string
+	if event := sqlhooks.EventFromContext(ctx); event != nil && event.Query == query {
+		fp = event.Fingerprint()
+	} else {
+		fp = sqlutil.Fingerprint(query)
+	}
+	largest := 0
+	for _, size := range sizes {
+		if size > largest {
+			largest = size
+		}
+	}
+	rejected := h.cfg.MaxSize > 0 && largest > h.cfg.MaxSize && ctx.Value(allowKey{}) == nil
+	h.observe(query, fp, sizes, rejected)
+	if rejected {
+		return ctx, &TooLargeError{Query: query, Size: largest, Max: h.cfg.MaxSize}
+	}
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) observe(query, fp string, sizes []int, rejected bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	d, ok := h.dists[fp]
+	if !ok {
+		if len(h.dists) >= h.cfg.MaxFingerprints {
+			return
+		}
+		d = &Distribution{
+			Fingerprint: fp,
+			Query:       sqlutil.Normalize(query),
+			Buckets:     h.cfg.Buckets,
+			Counts:      make([]int64, len(h.cfg.Buckets)+1),
+		}
+		h.dists[fp] = d
+	}
+	for _, size := range sizes {
+		d.Counts[sort.SearchInts(d.Buckets, size)]++
+		d.Lists++
+		d.Sum += int64(size)
+		if size > d.Max {
+			d.Max = size
+		}
+	}
+	if rejected {
+		d.Rejected++
+	}
+}
+
+// Distributions returns the distributions of the fingerprints, the largest
+// lists first
+func (h *Hook) Distributions() []Distribution {
+	h.mu.Lock()
+	dists := make([]Distribution, 0, len(h.dists))
+	for _, d := range h.dists {
+		c := *d
+		c.Counts = append([]int64(nil), d.Counts...)
+		dists = append(dists, c)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(dists, func(i, j int) bool {
+		if dists[i].Max != dists[j].Max {
+			return dists[i].Max > dists[j].Max
+		}
+		return dists[i].Fingerprint < dists[j].Fingerprint
+	})
+	return dists
+}
+
+// ServeHTTP writes the distributions as Prometheus histograms, in the text
+// format
+func (h *Hook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dists := h.Distributions()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var b strings.Builder
+	b.WriteString("# HELP sql_in_list_size Values of the IN lists of the statements.\n")
+	b.WriteString("# TYPE sql_in_list_size histogram\n")
+	for _, d := range dists {
+		var count int64
+		for i, bound := range d.Buckets {
+			count += d.Counts[i]
+			fmt.Fprintf(&b, "sql_in_list_size_bucket{fingerprint=\"%s\",le=\"%d\"} %d\n", d.Fingerprint, bound, count)
+		}
+		fmt.Fprintf(&b, "sql_in_list_size_bucket{fingerprint=\"%s\",le=\"+Inf\"} %d\n", d.Fingerprint, d.Lists)
+		fmt.Fprintf(&b, "sql_in_list_size_sum{fingerprint=\"%s\"} %d\n", d.Fingerprint, d.Sum)
+		fmt.Fprintf(&b, "sql_in_list_size_count{fingerprint=\"%s\"} %d\n", d.Fingerprint, d.Lists)
+	}
+	b.WriteString("# HELP sql_in_list_rejected_total Statements rejected for an IN list larger than the maximum.\n")
+	b.WriteString("# TYPE sql_in_list_rejected_total counter\n")
+	for _, d := range dists {
+		fmt.Fprintf(&b, "sql_in_list_rejected_total{fingerprint=\"%s\"} %d\n", d.Fingerprint, d.Rejected)
+	}
+	_, _ = w.Write([]byte(b.String()))
+}
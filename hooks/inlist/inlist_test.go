@@ -0,0 +1,84 @@
+package inlist
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizes(t *testing.T) {
+	for query, sizes := range map[string][]int{
+		"SELECT * FROM t WHERE id IN (1, 2, 3)":                              {3},
+		"SELECT * FROM t WHERE id IN (?) AND name NOT IN ('a', 'b')":         {1, 2},
+		"SELECT * FROM t WHERE (a, b) IN ((1, 2), (3, 4), (5, 6))":           {3},
+		"SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE x IN ($1, $2))": {2},
+		"SELECT * FROM t WHERE id IN ()":                                     {0},
+		"SELECT * FROM t WHERE f(a, b) = 1 -- IN (1, 2)":                     nil,
+	} {
+		assert.Equal(t, sizes, Sizes(query), query)
+	}
+}
+
+func TestHook(t *testing.T) {
+	h := New(Config{Buckets: []int{2, 4}, MaxSize: 4})
+	driverName := fmt.Sprintf("inlist-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	list := func(n int) string {
+		return "SELECT id FROM t WHERE id IN (" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+	}
+	args := func(n int) []interface{} {
+		args := make([]interface{}, n)
+		for i := range args {
+			args[i] = i
+		}
+		return args
+	}
+	for _, n := range []int{1, 3, 4} {
+		rows, err := db.Query(list(n), args(n)...)
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+	}
+
+	_, err = db.Query(list(5), args(5)...)
+	var tooLarge *TooLargeError
+	require.True(t, errors.As(err, &tooLarge), "%v", err)
+	assert.Equal(t, 5, tooLarge.Size)
+	assert.Equal(t, 4, tooLarge.Max)
+
+	rows, err := db.QueryContext(Allow(context.Background()), list(6), args(6)...)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	dists := h.Distributions()
+	require.Len(t, dists, 1, "the fingerprints collapse the lists")
+	d := dists[0]
+	assert.Equal(t, "SELECT id FROM t WHERE id IN (?)", d.Query)
+	assert.Equal(t, []int64{1, 2, 2}, d.Counts)
+	assert.Equal(t, int64(5), d.Lists)
+	assert.Equal(t, int64(19), d.Sum)
+	assert.Equal(t, 6, d.Max)
+	assert.Equal(t, int64(1), d.Rejected)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, fmt.Sprintf(`sql_in_list_size_bucket{fingerprint="%s",le="4"} 3`, d.Fingerprint))
+	assert.Contains(t, body, fmt.Sprintf(`sql_in_list_size_bucket{fingerprint="%s",le="+Inf"} 5`, d.Fingerprint))
+	assert.Contains(t, body, fmt.Sprintf(`sql_in_list_rejected_total{fingerprint="%s"} 1`, d.Fingerprint))
+}
@@ -0,0 +1,294 @@
+package inlist
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// SplitConfig configures a Splitter
+type SplitConfig struct {
+	// ChunkSize is the maximum number of values of the IN list of a chunk.
+	// Defaults to 1000.
+	ChunkSize int
+}
+
+// Splitter is a sqlhooks.Interceptor splitting the SELECT statements with an
+// IN list of more than SplitConfig.ChunkSize values into queries of chunks of
+// the list, run one after the other on the same connection as the rows are
+// read, and merged into one driver.Rows:
+//
+//	sql.Register("postgres-split", sqlhooks.Wrap(&pq.Driver{}, inlist.NewSplitter(inlist.SplitConfig{ChunkSize: 500})))
+//
+// Only the statements whose rows are the union of the rows of the chunks
+// are split: the list is the only oversized one, in the outermost query,
+// made of literals or ? placeholders, and the query has no OR, NOT IN,
+// DISTINCT, GROUP BY, ORDER BY, LIMIT, OFFSET, FETCH, set operation or
+// aggregate at its top level. Duplicate values are removed so that no row is
+// returned twice. Outside of a transaction the chunks do not read a
+// consistent snapshot. A Hook rejecting lists larger than its
+// Config.MaxSize rejects them before they are split.
+type Splitter struct {
+	cfg SplitConfig
+}
+
+// NewSplitter returns a new Splitter
+func NewSplitter(cfg SplitConfig) *Splitter {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1000
+	}
+	return &Splitter{cfg: cfg}
+}
+
+func (s *Splitter) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *Splitter) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *Splitter) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return next(ctx, query, args)
+}
+
+func (s *Splitter) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	chunks := s.split(query, args)
+	if chunks == nil {
+		return next(ctx, query, args)
+	}
+	rows, err := next(ctx, chunks[0].query, chunks[0].args)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkedRows{ctx: ctx, next: next, chunks: chunks, cur: rows, columns: rows.Columns()}, nil
+}
+
+type chunk struct {
+	query string
+	args  []driver.NamedValue
+}
+
+// unsplittable are the keywords of the top level of a query whose rows are
+// not the union of the rows of its chunks
+var unsplittable = map[string]bool{
+	"OR": true, "NOT": true, "DISTINCT": true, "GROUP": true, "HAVING": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "UNION": true,
+	"INTERSECT": true, "EXCEPT": true, "COUNT": true, "SUM": true, "AVG": true,
+	"MIN": true, "MAX": true, "WINDOW": true, "OVER": true,
+}
+
+// split returns the chunks of query, nil if it is not split
+func (s *Splitter) split(query string, args []driver.NamedValue) []chunk {
+	if sqlutil.Classify(query) != sqlutil.Select {
+		return nil
+	}
+	for _, arg := range args {
+		if arg.Name != "" {
+			return nil
+		}
+	}
+	tokens := sqlutil.Tokenize(query)
+
+	// Find the oversized list: open and close are the indexes of its
+	// parentheses, items those of its values, first the index of the
+	// argument of its first placeholder
+	var (
+		open, close = -1, -1
+		items       []int
+		first       int
+		depth       int
+		placeholder int
+		prev        int
+	)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.Type {
+		case sqlutil.Space, sqlutil.Comment:
+			continue
+		case sqlutil.Placeholder:
+			if tok.Text != "?" {
+				return nil
+			}
+			placeholder++
+		case sqlutil.Word:
+			if depth == 0 && unsplittable[strings.ToUpper(tok.Text)] {
+				return nil
+			}
+		case sqlutil.Punct:
+			switch tok.Text {
+			case "(":
+				if depth == 0 && tokens[prev].IsKeyword("IN") {
+					list, end, ok := listItems(tokens, i)
+					if ok && len(list) > s.cfg.ChunkSize {
+						if open >= 0 {
+							// Only one list can be split
+							return nil
+						}
+						open, close, items, first = i, end, list, placeholder
+					}
+				}
+				depth++
+			case ")":
+				depth--
+			}
+		}
+		prev = i
+	}
+	if open < 0 {
+		return nil
+	}
+
+	// Values, without duplicates
+	placeholders := tokens[items[0]].Type == sqlutil.Placeholder
+	seen := make(map[string]bool, len(items))
+	var values []int
+	for n, i := range items {
+		var key string
+		if placeholders {
+			if first+n >= len(args) {
+				return nil
+			}
+			key = valueKey(args[first+n].Value)
+		} else {
+			key = tokens[i].Text
+		}
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, n)
+		}
+	}
+
+	var prefix, suffix strings.Builder
+	for _, tok := range tokens[:open+1] {
+		prefix.WriteString(tok.Text)
+	}
+	for _, tok := range tokens[close:] {
+		suffix.WriteString(tok.Text)
+	}
+	var chunks []chunk
+	for start := 0; start < len(values); start += s.cfg.ChunkSize {
+		end := start + s.cfg.ChunkSize
+		if end > len(values) {
+			end = len(values)
+		}
+		var b strings.Builder
+		b.WriteString(prefix.String())
+		c := chunk{}
+		if placeholders {
+			c.args = append(c.args, args[:first]...)
+		}
+		for k, n := range values[start:end] {
+			if k > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(tokens[items[n]].Text)
+			if placeholders {
+				c.args = append(c.args, args[first+n])
+			}
+		}
+		b.WriteString(suffix.String())
+		c.query = b.String()
+		if placeholders {
+			c.args = append(c.args, args[first+len(items):]...)
+		} else {
+			c.args = append(c.args, args...)
+		}
+		for k := range c.args {
+			c.args[k].Ordinal = k + 1
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+// listItems returns the indexes of the values of the list opening at open,
+// and the index of its closing parenthesis. It reports false unless every
+// value is a single literal or ? placeholder, all of the same type.
+func listItems(tokens []sqlutil.Token, open int) ([]int, int, bool) {
+	var items []int
+	expectValue := true
+	for i := open + 1; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.Type == sqlutil.Space || tok.Type == sqlutil.Comment:
+		case expectValue && (tok.Type == sqlutil.Number || tok.Type == sqlutil.String || tok.Text == "?"):
+			if len(items) > 0 && (tok.Type == sqlutil.Placeholder) != (tokens[items[0]].Type == sqlutil.Placeholder) {
+				return nil, 0, false
+			}
+			items = append(items, i)
+			expectValue = false
+		case !expectValue && tok.Text == ",":
+			expectValue = true
+		case !expectValue && tok.Text == ")":
+			return items, i, true
+		default:
+			return nil, 0, false
+		}
+	}
+	return nil, 0, false
+}
+
+// valueKey identifies a driver.Value
+func valueKey(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return "[]byte:" + string(b)
+	}
+	return fmt.Sprintf("%T:%v", v, v)
+}
+
+// chunkedRows reads the rows of the chunks one after the other, querying
+// the next chunk once the rows of the previous one are read and closed
+type chunkedRows struct {
+	ctx     context.Context
+	next    sqlhooks.QueryFunc
+	chunks  []chunk
+	i       int
+	cur     driver.Rows
+	columns []string
+}
+
+func (r *chunkedRows) Columns() []string { return r.columns }
+
+func (r *chunkedRows) Next(dest []driver.Value) error {
+	for {
+		if r.cur == nil {
+			return io.EOF
+		}
+		err := r.cur.Next(dest)
+		if err != io.EOF {
+			return err
+		}
+		err = r.cur.Close()
+		r.cur = nil
+		if err != nil {
+			return err
+		}
+		r.i++
+		if r.i == len(r.chunks) {
+			return io.EOF
+		}
+		rows, err := r.next(r.ctx, r.chunks[r.i].query, r.chunks[r.i].args)
+		if err != nil {
+			return err
+		}
+		r.cur = rows
+		if n := len(rows.Columns()); n != len(r.columns) {
+			return fmt.Errorf("inlist: chunk %d returned %d columns, want %d", r.i, n, len(r.columns))
+		}
+	}
+}
+
+func (r *chunkedRows) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
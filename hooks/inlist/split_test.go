@@ -0,0 +1,82 @@
+package inlist
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitter(t *testing.T) {
+	var queries []string
+	recorder := &sqlhooks.Interceptors{Query: func(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+		queries = append(queries, query)
+		return next(ctx, query, args)
+	}}
+	driverName := fmt.Sprintf("inlist-split-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlhooks.Compose(NewSplitter(SplitConfig{ChunkSize: 3}), recorder)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t (id INTEGER, name TEXT)")
+	require.NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err = db.Exec("INSERT INTO t VALUES (?, ?)", i, fmt.Sprint("n", i))
+		require.NoError(t, err)
+	}
+
+	read := func(query string, args ...interface{}) []string {
+		queries = nil
+		rows, err := db.Query(query, args...)
+		require.NoError(t, err)
+		defer rows.Close()
+		cols, err := rows.Columns()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"name", "id"}, cols)
+		var names []string
+		for rows.Next() {
+			var name string
+			var id int
+			require.NoError(t, rows.Scan(&name, &id))
+			names = append(names, name)
+		}
+		require.NoError(t, rows.Err())
+		return names
+	}
+
+	// Duplicates are removed, the other arguments kept around the list
+	names := read("SELECT name, id FROM t WHERE name != ? AND id IN (?, ?, ?, ?, ?, ?, ?) AND id < ?", "n2", 1, 2, 3, 3, 4, 5, 9, 9)
+	assert.Equal(t, []string{"n1", "n3", "n4", "n5"}, names)
+	assert.Equal(t, []string{
+		"SELECT name, id FROM t WHERE name != ? AND id IN (?, ?, ?) AND id < ?",
+		"SELECT name, id FROM t WHERE name != ? AND id IN (?, ?, ?) AND id < ?",
+	}, queries)
+
+	names = read("SELECT name, id FROM t WHERE id IN (1, 2, 3, 4, 5)")
+	assert.Equal(t, []string{"n1", "n2", "n3", "n4", "n5"}, names)
+	assert.Equal(t, []string{
+		"SELECT name, id FROM t WHERE id IN (1, 2, 3)",
+		"SELECT name, id FROM t WHERE id IN (4, 5)",
+	}, queries)
+
+	// Queries whose rows are not the union of the chunks are left alone
+	for _, query := range []string{
+		"SELECT name, id FROM t WHERE id IN (1, 2, 3, 4) ORDER BY id",
+		"SELECT name, id FROM t WHERE id IN (1, 2, 3, 4) OR id = 5",
+		"SELECT name, id FROM t WHERE id NOT IN (1, 2, 3, 4)",
+		"SELECT name, id FROM t WHERE id IN (1, 2, 3, id + 1)",
+		"SELECT name, id FROM t WHERE id IN (1, 2, 3)",
+	} {
+		read(query)
+		assert.Equal(t, []string{query}, queries)
+	}
+}
@@ -0,0 +1,122 @@
+// Package jitter delays the completion of queries by random amounts, so that
+// concurrent queries complete in varying orders. It is meant for integration
+// tests, to shake out application code assuming that queries complete in the
+// order they were issued, or cache code assuming that the first query to
+// miss is the first one to fill the cache.
+//
+//	h := jitter.New(jitter.Config{
+//		MaxDelay:     50 * time.Millisecond,
+//		Fingerprints: []string{sqlutil.Fingerprint("SELECT * FROM products WHERE id = ?")},
+//	})
+//	sql.Register("sqlite3-jitter", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+//
+// Runs are reproducible, up to the scheduling of goroutines, with a fixed
+// Config.Seed.
+package jitter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Config configures a Hook
+type Config struct {
+	// MaxDelay is the upper bound of the delays, which are uniformly
+	// distributed. Defaults to 10ms.
+	MaxDelay time.Duration
+	// Probability is the probability that a matching query is delayed.
+	// Defaults to 1.
+	Probability float64
+	// Fingerprints are the sqlutil.Fingerprint of the queries to delay.
+	// All the queries are delayed if empty.
+	Fingerprints []string
+	// Seed seeds the random delays. Defaults to the current time.
+	Seed int64
+}
+
+// Hook is a sqlhooks.Hooks delaying the completion of queries
+type Hook struct {
+	cfg          Config
+	fingerprints map[string]bool
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 10 * time.Millisecond
+	}
+	if cfg.Probability <= 0 {
+		cfg.Probability = 1
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	h := &Hook{cfg: cfg, rand: rand.New(rand.NewSource(cfg.Seed))}
+	if len(cfg.Fingerprints) > 0 {
+		h.fingerprints = make(map[string]bool, len(cfg.Fingerprints))
+		for _, fp := range cfg.Fingerprints {
+			h.fingerprints[fp] = true
+		}
+	}
+	return h
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+// After delays the completion of the matching queries
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.wait(ctx, query)
+	return ctx, nil
+}
+
+// OnError delays the failures of the matching queries
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.wait(ctx, query)
+	return err
+}
+
+// wait sleeps for a random delay if query matches, or until ctx is done
+func (h *Hook) wait(ctx context.Context, query string) {
+	delay := h.delay(ctx, query)
+	if delay <= 0 {
+		return
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// delay returns the delay of query, zero if it is not delayed
+func (h *Hook) delay(ctx context.Context, query string) time.Duration {
+	if h.fingerprints != nil {
+		var fp string
+		if event := sqlhooks.EventFromContext(ctx); event != nil && event.Query == query {
+			fp = event.Fingerprint()
+		} else {
+			fp = sqlutil.Fingerprint(query)
+		}
+		if !h.fingerprints[fp] {
+			return 0
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cfg.Probability < 1 && h.rand.Float64() >= h.cfg.Probability {
+		return 0
+	}
+	return time.Duration(h.rand.Int63n(int64(h.cfg.MaxDelay)))
+}
@@ -0,0 +1,73 @@
+package jitter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelay(t *testing.T) {
+	ctx := context.Background()
+	a := New(Config{MaxDelay: time.Second, Seed: 42})
+	b := New(Config{MaxDelay: time.Second, Seed: 42})
+	for i := 0; i < 10; i++ {
+		d := a.delay(ctx, "SELECT 1")
+		assert.True(t, d >= 0 && d < time.Second)
+		assert.Equal(t, d, b.delay(ctx, "SELECT 1"), "same seed, same delays")
+	}
+
+	h := New(Config{
+		MaxDelay:     time.Second,
+		Fingerprints: []string{sqlutil.Fingerprint("SELECT * FROM t WHERE id = ?")},
+		Seed:         1,
+	})
+	assert.Zero(t, h.delay(ctx, "SELECT * FROM u WHERE id = 1"))
+	var delayed int
+	for i := 0; i < 10; i++ {
+		if h.delay(ctx, fmt.Sprintf("SELECT * FROM t WHERE id = %d", i)) > 0 {
+			delayed++
+		}
+	}
+	assert.Equal(t, 10, delayed)
+
+	h = New(Config{MaxDelay: time.Second, Probability: 0.5, Seed: 1})
+	delayed = 0
+	for i := 0; i < 1000; i++ {
+		if h.delay(ctx, "SELECT 1") > 0 {
+			delayed++
+		}
+	}
+	assert.InDelta(t, 500, delayed, 100)
+}
+
+func TestHook(t *testing.T) {
+	h := New(Config{
+		MaxDelay:     time.Hour,
+		Fingerprints: []string{sqlutil.Fingerprint("SELECT 1")},
+	})
+	driverName := fmt.Sprintf("jitter-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1, 2")
+	require.NoError(t, err)
+
+	// The delay stops with the context, the query still succeeds
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+}
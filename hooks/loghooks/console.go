@@ -0,0 +1,195 @@
+package loghooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// ColorMode tells whether a Console colors its output
+type ColorMode int
+
+const (
+	// ColorAuto colors the output if it is a terminal and the NO_COLOR
+	// environment variable is not set
+	ColorAuto ColorMode = iota
+	// ColorAlways always colors the output
+	ColorAlways
+	// ColorNever never colors the output
+	ColorNever
+)
+
+// ConsoleOptions configures a Console
+type ConsoleOptions struct {
+	// Out defaults to os.Stderr.
+	Out io.Writer
+	// Color defaults to ColorAuto.
+	Color ColorMode
+	// Slow highlights the statements taking at least Slow. Defaults to
+	// 100ms.
+	Slow time.Duration
+	// Format prints the statements on several lines, formatted with
+	// sqlutil.Format.
+	Format bool
+}
+
+// Console is a hook for local development: it pretty-prints every statement
+// with its arguments and duration, highlighting keywords, literals, slow
+// statements and errors.
+//
+//	sql.Register("sqlite3-dev", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, loghooks.NewConsole(loghooks.ConsoleOptions{})))
+type Console struct {
+	out    io.Writer
+	color  bool
+	slow   time.Duration
+	format bool
+	now    func() time.Time
+
+	mu sync.Mutex
+}
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// NewConsole returns a Console configured with opts
+func NewConsole(opts ConsoleOptions) *Console {
+	c := &Console{out: opts.Out, slow: opts.Slow, format: opts.Format, now: time.Now}
+	if c.out == nil {
+		c.out = os.Stderr
+	}
+	if c.slow <= 0 {
+		c.slow = 100 * time.Millisecond
+	}
+	switch opts.Color {
+	case ColorAlways:
+		c.color = true
+	case ColorAuto:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		c.color = !noColor && isTerminal(c.out)
+	}
+	return c
+}
+
+// isTerminal reports whether w is a character device, such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *Console) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return context.WithValue(ctx, &started, start{time: c.now()}), nil
+}
+
+func (c *Console) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	c.print(ctx, nil, query, args)
+	return ctx, nil
+}
+
+func (c *Console) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	c.print(ctx, err, query, args)
+	return err
+}
+
+func (c *Console) print(ctx context.Context, err error, query string, args []interface{}) {
+	var took time.Duration
+	if s, ok := ctx.Value(&started).(start); ok {
+		took = c.now().Sub(s.time)
+	}
+
+	var b strings.Builder
+	durationColor := ansiGreen
+	if took >= c.slow {
+		durationColor = ansiYellow + ansiBold
+	}
+	header := fmt.Sprintf("[%9s] ", took.Round(time.Microsecond))
+	c.paint(&b, durationColor, header[:len(header)-1])
+	b.WriteByte(' ')
+	query = strings.TrimSpace(query)
+	if c.format {
+		// Align the lines of the statement after the duration, whose
+		// width is counted in runes as fmt pads it, "µs" being 3 bytes
+		query = strings.Replace(sqlutil.Format(query), "\n", "\n"+strings.Repeat(" ", utf8.RuneCountInString(header)), -1)
+	}
+	c.highlight(&b, query)
+	if len(args) > 0 {
+		b.WriteByte(' ')
+		c.paint(&b, ansiDim, formatArgs(args))
+	}
+	if err != nil {
+		b.WriteByte(' ')
+		c.paint(&b, ansiRed+ansiBold, "error: "+err.Error())
+	}
+	b.WriteByte('\n')
+
+	c.mu.Lock()
+	_, _ = io.WriteString(c.out, b.String())
+	c.mu.Unlock()
+}
+
+// formatArgs formats args as a list, quoting strings and byte slices
+func formatArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		switch arg.(type) {
+		case string, []byte:
+			parts[i] = fmt.Sprintf("%q", arg)
+		default:
+			parts[i] = fmt.Sprintf("%v", arg)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// highlight writes query to b with its tokens colored by type
+func (c *Console) highlight(b *strings.Builder, query string) {
+	if !c.color {
+		b.WriteString(query)
+		return
+	}
+	for _, t := range sqlutil.Tokenize(query) {
+		switch {
+		case sqlutil.IsReserved(t):
+			c.paint(b, ansiBlue+ansiBold, t.Text)
+		case t.Type == sqlutil.String:
+			c.paint(b, ansiGreen, t.Text)
+		case t.Type == sqlutil.Number:
+			c.paint(b, ansiMagenta, t.Text)
+		case t.Type == sqlutil.Placeholder:
+			c.paint(b, ansiCyan, t.Text)
+		case t.Type == sqlutil.Comment:
+			c.paint(b, ansiDim, t.Text)
+		default:
+			b.WriteString(t.Text)
+		}
+	}
+}
+
+func (c *Console) paint(b *strings.Builder, color, text string) {
+	if !c.color {
+		b.WriteString(text)
+		return
+	}
+	b.WriteString(color)
+	b.WriteString(text)
+	b.WriteString(ansiReset)
+}
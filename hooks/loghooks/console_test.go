@@ -0,0 +1,56 @@
+package loghooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsole(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewConsole(ConsoleOptions{Out: &buf})
+
+	ctx, _ := c.Before(context.Background(), "SELECT * FROM t WHERE id = ? AND name = ?", 1, "x")
+	c.After(ctx, "SELECT * FROM t WHERE id = ? AND name = ?", 1, "x")
+	ctx, _ = c.Before(context.Background(), "SELECT nope")
+	c.OnError(ctx, errors.New("no such column"), "SELECT nope")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `] SELECT * FROM t WHERE id = ? AND name = ? [1, "x"]`)
+	assert.True(t, strings.HasSuffix(lines[1], "] SELECT nope error: no such column"))
+	assert.NotContains(t, buf.String(), "\x1b[")
+
+	buf.Reset()
+	c = NewConsole(ConsoleOptions{Out: &buf, Color: ColorAlways})
+	ctx, _ = c.Before(context.Background(), "SELECT 'a'")
+	c.After(ctx, "SELECT 'a'")
+	assert.Contains(t, buf.String(), ansiBlue+ansiBold+"SELECT"+ansiReset+" "+ansiGreen+"'a'"+ansiReset)
+}
+
+func TestConsoleFormat(t *testing.T) {
+	for _, took := range []time.Duration{0, 1500 * time.Nanosecond, 61*time.Second + 234567*time.Microsecond} {
+		var buf bytes.Buffer
+		c := NewConsole(ConsoleOptions{Out: &buf, Format: true})
+		now := time.Now()
+		c.now = func() time.Time { return now }
+
+		ctx, _ := c.Before(context.Background(), "SELECT a FROM t WHERE b = ?", 1)
+		now = now.Add(took)
+		c.After(ctx, "SELECT a FROM t WHERE b = ?", 1)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		// The lines are aligned on the first one in columns, not bytes
+		indent := strings.Repeat(" ", utf8.RuneCountInString(lines[0][:strings.Index(lines[0], "SELECT")]))
+		assert.Equal(t, indent+"FROM t", lines[1], "%s", took)
+		assert.Equal(t, indent+"WHERE b = ? [1]", lines[2], "%s", took)
+	}
+}
@@ -0,0 +1,112 @@
+package loghooks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// dedup rate-limits log lines by query fingerprint and error class
+type dedup struct {
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	entries   map[dedupKey]*dedupEntry
+	lastSweep time.Time
+}
+
+type dedupKey struct {
+	fingerprint string
+	class       string
+}
+
+type dedupEntry struct {
+	query      string // normalized
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+type summary struct {
+	query      string
+	class      string
+	suppressed int
+}
+
+func newDedup(interval time.Duration, burst int) *dedup {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &dedup{
+		interval: interval,
+		burst:    burst,
+		now:      time.Now,
+		entries:  make(map[dedupKey]*dedupEntry),
+	}
+}
+
+// errorClass returns the message of err with its literals replaced, so that
+// errors differing only by their values share a class
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	return sqlutil.Normalize(err.Error())
+}
+
+// allow reports whether a line for query and err may be logged, along with
+// the summaries of the intervals that are over
+func (d *dedup) allow(query string, err error) (bool, []summary) {
+	key := dedupKey{fingerprint: sqlutil.Fingerprint(query), class: errorClass(err)}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var summaries []summary
+	if now.Sub(d.lastSweep) >= d.interval {
+		summaries = d.sweep(now, false)
+		d.lastSweep = now
+	}
+
+	e, ok := d.entries[key]
+	if !ok {
+		e = &dedupEntry{query: sqlutil.Normalize(query), start: now}
+		d.entries[key] = e
+	}
+	if e.logged < d.burst {
+		e.logged++
+		return true, summaries
+	}
+	e.suppressed++
+	return false, summaries
+}
+
+// sweep forgets the entries whose interval is over, or all of them, and
+// returns the summaries of those that suppressed lines. It must be called
+// with d.mu held.
+func (d *dedup) sweep(now time.Time, all bool) []summary {
+	var summaries []summary
+	for key, e := range d.entries {
+		if !all && now.Sub(e.start) < d.interval {
+			continue
+		}
+		if e.suppressed > 0 {
+			summaries = append(summaries, summary{query: e.query, class: key.class, suppressed: e.suppressed})
+		}
+		delete(d.entries, key)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].suppressed > summaries[j].suppressed })
+	return summaries
+}
+
+// flush returns the summaries of all the entries and forgets them
+func (d *dedup) flush() []summary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sweep(d.now(), true)
+}
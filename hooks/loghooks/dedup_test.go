@@ -0,0 +1,63 @@
+package loghooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	lines []string
+}
+
+func (r *recorder) Printf(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestDedup(t *testing.T) {
+	rec := &recorder{}
+	h := NewWithOptions(Options{Logger: rec, DedupInterval: time.Minute, DedupBurst: 2})
+	now := time.Unix(0, 0)
+	h.dedup.now = func() time.Time { return now }
+
+	ctx, _ := h.Before(context.Background(), "")
+	for i := 0; i < 5; i++ {
+		h.OnError(ctx, fmt.Errorf("duplicate key %d", i), fmt.Sprintf("INSERT INTO t VALUES (%d)", i))
+	}
+	h.OnError(ctx, errors.New("timeout"), "INSERT INTO t VALUES (1)")
+	h.After(ctx, "SELECT 1")
+	assert.Len(t, rec.lines, 4)
+
+	// The summary is logged once the interval is over
+	now = now.Add(time.Minute)
+	h.After(ctx, "SELECT 2")
+	assert.Len(t, rec.lines, 6)
+	assert.Equal(t, "Suppressed 3 similar errors: duplicate key ?, Query: `INSERT INTO t VALUES (?)`", rec.lines[4])
+
+	h.After(ctx, "SELECT 3")
+	h.After(ctx, "SELECT 4")
+	h.Flush()
+	assert.Equal(t, "Suppressed 1 similar queries: `SELECT ?`", rec.lines[len(rec.lines)-1])
+}
+
+func TestSampler(t *testing.T) {
+	rec := &recorder{}
+	h := NewWithOptions(Options{Logger: rec, Sampler: sqlutil.NewSampler(1, 3)})
+
+	for i := 0; i < 7; i++ {
+		query := fmt.Sprintf("SELECT %d", i)
+		ctx, _ := h.Before(context.Background(), query)
+		h.After(ctx, query)
+	}
+	assert.Len(t, rec.lines, 3)
+
+	// Errors are logged even if the statement is not sampled
+	ctx, _ := h.Before(context.Background(), "SELECT 8")
+	h.OnError(ctx, errors.New("boom"), "SELECT 8")
+	assert.Len(t, rec.lines, 4)
+}
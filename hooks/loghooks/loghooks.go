@@ -5,34 +5,106 @@ import (
 	"log"
 	"os"
 	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
 )
 
 var started int
 
-type logger interface {
+// Logger is the interface the Hook logs to, satisfied by *log.Logger
+type Logger interface {
 	Printf(string, ...interface{})
 }
 
+// Options configures a Hook built with NewWithOptions
+type Options struct {
+	// Logger defaults to a *log.Logger writing to stderr.
+	Logger Logger
+	// DedupInterval, if positive, rate-limits identical log lines: lines
+	// for the same query fingerprint and error class are logged at most
+	// DedupBurst times per interval, and the number of suppressed lines is
+	// logged once the interval is over.
+	DedupInterval time.Duration
+	// DedupBurst defaults to 1.
+	DedupBurst int
+	// Sampler, if set, decides which statements are logged. Errors are
+	// always logged.
+	Sampler *sqlutil.Sampler
+}
+
 type Hook struct {
-	log logger
+	log     Logger
+	dedup   *dedup
+	sampler *sqlutil.Sampler
+}
+
+// start is stored in the context by Before
+type start struct {
+	time    time.Time
+	sampled bool
 }
 
 func New() *Hook {
-	return &Hook{
-		log: log.New(os.Stderr, "", log.LstdFlags),
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions returns a Hook configured with opts
+func NewWithOptions(opts Options) *Hook {
+	h := &Hook{log: opts.Logger, sampler: opts.Sampler}
+	if h.log == nil {
+		h.log = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if opts.DedupInterval > 0 {
+		h.dedup = newDedup(opts.DedupInterval, opts.DedupBurst)
 	}
+	return h
 }
+
 func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	return context.WithValue(ctx, &started, time.Now()), nil
+	return context.WithValue(ctx, &started, start{time: time.Now(), sampled: h.sampler.Sample(query)}), nil
 }
 
 func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	h.log.Printf("Query: `%s`, Args: `%q`. took: %s", query, args, time.Since(ctx.Value(&started).(time.Time)))
+	s := ctx.Value(&started).(start)
+	if s.sampled && h.allow(query, nil) {
+		h.log.Printf("Query: `%s`, Args: `%q`. took: %s", query, args, time.Since(s.time))
+	}
 	return ctx, nil
 }
 
 func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
-	h.log.Printf("Error: %v, Query: `%s`, Args: `%q`, Took: %s",
-		err, query, args, time.Since(ctx.Value(&started).(time.Time)))
+	if h.allow(query, err) {
+		h.log.Printf("Error: %v, Query: `%s`, Args: `%q`, Took: %s",
+			err, query, args, time.Since(ctx.Value(&started).(start).time))
+	}
 	return err
 }
+
+// allow reports whether the line for query and err should be logged, and
+// logs the summaries of the lines suppressed in the previous intervals
+func (h *Hook) allow(query string, err error) bool {
+	if h.dedup == nil {
+		return true
+	}
+	ok, summaries := h.dedup.allow(query, err)
+	h.logSummaries(summaries)
+	return ok
+}
+
+// Flush logs the summaries of the lines suppressed so far, e.g. before
+// shutting down
+func (h *Hook) Flush() {
+	if h.dedup != nil {
+		h.logSummaries(h.dedup.flush())
+	}
+}
+
+func (h *Hook) logSummaries(summaries []summary) {
+	for _, s := range summaries {
+		if s.class == "" {
+			h.log.Printf("Suppressed %d similar queries: `%s`", s.suppressed, s.query)
+		} else {
+			h.log.Printf("Suppressed %d similar errors: %s, Query: `%s`", s.suppressed, s.class, s.query)
+		}
+	}
+}
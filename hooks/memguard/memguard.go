@@ -0,0 +1,168 @@
+// Package memguard estimates the memory held by the results of the queries
+// as their rows are read, and fails the iteration of the results exceeding
+// a budget, to protect services from running out of memory on unexpectedly
+// large results, such as a query missing its LIMIT:
+//
+//	h := memguard.New(memguard.Config{Budget: 32 << 20})
+//	sql.Register("postgres-memguard", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// rows.Next then returns false past the budget, and rows.Err a
+// *BudgetError. Queries expected to read large results get their own
+// budget:
+//
+//	rows, err := db.QueryContext(memguard.WithBudget(ctx, 1<<30), "SELECT * FROM events")
+//
+// The estimate is the size of the values the driver returned, as held by
+// database/sql: the length of strings and byte slices, the size of the
+// other types, and the interface holding every value. It does not account
+// for what the application keeps from them, nor for the buffers of the
+// driver.
+package memguard
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// BudgetError is the error of the iteration of the rows of a query whose
+// estimated size exceeded its budget
+type BudgetError struct {
+	Query  string
+	Budget int64
+	// Estimated is the estimated size of the rows read, the last one
+	// included, and Rows their number
+	Estimated int64
+	Rows      int64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("memguard: result of %q exceeds its budget of %d bytes after %d rows (%d bytes)", e.Query, e.Budget, e.Rows, e.Estimated)
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context whose queries are limited to budget bytes
+// instead of Config.Budget, zero or a negative budget lifting the limit
+func WithBudget(ctx context.Context, budget int64) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// Config configures a Hook
+type Config struct {
+	// Budget is the estimated size, in bytes, above which the results of
+	// a query are rejected. Defaults to 64 MiB.
+	Budget int64
+	// OnExceeded, if set, is called synchronously with the error of the
+	// queries exceeding their budget, with their context.
+	OnExceeded func(ctx context.Context, err *BudgetError)
+}
+
+// Hook is a sqlhooks.Interceptor estimating the size of the rows of the
+// queries
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Budget <= 0 {
+		cfg.Budget = 64 << 20
+	}
+	return &Hook{cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return err
+}
+
+// InterceptQuery wraps the rows of the query to estimate their size
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := next(ctx, query, args)
+	if err != nil {
+		return rows, err
+	}
+	budget := h.cfg.Budget
+	if b, ok := ctx.Value(budgetKey{}).(int64); ok {
+		budget = b
+	}
+	if budget <= 0 {
+		return rows, nil
+	}
+	return &budgetRows{Rows: sqlhooks.NewRows(rows), ctx: ctx, hook: h, query: query, budget: budget}, nil
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return next(ctx, query, args)
+}
+
+// budgetRows estimates the size of the rows read and fails past the budget
+type budgetRows struct {
+	*sqlhooks.Rows
+	ctx    context.Context
+	hook   *Hook
+	query  string
+	budget int64
+
+	estimated, n int64
+	err          *BudgetError
+}
+
+func (r *budgetRows) Next(dest []driver.Value) error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	r.n++
+	for _, v := range dest {
+		r.estimated += Size(v)
+	}
+	if r.estimated <= r.budget {
+		return nil
+	}
+	r.err = &BudgetError{Query: r.query, Budget: r.budget, Estimated: r.estimated, Rows: r.n}
+	if r.hook.cfg.OnExceeded != nil {
+		r.hook.cfg.OnExceeded(r.ctx, r.err)
+	}
+	return r.err
+}
+
+// interfaceSize is the size of the interface holding every value
+const interfaceSize = 16
+
+var timeSize = int64(reflect.TypeOf(time.Time{}).Size())
+
+// Size returns the estimated size, in bytes, of a value returned by a
+// driver
+func Size(v driver.Value) int64 {
+	switch v := v.(type) {
+	case nil:
+		return interfaceSize
+	case string:
+		return interfaceSize + 16 + int64(len(v))
+	case []byte:
+		return interfaceSize + 24 + int64(cap(v))
+	case time.Time:
+		return interfaceSize + timeSize
+	case int64, float64:
+		return interfaceSize + 8
+	case bool:
+		return interfaceSize + 1
+	default:
+		return interfaceSize + int64(reflect.TypeOf(v).Size())
+	}
+}
@@ -0,0 +1,78 @@
+package memguard
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) *sql.DB {
+	driverName := fmt.Sprintf("memguard-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE t (id INTEGER, v TEXT)")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = db.Exec("INSERT INTO t VALUES (?, ?)", i, strings.Repeat("x", 100))
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func count(ctx context.Context, db *sql.DB) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, v FROM t")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+func TestHook(t *testing.T) {
+	var exceeded []*BudgetError
+	// A row holds over 100 bytes
+	db := open(t, Config{Budget: 500, OnExceeded: func(ctx context.Context, err *BudgetError) {
+		exceeded = append(exceeded, err)
+	}})
+	defer db.Close()
+
+	n, err := count(context.Background(), db)
+	var budgetErr *BudgetError
+	require.True(t, errors.As(err, &budgetErr), "got %v", err)
+	assert.True(t, n > 0 && n < 5, "the rows under the budget are read")
+	assert.Equal(t, int64(n+1), budgetErr.Rows)
+	assert.Equal(t, int64(500), budgetErr.Budget)
+	assert.True(t, budgetErr.Estimated > 500)
+	assert.True(t, budgetErr.Estimated-budgetErr.Estimated/budgetErr.Rows <= 500, "it fails at the first row past the budget")
+	assert.Equal(t, []*BudgetError{budgetErr}, exceeded)
+
+	n, err = count(WithBudget(context.Background(), 1<<20), db)
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	n, err = count(WithBudget(context.Background(), 0), db)
+	require.NoError(t, err, "a zero budget lifts the limit")
+	assert.Equal(t, 10, n)
+}
+
+func TestSize(t *testing.T) {
+	assert.Equal(t, int64(16), Size(nil))
+	assert.Equal(t, int64(16+16+3), Size("abc"))
+	assert.Equal(t, int64(16+24+8), Size(make([]byte, 2, 8)))
+	assert.Equal(t, int64(16+8), Size(1.5))
+	assert.Equal(t, int64(16+1), Size(true))
+}
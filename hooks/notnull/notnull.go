@@ -0,0 +1,332 @@
+// Package notnull rejects the statements binding NULL, or optionally a zero
+// value, to the columns and parameters declared as never NULL, such as a
+// tenant_id, before they reach the database: a cheap safety net against the
+// rows written or read without their tenant when the constraints of the
+// schema are missing or too lax.
+//
+//	h := notnull.New(notnull.Config{Columns: []string{"tenant_id", "invoices.customer_id"}, Zero: true})
+//	sql.Register("postgres-notnull", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(h, hooks)))
+//
+// The values of a column are found in the column list and the VALUES of the
+// INSERT statements, and in the assignments and comparisons of the column to
+// a placeholder or a literal, such as SET tenant_id = ? or WHERE tenant_id =
+// $1. Config.Params declares the named parameters, whatever the statement.
+//
+// Violating statements fail with a *ViolationError, unless their context was
+// returned by Allow.
+package notnull
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// ViolationError is returned for the statements binding NULL or a zero value
+// to a declared column or parameter
+type ViolationError struct {
+	Query string
+	// Column is the column as declared in Config.Columns, or the parameter
+	// as declared in Config.Params
+	Column string
+	// Value is the value bound, nil for NULL
+	Value interface{}
+}
+
+func (e *ViolationError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("notnull: %s is NULL", e.Column)
+	}
+	return fmt.Sprintf("notnull: %s is zero", e.Column)
+}
+
+// Config configures a Hook
+type Config struct {
+	// Columns are the columns that must never be NULL, either "column" for
+	// the columns of every table or "table.column". Names are matched
+	// ignoring case.
+	Columns []string
+	// Params are the named parameters that must never be NULL, as bound
+	// with sql.Named.
+	Params []string
+	// Zero also rejects the zero values, such as 0, "" or the zero time.
+	Zero bool
+}
+
+type column struct {
+	decl  string
+	table string
+	name  string
+}
+
+// Hook checks the values of the declared columns. It is a
+// sqlhooks.ArgsMutator, which leaves the arguments unchanged, to know the
+// names of the arguments.
+type Hook struct {
+	cfg     Config
+	columns []column
+	params  map[string]string
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	h := &Hook{cfg: cfg, params: map[string]string{}}
+	for _, decl := range cfg.Columns {
+		c := column{decl: decl, name: strings.ToLower(decl)}
+		if i := strings.LastIndexByte(c.name, '.'); i >= 0 {
+			c.table, c.name = c.name[:i], c.name[i+1:]
+		}
+		h.columns = append(h.columns, c)
+	}
+	for _, param := range cfg.Params {
+		h.params[strings.TrimLeft(param, ":@$")] = param
+	}
+	return h
+}
+
+type allowKey struct{}
+
+// Allow returns a context in which the statements are not checked, for the
+// rows intentionally written without a value
+func Allow(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowKey{}, true)
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	if allowed, _ := ctx.Value(allowKey{}).(bool); allowed {
+		return ctx, args, nil
+	}
+	for _, arg := range args {
+		if decl, ok := h.params[arg.Name]; ok && arg.Name != "" && h.violates(arg.Value) {
+			return ctx, nil, &ViolationError{Query: query, Column: decl, Value: arg.Value}
+		}
+	}
+	if len(h.columns) == 0 {
+		return ctx, args, nil
+	}
+	s := parse(query)
+	for _, b := range s.bindings {
+		c, ok := h.match(s, b)
+		if !ok {
+			continue
+		}
+		value, ok := b.resolve(args)
+		if ok && h.violates(value) {
+			return ctx, nil, &ViolationError{Query: query, Column: c.decl, Value: value}
+		}
+	}
+	return ctx, args, nil
+}
+
+// match returns the declared column bound by b
+func (h *Hook) match(s *statement, b binding) (column, bool) {
+	for _, c := range h.columns {
+		if c.name != b.column {
+			continue
+		}
+		// Unqualified columns of several tables may be of any of them
+		if c.table == "" || c.table == b.table || b.table == "" && s.tables[c.table] {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+func (h *Hook) violates(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	return h.cfg.Zero && reflect.ValueOf(value).IsZero()
+}
+
+// statement lists the values bound to the columns of a statement
+type statement struct {
+	// tables are the names of the tables of the statement, and aliases
+	// their qualifiers
+	tables   map[string]bool
+	aliases  map[string]string
+	bindings []binding
+}
+
+// binding is a value bound to a column, either a literal or a placeholder
+type binding struct {
+	table  string
+	column string
+	value  sqlutil.Token
+	// arg is the index of the argument of ? and $N placeholders
+	arg int
+}
+
+// resolve returns the value of b, if known
+func (b binding) resolve(args []driver.NamedValue) (interface{}, bool) {
+	switch b.value.Type {
+	case sqlutil.Placeholder:
+		if strings.HasPrefix(b.value.Text, ":") {
+			for _, arg := range args {
+				if arg.Name == b.value.Text[1:] {
+					return arg.Value, true
+				}
+			}
+			return nil, false
+		}
+		if b.arg < 0 || b.arg >= len(args) {
+			return nil, false
+		}
+		return args[b.arg].Value, true
+	case sqlutil.Number:
+		f, err := strconv.ParseFloat(b.value.Text, 64)
+		return f, err == nil
+	case sqlutil.String:
+		if b.value.Text == "''" {
+			return "", true
+		}
+		return b.value.Text, true
+	default:
+		// NULL
+		return nil, true
+	}
+}
+
+func parse(query string) *statement {
+	all := sqlutil.Tokenize(query)
+	s := &statement{tables: map[string]bool{}, aliases: map[string]string{}}
+	into := ""
+	for _, ref := range sqlutil.Tables(all) {
+		name := strings.ToLower(ref.Name)
+		s.tables[name] = true
+		s.aliases[strings.ToLower(ref.Qualifier(all))] = name
+		if ref.Alias != "" {
+			s.aliases[strings.ToLower(ref.Alias)] = name
+		}
+		if ref.Keyword == "INTO" && ref.Depth == 0 {
+			into = name
+		}
+	}
+
+	var toks []sqlutil.Token
+	for _, tok := range all {
+		if tok.Type != sqlutil.Space && tok.Type != sqlutil.Comment {
+			toks = append(toks, tok)
+		}
+	}
+	// The index of the argument of every ? and $N placeholder
+	args, seq := make([]int, len(toks)), 0
+	for i, tok := range toks {
+		args[i] = -1
+		if tok.Type != sqlutil.Placeholder {
+			continue
+		}
+		if tok.Text == "?" {
+			args[i], seq = seq, seq+1
+		} else if n, err := strconv.Atoi(strings.TrimPrefix(tok.Text, "$")); err == nil {
+			args[i] = n - 1
+		}
+	}
+
+	for i, tok := range toks {
+		if tok.IsKeyword("INTO") && into != "" {
+			s.bindings = append(s.bindings, insertBindings(toks, args, i, into)...)
+		}
+		if tok.Type != sqlutil.Punct || tok.Text != "=" || i == 0 || i+1 == len(toks) {
+			continue
+		}
+		if !isName(toks[i-1]) || !isValue(toks[i+1]) || i+2 < len(toks) && continues(toks[i+2]) {
+			continue
+		}
+		b := binding{column: unquote(toks[i-1]), value: toks[i+1], arg: args[i+1]}
+		if i >= 3 && toks[i-2].Text == "." && isName(toks[i-3]) {
+			q := unquote(toks[i-3])
+			if b.table = s.aliases[q]; b.table == "" {
+				b.table = q
+			}
+		}
+		s.bindings = append(s.bindings, b)
+	}
+	return s
+}
+
+// insertBindings returns the values of the VALUES of the INSERT statement
+// whose INTO keyword is toks[i], matched with its column list
+func insertBindings(toks []sqlutil.Token, args []int, i int, table string) []binding {
+	// Skip the table name up to the column list
+	for i++; i < len(toks) && toks[i].Text != "("; i++ {
+		if toks[i].Type == sqlutil.Word && sqlutil.IsClauseKeyword(toks[i]) || toks[i].IsKeyword("VALUES") {
+			return nil
+		}
+	}
+	var columns []string
+	for i++; i < len(toks) && toks[i].Text != ")"; i++ {
+		if isName(toks[i]) {
+			columns = append(columns, unquote(toks[i]))
+		}
+	}
+	if i++; i >= len(toks) || !toks[i].IsKeyword("VALUES") {
+		return nil
+	}
+	var bindings []binding
+	for i++; i < len(toks) && toks[i].Text == "("; i++ {
+		// The values of a tuple start after ( and the commas of its depth,
+		// only the values of a single token being bound
+		n, depth, first := 0, 0, i+1
+		for i++; i < len(toks); i++ {
+			text := toks[i].Text
+			if depth == 0 && (text == "," || text == ")") {
+				if i == first+1 && n < len(columns) && isValue(toks[first]) {
+					bindings = append(bindings, binding{table: table, column: columns[n], value: toks[first], arg: args[first]})
+				}
+				if text == ")" {
+					break
+				}
+				n, first = n+1, i+1
+			} else if text == "(" {
+				depth++
+			} else if text == ")" {
+				depth--
+			}
+		}
+		if i+1 >= len(toks) || toks[i+1].Text != "," {
+			break
+		}
+		i++
+	}
+	return bindings
+}
+
+func isName(t sqlutil.Token) bool {
+	return t.Type == sqlutil.QuotedIdent || t.Type == sqlutil.Word && !sqlutil.IsClauseKeyword(t)
+}
+
+func isValue(t sqlutil.Token) bool {
+	switch t.Type {
+	case sqlutil.Placeholder, sqlutil.Number, sqlutil.String:
+		return true
+	}
+	return t.IsKeyword("NULL")
+}
+
+// continues reports whether t continues the expression of a value, which is
+// then not the value bound to the column
+func continues(t sqlutil.Token) bool {
+	return t.Type == sqlutil.Punct && strings.ContainsAny(t.Text, "+-*/|%:.[")
+}
+
+func unquote(t sqlutil.Token) string {
+	name := t.Text
+	if t.Type == sqlutil.QuotedIdent && len(name) >= 2 {
+		name = name[1 : len(name)-1]
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,81 @@
+package notnull
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindings(t *testing.T) {
+	for query, want := range map[string][]string{
+		"INSERT INTO invoices (tenant_id, total) VALUES (?, ?), ($3, lower(?))": {"invoices.tenant_id=?", "invoices.total=?", "invoices.tenant_id=$3"},
+		"UPDATE invoices i SET total = 0 WHERE i.tenant_id = :tenant":           {".total=0", "invoices.tenant_id=:tenant"},
+		"SELECT * FROM invoices WHERE tenant_id = ? + 1 AND total = NULL":       {".total=NULL"},
+		`DELETE FROM "invoices" WHERE "tenant_id" = $1::int`:                    nil,
+	} {
+		var got []string
+		for _, b := range parse(query).bindings {
+			got = append(got, b.table+"."+b.column+"="+b.value.Text)
+		}
+		assert.Equal(t, want, got, query)
+	}
+}
+
+func open(t *testing.T, cfg Config) *sql.DB {
+	driverName := fmt.Sprintf("notnull-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE invoices (tenant_id INTEGER, customer_id INTEGER, total INTEGER)")
+	require.NoError(t, err)
+	return db
+}
+
+func TestHook(t *testing.T) {
+	db := open(t, Config{Columns: []string{"tenant_id", "invoices.customer_id"}, Params: []string{":tenant"}})
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO invoices (tenant_id, customer_id, total) VALUES (?, ?, ?)", 1, 2, nil)
+	require.NoError(t, err)
+
+	_, err = db.Exec("INSERT INTO invoices (tenant_id, customer_id) VALUES (?, ?), (?, ?)", 1, 2, nil, 2)
+	require.IsType(t, &ViolationError{}, err)
+	assert.EqualError(t, err, "notnull: tenant_id is NULL")
+
+	_, err = db.Exec("UPDATE invoices SET customer_id = NULL WHERE tenant_id = 1")
+	assert.EqualError(t, err, "notnull: invoices.customer_id is NULL")
+
+	_, err = db.Query("SELECT * FROM invoices i WHERE i.tenant_id = $1", nil)
+	assert.EqualError(t, err, "notnull: tenant_id is NULL")
+
+	_, err = db.Query("SELECT * FROM invoices WHERE total > :tenant", sql.Named("tenant", nil))
+	assert.EqualError(t, err, "notnull: :tenant is NULL")
+
+	// Zero values are allowed unless Config.Zero is set
+	_, err = db.Exec("UPDATE invoices SET total = 0 WHERE tenant_id = ?", 0)
+	assert.NoError(t, err)
+
+	_, err = db.ExecContext(Allow(context.Background()), "UPDATE invoices SET tenant_id = NULL")
+	assert.NoError(t, err)
+}
+
+func TestHookZero(t *testing.T) {
+	db := open(t, Config{Columns: []string{"tenant_id"}, Zero: true})
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM invoices WHERE tenant_id = ?", 0)
+	assert.EqualError(t, err, "notnull: tenant_id is zero")
+	_, err = db.Exec("DELETE FROM invoices WHERE tenant_id = 0")
+	assert.EqualError(t, err, "notnull: tenant_id is zero")
+	_, err = db.Exec("DELETE FROM invoices WHERE tenant_id = ''")
+	assert.EqualError(t, err, "notnull: tenant_id is zero")
+	_, err = db.Exec("DELETE FROM invoices WHERE tenant_id = ?", 3)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,189 @@
+// Package otelhooks traces the statements run through sqlhooks with
+// OpenTelemetry, following the semantic conventions of database client
+// spans:
+//
+//	h := otelhooks.New(otelhooks.Config{})
+//	sql.Register("postgres-otel", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// Every statement gets a client span, a child of the span in its context if
+// any, named after its operation and carrying the db.system, db.statement and
+// db.operation attributes, along with the type and version of the wrapped
+// driver and the version of sqlhooks. Errors are recorded on the span, whose
+// status is then set to codes.Error. The arguments of the statements are
+// never recorded.
+package otelhooks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer the spans are created with
+const InstrumentationName = "github.com/qustavo/sqlhooks/v2/hooks/otelhooks"
+
+// Attribute keys of the semantic conventions for database client spans
+const (
+	DBSystem    = attribute.Key("db.system")
+	DBStatement = attribute.Key("db.statement")
+	DBOperation = attribute.Key("db.operation")
+)
+
+// Attribute keys describing the wrapped driver, see sqlhooks.DriverInfo
+const (
+	DBDriverName    = attribute.Key("db.driver.name")
+	DBDriverVersion = attribute.Key("db.driver.version")
+	SQLHooksVersion = attribute.Key("sqlhooks.version")
+)
+
+// systems maps the names of the sqlutil dialects to the values of db.system
+var systems = map[string]string{
+	"postgres":  "postgresql",
+	"mysql":     "mysql",
+	"sqlite":    "sqlite",
+	"sqlserver": "mssql",
+}
+
+// Config configures a Hook
+type Config struct {
+	// TracerProvider provides the tracer of the spans. Defaults to the
+	// global provider, otel.GetTracerProvider().
+	TracerProvider trace.TracerProvider
+	// System is the value of db.system. Defaults to the value matching the
+	// dialect of the wrapped driver, see sqlhooks.WithDialect; db.system is
+	// left out when both are unknown.
+	System string
+	// Attributes are added to every span, such as db.name or net.peer.name.
+	Attributes []attribute.KeyValue
+	// Filter, if set, decides which attributes are set on the spans, those
+	// for which it returns false being dropped. It can for instance drop
+	// db.statement where queries may embed sensitive literals.
+	Filter func(attribute.KeyValue) bool
+	// Sampler, if set, decides which statements are traced. Statements
+	// that are not sampled are run without a span.
+	Sampler *sqlutil.Sampler
+}
+
+// spanKey holds the span started by Before, which must not be mistaken for
+// the parent span when the statement is not traced
+type spanKey struct{}
+
+// Hook is a sqlhooks.Hooks tracing every statement
+type Hook struct {
+	cfg    Config
+	tracer trace.Tracer
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	return &Hook{cfg: cfg, tracer: cfg.TracerProvider.Tracer(InstrumentationName)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if !h.cfg.Sampler.Sample(query) {
+		return ctx, nil
+	}
+
+	event := sqlhooks.EventFromContext(ctx)
+	op := operation(event, query)
+	name := op
+	if name == "" {
+		name = "sql"
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(h.cfg.Attributes)+6)
+	if system := h.system(event); system != "" {
+		attrs = append(attrs, DBSystem.String(system))
+	}
+	if query != "" {
+		attrs = append(attrs, DBStatement.String(query))
+	}
+	if op != "" {
+		attrs = append(attrs, DBOperation.String(op))
+	}
+	if event != nil && event.Driver != nil {
+		attrs = append(attrs, DBDriverName.String(event.Driver.Name))
+		if event.Driver.Version != "" {
+			attrs = append(attrs, DBDriverVersion.String(event.Driver.Version))
+		}
+		if event.Driver.SQLHooksVersion != "" {
+			attrs = append(attrs, SQLHooksVersion.String(event.Driver.SQLHooksVersion))
+		}
+	}
+	attrs = append(attrs, h.cfg.Attributes...)
+	if h.cfg.Filter != nil {
+		kept := attrs[:0]
+		for _, attr := range attrs {
+			if h.cfg.Filter(attr) {
+				kept = append(kept, attr)
+			}
+		}
+		attrs = kept
+	}
+
+	ctx, span := h.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	span, _ := ctx.Value(spanKey{}).(trace.Span)
+	if span != nil {
+		span.End()
+	}
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	span, _ := ctx.Value(spanKey{}).(trace.Span)
+	if span != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+	}
+	return err
+}
+
+// system returns the value of db.system for event
+func (h *Hook) system(event *sqlhooks.Event) string {
+	if h.cfg.System != "" {
+		return h.cfg.System
+	}
+	if event == nil || event.Dialect == nil {
+		return ""
+	}
+	if system, ok := systems[event.Dialect.Name()]; ok {
+		return system
+	}
+	return event.Dialect.Name()
+}
+
+// operation returns the value of db.operation: the kind of the statement for
+// reads and writes, its leading keyword otherwise, and the name of the
+// driver operation for those without a query such as pings
+func operation(event *sqlhooks.Event, query string) string {
+	switch kind := sqlutil.Classify(query); kind {
+	case sqlutil.Select, sqlutil.Insert, sqlutil.Update, sqlutil.Delete:
+		return kind.String()
+	}
+	for _, t := range sqlutil.Tokenize(query) {
+		if t.Type == sqlutil.Word {
+			return strings.ToUpper(t.Text)
+		}
+	}
+	if event != nil && event.Op != sqlhooks.OpUnknown {
+		return strings.ToUpper(event.Op.String())
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package otelhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func open(t *testing.T, cfg Config) (*sql.DB, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	cfg.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	driverName := fmt.Sprintf("otel-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db, recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := make(map[attribute.Key]string)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value.Emit()
+	}
+	return attrs
+}
+
+func TestSpans(t *testing.T) {
+	db, recorder := open(t, Config{
+		Attributes: []attribute.KeyValue{attribute.String("db.name", "main")},
+	})
+	defer db.Close()
+
+	root := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceFlags: trace.FlagsSampled})
+	parent := trace.ContextWithRemoteSpanContext(context.Background(), root)
+
+	_, err := db.ExecContext(parent, "CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(parent, "SELECT id FROM t WHERE id = ?", 1)
+	require.NoError(t, err)
+	rows.Close()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "CREATE", spans[0].Name())
+	assert.Equal(t, "SELECT", spans[1].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[1].SpanKind())
+	assert.Equal(t, root.TraceID(), spans[1].SpanContext().TraceID())
+	assert.Equal(t, root.SpanID(), spans[1].Parent().SpanID())
+	info := sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(Config{})).(*sqlhooks.Driver).Info()
+	assert.Equal(t, map[attribute.Key]string{
+		DBSystem:        "sqlite",
+		DBStatement:     "SELECT id FROM t WHERE id = ?",
+		DBOperation:     "SELECT",
+		DBDriverName:    "*sqlite3.SQLiteDriver",
+		DBDriverVersion: "v1.10.0",
+		SQLHooksVersion: info.SQLHooksVersion,
+		"db.name":       "main",
+	}, attributes(spans[1]))
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestSpanErrors(t *testing.T) {
+	db, recorder := open(t, Config{System: "custom"})
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "INSERT", spans[0].Name())
+	assert.Equal(t, "custom", attributes(spans[0])[DBSystem])
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "exception", spans[0].Events()[0].Name)
+}
+
+func TestFilter(t *testing.T) {
+	db, recorder := open(t, Config{
+		Filter: func(attr attribute.KeyValue) bool { return attr.Key != DBStatement && attr.Key != SQLHooksVersion },
+	})
+	defer db.Close()
+
+	_, err := db.Exec("SELECT 1")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, map[attribute.Key]string{
+		DBSystem:        "sqlite",
+		DBOperation:     "SELECT",
+		DBDriverName:    "*sqlite3.SQLiteDriver",
+		DBDriverVersion: "v1.10.0",
+	}, attributes(spans[0]))
+}
@@ -5,19 +5,37 @@ import (
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/log"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
 )
 
+// Options configures a Hook built with NewWithOptions
+type Options struct {
+	// Sampler, if set, decides which statements are traced. Statements
+	// that are not sampled are run without a span.
+	Sampler *sqlutil.Sampler
+}
+
+// spanKey holds the span started by Before, which must not be mistaken for
+// the parent span when the statement is not sampled
+type spanKey struct{}
+
 type Hook struct {
-	tracer opentracing.Tracer
+	tracer  opentracing.Tracer
+	sampler *sqlutil.Sampler
 }
 
 func New(tracer opentracing.Tracer) *Hook {
-	return &Hook{tracer: tracer}
+	return NewWithOptions(tracer, Options{})
+}
+
+// NewWithOptions returns a Hook configured with opts
+func NewWithOptions(tracer opentracing.Tracer, opts Options) *Hook {
+	return &Hook{tracer: tracer, sampler: opts.Sampler}
 }
 
 func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
 	parent := opentracing.SpanFromContext(ctx)
-	if parent == nil {
+	if parent == nil || !h.sampler.Sample(query) {
 		return ctx, nil
 	}
 
@@ -27,11 +45,12 @@ func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (c
 		log.Object("args", args),
 	)
 
-	return opentracing.ContextWithSpan(ctx, span), nil
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return context.WithValue(ctx, spanKey{}, span), nil
 }
 
 func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	span := opentracing.SpanFromContext(ctx)
+	span, _ := ctx.Value(spanKey{}).(opentracing.Span)
 	if span != nil {
 		defer span.Finish()
 	}
@@ -40,7 +59,7 @@ func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (co
 }
 
 func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
-	span := opentracing.SpanFromContext(ctx)
+	span, _ := ctx.Value(spanKey{}).(opentracing.Span)
 	if span != nil {
 		defer span.Finish()
 		span.SetTag("error", true)
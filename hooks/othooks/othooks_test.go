@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
 	sqlite3 "github.com/mattn/go-sqlite3"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/mocktracer"
@@ -72,3 +73,28 @@ func TestNoSpansAreRecorded(t *testing.T) {
 
 	assert.Empty(t, tracer.FinishedSpans())
 }
+
+func TestSampledSpans(t *testing.T) {
+	sampled := mocktracer.New()
+	sql.Register("ot-sampled", sqlhooks.Wrap(&sqlite3.SQLiteDriver{},
+		NewWithOptions(sampled, Options{Sampler: sqlutil.NewSampler(1, 2)})))
+	db, err := sql.Open("ot-sampled", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	parent := sampled.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	for i := 0; i < 4; i++ {
+		rows, err := db.QueryContext(ctx, "SELECT 1+?", i)
+		require.NoError(t, err)
+		rows.Close()
+	}
+	rows, err := db.QueryContext(ctx, "SELECT 2")
+	require.NoError(t, err)
+	rows.Close()
+
+	// The parent is not finished by the statements that were not sampled
+	assert.Len(t, sampled.FinishedSpans(), 3)
+	parent.Finish()
+	assert.Len(t, sampled.FinishedSpans(), 4)
+}
@@ -0,0 +1,201 @@
+// Package outlier detects the statements whose latency deviates sharply
+// from the baseline of their fingerprint, such as after a plan flip or the
+// drop of an index, without configuring a threshold per statement:
+//
+//	h := outlier.New(outlier.Config{
+//		ZScore:    4,
+//		OnOutlier: func(ctx context.Context, o outlier.Outlier) { log.Print(o) },
+//	})
+//	sql.Register("postgres-outlier", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(h, hooks)))
+//
+// The baseline of every fingerprint is the exponentially weighted moving
+// average (EWMA) of its latency and of their variance. A statement is an
+// outlier when it is slower than the average by Config.ZScore standard
+// deviations, and by at least Config.MinDeviation. Outliers are part of the
+// baseline as well, so that a lasting regression stops being reported once
+// it becomes the norm.
+package outlier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Outlier describes a statement slower than the baseline of its fingerprint
+type Outlier struct {
+	Op          sqlhooks.Op
+	Query       string
+	Fingerprint string
+	ConnID      uint64
+	Duration    time.Duration
+	// Baseline is the baseline of the fingerprint before the statement
+	Baseline Baseline
+	// ZScore is the number of standard deviations between Duration and the
+	// average of the baseline
+	ZScore float64
+}
+
+// String formats the outlier on one line, suitable for logging
+func (o Outlier) String() string {
+	return fmt.Sprintf("latency outlier %s on conn %d: %s, %.1f σ above %s ± %s, %s",
+		o.Op, o.ConnID, o.Duration, o.ZScore, o.Baseline.Mean, o.Baseline.StdDev, o.Query)
+}
+
+// Baseline is the latency of a fingerprint
+type Baseline struct {
+	// Mean and StdDev are the EWMA of the latency and its standard
+	// deviation
+	Mean   time.Duration
+	StdDev time.Duration
+	// Samples is the number of statements observed
+	Samples int64
+}
+
+// Config configures a Hook
+type Config struct {
+	// Alpha is the weight of every new latency in the EWMA, between 0 and
+	// 1. The higher, the faster the baseline follows the latency. Defaults
+	// to 0.05.
+	Alpha float64
+	// ZScore is the number of standard deviations above the average from
+	// which a statement is an outlier. Defaults to 3.
+	ZScore float64
+	// MinDeviation is the minimum difference with the average of an
+	// outlier, not to report the jitter of fast and steady statements.
+	// Defaults to one millisecond.
+	MinDeviation time.Duration
+	// MinSamples is the number of statements of a fingerprint observed
+	// before reporting its outliers. Defaults to 30.
+	MinSamples int64
+	// MaxFingerprints caps the number of baselines kept, the fingerprints
+	// seen beyond it are not tracked. Defaults to 10000.
+	MaxFingerprints int
+	// OnOutlier is called synchronously after an outlier, with the context
+	// of its After hooks. Defaults to logging it with the log package.
+	OnOutlier func(ctx context.Context, o Outlier)
+}
+
+// Hook tracks the baseline of every fingerprint and reports the outliers.
+// Failed statements are not observed.
+type Hook struct {
+	cfg Config
+
+	mu        sync.Mutex
+	baselines map[string]*baseline
+}
+
+// baseline is the EWMA of the latency of a fingerprint and of its variance,
+// in nanoseconds
+type baseline struct {
+	mean, variance float64
+	samples        int64
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = 0.05
+	}
+	if cfg.ZScore <= 0 {
+		cfg.ZScore = 3
+	}
+	if cfg.MinDeviation <= 0 {
+		cfg.MinDeviation = time.Millisecond
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 30
+	}
+	if cfg.MaxFingerprints <= 0 {
+		cfg.MaxFingerprints = 10000
+	}
+	if cfg.OnOutlier == nil {
+		cfg.OnOutlier = func(ctx context.Context, o Outlier) { log.Print(o) }
+	}
+	return &Hook{cfg: cfg, baselines: make(map[string]*baseline)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil || query == "" {
+		return ctx, nil
+	}
+	if o, ok := h.observe(event.Fingerprint(), event.Duration); ok {
+		o.Op, o.Query, o.ConnID = event.Op, query, event.ConnID
+		h.cfg.OnOutlier(ctx, o)
+	}
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return err
+}
+
+// Baseline returns the baseline of fingerprint, and false if it is not
+// tracked
+func (h *Hook) Baseline(fingerprint string) (Baseline, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.baselines[fingerprint]
+	if !ok {
+		return Baseline{}, false
+	}
+	return b.export(), true
+}
+
+// observe adds d to the baseline of fingerprint, returning an Outlier with
+// its Duration, Baseline and ZScore if d is one
+func (h *Hook) observe(fingerprint string, d time.Duration) (Outlier, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.baselines[fingerprint]
+	if !ok {
+		if len(h.baselines) >= h.cfg.MaxFingerprints {
+			return Outlier{}, false
+		}
+		b = &baseline{mean: float64(d)}
+		h.baselines[fingerprint] = b
+	}
+
+	var (
+		o       Outlier
+		outlier bool
+		diff    = float64(d) - b.mean
+	)
+	if b.samples >= h.cfg.MinSamples && diff >= float64(h.cfg.MinDeviation) {
+		stddev := math.Sqrt(b.variance)
+		z := math.Inf(1)
+		if stddev > 0 {
+			z = diff / stddev
+		}
+		if z >= h.cfg.ZScore {
+			o = Outlier{Fingerprint: fingerprint, Duration: d, Baseline: b.export(), ZScore: z}
+			outlier = true
+		}
+	}
+
+	if b.samples > 0 {
+		incr := h.cfg.Alpha * diff
+		b.mean += incr
+		b.variance = (1 - h.cfg.Alpha) * (b.variance + diff*incr)
+	}
+	b.samples++
+	return o, outlier
+}
+
+func (b *baseline) export() Baseline {
+	return Baseline{
+		Mean:    time.Duration(b.mean),
+		StdDev:  time.Duration(math.Sqrt(b.variance)),
+		Samples: b.samples,
+	}
+}
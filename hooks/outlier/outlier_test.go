@@ -0,0 +1,88 @@
+package outlier
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	h := New(Config{MinSamples: 10, MaxFingerprints: 1})
+
+	// A steady latency with some jitter
+	for i := 0; i < 100; i++ {
+		d := 10*time.Millisecond + time.Duration(i%5)*100*time.Microsecond
+		_, ok := h.observe("a", d)
+		require.False(t, ok, "sample %d", i)
+	}
+	b, ok := h.Baseline("a")
+	require.True(t, ok)
+	assert.Equal(t, int64(100), b.Samples)
+	assert.InDelta(t, float64(10200*time.Microsecond), float64(b.Mean), float64(200*time.Microsecond))
+	assert.True(t, b.StdDev > 0 && b.StdDev < time.Millisecond, "%s", b.StdDev)
+
+	o, ok := h.observe("a", 50*time.Millisecond)
+	require.True(t, ok, "a plan flip is an outlier")
+	assert.Equal(t, "a", o.Fingerprint)
+	assert.Equal(t, 50*time.Millisecond, o.Duration)
+	assert.Equal(t, b, o.Baseline)
+	assert.True(t, o.ZScore > 3)
+
+	_, ok = h.observe("a", time.Microsecond)
+	assert.False(t, ok, "faster statements are not outliers")
+
+	_, ok = h.observe("b", time.Second)
+	assert.False(t, ok)
+	_, ok = h.Baseline("b")
+	assert.False(t, ok, "fingerprints beyond MaxFingerprints are not tracked")
+}
+
+func TestObserveMinDeviation(t *testing.T) {
+	h := New(Config{MinSamples: 1})
+	for i := 0; i < 10; i++ {
+		h.observe("a", 10*time.Microsecond)
+	}
+	_, ok := h.observe("a", 500*time.Microsecond)
+	assert.False(t, ok, "steady fast statements are not reported under MinDeviation")
+	_, ok = h.observe("a", 5*time.Millisecond)
+	assert.True(t, ok)
+}
+
+func TestHook(t *testing.T) {
+	var outliers []Outlier
+	h := New(Config{
+		MinSamples:   1,
+		MinDeviation: time.Nanosecond,
+		ZScore:       1e-9,
+		OnOutlier:    func(ctx context.Context, o Outlier) { outliers = append(outliers, o) },
+	})
+	driverName := fmt.Sprintf("outlier-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := "SELECT ?"
+	for i := 0; i < 50; i++ {
+		_, err := db.Exec(query, i)
+		require.NoError(t, err)
+	}
+	b, ok := h.Baseline(sqlutil.Fingerprint(query))
+	require.True(t, ok)
+	assert.Equal(t, int64(50), b.Samples)
+
+	require.NotEmpty(t, outliers, "the slowest statements exceed the tiny ZScore")
+	o := outliers[0]
+	assert.Equal(t, sqlhooks.OpExec, o.Op)
+	assert.Equal(t, query, o.Query)
+	assert.Equal(t, sqlutil.Fingerprint(query), o.Fingerprint)
+	assert.Contains(t, o.String(), "latency outlier exec")
+}
@@ -0,0 +1,318 @@
+// Package pagination records how deep the paginated queries page, flagging
+// the ones paging with high OFFSET values: the database reads and discards
+// every skipped row, so the cost of a page grows with its depth, and such
+// queries should move to keyset pagination (WHERE id > ? ORDER BY id LIMIT
+// ?).
+//
+// The offsets of the SELECT statements with a LIMIT, OFFSET or FETCH clause
+// are recorded per statement fingerprint, as a distribution over
+// Config.Buckets, and OnDeepOffset is called the first time a fingerprint
+// pages beyond Config.DeepOffset:
+//
+//	h := pagination.New(pagination.Config{OnDeepOffset: func(a pagination.Alert) {
+//		log.Printf("deep pagination, offset %d: %s", a.Offset, a.Query)
+//	}})
+//	sql.Register("postgres-pagination", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// Offsets bound as arguments are resolved for the positional placeholders,
+// ? and $1.
+package pagination
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Page is the pagination of a query
+type Page struct {
+	// Limit is the number of rows of the page, -1 without a limit or when
+	// it cannot be resolved.
+	Limit  int64
+	Offset int64
+}
+
+// Parse returns the pagination of the outermost query of query, run with
+// args. It reports false for queries without LIMIT, OFFSET nor FETCH
+// clauses, and for offsets that are not a number or a positional
+// placeholder.
+func Parse(query string, args []interface{}) (Page, bool) {
+	var (
+		toks   []sqlutil.Token
+		values []value
+		depth  int
+		seq    int
+	)
+	// The clauses of the outermost query are the last ones at depth 0
+	limit, offset, fetch := -1, -1, -1
+	for _, tok := range sqlutil.Tokenize(query) {
+		switch tok.Type {
+		case sqlutil.Space, sqlutil.Comment:
+			continue
+		case sqlutil.Punct:
+			if tok.Text == "(" {
+				depth++
+			} else if tok.Text == ")" {
+				depth--
+			}
+		case sqlutil.Word:
+			if depth == 0 {
+				switch {
+				case tok.IsKeyword("LIMIT"):
+					limit = len(toks)
+				case tok.IsKeyword("OFFSET"):
+					offset = len(toks)
+				case tok.IsKeyword("FETCH"):
+					fetch = len(toks)
+				}
+			}
+		}
+		v := value{}
+		switch tok.Type {
+		case sqlutil.Number:
+			v.n, v.ok = parseInt(tok.Text)
+		case sqlutil.Placeholder:
+			i := placeholderIndex(tok.Text, &seq)
+			if i >= 0 && i < len(args) {
+				v.n, v.ok = toInt(args[i])
+			}
+		}
+		toks = append(toks, tok)
+		values = append(values, v)
+	}
+	if limit < 0 && offset < 0 && fetch < 0 {
+		return Page{}, false
+	}
+
+	at := func(i int) value {
+		if i < len(values) {
+			return values[i]
+		}
+		return value{}
+	}
+	page := Page{Limit: -1}
+	if limit >= 0 {
+		if v := at(limit + 1); v.ok {
+			page.Limit = v.n
+		}
+		// MySQL's LIMIT offset, count
+		if limit+2 < len(toks) && toks[limit+2].Text == "," {
+			o, n := at(limit+1), at(limit+3)
+			if !o.ok {
+				return Page{}, false
+			}
+			page.Offset, page.Limit = o.n, -1
+			if n.ok {
+				page.Limit = n.n
+			}
+		}
+	}
+	if offset >= 0 {
+		v := at(offset + 1)
+		if !v.ok {
+			return Page{}, false
+		}
+		page.Offset = v.n
+	}
+	// FETCH FIRST|NEXT n ROW|ROWS ONLY
+	if fetch >= 0 {
+		if v := at(fetch + 2); v.ok {
+			page.Limit = v.n
+		}
+	}
+	return page, true
+}
+
+type value struct {
+	n  int64
+	ok bool
+}
+
+// placeholderIndex returns the index of the argument of the placeholder p,
+// seq counting the ? placeholders
+func placeholderIndex(p string, seq *int) int {
+	switch {
+	case p == "?":
+		*seq++
+		return *seq - 1
+	case strings.HasPrefix(p, "$"):
+		p = p[1:]
+	default:
+		return -1
+	}
+	n, err := strconv.Atoi(p)
+	if err != nil {
+		return -1
+	}
+	return n - 1
+}
+
+func parseInt(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case string:
+		return parseInt(v)
+	case []byte:
+		return parseInt(string(v))
+	}
+	return 0, false
+}
+
+// Alert describes the first page of a fingerprint beyond Config.DeepOffset
+type Alert struct {
+	Query       string
+	Fingerprint string
+	Page
+}
+
+// Config configures a Hook
+type Config struct {
+	// Buckets are the upper bounds of the offset buckets. Defaults to 0,
+	// 100, 1000, 10000 and 100000.
+	Buckets []int64
+	// DeepOffset is the offset above which a page is deep. Defaults to
+	// 10000.
+	DeepOffset int64
+	// OnDeepOffset, if set, is called synchronously before the statement
+	// the first time a fingerprint pages deeper than DeepOffset.
+	OnDeepOffset func(Alert)
+	// MaxFingerprints caps the number of fingerprints recorded, the pages
+	// of the others being ignored. Defaults to 1000.
+	MaxFingerprints int
+}
+
+// Distribution is the distribution of the offsets of a fingerprint
+type Distribution struct {
+	Fingerprint string
+	// Query is the normalized statement
+	Query string
+	// Counts are the number of pages per bucket: Counts[i] is the number of
+	// pages with an offset up to Buckets[i] and above Buckets[i-1], the
+	// last one counting the pages beyond the last bucket.
+	Buckets []int64
+	Counts  []int64
+	Pages   int64
+	// MaxOffset is the deepest offset, and DeepPages the number of pages
+	// beyond Config.DeepOffset.
+	MaxOffset int64
+	DeepPages int64
+}
+
+// Hook records the distribution of the offsets of every fingerprint
+type Hook struct {
+	cfg Config
+
+	mu    sync.Mutex
+	dists map[string]*Distribution
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = []int64{0, 100, 1000, 10000, 100000}
+	}
+	if cfg.DeepOffset <= 0 {
+		cfg.DeepOffset = 10000
+	}
+	if cfg.MaxFingerprints <= 0 {
+		cfg.MaxFingerprints = 1000
+	}
+	return &Hook{cfg: cfg, dists: make(map[string]*Distribution)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if sqlutil.Classify(query) != sqlutil.Select {
+		return ctx, nil
+	}
+	page, ok := Parse(query, args)
+	if !ok {
+		return ctx, nil
+	}
+	var fp string
+	if event := sqlhooks.EventFromContext(ctx); event != nil && event.Query == query {
+		fp = event.Fingerprint()
+	} else {
+		fp = sqlutil.Fingerprint(query)
+	}
+	h.observe(query, fp, page)
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) observe(query, fp string, page Page) {
+	h.mu.Lock()
+	d, ok := h.dists[fp]
+	if !ok {
+		if len(h.dists) >= h.cfg.MaxFingerprints {
+			h.mu.Unlock()
+			return
+		}
+		d = &Distribution{
+			Fingerprint: fp,
+			Query:       sqlutil.Normalize(query),
+			Buckets:     h.cfg.Buckets,
+			Counts:      make([]int64, len(h.cfg.Buckets)+1),
+		}
+		h.dists[fp] = d
+	}
+	d.Counts[sort.Search(len(d.Buckets), func(i int) bool { return page.Offset <= d.Buckets[i] })]++
+	d.Pages++
+	if page.Offset > d.MaxOffset {
+		d.MaxOffset = page.Offset
+	}
+	deep := page.Offset > h.cfg.DeepOffset
+	first := deep && d.DeepPages == 0
+	if deep {
+		d.DeepPages++
+	}
+	h.mu.Unlock()
+
+	if first && h.cfg.OnDeepOffset != nil {
+		h.cfg.OnDeepOffset(Alert{Query: query, Fingerprint: fp, Page: page})
+	}
+}
+
+// Distributions returns the distributions of the fingerprints, the deepest
+// first
+func (h *Hook) Distributions() []Distribution {
+	h.mu.Lock()
+	dists := make([]Distribution, 0, len(h.dists))
+	for _, d := range h.dists {
+		c := *d
+		c.Counts = append([]int64(nil), d.Counts...)
+		dists = append(dists, c)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(dists, func(i, j int) bool {
+		if dists[i].MaxOffset != dists[j].MaxOffset {
+			return dists[i].MaxOffset > dists[j].MaxOffset
+		}
+		return dists[i].Fingerprint < dists[j].Fingerprint
+	})
+	return dists
+}
@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		args  []interface{}
+		page  Page
+		ok    bool
+	}{
+		{"SELECT * FROM t LIMIT 10", nil, Page{Limit: 10}, true},
+		{"SELECT * FROM t LIMIT 10 OFFSET 20", nil, Page{Limit: 10, Offset: 20}, true},
+		{"SELECT * FROM t OFFSET 20 LIMIT 10", nil, Page{Limit: 10, Offset: 20}, true},
+		{"SELECT * FROM t LIMIT 20, 10", nil, Page{Limit: 10, Offset: 20}, true},
+		{"SELECT * FROM t WHERE a = ? LIMIT ? OFFSET ?", []interface{}{"x", int64(10), int64(500)}, Page{Limit: 10, Offset: 500}, true},
+		{"SELECT * FROM t WHERE a = $3 LIMIT $1 OFFSET $2", []interface{}{10, 500, "x"}, Page{Limit: 10, Offset: 500}, true},
+		{"SELECT * FROM t ORDER BY id OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY", nil, Page{Limit: 20, Offset: 40}, true},
+		{"SELECT * FROM t LIMIT ALL OFFSET 5", nil, Page{Limit: -1, Offset: 5}, true},
+		{"SELECT * FROM (SELECT * FROM t LIMIT 10 OFFSET 20) s", nil, Page{}, false},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM u LIMIT 5) LIMIT 3", nil, Page{Limit: 3}, true},
+		{"SELECT * FROM t OFFSET :off", nil, Page{}, false},
+		{"SELECT 'LIMIT 10' -- LIMIT 10", nil, Page{}, false},
+	} {
+		page, ok := Parse(tc.query, tc.args)
+		assert.Equal(t, tc.ok, ok, tc.query)
+		assert.Equal(t, tc.page, page, tc.query)
+	}
+}
+
+func TestHook(t *testing.T) {
+	var alerts []Alert
+	h := New(Config{Buckets: []int64{10, 100}, DeepOffset: 100, OnDeepOffset: func(a Alert) {
+		alerts = append(alerts, a)
+	}})
+	driverName := fmt.Sprintf("pagination-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	query := "SELECT id FROM t ORDER BY id LIMIT ? OFFSET ?"
+	for _, offset := range []int{0, 50, 200, 300} {
+		rows, err := db.Query(query, 10, offset)
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+	}
+	rows, err := db.Query("SELECT id FROM t LIMIT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	dists := h.Distributions()
+	require.Len(t, dists, 2)
+	assert.Equal(t, "SELECT id FROM t ORDER BY id LIMIT ? OFFSET ?", dists[0].Query)
+	assert.Equal(t, []int64{1, 1, 2}, dists[0].Counts)
+	assert.Equal(t, int64(4), dists[0].Pages)
+	assert.Equal(t, int64(300), dists[0].MaxOffset)
+	assert.Equal(t, int64(2), dists[0].DeepPages)
+	assert.Equal(t, int64(0), dists[1].MaxOffset)
+
+	require.Len(t, alerts, 1, "deep fingerprints are flagged once")
+	assert.Equal(t, Page{Limit: 10, Offset: 200}, alerts[0].Page)
+	assert.Equal(t, dists[0].Fingerprint, alerts[0].Fingerprint)
+}
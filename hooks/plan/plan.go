@@ -0,0 +1,255 @@
+// Package plan detects the changes of the execution plan of the statements,
+// such as the planner switching to a sequential scan as a table grows or as
+// an index is dropped. The plans of sampled statements are explained and
+// hashed per fingerprint, and a change of the hash is reported:
+//
+//	plans := plan.New(plan.DB(monitoringDB, plan.Postgres), plan.Config{
+//		Sampler:  sqlutil.NewSampler(1, 1000),
+//		OnChange: func(ctx context.Context, c plan.Change) { log.Print(c) },
+//	})
+//	sql.Register("postgres-plan", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(plans, hooks)))
+//
+// Changes attribute the latency shifts found by the outlier package to the
+// plans:
+//
+//	outlier.New(outlier.Config{OnOutlier: func(ctx context.Context, o outlier.Outlier) {
+//		if c, ok := plans.LastChange(o.Fingerprint); ok && time.Since(c.Current.At) < 10*time.Minute {
+//			log.Printf("%s, after a plan change: %s", o, c)
+//		}
+//	}})
+//
+// The sampled statements are explained synchronously in their After hook,
+// on another connection: the Explainer must not use the connection pool
+// being hooked if it is limited to one connection.
+package plan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Prefixes of the statements explaining a query, for DB
+const (
+	// Postgres leaves the costs out, which vary with the statistics
+	Postgres = "EXPLAIN (COSTS OFF) "
+	MySQL    = "EXPLAIN "
+	SQLite   = "EXPLAIN QUERY PLAN "
+)
+
+// Explainer returns the plan of query run with args
+type Explainer func(ctx context.Context, query string, args []interface{}) (string, error)
+
+// DB returns an Explainer running query prefixed by prefix on db, with the
+// hooks disabled, and formatting its rows as lines of tab separated
+// columns.
+func DB(db *sql.DB, prefix string) Explainer {
+	return func(ctx context.Context, query string, args []interface{}) (string, error) {
+		rows, err := db.QueryContext(sqlhooks.WithHooksDisabled(ctx), prefix+query, args...)
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil {
+			return "", err
+		}
+		var (
+			b      strings.Builder
+			values = make([]sql.NullString, len(columns))
+			dest   = make([]interface{}, len(columns))
+		)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return "", err
+			}
+			for i, v := range values {
+				if i > 0 {
+					b.WriteByte('\t')
+				}
+				b.WriteString(v.String)
+			}
+			b.WriteByte('\n')
+		}
+		return b.String(), rows.Err()
+	}
+}
+
+var numbers = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+
+// Normalize replaces the numbers of plan, such as estimated rows or costs,
+// or the IDs of the nodes, with a "?"
+func Normalize(plan string) string {
+	return numbers.ReplaceAllString(plan, "?")
+}
+
+// Plan is the plan of a fingerprint
+type Plan struct {
+	// Hash is the hash of the normalized plan
+	Hash string
+	Text string
+	// At is when the plan was first explained
+	At time.Time
+}
+
+// Change describes the change of the plan of a fingerprint
+type Change struct {
+	Fingerprint string
+	// Query is the statement explained
+	Query    string
+	Previous Plan
+	Current  Plan
+}
+
+// String formats the change on one line, suitable for logging
+func (c Change) String() string {
+	return fmt.Sprintf("plan of %s changed from %s to %s: %s", c.Fingerprint, c.Previous.Hash, c.Current.Hash, c.Query)
+}
+
+// Config configures a Hook
+type Config struct {
+	// Normalize removes the variable parts of the plans before they are
+	// hashed. Defaults to the Normalize function.
+	Normalize func(plan string) string
+	// Sampler decides which statements are explained. Defaults to the first
+	// one of every fingerprint then one in 1000.
+	Sampler *sqlutil.Sampler
+	// MaxFingerprints caps the number of plans kept, the fingerprints seen
+	// beyond it are not explained. Defaults to 10000.
+	MaxFingerprints int
+	// OnChange is called synchronously after a statement whose plan
+	// changed, with the context of its After hooks. Defaults to logging the
+	// change with the log package.
+	OnChange func(ctx context.Context, c Change)
+	// OnExplainError, if set, is called with the errors of the Explainer,
+	// which are ignored otherwise.
+	OnExplainError func(ctx context.Context, query string, err error)
+}
+
+// Hook explains the sampled statements and reports their plan changes. Only
+// the successful SELECT, INSERT, UPDATE and DELETE statements are explained.
+type Hook struct {
+	explain Explainer
+	cfg     Config
+	now     func() time.Time
+
+	mu      sync.Mutex
+	plans   map[string]Plan
+	changes map[string]Change
+}
+
+// New returns a new Hook explaining the statements with explain
+func New(explain Explainer, cfg Config) *Hook {
+	if cfg.Normalize == nil {
+		cfg.Normalize = Normalize
+	}
+	if cfg.Sampler == nil {
+		cfg.Sampler = sqlutil.NewSampler(1, 1000)
+	}
+	if cfg.MaxFingerprints <= 0 {
+		cfg.MaxFingerprints = 10000
+	}
+	if cfg.OnChange == nil {
+		cfg.OnChange = func(ctx context.Context, c Change) { log.Print(c) }
+	}
+	return &Hook{explain: explain, cfg: cfg, now: time.Now, plans: make(map[string]Plan), changes: make(map[string]Change)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil || event.Op != sqlhooks.OpQuery && event.Op != sqlhooks.OpExec {
+		return ctx, nil
+	}
+	switch event.Kind() {
+	case sqlutil.Select, sqlutil.Insert, sqlutil.Update, sqlutil.Delete:
+	default:
+		return ctx, nil
+	}
+	if sqlutil.Normalize(query) == "" || strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "EXPLAIN") {
+		return ctx, nil
+	}
+	if !h.cfg.Sampler.Sample(query) {
+		return ctx, nil
+	}
+	h.Check(ctx, event.Fingerprint(), query, args)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return err
+}
+
+// Check explains query run with args, of fingerprint, and reports the
+// change of its plan. It is called by After for the sampled statements, and
+// can be called to explain a statement on demand, such as after a latency
+// outlier.
+func (h *Hook) Check(ctx context.Context, fingerprint, query string, args []interface{}) {
+	h.mu.Lock()
+	_, known := h.plans[fingerprint]
+	full := len(h.plans) >= h.cfg.MaxFingerprints
+	h.mu.Unlock()
+	if !known && full {
+		return
+	}
+
+	text, err := h.explain(ctx, query, args)
+	if err != nil {
+		if h.cfg.OnExplainError != nil {
+			h.cfg.OnExplainError(ctx, query, err)
+		}
+		return
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(h.cfg.Normalize(text)))
+	current := Plan{Hash: fmt.Sprintf("%016x", hash.Sum64()), Text: text, At: h.now()}
+
+	h.mu.Lock()
+	previous, ok := h.plans[fingerprint]
+	if ok && previous.Hash == current.Hash {
+		h.mu.Unlock()
+		return
+	}
+	h.plans[fingerprint] = current
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	c := Change{Fingerprint: fingerprint, Query: query, Previous: previous, Current: current}
+	h.changes[fingerprint] = c
+	h.mu.Unlock()
+	h.cfg.OnChange(ctx, c)
+}
+
+// Plan returns the last plan of fingerprint, and false if it was never
+// explained
+func (h *Hook) Plan(fingerprint string) (Plan, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.plans[fingerprint]
+	return p, ok
+}
+
+// LastChange returns the last change of the plan of fingerprint, and false
+// if it never changed
+func (h *Hook) LastChange(fingerprint string) (Change, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.changes[fingerprint]
+	return c, ok
+}
@@ -0,0 +1,116 @@
+package plan
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dsn := filepath.Join(dir, "test.db")
+
+	explainDB, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer explainDB.Close()
+	// Explain on new connections, which see the schema changes of db
+	explainDB.SetMaxIdleConns(0)
+
+	var changes []Change
+	h := New(DB(explainDB, SQLite), Config{
+		Sampler:  sqlutil.NewSampler(100, 0),
+		OnChange: func(ctx context.Context, c Change) { changes = append(changes, c) },
+	})
+	driverName := fmt.Sprintf("plan-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (id INTEGER, v TEXT)")
+	require.NoError(t, err)
+	query := "SELECT id FROM t WHERE v = ?"
+	fingerprint := sqlutil.Fingerprint(query)
+	for i := 0; i < 2; i++ {
+		rows, err := db.Query(query, "a")
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+	}
+	p, ok := h.Plan(fingerprint)
+	require.True(t, ok)
+	assert.Contains(t, p.Text, "SCAN")
+	assert.Empty(t, changes, "the plan is stable")
+
+	_, err = db.Exec("CREATE INDEX t_v ON t (v)")
+	require.NoError(t, err)
+	rows, err := db.Query(query, "b")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	require.Len(t, changes, 1)
+	c := changes[0]
+	assert.Equal(t, fingerprint, c.Fingerprint)
+	assert.Equal(t, p, c.Previous)
+	assert.Contains(t, c.Current.Text, "USING INDEX t_v")
+	assert.NotEqual(t, c.Previous.Hash, c.Current.Hash)
+	last, ok := h.LastChange(fingerprint)
+	require.True(t, ok)
+	assert.Equal(t, c, last)
+
+	_, ok = h.Plan(sqlutil.Fingerprint("CREATE INDEX t_v ON t (v)"))
+	assert.False(t, ok, "DDL is not explained")
+}
+
+func TestCheck(t *testing.T) {
+	plans := []string{"scan (rows=10)", "scan (rows=12)", "index scan"}
+	var explainErr error
+	h := New(func(ctx context.Context, query string, args []interface{}) (string, error) {
+		if explainErr != nil {
+			return "", explainErr
+		}
+		p := plans[0]
+		plans = plans[1:]
+		return p, nil
+	}, Config{
+		MaxFingerprints: 1,
+		OnChange:        func(ctx context.Context, c Change) {},
+		OnExplainError:  func(ctx context.Context, query string, err error) { assert.EqualError(t, err, "explain failed") },
+	})
+	ctx := context.Background()
+
+	h.Check(ctx, "a", "SELECT 1", nil)
+	h.Check(ctx, "a", "SELECT 1", nil)
+	_, ok := h.LastChange("a")
+	assert.False(t, ok, "numbers are normalized away")
+	h.Check(ctx, "a", "SELECT 1", nil)
+	c, ok := h.LastChange("a")
+	require.True(t, ok)
+	assert.Equal(t, "scan (rows=10)", c.Previous.Text)
+	assert.Equal(t, "index scan", c.Current.Text)
+
+	explainErr = errors.New("explain failed")
+	h.Check(ctx, "a", "SELECT 1", nil)
+	p, ok := h.Plan("a")
+	require.True(t, ok)
+	assert.Equal(t, "index scan", p.Text, "failures are ignored")
+
+	explainErr = nil
+	h.Check(ctx, "b", "SELECT 2", nil)
+	_, ok = h.Plan("b")
+	assert.False(t, ok, "fingerprints beyond MaxFingerprints are not explained")
+	assert.Empty(t, plans)
+}
@@ -0,0 +1,273 @@
+// Package poolhooks warns about connection pool exhaustion before it turns
+// into an outage. It samples sql.DBStats periodically and combines the wait
+// counters with the number of queries in flight observed through the hooks.
+//
+// The Hook also signals sustained saturation, so that upstream components
+// can shed load before the database does: Saturated and Pressure can be
+// polled or selected on, Config.OnPressure is called when the pool becomes
+// saturated or recovers, and Shed rejects HTTP requests meanwhile.
+//
+//	h := poolhooks.New(poolhooks.Config{})
+//	go h.Watch(ctx, db)
+//	http.Handle("/api/", h.Shed(api))
+//
+// ReuseTracker measures the first statements run on connections reused after
+// being idle separately, to tune sql.DB.SetConnMaxIdleTime.
+package poolhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Warning is passed to Config.OnWarning when callers are frequently blocked
+// waiting for a connection.
+type Warning struct {
+	// Stats is the most recent sample.
+	Stats sql.DBStats
+	// InFlight is the number of queries currently executing on the driver.
+	// A pool that is exhausted while few queries are in flight usually has
+	// connections held by transactions or unclosed rows.
+	InFlight int64
+	// Samples is the number of samples in the window, and Blocked how many of
+	// them saw at least one caller waiting for a connection.
+	Samples int
+	Blocked int
+	// Waits is the number of waits observed in the window.
+	Waits int64
+	// WaitP50, WaitP90 and WaitP99 are percentiles of the average wait
+	// duration of the blocked samples. database/sql does not expose
+	// individual waits, so these are computed over per-sample averages.
+	WaitP50 time.Duration
+	WaitP90 time.Duration
+	WaitP99 time.Duration
+}
+
+// Config configures a Hook
+type Config struct {
+	// Interval between DBStats samples. Defaults to one second.
+	Interval time.Duration
+	// Window is the number of samples considered. Defaults to 10.
+	Window int
+	// BlockedRatio is the fraction of samples in the window that must have
+	// seen callers waiting for a connection to raise a warning. Defaults
+	// to 0.5.
+	BlockedRatio float64
+	// OnWarning is called with the warning. It is called at most once per
+	// window.
+	OnWarning func(Warning)
+	// OnPressure is called with true when the pool becomes saturated, that
+	// is when BlockedRatio of the samples in the window saw callers
+	// waiting, and with false when it recovers, once fewer than half as
+	// many samples did.
+	OnPressure func(saturated bool)
+}
+
+type sample struct {
+	waits   int64
+	avgWait time.Duration
+}
+
+// Hook tracks queries in flight and, once Watch is called, samples the pool
+// statistics of a *sql.DB.
+type Hook struct {
+	inFlight int64 // accessed atomically, kept first for 64-bit alignment
+	cfg      Config
+
+	mu        sync.Mutex
+	last      *sql.DBStats
+	samples   []sample
+	cooldown  int
+	saturated bool
+	pressure  chan struct{} // closed while saturated
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10
+	}
+	if cfg.BlockedRatio <= 0 {
+		cfg.BlockedRatio = 0.5
+	}
+	return &Hook{cfg: cfg, pressure: make(chan struct{})}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+// InterceptQuery counts the query in flight while the driver runs it. The
+// queries are counted by the Interceptor rather than between Before and
+// After, which are not called when another hook composed with h fails Before.
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(&h.inFlight, 1)
+	defer atomic.AddInt64(&h.inFlight, -1)
+	return next(ctx, query, args)
+}
+
+// InterceptExec counts the statement in flight while the driver runs it
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	atomic.AddInt64(&h.inFlight, 1)
+	defer atomic.AddInt64(&h.inFlight, -1)
+	return next(ctx, query, args)
+}
+
+// InFlight returns the number of queries currently executing
+func (h *Hook) InFlight() int64 {
+	return atomic.LoadInt64(&h.inFlight)
+}
+
+// Saturated reports whether the pool is saturated, see Config.OnPressure
+func (h *Hook) Saturated() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.saturated
+}
+
+// Pressure returns a channel that is closed when the pool becomes
+// saturated. Once the pool recovers, Pressure returns a new channel.
+//
+//	select {
+//	case job := <-jobs:
+//		run(job)
+//	case <-h.Pressure():
+//		// Stop picking up jobs until the pool recovers
+//	}
+func (h *Hook) Pressure() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pressure
+}
+
+// Shed returns a handler replying 503 Service Unavailable while the pool is
+// saturated, and calling next otherwise
+func (h *Hook) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Saturated() {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Watch samples db.Stats() every Config.Interval until ctx is done.
+// It is usually run in its own goroutine.
+func (h *Hook) Watch(ctx context.Context, db *sql.DB) {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.sample(db.Stats())
+		}
+	}
+}
+
+func (h *Hook) sample(stats sql.DBStats) {
+	h.mu.Lock()
+	saturated := h.saturated
+	warning, ok := h.record(stats)
+	changed := h.saturated != saturated
+	saturated = h.saturated
+	h.mu.Unlock()
+
+	if ok && h.cfg.OnWarning != nil {
+		h.cfg.OnWarning(warning)
+	}
+	if changed && h.cfg.OnPressure != nil {
+		h.cfg.OnPressure(saturated)
+	}
+}
+
+// record must be called with h.mu held
+func (h *Hook) record(stats sql.DBStats) (Warning, bool) {
+	last := h.last
+	h.last = &stats
+	if last == nil {
+		return Warning{}, false
+	}
+
+	s := sample{waits: stats.WaitCount - last.WaitCount}
+	if s.waits > 0 {
+		s.avgWait = (stats.WaitDuration - last.WaitDuration) / time.Duration(s.waits)
+	}
+	h.samples = append(h.samples, s)
+	if len(h.samples) > h.cfg.Window {
+		h.samples = h.samples[1:]
+	}
+
+	var (
+		waits int64
+		avgs  []time.Duration
+	)
+	for _, s := range h.samples {
+		if s.waits > 0 {
+			waits += s.waits
+			avgs = append(avgs, s.avgWait)
+		}
+	}
+	threshold := h.cfg.BlockedRatio * float64(h.cfg.Window)
+	blocked := float64(len(avgs))
+	switch {
+	case !h.saturated && blocked >= threshold:
+		h.saturated = true
+		close(h.pressure)
+	case h.saturated && blocked < threshold/2:
+		h.saturated = false
+		h.pressure = make(chan struct{})
+	}
+
+	if h.cooldown > 0 {
+		h.cooldown--
+		return Warning{}, false
+	}
+	if blocked < threshold {
+		return Warning{}, false
+	}
+	h.cooldown = h.cfg.Window
+
+	sort.Slice(avgs, func(i, j int) bool { return avgs[i] < avgs[j] })
+	return Warning{
+		Stats:    stats,
+		InFlight: h.InFlight(),
+		Samples:  len(h.samples),
+		Blocked:  len(avgs),
+		Waits:    waits,
+		WaitP50:  percentile(avgs, 0.5),
+		WaitP90:  percentile(avgs, 0.9),
+		WaitP99:  percentile(avgs, 0.99),
+	}, true
+}
+
+// percentile returns the p-th percentile of sorted using the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,145 @@
+package poolhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlight(t *testing.T) {
+	h := New(Config{})
+	ctx := context.Background()
+
+	_, _ = h.InterceptExec(ctx, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		_, _ = h.InterceptQuery(ctx, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+			assert.Equal(t, int64(2), h.InFlight())
+			return nil, errors.New("failed")
+		}, "SELECT 2", nil)
+		assert.Equal(t, int64(1), h.InFlight())
+		return nil, nil
+	}, "SELECT 1", nil)
+	assert.Equal(t, int64(0), h.InFlight())
+}
+
+// failing fails every query Before
+type failing struct{}
+
+func (failing) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, errors.New("rejected")
+}
+
+func (failing) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func TestInFlightComposed(t *testing.T) {
+	h := New(Config{})
+	driverName := fmt.Sprintf("poolhooks-inflight-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlhooks.Compose(failing{}, h)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	require.EqualError(t, err, "rejected")
+	assert.Equal(t, int64(0), h.InFlight())
+}
+
+func TestWarning(t *testing.T) {
+	var warnings []Warning
+	h := New(Config{Window: 4, BlockedRatio: 0.5, OnWarning: func(w Warning) {
+		warnings = append(warnings, w)
+	}})
+
+	var stats sql.DBStats
+	step := func(waits int64, wait time.Duration) {
+		stats.WaitCount += waits
+		stats.WaitDuration += wait
+		h.sample(stats)
+	}
+
+	step(0, 0) // first sample only sets the baseline
+	step(0, 0)
+	step(2, 20*time.Millisecond)
+	step(0, 0)
+	require.Empty(t, warnings)
+
+	step(1, 30*time.Millisecond)
+	require.Len(t, warnings, 1)
+	w := warnings[0]
+	assert.Equal(t, 4, w.Samples)
+	assert.Equal(t, 2, w.Blocked)
+	assert.Equal(t, int64(3), w.Waits)
+	assert.Equal(t, 10*time.Millisecond, w.WaitP50)
+	assert.Equal(t, 30*time.Millisecond, w.WaitP99)
+
+	// Warnings are not repeated within the same window
+	for i := 0; i < 4; i++ {
+		step(1, time.Millisecond)
+	}
+	require.Len(t, warnings, 1)
+	step(1, time.Millisecond)
+	require.Len(t, warnings, 2)
+}
+
+func TestPressure(t *testing.T) {
+	var pressure []bool
+	h := New(Config{Window: 4, BlockedRatio: 0.5, OnPressure: func(saturated bool) {
+		pressure = append(pressure, saturated)
+	}})
+	shed := h.Shed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	status := func() int {
+		rec := httptest.NewRecorder()
+		shed.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec.Code
+	}
+
+	var stats sql.DBStats
+	step := func(waits int64) {
+		stats.WaitCount += waits
+		stats.WaitDuration += time.Duration(waits) * time.Millisecond
+		h.sample(stats)
+	}
+
+	step(0)
+	step(1)
+	ch := h.Pressure()
+	assert.False(t, h.Saturated())
+	assert.Equal(t, http.StatusOK, status())
+
+	step(1)
+	assert.True(t, h.Saturated())
+	assert.Equal(t, []bool{true}, pressure)
+	assert.Equal(t, http.StatusServiceUnavailable, status())
+	select {
+	case <-ch:
+	default:
+		t.Fatal("Pressure channel not closed")
+	}
+
+	// Recovering takes fewer than half the blocked samples
+	step(0)
+	step(0)
+	step(0)
+	assert.True(t, h.Saturated())
+	step(0)
+	assert.False(t, h.Saturated())
+	assert.Equal(t, []bool{true, false}, pressure)
+	assert.Equal(t, http.StatusOK, status())
+	select {
+	case <-h.Pressure():
+		t.Fatal("Pressure channel closed after recovery")
+	default:
+	}
+}
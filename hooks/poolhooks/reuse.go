@@ -0,0 +1,136 @@
+package poolhooks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Reuse describes the first statement run on a connection reused after
+// being idle
+type Reuse struct {
+	Query  string
+	ConnID uint64
+	// Idle is how long the connection was idle, see sqlhooks.Event.ConnIdle.
+	Idle    time.Duration
+	Latency time.Duration
+}
+
+// ReuseStats summarizes the latencies of the statements in a bucket of
+// idle durations
+type ReuseStats struct {
+	// MaxIdle is the upper bound of the bucket, zero for the statements that
+	// did not reuse an idle connection and -1 for the last bucket.
+	MaxIdle time.Duration
+	Count   int64
+	Total   time.Duration
+	Max     time.Duration
+}
+
+// Mean returns the mean latency of the bucket
+func (s ReuseStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// ReuseConfig configures a ReuseTracker
+type ReuseConfig struct {
+	// MinIdle is the idle time from which a reused connection is
+	// considered idle. Defaults to one second.
+	MinIdle time.Duration
+	// Buckets are the upper bounds of the idle durations latencies are
+	// grouped by, in increasing order. Defaults to 10s, 1m, 5m and 30m.
+	Buckets []time.Duration
+	// OnReuse, if set, is called after the first statement run on every
+	// idle connection.
+	OnReuse func(Reuse)
+}
+
+// ReuseTracker is a sqlhooks.Hooks measuring the latency of the first
+// statement run on a connection reused after being idle separately from the
+// other statements. Comparing them shows what TCP, TLS and session
+// revalidation cost after an idle period, and how sql.DB.SetConnMaxIdleTime
+// should be tuned.
+type ReuseTracker struct {
+	cfg ReuseConfig
+
+	mu       sync.Mutex
+	baseline ReuseStats
+	buckets  []ReuseStats
+}
+
+// NewReuseTracker returns a new ReuseTracker
+func NewReuseTracker(cfg ReuseConfig) *ReuseTracker {
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = time.Second
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+	}
+	t := &ReuseTracker{cfg: cfg}
+	for _, max := range cfg.Buckets {
+		t.buckets = append(t.buckets, ReuseStats{MaxIdle: max})
+	}
+	t.buckets = append(t.buckets, ReuseStats{MaxIdle: -1})
+	return t
+}
+
+type reuseStart struct{}
+
+func (t *ReuseTracker) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return context.WithValue(ctx, reuseStart{}, time.Now()), nil
+}
+
+func (t *ReuseTracker) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	t.record(ctx, query)
+	return ctx, nil
+}
+
+func (t *ReuseTracker) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	t.record(ctx, query)
+	return err
+}
+
+func (t *ReuseTracker) record(ctx context.Context, query string) {
+	start, ok := ctx.Value(reuseStart{}).(time.Time)
+	event := sqlhooks.EventFromContext(ctx)
+	if !ok || event == nil {
+		return
+	}
+	latency := time.Since(start)
+
+	t.mu.Lock()
+	if event.ConnIdle < t.cfg.MinIdle {
+		t.baseline.add(latency)
+		t.mu.Unlock()
+		return
+	}
+	i := sort.Search(len(t.cfg.Buckets), func(i int) bool { return event.ConnIdle <= t.cfg.Buckets[i] })
+	t.buckets[i].add(latency)
+	t.mu.Unlock()
+
+	if t.cfg.OnReuse != nil {
+		t.cfg.OnReuse(Reuse{Query: query, ConnID: event.ConnID, Idle: event.ConnIdle, Latency: latency})
+	}
+}
+
+func (s *ReuseStats) add(latency time.Duration) {
+	s.Count++
+	s.Total += latency
+	if latency > s.Max {
+		s.Max = latency
+	}
+}
+
+// Stats returns the statistics of the statements that did not reuse an
+// idle connection, followed by those of every bucket of idle durations
+func (t *ReuseTracker) Stats() []ReuseStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]ReuseStats{t.baseline}, t.buckets...)
+}
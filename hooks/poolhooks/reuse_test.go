@@ -0,0 +1,50 @@
+package poolhooks
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReuseTracker(t *testing.T) {
+	var reuses []Reuse
+	tracker := NewReuseTracker(ReuseConfig{
+		MinIdle: 20 * time.Millisecond,
+		Buckets: []time.Duration{time.Minute},
+		OnReuse: func(r Reuse) { reuses = append(reuses, r) },
+	})
+	driverName := fmt.Sprintf("poolhooks-reuse-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, tracker))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Empty(t, reuses)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = db.Exec("INSERT INTO t VALUES (2)")
+	require.NoError(t, err)
+	require.Len(t, reuses, 1)
+	assert.Equal(t, "INSERT INTO t VALUES (2)", reuses[0].Query)
+	assert.True(t, reuses[0].Idle >= 30*time.Millisecond)
+
+	stats := tracker.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, int64(2), stats[0].Count)
+	assert.Equal(t, time.Minute, stats[1].MaxIdle)
+	assert.Equal(t, int64(1), stats[1].Count)
+	assert.Equal(t, reuses[0].Latency, stats[1].Mean())
+	assert.Equal(t, time.Duration(-1), stats[2].MaxIdle)
+	assert.Zero(t, stats[2].Count)
+}
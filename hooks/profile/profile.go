@@ -0,0 +1,94 @@
+// Package profile bundles the guards and the logging suited to an
+// environment, so that they are selected with one call:
+//
+//	p, _ := profile.ByName(os.Getenv("APP_ENV"))
+//	sql.Register("postgres-app", sqlhooks.Wrap(&pq.Driver{}, p.Hooks()))
+//
+// Profiles are plain values, any setting can be overridden before calling
+// Hooks:
+//
+//	p := profile.ProdStrict()
+//	p.Guard.ReadOnly = true // an analytics replica
+package profile
+
+import (
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/hooks/guard"
+	"github.com/qustavo/sqlhooks/v2/hooks/loghooks"
+)
+
+// Logging selects how a Profile logs statements
+type Logging int
+
+const (
+	// NoLogging logs nothing
+	NoLogging Logging = iota
+	// LogStatements logs every statement and error with loghooks, with
+	// identical lines rate-limited
+	LogStatements
+	// LogConsole pretty-prints every statement with a loghooks.Console
+	LogConsole
+)
+
+// Profile is a set of guards and a logging setting
+type Profile struct {
+	Name    string
+	Guard   guard.Config
+	Logging Logging
+	// DedupInterval rate-limits identical lines with LogStatements, see
+	// loghooks.Options.
+	DedupInterval time.Duration
+}
+
+// Dev pretty-prints every statement and rejects UPDATE and DELETE without
+// a WHERE clause
+func Dev() Profile {
+	return Profile{
+		Name:    "dev",
+		Guard:   guard.Config{RequireWhere: true},
+		Logging: LogConsole,
+	}
+}
+
+// Staging logs the statements, rate-limited, and rejects UPDATE and DELETE
+// without a WHERE clause and schema changes outside of guard.AllowDDL
+func Staging() Profile {
+	return Profile{
+		Name:          "staging",
+		Guard:         guard.Config{RequireWhere: true, BlockDDL: true},
+		Logging:       LogStatements,
+		DedupInterval: time.Minute,
+	}
+}
+
+// ProdStrict has the guards of Staging and does not log statements
+func ProdStrict() Profile {
+	return Profile{
+		Name:  "prod-strict",
+		Guard: guard.Config{RequireWhere: true, BlockDDL: true},
+	}
+}
+
+// ByName returns the profile named name: dev, staging or prod-strict
+func ByName(name string) (Profile, bool) {
+	for _, p := range []Profile{Dev(), Staging(), ProdStrict()} {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Hooks returns the hooks of the profile, the guards running first
+func (p Profile) Hooks() sqlhooks.Hooks {
+	hooks := []sqlhooks.Hooks{guard.New(p.Guard)}
+	switch p.Logging {
+	case LogStatements:
+		hooks = append(hooks, loghooks.NewWithOptions(loghooks.Options{DedupInterval: p.DedupInterval}))
+	case LogConsole:
+		hooks = append(hooks, loghooks.NewConsole(loghooks.ConsoleOptions{Format: true}))
+	}
+	return sqlhooks.Compose(hooks...)
+}
@@ -0,0 +1,43 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/hooks/guard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"dev", "staging", "prod-strict"} {
+		p, ok := ByName(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, name, p.Name)
+	}
+	_, ok := ByName("prod")
+	assert.False(t, ok)
+}
+
+func TestProfile(t *testing.T) {
+	p := ProdStrict()
+	p.Guard.BlockDDL = false
+	driverName := fmt.Sprintf("profile-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, p.Hooks()))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM t")
+	assert.Equal(t, guard.ErrNoWhere, err)
+	_, err = db.ExecContext(guard.AllowFullTable(context.Background()), "DELETE FROM t")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,163 @@
+// Package prometheus exports Prometheus metrics of the statements run
+// through sqlhooks: the number of statements and errors, and a histogram of
+// their latency, per operation:
+//
+//	h, err := prometheus.New(prometheus.Config{Registerer: registry})
+//	if err != nil {
+//		return err
+//	}
+//	sql.Register("postgres-metrics", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// The operation label is the sqlhooks.Op of the statement, "query" or
+// "exec", or "begin", "commit"... for the operations enabled with
+// sqlhooks.WithOps. Statements can further be partitioned with a
+// Config.Labeler, whose values must be few: the queries themselves are never
+// used as labels.
+//
+// The driver_info gauge is set to 1 for every wrapped driver the Hook
+// observed, labelled with the type and version of the driver and the version
+// of sqlhooks, for inventories of the drivers run across a fleet.
+package prometheus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// Config configures a Hook
+type Config struct {
+	// Registerer registers the metrics. Defaults to
+	// prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Namespace and Subsystem prefix the names of the metrics, which
+	// default to sql_statements_total, sql_errors_total,
+	// sql_duration_seconds and sql_driver_info.
+	Namespace string
+	Subsystem string
+	// Buckets are the buckets of the latency histogram, in seconds.
+	// Defaults to prometheus.DefBuckets.
+	Buckets []float64
+	// ConstLabels are added to every metric, such as the name of the
+	// database.
+	ConstLabels prometheus.Labels
+	// Labeler, if set, adds a label to the metrics, named LabelName, whose
+	// value it returns for every statement, such as the name of the
+	// repository method running it or a sqlutil.Fingerprint looked up in a
+	// fixed set.
+	Labeler func(ctx context.Context, query string) string
+	// LabelName is the name of the label set by Labeler. Defaults to
+	// "query".
+	LabelName string
+}
+
+// Hook is a sqlhooks.Hooks recording metrics of every statement
+type Hook struct {
+	labeler    func(ctx context.Context, query string) string
+	statements *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	driverInfo *prometheus.GaugeVec
+	// drivers are the sqlhooks.DriverInfo already set in driverInfo
+	drivers sync.Map
+}
+
+// New returns a new Hook, whose metrics are registered on
+// Config.Registerer
+func New(cfg Config) (*Hook, error) {
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	if cfg.Namespace == "" && cfg.Subsystem == "" {
+		cfg.Namespace = "sql"
+	}
+	if cfg.Buckets == nil {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+	if cfg.LabelName == "" {
+		cfg.LabelName = "query"
+	}
+	labels := []string{"operation"}
+	if cfg.Labeler != nil {
+		labels = append(labels, cfg.LabelName)
+	}
+
+	h := &Hook{
+		labeler: cfg.Labeler,
+		statements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "statements_total",
+			Help:        "Number of statements run.",
+			ConstLabels: cfg.ConstLabels,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "errors_total",
+			Help:        "Number of statements that failed.",
+			ConstLabels: cfg.ConstLabels,
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "duration_seconds",
+			Help:        "Latency of the statements.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     cfg.Buckets,
+		}, labels),
+		driverInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        "driver_info",
+			Help:        "Wrapped drivers, with their versions and the version of sqlhooks.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"driver", "version", "sqlhooks_version"}),
+	}
+	for _, c := range []prometheus.Collector{h.statements, h.errors, h.duration, h.driverInfo} {
+		if err := cfg.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.observe(ctx, query, nil)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.observe(ctx, query, err)
+	return err
+}
+
+func (h *Hook) observe(ctx context.Context, query string, err error) {
+	op := sqlhooks.OpUnknown
+	if event := sqlhooks.EventFromContext(ctx); event != nil {
+		op = event.Op
+		if info := event.Driver; info != nil {
+			if _, seen := h.drivers.LoadOrStore(info, true); !seen {
+				h.driverInfo.WithLabelValues(info.Name, info.Version, info.SQLHooksVersion).Set(1)
+			}
+		}
+	}
+	labels := []string{op.String()}
+	if h.labeler != nil {
+		labels = append(labels, h.labeler(ctx, query))
+	}
+
+	h.statements.WithLabelValues(labels...).Inc()
+	if err != nil {
+		h.errors.WithLabelValues(labels...).Inc()
+	}
+	if d, ok := sqlhooks.DurationFromContext(ctx); ok {
+		h.duration.WithLabelValues(labels...).Observe(d.Seconds())
+	}
+}
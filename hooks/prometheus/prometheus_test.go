@@ -0,0 +1,89 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, h *Hook) *sql.DB {
+	driverName := fmt.Sprintf("prometheus-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db
+}
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	h, err := New(Config{Registerer: registry})
+	require.NoError(t, err)
+	db := open(t, h)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+	rows, err := db.Query("SELECT id FROM t")
+	require.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(h.statements.WithLabelValues("exec")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(h.statements.WithLabelValues("query")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(h.errors.WithLabelValues("exec")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(h.errors.WithLabelValues("query")))
+	assert.Equal(t, 1, testutil.CollectAndCount(h.driverInfo))
+	info := sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h).(*sqlhooks.Driver).Info()
+	assert.Equal(t, 1.0, testutil.ToFloat64(h.driverInfo.WithLabelValues("*sqlite3.SQLiteDriver", "v1.10.0", info.SQLHooksVersion)))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	names := make([]string, len(families))
+	for i, f := range families {
+		names[i] = f.GetName()
+	}
+	assert.Equal(t, []string{"sql_driver_info", "sql_duration_seconds", "sql_errors_total", "sql_statements_total"}, names)
+	for _, f := range families {
+		if f.GetName() == "sql_duration_seconds" {
+			require.Len(t, f.Metric, 2)
+			assert.Equal(t, uint64(2), f.Metric[0].GetHistogram().GetSampleCount())
+		}
+	}
+
+	_, err = New(Config{Registerer: registry})
+	assert.Error(t, err, "metrics registered twice")
+}
+
+func TestLabeler(t *testing.T) {
+	type methodKey struct{}
+	h, err := New(Config{
+		Registerer: prometheus.NewRegistry(),
+		Namespace:  "app",
+		Subsystem:  "db",
+		LabelName:  "method",
+		Labeler: func(ctx context.Context, query string) string {
+			method, _ := ctx.Value(methodKey{}).(string)
+			return method
+		},
+	})
+	require.NoError(t, err)
+	db := open(t, h)
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), methodKey{}, "users.get")
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(h.statements.WithLabelValues("exec", "users.get")))
+	assert.Equal(t, 1, testutil.CollectAndCount(h.duration, "app_db_duration_seconds"))
+}
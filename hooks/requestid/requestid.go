@@ -0,0 +1,100 @@
+// Package requestid tags every statement with the ID of the request it runs
+// for, as a trailing comment:
+//
+//	SELECT * FROM users WHERE id = ? /* request_id='f3a9c1' */
+//
+// so that server-side slow query logs and pg_stat_activity can be tied back
+// to application requests.
+//
+// Statements prepared with Prepare are prepared again, with the comment, on
+// every execution with a request ID; prefer unprepared statements with this
+// hook.
+package requestid
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/url"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose statements are tagged with id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID stored by WithRequestID
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Hook is a sqlhooks.Interceptor appending the request ID to statements
+type Hook struct {
+	key         string
+	fromContext func(context.Context) (string, bool)
+	dialect     sqlutil.Dialect
+}
+
+// New returns a Hook tagging statements with key, request_id if empty. The
+// request ID is read with FromContext, or with fromContext if not nil, e.g. to
+// reuse the ID set by an HTTP middleware.
+func New(key string, fromContext func(context.Context) (string, bool)) *Hook {
+	if key == "" {
+		key = "request_id"
+	}
+	if fromContext == nil {
+		fromContext = FromContext
+	}
+	return &Hook{key: key, fromContext: fromContext}
+}
+
+// WithDialect makes the Hook format its comments with d rather than with the
+// dialect of the driver, see sqlhooks.WithDialect. It returns h.
+func (h *Hook) WithDialect(d sqlutil.Dialect) *Hook {
+	h.dialect = d
+	return h
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return next(ctx, h.tag(ctx, query), args)
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return next(ctx, h.tag(ctx, query), args)
+}
+
+func (h *Hook) tag(ctx context.Context, query string) string {
+	id, ok := h.fromContext(ctx)
+	if !ok {
+		return query
+	}
+	comment := h.key + "='" + url.QueryEscape(id) + "'"
+	d := h.dialect
+	if event := sqlhooks.EventFromContext(ctx); d == nil && event != nil {
+		d = event.Dialect
+	}
+	if d != nil {
+		return sqlutil.AppendComment(query, d.Comment(comment))
+	}
+	return AppendComment(query, comment)
+}
+
+// AppendComment returns query with /* comment */ appended after its last
+// token, before any trailing semicolon or line comment that would swallow
+// it. comment must not contain */.
+func AppendComment(query, comment string) string {
+	return sqlutil.AppendComment(query, "/* "+comment+" */")
+}
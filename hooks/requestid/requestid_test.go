@@ -0,0 +1,60 @@
+package requestid
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendComment(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT 1":             "SELECT 1 /* k='v' */",
+		"SELECT 1;\n":          "SELECT 1 /* k='v' */;\n",
+		"SELECT 1 -- trailing": "SELECT 1 /* k='v' */ -- trailing",
+	} {
+		assert.Equal(t, want, AppendComment(query, "k='v'"), query)
+	}
+}
+
+func TestHook(t *testing.T) {
+	var queries []string
+	driverName := fmt.Sprintf("requestid-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, sqlhooks.Compose(New("", nil), &sqlhooks.Interceptors{
+		Exec: func(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			queries = append(queries, query)
+			return next(ctx, query, args)
+		},
+	})))
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(WithRequestID(context.Background(), "a*/b c"), "UPDATE t SET n = 1")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE t SET n = 1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"UPDATE t SET n = 1 /* request_id='a%2A%2Fb+c' */",
+		"UPDATE t SET n = 1",
+	}, queries)
+}
+
+type noComments struct{ sqlutil.Dialect }
+
+func (noComments) Comment(text string) string { return "" }
+
+func TestWithDialect(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "f3a9")
+	assert.Equal(t, "SELECT 1", New("", nil).WithDialect(noComments{sqlutil.SQLite}).tag(ctx, "SELECT 1"))
+	assert.Equal(t, "SELECT 1 /* request_id='f3a9' */", New("", nil).WithDialect(sqlutil.SQLServer).tag(ctx, "SELECT 1"))
+}
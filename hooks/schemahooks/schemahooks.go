@@ -0,0 +1,110 @@
+// Package schemahooks notifies registered callbacks when the database schema
+// changes, so that caches depending on it (prepared statements, results,
+// parsed queries) can be invalidated together.
+//
+// Changes are detected when DDL statements run through the wrapped driver.
+// Changes made by other clients, such as a migration tool, can be detected
+// with Poll.
+package schemahooks
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Source tells how a Change was detected
+type Source int
+
+const (
+	// Statement changes were detected in a DDL statement run through the driver
+	Statement Source = iota
+	// Poller changes were detected by Poll
+	Poller
+)
+
+// Change describes a schema change
+type Change struct {
+	Source Source
+	// Query is the DDL statement, for Statement changes.
+	Query string
+	// Version is the new schema version returned by the poll query, for
+	// Poller changes.
+	Version string
+}
+
+// Hook calls the registered callbacks after every successful DDL statement
+type Hook struct {
+	mu        sync.RWMutex
+	callbacks []func(Change)
+}
+
+// New returns a Hook calling the given callbacks
+func New(callbacks ...func(Change)) *Hook {
+	return &Hook{callbacks: callbacks}
+}
+
+// OnChange registers a callback. Callbacks are called synchronously, in
+// registration order, from the goroutine that ran the statement.
+func (h *Hook) OnChange(fn func(Change)) {
+	h.mu.Lock()
+	h.callbacks = append(h.callbacks, fn)
+	h.mu.Unlock()
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if sqlutil.IsDDL(query) {
+		h.notify(Change{Source: Statement, Query: query})
+	}
+	return ctx, nil
+}
+
+func (h *Hook) notify(c Change) {
+	h.mu.RLock()
+	callbacks := h.callbacks
+	h.mu.RUnlock()
+
+	for _, fn := range callbacks {
+		fn(c)
+	}
+}
+
+// Poll runs query on db every interval until ctx is done, and notifies the
+// callbacks whenever its result changes. query must return a single value
+// identifying the schema version, e.g. the latest migration applied:
+//
+//	SELECT MAX(version) FROM schema_migrations
+//
+// The first result is taken as the baseline and is not notified. Errors are
+// ignored and the query retried on the next tick.
+func (h *Hook) Poll(ctx context.Context, db *sql.DB, interval time.Duration, query string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		current string
+		polled  bool
+	)
+	for {
+		var version sql.NullString
+		if err := db.QueryRowContext(ctx, query).Scan(&version); err == nil {
+			if polled && version.String != current {
+				h.notify(Change{Source: Poller, Version: version.String})
+			}
+			current, polled = version.String, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package schemahooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementChanges(t *testing.T) {
+	changes := make(chan Change, 10)
+	hook := New(func(c Change) { changes <- c })
+	driverName := fmt.Sprintf("schemahooks-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, hook))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE users(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users VALUES (1)")
+	require.NoError(t, err)
+	_, err = db.Exec("ALTER TABLE nope ADD COLUMN name text")
+	require.Error(t, err)
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, Change{Source: Statement, Query: "CREATE TABLE users(id int)"}, <-changes)
+
+	t.Run("Poll", func(t *testing.T) {
+		_, err := db.Exec("CREATE TABLE migrations(version int)")
+		require.NoError(t, err)
+		<-changes
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go hook.Poll(ctx, db, time.Millisecond, "SELECT MAX(version) FROM migrations")
+
+		// Keep migrating until the poller, which may not have taken its
+		// baseline yet, notices a change.
+		deadline := time.After(time.Second)
+		for version := 1; ; version++ {
+			_, err = db.Exec("INSERT INTO migrations VALUES (?)", version)
+			require.NoError(t, err)
+
+			select {
+			case c := <-changes:
+				assert.Equal(t, Poller, c.Source)
+				assert.NotEmpty(t, c.Version)
+				return
+			case <-deadline:
+				t.Fatal("change not detected")
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	})
+}
@@ -0,0 +1,186 @@
+// Package singleflight coalesces concurrent identical reads into a single
+// driver call, whose result is fanned out to every caller. It protects the
+// database from thundering herds on hot keys, e.g. when a popular cache entry
+// expires.
+//
+// Only SELECT statements outside of transactions are coalesced. Two queries
+// are identical when their text and arguments are; the fingerprint is not
+// enough, since literals are part of the text. Results are read into memory
+// before being returned, so the Group is meant for lookups returning a few
+// rows, not for reports.
+package singleflight
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+var errLeaderPanicked = errors.New("singleflight: coalesced query panicked")
+
+// Group is a sqlhooks.Interceptor coalescing identical queries
+type Group struct {
+	shared int64 // accessed atomically, kept first for 64-bit alignment
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	res  *result
+	err  error
+}
+
+// result is a query result read into memory
+type result struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// New returns a new Group
+func New() *Group {
+	return &Group{calls: make(map[string]*call)}
+}
+
+// Shared returns the number of queries that were served the result of
+// another one
+func (g *Group) Shared() int64 {
+	return atomic.LoadInt64(&g.shared)
+}
+
+func (g *Group) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (g *Group) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (g *Group) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return next(ctx, query, args)
+}
+
+func (g *Group) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if event := sqlhooks.EventFromContext(ctx); event != nil && event.InTx {
+		return next(ctx, query, args)
+	}
+	if sqlutil.Classify(query) != sqlutil.Select {
+		return next(ctx, query, args)
+	}
+
+	k := key(query, args)
+	g.mu.Lock()
+	if c, ok := g.calls[k]; ok {
+		g.mu.Unlock()
+		return g.wait(ctx, c, next, query, args)
+	}
+	c := &call{done: make(chan struct{}), err: errLeaderPanicked}
+	g.calls[k] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, k)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.res, c.err = read(next(ctx, query, args))
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.res.iter(), nil
+}
+
+// wait returns the result of c. If c failed because the context of its
+// caller was done, the query is run again with ctx.
+func (g *Group) wait(ctx context.Context, c *call, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+	}
+
+	if errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded) {
+		return next(ctx, query, args)
+	}
+	atomic.AddInt64(&g.shared, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.res.iter(), nil
+}
+
+func key(query string, args []driver.NamedValue) string {
+	var b strings.Builder
+	b.WriteString(query)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\x00%s:%d:%T:%v", arg.Name, arg.Ordinal, arg.Value, arg.Value)
+	}
+	return b.String()
+}
+
+// read reads rows into memory and closes them
+func read(rows driver.Rows, err error) (*result, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	res := &result{columns: rows.Columns()}
+	for {
+		dest := make([]driver.Value, len(res.columns))
+		if err := rows.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Drivers may reuse the memory of []byte values on the next call
+		for i, v := range dest {
+			dest[i] = clone(v)
+		}
+		res.rows = append(res.rows, dest)
+	}
+	return res, rows.Close()
+}
+
+func clone(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok && b != nil {
+		return append([]byte(nil), b...)
+	}
+	return v
+}
+
+// iter returns a new iterator over the result
+func (res *result) iter() driver.Rows {
+	return &rows{res: res}
+}
+
+// rows iterates over a shared result, it copies the values that callers
+// could modify
+type rows struct {
+	res *result
+	i   int
+}
+
+func (r *rows) Columns() []string { return r.res.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.rows) {
+		return io.EOF
+	}
+	for i, v := range r.res.rows[r.i] {
+		dest[i] = clone(v)
+	}
+	r.i++
+	return nil
+}
@@ -0,0 +1,69 @@
+package singleflight
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	g := New()
+	driverName := fmt.Sprintf("singleflight-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, sqlhooks.Compose(g, &sqlhooks.Interceptors{
+		Query: func(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+			atomic.AddInt64(&calls, 1)
+			<-release
+			return next(ctx, query, args)
+		},
+	})))
+
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int64
+			assert.NoError(t, db.QueryRow("SELECT n FROM t WHERE id = ?", 1).Scan(&n))
+			assert.Equal(t, int64(1), n)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	assert.Equal(t, int64(9), g.Shared())
+
+	// Different arguments and writes are not coalesced
+	var n int64
+	require.NoError(t, db.QueryRow("SELECT n FROM t WHERE id = ?", 2).Scan(&n))
+	_, err = db.Exec("UPDATE t SET n = 1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+}
+
+func TestSharedRowsAreCopied(t *testing.T) {
+	res := &result{columns: []string{"b"}, rows: [][]driver.Value{{[]byte("abc")}}}
+	dest := make([]driver.Value, 1)
+	require.NoError(t, res.iter().Next(dest))
+	dest[0].([]byte)[0] = 'x'
+
+	require.NoError(t, res.iter().Next(dest))
+	assert.Equal(t, []byte("abc"), dest[0])
+}
@@ -0,0 +1,17 @@
+// Package sloghooks logs every statement with log/slog, as structured
+// records carrying its operation, query, duration, number of arguments,
+// rows affected and error, so that the logs can be filtered and aggregated
+// by their attributes. It requires Go 1.21.
+//
+//	h := sloghooks.New(sloghooks.Config{
+//		Logger:        slog.New(slog.NewJSONHandler(os.Stderr, nil)),
+//		SlowThreshold: 200 * time.Millisecond,
+//	})
+//	sql.Register("postgres-slog", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// Statements are logged at Config.Level, those slower than
+// Config.SlowThreshold at Config.SlowLevel and the failed ones at
+// Config.ErrorLevel, so that the handler's level can keep the fast
+// statements out of the logs. The values of the arguments are only logged
+// with Config.LogArgs, redacted by Config.Redact if set.
+package sloghooks
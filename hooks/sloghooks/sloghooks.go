@@ -0,0 +1,155 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Config configures a Hook
+type Config struct {
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+	// Level is the level of the statements. Defaults to slog.LevelInfo.
+	Level slog.Level
+	// SlowThreshold, if positive, logs the statements taking longer at
+	// SlowLevel, which defaults to slog.LevelWarn.
+	SlowThreshold time.Duration
+	SlowLevel     slog.Level
+	// ErrorLevel is the level of the failed statements. Defaults to
+	// slog.LevelError.
+	ErrorLevel slog.Level
+	// LogArgs logs the values of the arguments rather than their number.
+	LogArgs bool
+	// Redact, if set, replaces the values of the arguments logged with
+	// LogArgs, such as Redacted does.
+	Redact func(v interface{}) interface{}
+	// Sampler, if set, decides which statements are logged. Failed and
+	// slow statements are always logged.
+	Sampler *sqlutil.Sampler
+}
+
+// Redacted replaces a value with its type, such as "<string>", to log the
+// shape of the arguments without their content
+func Redacted(v interface{}) interface{} {
+	return fmt.Sprintf("<%T>", v)
+}
+
+// Hook logs the statements to a slog.Logger. It is a sqlhooks.Interceptor,
+// to read the rows affected by the statements.
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook. As slog.LevelInfo is the zero level, SlowLevel and
+// ErrorLevel cannot be set to it.
+func New(cfg Config) *Hook {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.SlowLevel == 0 {
+		cfg.SlowLevel = slog.LevelWarn
+	}
+	if cfg.ErrorLevel == 0 {
+		cfg.ErrorLevel = slog.LevelError
+	}
+	return &Hook{cfg: cfg}
+}
+
+type stateKey struct{}
+
+// state is stored in the context by Before, the interceptor records the
+// rows affected in it
+type state struct {
+	start        time.Time
+	sampled      bool
+	rowsAffected int64
+	hasRows      bool
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return context.WithValue(ctx, stateKey{}, &state{start: time.Now(), sampled: h.cfg.Sampler.Sample(query)}), nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.log(ctx, nil, query, args)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.log(ctx, err, query, args)
+	return err
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return next(ctx, query, args)
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := next(ctx, query, args)
+	if s, ok := ctx.Value(stateKey{}).(*state); ok && err == nil && result != nil {
+		s.rowsAffected, err = result.RowsAffected()
+		// Drivers may not know the rows affected, such as for DDL
+		s.hasRows, err = err == nil, nil
+	}
+	return result, err
+}
+
+func (h *Hook) log(ctx context.Context, err error, query string, args []interface{}) {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return
+	}
+	duration := time.Since(s.start)
+	op := sqlhooks.OpUnknown
+	if event := sqlhooks.EventFromContext(ctx); event != nil {
+		op, duration = event.Op, event.Duration
+	}
+
+	level, msg := h.cfg.Level, "statement"
+	switch {
+	case err != nil:
+		level, msg = h.cfg.ErrorLevel, "statement failed"
+	case h.cfg.SlowThreshold > 0 && duration >= h.cfg.SlowThreshold:
+		level, msg = h.cfg.SlowLevel, "slow statement"
+	case !s.sampled:
+		return
+	}
+	if !h.cfg.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs,
+		slog.String("op", op.String()),
+		slog.String("query", query),
+		slog.Duration("duration", duration),
+	)
+	if h.cfg.LogArgs {
+		values := args
+		if h.cfg.Redact != nil {
+			values = make([]interface{}, len(args))
+			for i, arg := range args {
+				values[i] = h.cfg.Redact(arg)
+			}
+		}
+		attrs = append(attrs, slog.Any("args", values))
+	} else {
+		attrs = append(attrs, slog.Int("args", len(args)))
+	}
+	if s.hasRows {
+		attrs = append(attrs, slog.Int64("rows_affected", s.rowsAffected))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	h.cfg.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
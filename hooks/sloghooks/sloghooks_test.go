@@ -0,0 +1,105 @@
+//go:build go1.21
+// +build go1.21
+
+package sloghooks
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) (*sql.DB, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	cfg.Logger = slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	driverName := fmt.Sprintf("sloghooks-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db, buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		rec := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+	buf.Reset()
+	return recs
+}
+
+func TestHook(t *testing.T) {
+	db, buf := open(t, Config{Level: slog.LevelDebug})
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t (name TEXT)")
+	require.NoError(t, err)
+	records(t, buf)
+
+	_, err = db.Exec("INSERT INTO t VALUES (?), (?)", "alice", "bob")
+	require.NoError(t, err)
+	recs := records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "DEBUG", recs[0]["level"])
+	assert.Equal(t, "statement", recs[0]["msg"])
+	assert.Equal(t, "exec", recs[0]["op"])
+	assert.Equal(t, "INSERT INTO t VALUES (?), (?)", recs[0]["query"])
+	assert.Equal(t, float64(2), recs[0]["args"])
+	assert.Equal(t, float64(2), recs[0]["rows_affected"])
+	assert.Contains(t, recs[0], "duration")
+
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+	recs = records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "ERROR", recs[0]["level"])
+	assert.Equal(t, "statement failed", recs[0]["msg"])
+	assert.Contains(t, recs[0]["error"], "no such table")
+}
+
+func TestHookArgs(t *testing.T) {
+	db, buf := open(t, Config{LogArgs: true, Redact: Redacted})
+	defer db.Close()
+
+	var name string
+	require.NoError(t, db.QueryRow("SELECT ?", "secret").Scan(&name))
+	recs := records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "INFO", recs[0]["level"])
+	assert.Equal(t, []interface{}{"<string>"}, recs[0]["args"])
+	assert.NotContains(t, recs[0], "rows_affected")
+}
+
+func TestHookSlow(t *testing.T) {
+	db, buf := open(t, Config{Level: slog.LevelDebug - 4, SlowThreshold: time.Nanosecond})
+	defer db.Close()
+
+	_, err := db.Exec("SELECT 1")
+	require.NoError(t, err)
+	recs := records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "WARN", recs[0]["level"])
+	assert.Equal(t, "slow statement", recs[0]["msg"])
+
+	// Statements below the handler's level are not logged
+	db, buf = open(t, Config{Level: slog.LevelDebug - 4})
+	defer db.Close()
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Empty(t, records(t, buf))
+}
@@ -0,0 +1,148 @@
+// Package slowquery reports the statements taking longer than a threshold,
+// with their normalized form and fingerprint to group them, and optionally
+// the statement with its arguments inlined, ready to be explained:
+//
+//	h := slowquery.New(slowquery.Config{
+//		Threshold:  200 * time.Millisecond,
+//		Thresholds: map[sqlhooks.Op]time.Duration{sqlhooks.OpCommit: time.Second},
+//		Sampler:    sqlutil.NewSampler(10, 100),
+//		OnSlow:     func(ctx context.Context, r slowquery.Report) { log.Print(r) },
+//	})
+//	sql.Register("postgres-slow", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(h, hooks)))
+//
+// Wrap composes the Hook with existing hooks in one call.
+package slowquery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Report describes a slow statement
+type Report struct {
+	Op    sqlhooks.Op
+	Query string
+	// Normalized and Fingerprint are those of sqlutil, to group the reports
+	// of a same statement
+	Normalized  string
+	Fingerprint string
+	// Interpolated is the query with its arguments inlined, set with
+	// Config.Interpolate. It is empty if the arguments could not be
+	// inlined.
+	Interpolated string
+	ConnID       uint64
+	Duration     time.Duration
+	// Threshold is the threshold of Op the statement exceeded
+	Threshold time.Duration
+	Err       error
+}
+
+// Explain returns the EXPLAIN statement of the query, with its arguments
+// inlined if known, to be run by hand while investigating. It must not be
+// run by the application: the literals of sqlutil.Interpolate are only
+// approximate.
+func (r Report) Explain() string {
+	if r.Interpolated != "" {
+		return "EXPLAIN " + r.Interpolated
+	}
+	return "EXPLAIN " + r.Query
+}
+
+// String formats the report on one line, suitable for logging
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "slow %s on conn %d: %s > %s, %s", r.Op, r.ConnID, r.Duration, r.Threshold, r.Normalized)
+	if r.Err != nil {
+		fmt.Fprintf(&b, " (error: %v)", r.Err)
+	}
+	return b.String()
+}
+
+// Config configures a Hook
+type Config struct {
+	// Threshold is the duration above which a statement is reported.
+	// Defaults to one second.
+	Threshold time.Duration
+	// Thresholds overrides Threshold per operation, a negative threshold
+	// ignoring the operation.
+	Thresholds map[sqlhooks.Op]time.Duration
+	// Sampler, if set, decides which slow statements are reported, not to
+	// flood the logs with the same statement during an incident.
+	Sampler *sqlutil.Sampler
+	// Interpolate fills Report.Interpolated, which exposes the values of
+	// the arguments in the reports.
+	Interpolate bool
+	// OnSlow is called synchronously after a slow statement, with the
+	// context of its After or OnError hooks. Defaults to logging the report
+	// with the log package.
+	OnSlow func(ctx context.Context, r Report)
+}
+
+// Hook reports the slow statements
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = time.Second
+	}
+	if cfg.OnSlow == nil {
+		cfg.OnSlow = func(ctx context.Context, r Report) { log.Print(r) }
+	}
+	return &Hook{cfg: cfg}
+}
+
+// Wrap returns hooks composed with a Hook configured with cfg
+func Wrap(hooks sqlhooks.Hooks, cfg Config) sqlhooks.Hooks {
+	return sqlhooks.Compose(hooks, New(cfg))
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.observe(ctx, nil, query, args)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.observe(ctx, err, query, args)
+	return err
+}
+
+func (h *Hook) observe(ctx context.Context, err error, query string, args []interface{}) {
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil {
+		return
+	}
+	threshold, ok := h.cfg.Thresholds[event.Op]
+	if !ok {
+		threshold = h.cfg.Threshold
+	}
+	if threshold < 0 || event.Duration < threshold || !h.cfg.Sampler.Sample(query) {
+		return
+	}
+	r := Report{
+		Op:          event.Op,
+		Query:       query,
+		Normalized:  sqlutil.Normalize(query),
+		Fingerprint: event.Fingerprint(),
+		ConnID:      event.ConnID,
+		Duration:    event.Duration,
+		Threshold:   threshold,
+		Err:         err,
+	}
+	if h.cfg.Interpolate {
+		r.Interpolated, _ = sqlutil.Interpolate(query, args)
+	}
+	h.cfg.OnSlow(ctx, r)
+}
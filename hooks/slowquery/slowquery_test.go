@@ -0,0 +1,71 @@
+package slowquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) (*sql.DB, *[]Report) {
+	var reports []Report
+	cfg.OnSlow = func(ctx context.Context, r Report) { reports = append(reports, r) }
+	driverName := fmt.Sprintf("slowquery-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, Wrap(&sqlhooks.Interceptors{}, cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db, &reports
+}
+
+func TestHook(t *testing.T) {
+	db, reports := open(t, Config{
+		Threshold:   time.Nanosecond,
+		Thresholds:  map[sqlhooks.Op]time.Duration{sqlhooks.OpQuery: -1},
+		Interpolate: true,
+	})
+	defer db.Close()
+
+	_, err := db.Exec("SELECT ?, 'x' WHERE 1 IN (1, 2)", 42)
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+
+	require.Len(t, *reports, 2, "queries are ignored")
+	r := (*reports)[0]
+	assert.Equal(t, sqlhooks.OpExec, r.Op)
+	assert.Equal(t, sqlutil.Normalize(r.Query), r.Normalized)
+	assert.Equal(t, sqlutil.Fingerprint(r.Query), r.Fingerprint)
+	assert.Equal(t, "EXPLAIN SELECT 42, 'x' WHERE 1 IN (1, 2)", r.Explain())
+	assert.Equal(t, time.Nanosecond, r.Threshold)
+	assert.True(t, r.Duration > 0)
+	assert.Contains(t, (*reports)[1].Err.Error(), "no such table")
+}
+
+func TestHookSampler(t *testing.T) {
+	db, reports := open(t, Config{Threshold: time.Nanosecond, Sampler: sqlutil.NewSampler(2, 0)})
+	defer db.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := db.Exec("SELECT ?", i)
+		require.NoError(t, err)
+	}
+	require.Len(t, *reports, 2)
+	assert.Empty(t, (*reports)[0].Interpolated)
+	assert.Equal(t, "EXPLAIN SELECT ?", (*reports)[0].Explain())
+
+	// Statements under the threshold are not reported
+	db, reports = open(t, Config{Threshold: time.Hour})
+	defer db.Close()
+	_, err := db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Empty(t, *reports)
+}
@@ -0,0 +1,222 @@
+// Package slowtx reports the transactions lasting longer than a threshold,
+// with the statements they ran, in a single structured report rather than
+// scattered slow query lines:
+//
+//	h := slowtx.New(slowtx.Config{
+//		Threshold: time.Second,
+//		OnReport:  func(r slowtx.Report) { log.Print(r) },
+//	})
+//	sql.Register("postgres-slowtx", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+// The statements of a transaction are the queries and statement executions
+// run in it, in the order they completed.
+package slowtx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Outcome is how a transaction ended
+type Outcome string
+
+// Outcomes of a transaction, the failed ones being those whose commit or
+// rollback returned an error
+const (
+	Committed      Outcome = "committed"
+	RolledBack     Outcome = "rolled back"
+	CommitFailed   Outcome = "commit failed"
+	RollbackFailed Outcome = "rollback failed"
+)
+
+// Statement is a statement run in a transaction
+type Statement struct {
+	Query string
+	// Offset is the time elapsed between the beginning of the transaction
+	// and the start of the statement.
+	Offset   time.Duration
+	Duration time.Duration
+	Err      error
+}
+
+// Report describes a slow transaction
+type Report struct {
+	ConnID    uint64
+	TxID      string
+	StartedAt time.Time
+	Duration  time.Duration
+	// LocksHeld estimates how long the transaction held row locks: the time
+	// elapsed between the start of its first write, which is when databases
+	// usually start locking rows, and its end. It is zero for read-only
+	// transactions.
+	LocksHeld  time.Duration
+	Statements []Statement
+	// Dropped is the number of statements beyond Config.MaxStatements, left
+	// out of Statements.
+	Dropped int
+	Outcome Outcome
+	// Err is the error of the commit or rollback.
+	Err error
+}
+
+// String formats the report on several lines, suitable for logging
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "slow transaction %s on conn %d: %s after %s, locks held %s, %d statements",
+		r.TxID, r.ConnID, r.Outcome, r.Duration, r.LocksHeld, len(r.Statements)+r.Dropped)
+	if r.Err != nil {
+		fmt.Fprintf(&b, ", error: %v", r.Err)
+	}
+	for _, s := range r.Statements {
+		fmt.Fprintf(&b, "\n  +%s %s %s", s.Offset, s.Duration, s.Query)
+		if s.Err != nil {
+			fmt.Fprintf(&b, " (error: %v)", s.Err)
+		}
+	}
+	if r.Dropped > 0 {
+		fmt.Fprintf(&b, "\n  ... %d more", r.Dropped)
+	}
+	return b.String()
+}
+
+// Config configures a Hook
+type Config struct {
+	// Threshold is the duration above which a transaction is reported.
+	// Defaults to one second.
+	Threshold time.Duration
+	// MaxStatements caps the number of statements recorded per
+	// transaction. Defaults to 100.
+	MaxStatements int
+	// OnReport is called synchronously after a slow transaction ends.
+	OnReport func(Report)
+}
+
+// tx is a transaction in progress
+type tx struct {
+	report Report
+	// firstWrite is the start of the first write, zero if none
+	firstWrite time.Time
+}
+
+// Hook is a sqlhooks.Hooks and sqlhooks.TxHooks recording the statements of
+// the transactions
+type Hook struct {
+	cfg Config
+	now func() time.Time
+
+	mu  sync.Mutex
+	txs map[string]*tx
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = time.Second
+	}
+	if cfg.MaxStatements <= 0 {
+		cfg.MaxStatements = 100
+	}
+	return &Hook{cfg: cfg, now: time.Now, txs: make(map[string]*tx)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.record(ctx, query, nil)
+	return ctx, nil
+}
+
+func (h *Hook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.record(ctx, query, err)
+	return err
+}
+
+// record adds the statement that just ended to its transaction
+func (h *Hook) record(ctx context.Context, query string, err error) {
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil || event.IDs.Tx == "" || event.Op != sqlhooks.OpQuery && event.Op != sqlhooks.OpExec {
+		return
+	}
+	start := h.now().Add(-event.Duration)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.txs[event.IDs.Tx]
+	if !ok {
+		return
+	}
+	if t.firstWrite.IsZero() && sqlutil.IsWrite(query) {
+		t.firstWrite = start
+	}
+	if len(t.report.Statements) >= h.cfg.MaxStatements {
+		t.report.Dropped++
+		return
+	}
+	t.report.Statements = append(t.report.Statements, Statement{
+		Query:    query,
+		Offset:   start.Sub(t.report.StartedAt),
+		Duration: event.Duration,
+		Err:      err,
+	})
+}
+
+func (h *Hook) BeforeBegin(ctx context.Context, event sqlhooks.TxEvent) {}
+
+func (h *Hook) AfterBegin(ctx context.Context, event sqlhooks.TxEvent) {
+	if event.Err != nil {
+		return
+	}
+	h.mu.Lock()
+	h.txs[event.TxID] = &tx{report: Report{
+		ConnID:    event.ConnID,
+		TxID:      event.TxID,
+		StartedAt: event.StartedAt,
+	}}
+	h.mu.Unlock()
+}
+
+func (h *Hook) BeforeCommit(ctx context.Context, event sqlhooks.TxEvent) {}
+
+func (h *Hook) AfterCommit(ctx context.Context, event sqlhooks.TxEvent) {
+	outcome := Committed
+	if event.Err != nil {
+		outcome = CommitFailed
+	}
+	h.end(event, outcome)
+}
+
+func (h *Hook) BeforeRollback(ctx context.Context, event sqlhooks.TxEvent) {}
+
+func (h *Hook) AfterRollback(ctx context.Context, event sqlhooks.TxEvent) {
+	outcome := RolledBack
+	if event.Err != nil {
+		outcome = RollbackFailed
+	}
+	h.end(event, outcome)
+}
+
+// end forgets the transaction of event, reporting it if it was slow
+func (h *Hook) end(event sqlhooks.TxEvent, outcome Outcome) {
+	h.mu.Lock()
+	t, ok := h.txs[event.TxID]
+	delete(h.txs, event.TxID)
+	h.mu.Unlock()
+
+	if !ok || event.Duration < h.cfg.Threshold || h.cfg.OnReport == nil {
+		return
+	}
+	r := t.report
+	r.Duration, r.Outcome, r.Err = event.Duration, outcome, event.Err
+	if !t.firstWrite.IsZero() {
+		r.LocksHeld = r.StartedAt.Add(r.Duration).Sub(t.firstWrite)
+	}
+	h.cfg.OnReport(r)
+}
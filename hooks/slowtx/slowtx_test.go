@@ -0,0 +1,92 @@
+package slowtx
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) *sql.DB {
+	driverName := fmt.Sprintf("slowtx-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	return db
+}
+
+func TestReport(t *testing.T) {
+	var reports []Report
+	db := open(t, Config{
+		Threshold:     10 * time.Millisecond,
+		MaxStatements: 2,
+		OnReport:      func(r Report) { reports = append(reports, r) },
+	})
+	defer db.Close()
+
+	// Fast transactions are not reported
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Empty(t, reports)
+
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	rows, err := tx.Query("SELECT id FROM t")
+	require.NoError(t, err)
+	rows.Close()
+	time.Sleep(10 * time.Millisecond)
+	_, err = tx.Exec("UPDATE t SET id = 2")
+	require.NoError(t, err)
+	_, err = tx.Exec("UPDATE missing SET id = 2")
+	require.Error(t, err)
+	require.NoError(t, tx.Rollback())
+
+	require.Len(t, reports, 1)
+	r := reports[0]
+	assert.Equal(t, RolledBack, r.Outcome)
+	assert.NoError(t, r.Err)
+	assert.NotEmpty(t, r.TxID)
+	assert.True(t, r.Duration >= 10*time.Millisecond)
+	assert.True(t, r.LocksHeld > 0 && r.LocksHeld < 10*time.Millisecond, r.LocksHeld)
+	require.Len(t, r.Statements, 2)
+	assert.Equal(t, 1, r.Dropped)
+	assert.Equal(t, "SELECT id FROM t", r.Statements[0].Query)
+	assert.Equal(t, "UPDATE t SET id = 2", r.Statements[1].Query)
+	assert.True(t, r.Statements[1].Offset >= 10*time.Millisecond)
+	assert.Contains(t, r.String(), "rolled back")
+	assert.Contains(t, r.String(), "... 1 more")
+}
+
+func TestReadOnly(t *testing.T) {
+	var reports []Report
+	db := open(t, Config{
+		Threshold: time.Millisecond,
+		OnReport:  func(r Report) { reports = append(reports, r) },
+	})
+	defer db.Close()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	rows, err := tx.Query("SELECT 1")
+	require.NoError(t, err)
+	rows.Close()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, tx.Commit())
+
+	require.Len(t, reports, 1)
+	assert.Equal(t, Committed, reports[0].Outcome)
+	assert.Zero(t, reports[0].LocksHeld)
+	require.Len(t, reports[0].Statements, 1)
+	assert.Nil(t, reports[0].Statements[0].Err)
+}
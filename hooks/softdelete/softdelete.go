@@ -0,0 +1,212 @@
+// Package softdelete enforces soft-delete semantics below the ORM: rows of
+// the registered tables whose deleted_at column is set are filtered out of
+// SELECT, UPDATE and DELETE statements.
+//
+//	rw := softdelete.New("deleted_at", "users", "orders")
+//	sql.Register("postgres-softdelete", sqlhooks.Wrap(&pq.Driver{}, rw))
+//
+//	SELECT * FROM users u JOIN orders o ON o.user_id = u.id WHERE u.id = $1
+//	SELECT * FROM users u JOIN orders o ON o.deleted_at IS NULL AND (o.user_id = u.id) WHERE u.deleted_at IS NULL AND (u.id = $1)
+//
+// Only the main statement is rewritten: tables referenced in subqueries,
+// common table expressions or set operations are left alone, as are joins
+// without an ON clause. Statements that soft delete or restore rows must
+// see deleted rows, run them with a context returned by WithDeleted.
+package softdelete
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+type withDeletedKey struct{}
+
+// WithDeleted returns a context whose statements are not rewritten
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+// Rewriter is a sqlhooks.Interceptor adding the soft-delete filter to the
+// statements on the registered tables
+type Rewriter struct {
+	column string
+	tables map[string]bool
+}
+
+// New returns a Rewriter filtering out the rows of tables whose column is not
+// NULL. column defaults to deleted_at. Table names are matched without their
+// schema, ignoring case.
+func New(column string, tables ...string) *Rewriter {
+	if column == "" {
+		column = "deleted_at"
+	}
+	rw := &Rewriter{column: column, tables: make(map[string]bool)}
+	for _, t := range tables {
+		rw.tables[strings.ToLower(t)] = true
+	}
+	return rw
+}
+
+func (rw *Rewriter) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (rw *Rewriter) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (rw *Rewriter) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if ctx.Value(withDeletedKey{}) == nil {
+		query = rw.Rewrite(query)
+	}
+	return next(ctx, query, args)
+}
+
+func (rw *Rewriter) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	if ctx.Value(withDeletedKey{}) == nil {
+		query = rw.Rewrite(query)
+	}
+	return next(ctx, query, args)
+}
+
+// whereFollowers are the clauses a WHERE clause comes before
+var whereFollowers = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "UNION": true, "EXCEPT": true,
+	"INTERSECT": true, "RETURNING": true, "FOR": true, "WINDOW": true, "OFFSET": true, "FETCH": true,
+}
+
+// Rewrite returns query with the soft-delete filter added for the registered
+// tables it references, or query itself if there are none
+func (rw *Rewriter) Rewrite(query string) string {
+	switch sqlutil.Classify(query) {
+	case sqlutil.Select, sqlutil.Update, sqlutil.Delete:
+	default:
+		return query
+	}
+
+	tokens := sqlutil.Tokenize(query)
+	depths := depths(tokens)
+	inserts := make(map[int]string)
+
+	var (
+		where []string
+		last  = -1
+	)
+	for _, ref := range sqlutil.Tables(tokens) {
+		if ref.Depth != 0 || ref.Keyword == "INTO" {
+			continue
+		}
+		last = ref.End
+		if !rw.tables[strings.ToLower(ref.Name)] {
+			continue
+		}
+		cond := ref.Qualifier(tokens) + "." + rw.column + " IS NULL"
+		if ref.Keyword != "JOIN" {
+			where = append(where, cond)
+			continue
+		}
+
+		on := skipSpace(tokens, ref.End)
+		if on >= len(tokens) || !tokens[on].IsKeyword("ON") {
+			continue
+		}
+		start := skipSpace(tokens, on+1)
+		end := exprEnd(tokens, depths, start, func(i int) bool {
+			return sqlutil.IsClauseKeyword(tokens[i]) && !isCall(tokens, i)
+		})
+		inserts[start] += cond + " AND ("
+		inserts[end] = ")" + inserts[end]
+		last = end
+	}
+	if len(where) == 0 {
+		if len(inserts) == 0 {
+			return query
+		}
+		return apply(tokens, inserts)
+	}
+	cond := strings.Join(where, " AND ")
+
+	isFollower := func(i int) bool {
+		return tokens[i].Type == sqlutil.Word && whereFollowers[strings.ToUpper(tokens[i].Text)]
+	}
+	for i := last; i < len(tokens); i++ {
+		if depths[i] != 0 || !tokens[i].IsKeyword("WHERE") {
+			continue
+		}
+		start := skipSpace(tokens, i+1)
+		end := exprEnd(tokens, depths, start, isFollower)
+		inserts[start] += cond + " AND ("
+		inserts[end] = ")" + inserts[end]
+		return apply(tokens, inserts)
+	}
+
+	end := exprEnd(tokens, depths, last, isFollower)
+	inserts[end] = " WHERE " + cond + inserts[end]
+	return apply(tokens, inserts)
+}
+
+// depths returns the parenthesis depth of every token
+func depths(tokens []sqlutil.Token) []int {
+	d := make([]int, len(tokens))
+	depth := 0
+	for i, t := range tokens {
+		if t.Type == sqlutil.Punct && t.Text == ")" && depth > 0 {
+			depth--
+		}
+		d[i] = depth
+		if t.Type == sqlutil.Punct && t.Text == "(" {
+			depth++
+		}
+	}
+	return d
+}
+
+// exprEnd returns the index following the last significant token of the
+// expression starting at tokens[start], which ends before a token at the
+// same depth matching stop, a semicolon, or the end of its parentheses.
+func exprEnd(tokens []sqlutil.Token, depths []int, start int, stop func(i int) bool) int {
+	if start >= len(tokens) {
+		return len(tokens)
+	}
+	depth := depths[start]
+	end := start
+	for i := start; i < len(tokens); i++ {
+		t := tokens[i]
+		if depths[i] < depth || depths[i] == depth && (t.Text == ";" || stop(i)) {
+			break
+		}
+		if t.Type != sqlutil.Space && t.Type != sqlutil.Comment {
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// isCall reports whether the keyword at tokens[i] is a function call, such
+// as LEFT(name, 1)
+func isCall(tokens []sqlutil.Token, i int) bool {
+	j := skipSpace(tokens, i+1)
+	return j < len(tokens) && tokens[j].Text == "("
+}
+
+func skipSpace(tokens []sqlutil.Token, i int) int {
+	for i < len(tokens) && (tokens[i].Type == sqlutil.Space || tokens[i].Type == sqlutil.Comment) {
+		i++
+	}
+	return i
+}
+
+// apply returns the text of tokens with inserts[i] inserted before tokens[i]
+func apply(tokens []sqlutil.Token, inserts map[int]string) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		b.WriteString(inserts[i])
+		b.WriteString(t.Text)
+	}
+	b.WriteString(inserts[len(tokens)])
+	return b.String()
+}
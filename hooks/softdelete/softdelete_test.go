@@ -0,0 +1,60 @@
+package softdelete
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	rw := New("", "users", "orders")
+	for query, want := range map[string]string{
+		"SELECT * FROM users":        "SELECT * FROM users WHERE users.deleted_at IS NULL",
+		"SELECT * FROM users;":       "SELECT * FROM users WHERE users.deleted_at IS NULL;",
+		"SELECT * FROM users -- all": "SELECT * FROM users WHERE users.deleted_at IS NULL -- all",
+		"SELECT * FROM public.users WHERE a = 1 OR b = 2 ORDER BY id":                                         "SELECT * FROM public.users WHERE public.users.deleted_at IS NULL AND (a = 1 OR b = 2) ORDER BY id",
+		"SELECT * FROM users u LEFT JOIN orders o ON o.user_id = u.id OR LEFT(o.ref, 1) = 'x' WHERE u.id = ?": "SELECT * FROM users u LEFT JOIN orders o ON o.deleted_at IS NULL AND (o.user_id = u.id OR LEFT(o.ref, 1) = 'x') WHERE u.deleted_at IS NULL AND (u.id = ?)",
+		"SELECT * FROM items i JOIN orders o ON o.id = i.order_id GROUP BY o.id":                              "SELECT * FROM items i JOIN orders o ON o.deleted_at IS NULL AND (o.id = i.order_id) GROUP BY o.id",
+		"SELECT * FROM users, orders LIMIT 1":                                                                 "SELECT * FROM users, orders WHERE users.deleted_at IS NULL AND orders.deleted_at IS NULL LIMIT 1",
+		"UPDATE users SET name = ? WHERE id = ?":                                                              "UPDATE users SET name = ? WHERE users.deleted_at IS NULL AND (id = ?)",
+		"UPDATE users SET name = ?":                                                                           "UPDATE users SET name = ? WHERE users.deleted_at IS NULL",
+		"DELETE FROM users WHERE id IN (SELECT id FROM users)":                                                "DELETE FROM users WHERE users.deleted_at IS NULL AND (id IN (SELECT id FROM users))",
+		"SELECT id FROM (SELECT * FROM users) sub":                                                            "SELECT id FROM (SELECT * FROM users) sub",
+		"SELECT * FROM items":                                                                                 "SELECT * FROM items",
+		"INSERT INTO users (name) VALUES (?)":                                                                 "INSERT INTO users (name) VALUES (?)",
+	} {
+		assert.Equal(t, want, rw.Rewrite(query), query)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	driverName := fmt.Sprintf("softdelete-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New("removed_at", "users")))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE users(id int, removed_at int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users VALUES (1, NULL), (2, 1)")
+	require.NoError(t, err)
+
+	count := func(ctx context.Context) (n int) {
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&n))
+		return n
+	}
+	assert.Equal(t, 1, count(context.Background()))
+	assert.Equal(t, 2, count(WithDeleted(context.Background())))
+
+	_, err = db.ExecContext(WithDeleted(context.Background()), "UPDATE users SET removed_at = NULL WHERE id = 2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count(context.Background()))
+}
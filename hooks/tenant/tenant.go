@@ -0,0 +1,241 @@
+// Package tenant routes statements to the schema of the tenant found in their
+// context, for schema-per-tenant architectures.
+//
+// In Prefix mode, unqualified table names are prefixed with the tenant
+// schema. In SearchPath mode, the statements are left untouched and the
+// connection is switched to the tenant schema before running them, with
+// SET search_path by default. The schema of every connection is tracked, so
+// that the switch only happens when a connection is reused for another
+// tenant.
+//
+//	h := tenant.New(tenant.Config{Mode: tenant.SearchPath, Default: "public"})
+//	sql.Register("postgres-tenant", sqlhooks.Wrap(&pq.Driver{}, h))
+//
+//	db.QueryContext(tenant.WithTenant(ctx, "acme"), "SELECT * FROM invoices")
+package tenant
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+var (
+	// ErrNoTenant is returned when Config.Required is set and the context
+	// of a statement has no tenant
+	ErrNoTenant = errors.New("tenant: no tenant in context")
+	// ErrInvalidTenant is returned for tenant schemas that are not plain
+	// identifiers, which are never interpolated into statements
+	ErrInvalidTenant = errors.New("tenant: invalid tenant schema")
+)
+
+// Mode selects how statements are routed to the tenant schema
+type Mode int
+
+const (
+	// Prefix qualifies the table names of the statements with the schema
+	Prefix Mode = iota
+	// SearchPath switches the connection to the schema before running the
+	// statements
+	SearchPath
+)
+
+// Config configures a Hook
+type Config struct {
+	Mode Mode
+	// Default is the schema used for statements without a tenant. If
+	// empty, such statements are left alone in Prefix mode and run with
+	// the schema the connection was last switched to in SearchPath mode.
+	Default string
+	// Required makes statements without a tenant fail with ErrNoTenant.
+	Required bool
+	// SetSchema is the statement switching the connection to a schema in
+	// SearchPath mode, with a %s verb for the schema. Defaults to
+	// "SET search_path TO %s"; use "USE %s" with MySQL.
+	SetSchema string
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose statements run in schema
+func WithTenant(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, schema)
+}
+
+// FromContext returns the tenant schema of ctx, if any
+func FromContext(ctx context.Context) (string, bool) {
+	schema, ok := ctx.Value(tenantKey{}).(string)
+	return schema, ok
+}
+
+// Hook is a sqlhooks.Interceptor routing statements to the tenant schema
+type Hook struct {
+	cfg Config
+
+	mu    sync.Mutex
+	conns map[uint64]string // schema of each connection, in SearchPath mode
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.SetSchema == "" {
+		cfg.SetSchema = "SET search_path TO %s"
+	}
+	return &Hook{cfg: cfg, conns: make(map[uint64]string)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) OnConnOpen(event sqlhooks.ConnEvent) {}
+
+// OnConnClose forgets the schema of the connection
+func (h *Hook) OnConnClose(event sqlhooks.ConnEvent) {
+	h.mu.Lock()
+	delete(h.conns, event.ConnID)
+	h.mu.Unlock()
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, err := h.route(ctx, query, func(set string) error {
+		rows, err := next(ctx, set, nil)
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, query, args)
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, err := h.route(ctx, query, func(set string) error {
+		_, err := next(ctx, set, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, query, args)
+}
+
+// route returns the statement to run for query, after switching the
+// connection schema with exec if needed
+func (h *Hook) route(ctx context.Context, query string, exec func(set string) error) (string, error) {
+	schema, ok := FromContext(ctx)
+	if !ok {
+		if h.cfg.Required {
+			return "", ErrNoTenant
+		}
+		schema = h.cfg.Default
+	}
+	if schema == "" {
+		return query, nil
+	}
+	if !isIdent(schema) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, schema)
+	}
+
+	if h.cfg.Mode == Prefix {
+		return Qualify(query, schema), nil
+	}
+
+	event := sqlhooks.EventFromContext(ctx)
+	if event == nil {
+		return "", errors.New("tenant: SearchPath mode requires the sqlhooks driver")
+	}
+	h.mu.Lock()
+	current := h.conns[event.ConnID]
+	h.mu.Unlock()
+	if current == schema {
+		return query, nil
+	}
+
+	if err := exec(fmt.Sprintf(h.cfg.SetSchema, schema)); err != nil {
+		// The schema of the connection is unknown
+		h.mu.Lock()
+		delete(h.conns, event.ConnID)
+		h.mu.Unlock()
+		return "", err
+	}
+	h.mu.Lock()
+	h.conns[event.ConnID] = schema
+	h.mu.Unlock()
+	return query, nil
+}
+
+// Qualify returns query with its unqualified table names prefixed with
+// schema. Names of common table expressions are left alone.
+func Qualify(query, schema string) string {
+	tokens := sqlutil.Tokenize(query)
+	ctes := cteNames(tokens)
+
+	var b strings.Builder
+	refs := sqlutil.Tables(tokens)
+	for i, t := range tokens {
+		for len(refs) > 0 && refs[0].Start < i {
+			refs = refs[1:]
+		}
+		if len(refs) > 0 && refs[0].Start == i && refs[0].Schema == "" && !ctes[strings.ToLower(refs[0].Name)] {
+			b.WriteString(schema)
+			b.WriteString(".")
+		}
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+// cteNames returns the lowercased names of the common table expressions
+// defined in tokens: name AS ( or name (columns) AS (
+func cteNames(tokens []sqlutil.Token) map[string]bool {
+	names := make(map[string]bool)
+	var significant []sqlutil.Token
+	for _, t := range tokens {
+		if t.Type != sqlutil.Space && t.Type != sqlutil.Comment {
+			significant = append(significant, t)
+		}
+	}
+	for i := 0; i+2 < len(significant); i++ {
+		name := significant[i]
+		if name.Type != sqlutil.Word && name.Type != sqlutil.QuotedIdent {
+			continue
+		}
+		j := i + 1
+		if significant[j].Text == "(" {
+			for j < len(significant) && significant[j].Text != ")" {
+				j++
+			}
+			j++
+		}
+		if j+1 < len(significant) && significant[j].IsKeyword("AS") && significant[j+1].Text == "(" &&
+			i > 0 && (significant[i-1].IsKeyword("WITH") || significant[i-1].IsKeyword("RECURSIVE") || significant[i-1].Text == ",") {
+			names[strings.ToLower(strings.Trim(name.Text, "\"`[]"))] = true
+		}
+	}
+	return names
+}
+
+func isIdent(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,86 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualify(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT * FROM invoices i JOIN lines ON lines.invoice_id = i.id": "SELECT * FROM acme.invoices i JOIN acme.lines ON lines.invoice_id = i.id",
+		"SELECT * FROM public.plans":                                     "SELECT * FROM public.plans",
+		"INSERT INTO invoices (id) SELECT id FROM drafts":                "INSERT INTO acme.invoices (id) SELECT id FROM acme.drafts",
+		"WITH recent AS (SELECT * FROM invoices) SELECT * FROM recent":   "WITH recent AS (SELECT * FROM acme.invoices) SELECT * FROM recent",
+		"UPDATE invoices SET paid = true":                                "UPDATE acme.invoices SET paid = true",
+	} {
+		assert.Equal(t, want, Qualify(query, "acme"), query)
+	}
+}
+
+func open(t *testing.T, h *Hook) (*sql.DB, *[]string) {
+	var queries []string
+	record := func(query string) { queries = append(queries, query) }
+	driverName := fmt.Sprintf("tenant-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, sqlhooks.Compose(h, &sqlhooks.Interceptors{
+		Query: func(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+			record(query)
+			return next(ctx, query, args)
+		},
+		Exec: func(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			record(query)
+			return next(ctx, query, args)
+		},
+	})))
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return db, &queries
+}
+
+func TestSearchPath(t *testing.T) {
+	db, queries := open(t, New(Config{Mode: SearchPath, Default: "public"}))
+	defer db.Close()
+
+	acme := WithTenant(context.Background(), "acme")
+	for _, ctx := range []context.Context{acme, acme, context.Background()} {
+		_, err := db.ExecContext(ctx, "UPDATE invoices SET paid = true")
+		require.NoError(t, err)
+	}
+	rows, err := db.QueryContext(acme, "SELECT * FROM invoices")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{
+		"SET search_path TO acme",
+		"UPDATE invoices SET paid = true",
+		"UPDATE invoices SET paid = true",
+		"SET search_path TO public",
+		"UPDATE invoices SET paid = true",
+		"SET search_path TO acme",
+		"SELECT * FROM invoices",
+	}, *queries)
+}
+
+func TestPrefix(t *testing.T) {
+	db, queries := open(t, New(Config{Mode: Prefix, Required: true}))
+	defer db.Close()
+
+	_, err := db.ExecContext(WithTenant(context.Background(), "acme"), "DELETE FROM invoices")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM invoices")
+	assert.Equal(t, ErrNoTenant, err)
+	_, err = db.ExecContext(WithTenant(context.Background(), "acme; DROP TABLE x"), "DELETE FROM invoices")
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+
+	assert.Equal(t, []string{"DELETE FROM acme.invoices"}, *queries)
+}
@@ -0,0 +1,156 @@
+// Package utf8check validates the string arguments of the statements before
+// they reach the driver, rejecting or sanitizing the invalid UTF-8 and the
+// NUL bytes: the servers refuse them with obscure errors, such as "invalid
+// byte sequence for encoding UTF8" or a truncated value, long after the
+// faulty input was accepted.
+//
+//	h := utf8check.New(utf8check.Postgres)
+//	sql.Register("postgres-utf8", sqlhooks.Wrap(&pq.Driver{}, sqlhooks.Compose(h, hooks)))
+//
+// The hooks are configured per wrapped driver, as the databases differ:
+// PostgreSQL rejects the NUL bytes of text values while MySQL and SQLite
+// store them, and SQLite accepts any byte. Postgres and MySQL are the
+// configurations matching their servers.
+//
+// Rejected arguments fail the statement with an *InvalidError, before the
+// other hooks run. Only the string arguments are checked, []byte arguments
+// being binary.
+package utf8check
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policy tells what to do with the invalid arguments
+type Policy int
+
+const (
+	// Ignore passes the arguments to the driver unchanged
+	Ignore Policy = iota
+	// Reject fails the statement with an *InvalidError
+	Reject
+	// Sanitize replaces the invalid bytes
+	Sanitize
+)
+
+// Config configures a Hook
+type Config struct {
+	// InvalidUTF8 applies to the strings which are not valid UTF-8.
+	// Sanitize replaces each invalid sequence with InvalidReplacement,
+	// U+FFFD by default.
+	InvalidUTF8        Policy
+	InvalidReplacement string
+	// NUL applies to the strings containing NUL bytes. Sanitize removes
+	// them, or replaces them with NULReplacement.
+	NUL            Policy
+	NULReplacement string
+}
+
+var (
+	// Postgres rejects invalid UTF-8 and NUL bytes, as the server does
+	Postgres = Config{InvalidUTF8: Reject, NUL: Reject}
+	// MySQL rejects invalid UTF-8, refused by the utf8mb4 columns
+	MySQL = Config{InvalidUTF8: Reject}
+)
+
+// InvalidError is returned for the rejected arguments
+type InvalidError struct {
+	Query string
+	// Ordinal is the position of the argument, starting at 1, and Name its
+	// name for the named arguments
+	Ordinal int
+	Name    string
+	// Offset is the offset of the first invalid byte in the argument
+	Offset int
+	// Reason is "invalid UTF-8" or "NUL byte"
+	Reason string
+}
+
+func (e *InvalidError) Error() string {
+	arg := fmt.Sprintf("$%d", e.Ordinal)
+	if e.Name != "" {
+		arg = "@" + e.Name
+	}
+	return fmt.Sprintf("utf8check: argument %s has %s at byte %d", arg, e.Reason, e.Offset)
+}
+
+// Hook validates the string arguments. It is a sqlhooks.ArgsMutator.
+type Hook struct {
+	cfg Config
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.InvalidReplacement == "" {
+		cfg.InvalidReplacement = string(utf8.RuneError)
+	}
+	return &Hook{cfg: cfg}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	// The arguments are only copied once one of them is sanitized
+	checked, copied := args, false
+	for i, arg := range args {
+		s, ok := arg.Value.(string)
+		if !ok {
+			continue
+		}
+		fixed, err := h.check(s)
+		if err != nil {
+			err.Query, err.Ordinal, err.Name = query, arg.Ordinal, arg.Name
+			return ctx, nil, err
+		}
+		if fixed == s {
+			continue
+		}
+		if !copied {
+			checked, copied = make([]driver.NamedValue, len(args)), true
+			copy(checked, args)
+		}
+		checked[i].Value = fixed
+	}
+	return ctx, checked, nil
+}
+
+// check returns s sanitized, or an error if it is rejected
+func (h *Hook) check(s string) (string, *InvalidError) {
+	if h.cfg.NUL != Ignore {
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			if h.cfg.NUL == Reject {
+				return s, &InvalidError{Offset: i, Reason: "NUL byte"}
+			}
+			s = strings.Replace(s, "\x00", h.cfg.NULReplacement, -1)
+		}
+	}
+	if h.cfg.InvalidUTF8 != Ignore && !utf8.ValidString(s) {
+		if h.cfg.InvalidUTF8 == Reject {
+			return s, &InvalidError{Offset: invalidOffset(s), Reason: "invalid UTF-8"}
+		}
+		s = strings.ToValidUTF8(s, h.cfg.InvalidReplacement)
+	}
+	return s, nil
+}
+
+// invalidOffset returns the offset of the first invalid sequence of s
+func invalidOffset(s string) int {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return i
+			}
+		}
+	}
+	return -1
+}
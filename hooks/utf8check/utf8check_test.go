@@ -0,0 +1,63 @@
+package utf8check
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) *sql.DB {
+	driverName := fmt.Sprintf("utf8check-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db
+}
+
+func TestReject(t *testing.T) {
+	db := open(t, Postgres)
+	defer db.Close()
+
+	var s string
+	require.NoError(t, db.QueryRow("SELECT ?", "héllo").Scan(&s))
+	assert.Equal(t, "héllo", s)
+
+	err := db.QueryRow("SELECT ?, ?", "ok", "ab\x00c").Scan(&s, &s)
+	require.IsType(t, &InvalidError{}, err)
+	assert.Equal(t, 2, err.(*InvalidError).Ordinal)
+	assert.Equal(t, 2, err.(*InvalidError).Offset)
+	assert.EqualError(t, err, "utf8check: argument $2 has NUL byte at byte 2")
+
+	_, err = db.Exec("SELECT :name", sql.Named("name", "h\xffi"))
+	assert.EqualError(t, err, "utf8check: argument @name has invalid UTF-8 at byte 1")
+
+	// Binary arguments are not checked
+	var b []byte
+	require.NoError(t, db.QueryRow("SELECT ?", []byte("\xff\x00")).Scan(&b))
+	assert.Equal(t, []byte("\xff\x00"), b)
+}
+
+func TestSanitize(t *testing.T) {
+	db := open(t, Config{InvalidUTF8: Sanitize, NUL: Sanitize})
+	defer db.Close()
+
+	var a, b string
+	require.NoError(t, db.QueryRow("SELECT ?, ?", "a\x00b", "h\xff\xfei").Scan(&a, &b))
+	assert.Equal(t, "ab", a)
+	assert.Equal(t, "h�i", b)
+}
+
+func TestIgnore(t *testing.T) {
+	db := open(t, MySQL)
+	defer db.Close()
+
+	var s string
+	require.NoError(t, db.QueryRow("SELECT ?", "a\x00b").Scan(&s))
+	assert.Equal(t, "a\x00b", s)
+}
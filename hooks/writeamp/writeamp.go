@@ -0,0 +1,140 @@
+// Package writeamp detects write amplification: INSERT, UPDATE and DELETE
+// statements affecting far more rows than they usually do, such as an UPDATE
+// whose WHERE clause was dropped by a refactoring or an argument that was
+// unexpectedly empty.
+//
+// The rows affected by every write are learned per statement fingerprint, as
+// an exponentially weighted moving average, and an alert is raised when a
+// statement affects Factor times more rows than its baseline:
+//
+//	h := writeamp.New(writeamp.Config{OnAlert: func(a writeamp.Alert) {
+//		log.Printf("%s affected %d rows, usually %.0f", a.Query, a.Rows, a.Baseline)
+//	}})
+//	sql.Register("postgres-writeamp", sqlhooks.Wrap(&pq.Driver{}, h))
+package writeamp
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Alert describes a write affecting unexpectedly many rows
+type Alert struct {
+	Query       string
+	Fingerprint string
+	// Rows is the number of rows affected by the statement.
+	Rows int64
+	// Baseline is the average number of rows the fingerprint affected
+	// before, over Samples statements.
+	Baseline float64
+	Samples  int
+}
+
+// Config configures a Hook
+type Config struct {
+	// Factor is the ratio of rows affected over the baseline raising an
+	// alert. Defaults to 100.
+	Factor float64
+	// MinRows is the number of rows affected below which no alert is
+	// raised relative to the baseline. Defaults to 1000.
+	MinRows int64
+	// MaxRows, if positive, raises an alert for every write affecting more
+	// rows, whatever its baseline.
+	MaxRows int64
+	// Warmup is the number of statements needed to learn the baseline of a
+	// fingerprint before alerting on it. Defaults to 10.
+	Warmup int
+	// Alpha is the weight of a new statement in the baseline. Defaults to
+	// 0.1.
+	Alpha float64
+	// OnAlert is called synchronously after the statement
+	OnAlert func(Alert)
+}
+
+type baseline struct {
+	avg     float64
+	samples int
+}
+
+// Hook is a sqlhooks.Interceptor learning the rows affected by writes
+type Hook struct {
+	cfg Config
+
+	mu        sync.Mutex
+	baselines map[string]*baseline
+}
+
+// New returns a new Hook
+func New(cfg Config) *Hook {
+	if cfg.Factor <= 0 {
+		cfg.Factor = 100
+	}
+	if cfg.MinRows <= 0 {
+		cfg.MinRows = 1000
+	}
+	if cfg.Warmup <= 0 {
+		cfg.Warmup = 10
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = 0.1
+	}
+	return &Hook{cfg: cfg, baselines: make(map[string]*baseline)}
+}
+
+func (h *Hook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *Hook) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return next(ctx, query, args)
+}
+
+func (h *Hook) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := next(ctx, query, args)
+	if err != nil || result == nil {
+		return result, err
+	}
+	switch sqlutil.Classify(query) {
+	case sqlutil.Insert, sqlutil.Update, sqlutil.Delete:
+	default:
+		return result, err
+	}
+	if rows, err := result.RowsAffected(); err == nil {
+		h.observe(query, rows)
+	}
+	return result, nil
+}
+
+// observe updates the baseline of query with rows and raises an alert if
+// needed
+func (h *Hook) observe(query string, rows int64) {
+	fp := sqlutil.Fingerprint(query)
+
+	h.mu.Lock()
+	b, ok := h.baselines[fp]
+	if !ok {
+		b = &baseline{}
+		h.baselines[fp] = b
+	}
+	alert := Alert{Query: query, Fingerprint: fp, Rows: rows, Baseline: b.avg, Samples: b.samples}
+	amplified := b.samples >= h.cfg.Warmup && rows >= h.cfg.MinRows && float64(rows) > h.cfg.Factor*b.avg
+	if b.samples == 0 {
+		b.avg = float64(rows)
+	} else {
+		b.avg += h.cfg.Alpha * (float64(rows) - b.avg)
+	}
+	b.samples++
+	h.mu.Unlock()
+
+	if (amplified || h.cfg.MaxRows > 0 && rows > h.cfg.MaxRows) && h.cfg.OnAlert != nil {
+		h.cfg.OnAlert(alert)
+	}
+}
@@ -0,0 +1,46 @@
+package writeamp
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAmplification(t *testing.T) {
+	var alerts []Alert
+	h := New(Config{Warmup: 3, MinRows: 100, OnAlert: func(a Alert) { alerts = append(alerts, a) }})
+	driverName := fmt.Sprintf("writeamp-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, h))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id INTEGER, paid BOOLEAN)")
+	require.NoError(t, err)
+	_, err = db.Exec("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c WHERE x < 500) INSERT INTO t SELECT x, 0 FROM c")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("UPDATE t SET paid = 1 WHERE id > ?", 499)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec("DELETE FROM t WHERE id = 1")
+	require.NoError(t, err)
+	assert.Empty(t, alerts, "no baseline yet")
+
+	// The same statement with an argument matching every row
+	_, err = db.Exec("UPDATE t SET paid = 1 WHERE id > ?", 0)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "UPDATE t SET paid = 1 WHERE id > ?", alerts[0].Query)
+	assert.Equal(t, int64(499), alerts[0].Rows)
+	assert.Equal(t, 1.0, alerts[0].Baseline)
+	assert.Equal(t, 3, alerts[0].Samples)
+}
@@ -0,0 +1,67 @@
+package zaphooks
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func open(t *testing.T, cfg Config) (*sql.DB, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	cfg.Logger = zap.New(core)
+	driverName := fmt.Sprintf("zaphooks-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db, logs
+}
+
+func TestHook(t *testing.T) {
+	db, logs := open(t, Config{})
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t (name TEXT)")
+	require.NoError(t, err)
+	logs.TakeAll()
+
+	_, err = db.Exec("INSERT INTO t VALUES (?), (?)", "alice", "bob")
+	require.NoError(t, err)
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "statement", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "exec", fields["op"])
+	assert.Equal(t, "INSERT INTO t VALUES (?), (?)", fields["query"])
+	assert.Equal(t, int64(2), fields["args"])
+	assert.Equal(t, int64(2), fields["rows_affected"])
+
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+	entries = logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	assert.Contains(t, entries[0].ContextMap()["error"], "no such table")
+}
+
+func TestHookSlow(t *testing.T) {
+	db, logs := open(t, Config{SlowThreshold: time.Nanosecond, LogArgs: true, Redact: Redacted})
+	defer db.Close()
+
+	var s string
+	require.NoError(t, db.QueryRow("SELECT ?", "secret").Scan(&s))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "slow statement", entries[0].Message)
+	assert.Equal(t, []interface{}{"<string>"}, entries[0].ContextMap()["args"])
+}
@@ -0,0 +1,81 @@
+package zerologhooks
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, cfg Config) (*sql.DB, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+	cfg.Logger = &logger
+	driverName := fmt.Sprintf("zerologhooks-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, New(cfg)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	return db, buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		rec := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+	buf.Reset()
+	return recs
+}
+
+func TestHook(t *testing.T) {
+	db, buf := open(t, Config{})
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t (name TEXT)")
+	require.NoError(t, err)
+	records(t, buf)
+
+	_, err = db.Exec("INSERT INTO t VALUES (?), (?)", "alice", "bob")
+	require.NoError(t, err)
+	recs := records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "debug", recs[0]["level"])
+	assert.Equal(t, "statement", recs[0]["message"])
+	assert.Equal(t, "exec", recs[0]["op"])
+	assert.Equal(t, float64(2), recs[0]["args"])
+	assert.Equal(t, float64(2), recs[0]["rows_affected"])
+
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	require.Error(t, err)
+	recs = records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "error", recs[0]["level"])
+	assert.Contains(t, recs[0]["error"], "no such table")
+}
+
+func TestHookSlow(t *testing.T) {
+	db, buf := open(t, Config{SlowThreshold: time.Nanosecond, LogArgs: true, Redact: Redacted})
+	defer db.Close()
+
+	var s string
+	require.NoError(t, db.QueryRow("SELECT ?", "secret").Scan(&s))
+	recs := records(t, buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "warn", recs[0]["level"])
+	assert.Equal(t, "slow statement", recs[0]["message"])
+	assert.Equal(t, []interface{}{"<string>"}, recs[0]["args"])
+}
@@ -0,0 +1,81 @@
+package sqlhooks
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+)
+
+// IDKind is the kind of entity an identifier is generated for
+type IDKind int
+
+const (
+	// IDConn identifies a connection, generated when it is opened.
+	IDConn IDKind = iota
+	// IDTx identifies a transaction, generated when it begins.
+	IDTx
+	// IDOp identifies a hooked operation.
+	IDOp
+)
+
+var idKindNames = [...]string{"conn", "tx", "op"}
+
+func (k IDKind) String() string {
+	if k < 0 || int(k) >= len(idKindNames) {
+		return "unknown"
+	}
+	return idKindNames[k]
+}
+
+// IDGenerator returns a new identifier of the given kind. ctx is the context
+// of the operation the identifier is generated for; for IDConn, it is the
+// context passed to Connector.Connect, or context.Background() for the
+// connections opened by Driver.Open.
+//
+// Generators must be safe for concurrent use. They can return ULIDs,
+// snowflakes, or derive the identifiers from the trace found in ctx, so that
+// they line up with an existing correlation scheme.
+type IDGenerator func(ctx context.Context, kind IDKind) string
+
+// IDs are the identifiers of a hooked operation, see Event.IDs
+type IDs struct {
+	// Conn identifies the connection, Tx the transaction the operation
+	// runs in, if any, and Op the operation itself.
+	Conn, Tx, Op string
+}
+
+// WithIDGenerator sets the generator of the identifiers reported in
+// Event.IDs. By default they are decimal numbers, increasing per kind
+// within the process, and IDs.Conn matches Event.ConnID.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(o *options) {
+		o.idGenerator = gen
+	}
+}
+
+// txSeq and opSeq are the last transaction and operation IDs of the
+// default generator, accessed atomically
+var txSeq, opSeq uint64
+
+// newID returns a new identifier of kind, conn being the numeric ID of the
+// connection for IDConn
+func (o *options) newID(ctx context.Context, kind IDKind, conn uint64) string {
+	if o.idGenerator != nil {
+		return o.idGenerator(ctx, kind)
+	}
+	switch kind {
+	case IDConn:
+		return strconv.FormatUint(conn, 10)
+	case IDTx:
+		return strconv.FormatUint(atomic.AddUint64(&txSeq, 1), 10)
+	default:
+		return strconv.FormatUint(atomic.AddUint64(&opSeq, 1), 10)
+	}
+}
+
+// currentTxID returns the ID of the transaction running on conn, if any
+func (conn *Conn) currentTxID() string {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.txID
+}
@@ -0,0 +1,70 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requestIDKey struct{}
+
+func TestIDGenerator(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		seq = map[IDKind]int{}
+		ids []IDs
+	)
+	gen := func(ctx context.Context, kind IDKind) string {
+		mu.Lock()
+		defer mu.Unlock()
+		seq[kind]++
+		prefix, _ := ctx.Value(requestIDKey{}).(string)
+		return fmt.Sprintf("%s%s-%d", prefix, kind, seq[kind])
+	}
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		mu.Lock()
+		ids = append(ids, EventFromContext(ctx).IDs)
+		mu.Unlock()
+		return ctx, nil
+	}
+
+	driverName := fmt.Sprintf("sqlhooks-ids-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithIDGenerator(gen), WithOps(OpBegin, OpCommit)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req1/")
+	_, err = db.ExecContext(ctx, "CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	_, err = db.ExecContext(ctx, "DELETE FROM t")
+	require.NoError(t, err)
+
+	assert.Equal(t, []IDs{
+		{Conn: "conn-1", Op: "req1/op-1"},
+		{Conn: "conn-1", Tx: "req1/tx-1", Op: "req1/op-2"},
+		{Conn: "conn-1", Tx: "req1/tx-1", Op: "req1/op-3"},
+		{Conn: "conn-1", Tx: "req1/tx-1", Op: "req1/op-4"},
+		{Conn: "conn-1", Op: "req1/op-5"},
+	}, ids)
+}
+
+func TestIDKindString(t *testing.T) {
+	assert.Equal(t, "conn", IDConn.String())
+	assert.Equal(t, "op", IDOp.String())
+	assert.Equal(t, "unknown", IDKind(42).String())
+}
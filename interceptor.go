@@ -0,0 +1,76 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// QueryFunc runs a query on the underlying driver
+type QueryFunc func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error)
+
+// ExecFunc runs a statement on the underlying driver
+type ExecFunc func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error)
+
+// Interceptor instances wrap the calls to the underlying driver, as an
+// alternative to pairing Before and After hooks. An interceptor may time or
+// retry the call, short-circuit it by not calling next, or call next with a
+// different query or arguments.
+//
+// Interceptors run after the Before hooks and before the After or OnError
+// hooks, so the context they receive carries the values added by Before.
+// When an interceptor changes the query of a prepared statement, the new
+// query is prepared and run once on the same connection.
+type Interceptor interface {
+	InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error)
+	InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error)
+}
+
+// chainQuery returns a QueryFunc calling interceptor with next
+func chainQuery(interceptor Interceptor, next QueryFunc) QueryFunc {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		return interceptor.InterceptQuery(ctx, next, query, args)
+	}
+}
+
+// chainExec returns an ExecFunc calling interceptor with next
+func chainExec(interceptor Interceptor, next ExecFunc) ExecFunc {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		return interceptor.InterceptExec(ctx, next, query, args)
+	}
+}
+
+// Interceptors implements Hooks and Interceptor with plain functions, so it
+// can be passed to Wrap or Compose. Nil functions call next unchanged.
+//
+//	sqlhooks.Wrap(drv, &sqlhooks.Interceptors{
+//		Query: func(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+//			defer func(start time.Time) { log.Printf("%s took %s", query, time.Since(start)) }(time.Now())
+//			return next(ctx, query, args)
+//		},
+//	})
+type Interceptors struct {
+	Query func(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error)
+	Exec  func(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error)
+}
+
+func (i *Interceptors) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i *Interceptors) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i *Interceptors) InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if i.Query == nil {
+		return next(ctx, query, args)
+	}
+	return i.Query(ctx, next, query, args)
+}
+
+func (i *Interceptors) InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	if i.Exec == nil {
+		return next(ctx, query, args)
+	}
+	return i.Exec(ctx, next, query, args)
+}
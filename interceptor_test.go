@@ -0,0 +1,93 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openWithHooks(t *testing.T, hooks Hooks) *sql.DB {
+	driverName := fmt.Sprintf("sqlhooks-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	record := func(name string) *Interceptors {
+		return &Interceptors{
+			Query: func(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+				calls = append(calls, name+" query")
+				return next(ctx, query, args)
+			},
+			Exec: func(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+				calls = append(calls, name+" exec")
+				return next(ctx, query, args)
+			},
+		}
+	}
+
+	db := openWithHooks(t, Compose(record("outer"), newTestHooks(), record("inner")))
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	rows, err := db.Query("SELECT id FROM t")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{"outer exec", "inner exec", "outer query", "inner query"}, calls)
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	denied := errors.New("denied")
+	hooks := newTestHooks()
+	var onError error
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		onError = err
+		return err
+	}
+
+	db := openWithHooks(t, Compose(hooks, &Interceptors{
+		Exec: func(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+			return nil, denied
+		},
+	}))
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t(id int)")
+	assert.Equal(t, denied, err)
+	assert.Equal(t, denied, onError)
+}
+
+func TestInterceptorRewrite(t *testing.T) {
+	db := openWithHooks(t, &Interceptors{
+		Query: func(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return next(ctx, "SELECT ? * 2", args)
+		},
+	})
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT ?", 21).Scan(&n))
+	assert.Equal(t, 42, n)
+
+	// Prepared statements run the replaced query too
+	stmt, err := db.Prepare("SELECT ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+	require.NoError(t, stmt.QueryRow(4).Scan(&n))
+	assert.Equal(t, 8, n)
+}
@@ -0,0 +1,74 @@
+package sqlhooks
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+)
+
+// WithLeakDetection reports the rows and statements garbage collected
+// without being closed to Diagnoser hooks, as LeakedRows and LeakedStmt
+// diagnostics along with the call site and stack trace of their creation.
+// Unclosed rows hold on to their connection, and leaking them eventually
+// exhausts the pool. The diagnostics are reported from a finalizer, in a
+// goroutine of the runtime, with a background context.
+//
+// database/sql closes the statements of a connection as it closes the
+// connection, so a leaked *sql.Stmt is only reported once its connection is
+// collected too. Recording the stack traces costs a stack walk per query,
+// the mode is meant for development, tests and canaries.
+func WithLeakDetection() Option {
+	return func(o *options) {
+		o.leakDetection = true
+	}
+}
+
+// leak reports the rows or statement it is attached to if it is garbage
+// collected before being released
+type leak struct {
+	hooks      Hooks
+	diagnostic Diagnostic
+}
+
+// trackLeak returns the leak to attach to new rows or a new statement, nil
+// unless WithLeakDetection is enabled
+func (conn *Conn) trackLeak(kind DiagnosticKind, query string) *leak {
+	if !conn.opts.leakDetection {
+		return nil
+	}
+	l := &leak{hooks: conn.hooks, diagnostic: Diagnostic{
+		Kind:     kind,
+		Query:    query,
+		CallSite: callSite(),
+		Stack:    string(debug.Stack()),
+	}}
+	if kind == LeakedRows {
+		l.diagnostic.Message = "rows garbage collected without being closed"
+	} else {
+		l.diagnostic.Message = "statement garbage collected without being closed"
+	}
+	runtime.SetFinalizer(l, (*leak).report)
+	return l
+}
+
+// release is called as the rows or statement are closed
+func (l *leak) release() {
+	if l != nil {
+		runtime.SetFinalizer(l, nil)
+	}
+}
+
+func (l *leak) report() {
+	diagnose(context.Background(), l.hooks, l.diagnostic)
+}
+
+// trackedRows releases its leak as it is closed
+type trackedRows struct {
+	*Rows
+	leak *leak
+}
+
+func (r *trackedRows) Close() error {
+	r.leak.release()
+	return r.Rows.Close()
+}
@@ -0,0 +1,78 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leakRecorder records the diagnostics, reported from finalizers
+type leakRecorder struct {
+	*testHooks
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+func (r *leakRecorder) OnDiagnostic(ctx context.Context, d Diagnostic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+func (r *leakRecorder) kinds() []DiagnosticKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kinds []DiagnosticKind
+	for _, d := range r.diagnostics {
+		kinds = append(kinds, d.Kind)
+	}
+	return kinds
+}
+
+func TestLeakDetection(t *testing.T) {
+	hooks := &leakRecorder{testHooks: newTestHooks()}
+	conn, err := Wrap(&sqlite3.SQLiteDriver{}, hooks, WithLeakDetection()).Open(":memory:")
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx := context.Background()
+
+	// Closed rows and statements are not reported
+	rows, err := conn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	stmt, err := conn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, stmt.Close())
+
+	func() {
+		_, err := conn.(driver.QueryerContext).QueryContext(ctx, "SELECT 2", nil)
+		require.NoError(t, err)
+		_, err = conn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT 3")
+		require.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		return len(hooks.kinds()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	assert.ElementsMatch(t, []DiagnosticKind{LeakedRows, LeakedStmt}, []DiagnosticKind{hooks.diagnostics[0].Kind, hooks.diagnostics[1].Kind})
+	for _, d := range hooks.diagnostics {
+		assert.Contains(t, d.CallSite, "leak_test.go")
+		assert.True(t, strings.Contains(d.Stack, "TestLeakDetection"), d.Stack)
+		if d.Kind == LeakedRows {
+			assert.Equal(t, "SELECT 2", d.Query)
+		} else {
+			assert.Equal(t, "SELECT 3", d.Query)
+		}
+	}
+}
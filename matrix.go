@@ -0,0 +1,175 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Matrix routes each operation to the hooks configured for it, so that hooks
+// don't have to inspect every event to decide whether it concerns them:
+//
+//	m := sqlhooks.NewMatrix().
+//		Add(logHooks, sqlhooks.OpQuery, sqlhooks.OpExec).
+//		Add(txMetrics, sqlhooks.OpBegin, sqlhooks.OpCommit, sqlhooks.OpRollback).
+//		Add(tracing) // every operation
+//	sql.Register("postgres-hooked", sqlhooks.Wrap(&pq.Driver{}, m, sqlhooks.WithOps(m.Ops()...)))
+//
+// Hooks registered for an operation run in registration order, as with
+// Compose, QueryRewriter and ArgsMutator callbacks included. The hooks of
+// OpExec also receive the ExecResultHooks and ResultHooks callbacks, those of
+// OpQuery the RowsHooks callbacks, those of OpPrepare the Diagnoser and
+// PrepareHooks callbacks, those of OpBegin, OpCommit and OpRollback the
+// matching TxHooks callbacks, and OpConnect selects the hooks receiving the
+// ConnHooks, ConnCloseErrorer, PoolObserver and SessionHooks callbacks.
+// Operations without an Event in the context, such as direct calls to the
+// Matrix, only run the hooks registered for every operation.
+type Matrix struct {
+	byOp [OpConnect + 1]composed
+	all  composed
+	ops  opSet
+}
+
+// NewMatrix returns an empty Matrix
+func NewMatrix() *Matrix {
+	return &Matrix{}
+}
+
+// Add registers hooks for the given operations, or for every operation if
+// none is given. It is not safe to call Add once the Matrix is passed to
+// Wrap.
+func (m *Matrix) Add(hooks Hooks, ops ...Op) *Matrix {
+	if len(ops) == 0 {
+		m.all = append(m.all, hooks)
+		for op := range m.byOp {
+			m.byOp[op] = append(m.byOp[op], hooks)
+		}
+		return m
+	}
+	for _, op := range ops {
+		if op > OpUnknown && op <= OpConnect {
+			m.byOp[op] = append(m.byOp[op], hooks)
+			m.ops |= newOpSet(op)
+		}
+	}
+	return m
+}
+
+// Ops returns the operations some hooks were registered for, to be passed to
+// WithOps
+func (m *Matrix) Ops() []Op {
+	var ops []Op
+	for op := OpQuery; op <= OpConnect; op++ {
+		if m.ops.has(op) || len(m.all) > 0 {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func (m *Matrix) components() []interface{} {
+	var hooks []interface{}
+	for _, c := range m.byOp {
+		hooks = append(hooks, c.components()...)
+	}
+	return hooks
+}
+
+func (m *Matrix) hooks(ctx context.Context) composed {
+	if event := EventFromContext(ctx); event != nil {
+		return m.byOp[event.Op]
+	}
+	return m.all
+}
+
+func (m *Matrix) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return m.hooks(ctx).Before(ctx, query, args...)
+}
+
+func (m *Matrix) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return m.hooks(ctx).After(ctx, query, args...)
+}
+
+func (m *Matrix) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	return m.hooks(ctx).OnError(ctx, err, query, args...)
+}
+
+func (m *Matrix) OnConnOpen(event ConnEvent)  { m.byOp[OpConnect].OnConnOpen(event) }
+func (m *Matrix) OnConnClose(event ConnEvent) { m.byOp[OpConnect].OnConnClose(event) }
+
+func (m *Matrix) OnConnCloseError(event ConnEvent) { m.byOp[OpConnect].OnConnCloseError(event) }
+
+func (m *Matrix) OnConnCreated(event PoolEvent) { m.byOp[OpConnect].OnConnCreated(event) }
+func (m *Matrix) OnConnReused(event PoolEvent)  { m.byOp[OpConnect].OnConnReused(event) }
+func (m *Matrix) OnConnRetired(event PoolEvent) { m.byOp[OpConnect].OnConnRetired(event) }
+
+func (m *Matrix) OnSessionDrift(ctx context.Context, drift SessionDrift) {
+	m.byOp[OpConnect].OnSessionDrift(ctx, drift)
+}
+
+func (m *Matrix) OnDiagnostic(ctx context.Context, diagnostic Diagnostic) {
+	m.byOp[OpPrepare].OnDiagnostic(ctx, diagnostic)
+}
+
+func (m *Matrix) BeforePrepare(ctx context.Context, event PrepareEvent) {
+	m.byOp[OpPrepare].BeforePrepare(ctx, event)
+}
+
+func (m *Matrix) AfterPrepare(ctx context.Context, event PrepareEvent) {
+	m.byOp[OpPrepare].AfterPrepare(ctx, event)
+}
+
+func (m *Matrix) BeforeBegin(ctx context.Context, event TxEvent) {
+	m.byOp[OpBegin].BeforeBegin(ctx, event)
+}
+
+func (m *Matrix) AfterBegin(ctx context.Context, event TxEvent) {
+	m.byOp[OpBegin].AfterBegin(ctx, event)
+}
+
+func (m *Matrix) BeforeCommit(ctx context.Context, event TxEvent) {
+	m.byOp[OpCommit].BeforeCommit(ctx, event)
+}
+
+func (m *Matrix) AfterCommit(ctx context.Context, event TxEvent) {
+	m.byOp[OpCommit].AfterCommit(ctx, event)
+}
+
+func (m *Matrix) BeforeRollback(ctx context.Context, event TxEvent) {
+	m.byOp[OpRollback].BeforeRollback(ctx, event)
+}
+
+func (m *Matrix) AfterRollback(ctx context.Context, event TxEvent) {
+	m.byOp[OpRollback].AfterRollback(ctx, event)
+}
+
+func (m *Matrix) AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error) {
+	return m.byOp[OpExec].AfterExec(ctx, result, query, args...)
+}
+
+func (m *Matrix) OnResult(ctx context.Context, event ResultEvent) error {
+	return m.byOp[OpExec].OnResult(ctx, event)
+}
+
+func (m *Matrix) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	return m.byOp[OpQuery].OnRowsNext(ctx, event)
+}
+
+func (m *Matrix) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	return m.byOp[OpQuery].OnRowsClose(ctx, event)
+}
+
+func (m *Matrix) BeforeWithArgs(ctx context.Context, query string, args []driver.NamedValue) (context.Context, []driver.NamedValue, error) {
+	return m.hooks(ctx).BeforeWithArgs(ctx, query, args)
+}
+
+func (m *Matrix) RewriteQuery(ctx context.Context, query string) (string, error) {
+	return m.hooks(ctx).RewriteQuery(ctx, query)
+}
+
+func (m *Matrix) InterceptQuery(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return m.byOp[OpQuery].InterceptQuery(ctx, next, query, args)
+}
+
+func (m *Matrix) InterceptExec(ctx context.Context, next ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	return m.byOp[OpExec].InterceptExec(ctx, next, query, args)
+}
@@ -0,0 +1,78 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// opRecorder records the operation and query of every Before call
+func opRecorder(calls *[]string) *testHooks {
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		op := OpUnknown
+		if event := EventFromContext(ctx); event != nil {
+			op = event.Op
+		}
+		*calls = append(*calls, fmt.Sprintf("%s %s", op, query))
+		return ctx, nil
+	}
+	return hooks
+}
+
+func TestMatrix(t *testing.T) {
+	var statements, transactions, all []string
+	m := NewMatrix().
+		Add(opRecorder(&statements), OpQuery, OpExec).
+		Add(opRecorder(&transactions), OpBegin, OpCommit, OpRollback).
+		Add(opRecorder(&all))
+	assert.Equal(t, []Op{OpQuery, OpExec, OpPrepare, OpBegin, OpCommit, OpRollback, OpPing, OpConnect}, m.Ops())
+
+	driverName := fmt.Sprintf("sqlhooks-matrix-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, m, WithOps(m.Ops()...)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	stmt, err := db.Prepare("SELECT id FROM t")
+	require.NoError(t, err)
+	require.NoError(t, stmt.Close())
+
+	assert.Equal(t, []string{"exec CREATE TABLE t(id int)"}, statements)
+	assert.Equal(t, []string{"begin BEGIN", "commit COMMIT"}, transactions)
+	assert.Equal(t, []string{"begin BEGIN", "exec CREATE TABLE t(id int)", "commit COMMIT", "prepare SELECT id FROM t"}, all)
+
+	// Without an Event, only the hooks registered for every operation run
+	all, statements = nil, nil
+	_, err = m.Before(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"unknown SELECT 1"}, all)
+	assert.Empty(t, statements)
+}
+
+func TestWithOpsDefault(t *testing.T) {
+	var calls []string
+	db := openWithHooks(t, opRecorder(&calls))
+	defer db.Close()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	require.NoError(t, db.Ping())
+
+	assert.Equal(t, []string{"exec CREATE TABLE t(id int)"}, calls)
+}
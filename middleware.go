@@ -0,0 +1,115 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+)
+
+// RowsMiddleware wraps the rows returned by a query. rows is a *Rows, so a
+// middleware type embedding it implements every optional driver.Rows
+// interface and only overrides the methods it intercepts:
+//
+//	type countingRows struct {
+//		*sqlhooks.Rows
+//		n int
+//	}
+//
+//	func (r *countingRows) Next(dest []driver.Value) error {
+//		err := r.Rows.Next(dest)
+//		if err == nil {
+//			r.n++
+//		}
+//		return err
+//	}
+type RowsMiddleware func(ctx context.Context, query string, rows *Rows) driver.Rows
+
+// StmtMiddleware wraps the statements returned by Prepare. stmt implements
+// driver.StmtExecContext and driver.StmtQueryContext, which a middleware
+// type embedding it inherits; its Exec and Query calls still run the hooks.
+type StmtMiddleware func(ctx context.Context, query string, stmt *Stmt) driver.Stmt
+
+// WithRowsMiddleware wraps the rows returned by queries with middleware,
+// after the After hooks ran.
+func WithRowsMiddleware(middleware RowsMiddleware) Option {
+	return func(o *options) {
+		o.rowsMiddleware = middleware
+	}
+}
+
+// WithStmtMiddleware wraps the prepared statements with middleware
+func WithStmtMiddleware(middleware StmtMiddleware) Option {
+	return func(o *options) {
+		o.stmtMiddleware = middleware
+	}
+}
+
+// Rows implements database/sql/driver.Rows along with the optional
+// interfaces of the rows it wraps. When they are not implemented by the
+// wrapped rows, the optional methods return the values database/sql uses
+// in their absence, so wrapping does not change the behaviour of sql.Rows.
+type Rows struct {
+	Rows driver.Rows
+}
+
+// NewRows returns a Rows wrapping rows
+func NewRows(rows driver.Rows) *Rows {
+	return &Rows{Rows: rows}
+}
+
+// Unwrap returns the wrapped rows, see the Unwrap function
+func (r *Rows) Unwrap() driver.Rows { return r.Rows }
+
+func (r *Rows) Columns() []string              { return r.Rows.Columns() }
+func (r *Rows) Close() error                   { return r.Rows.Close() }
+func (r *Rows) Next(dest []driver.Value) error { return r.Rows.Next(dest) }
+
+func (r *Rows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *Rows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rs.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rs.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *Rows) ColumnTypeLength(index int) (int64, bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rs.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *Rows) ColumnTypeNullable(index int) (bool, bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rs.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *Rows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rs.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
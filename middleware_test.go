@@ -0,0 +1,86 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingRows struct {
+	*Rows
+	n *int
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		*r.n++
+	}
+	return err
+}
+
+type closeRecordingStmt struct {
+	*Stmt
+	closed *[]string
+}
+
+func (s *closeRecordingStmt) Close() error {
+	*s.closed = append(*s.closed, s.query)
+	return s.Stmt.Close()
+}
+
+func TestMiddleware(t *testing.T) {
+	var (
+		rows   int
+		closed []string
+		execs  []string
+	)
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		execs = append(execs, query)
+		return ctx, nil
+	}
+
+	driverName := fmt.Sprintf("sqlhooks-middleware-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks,
+		WithRowsMiddleware(func(ctx context.Context, query string, r *Rows) driver.Rows {
+			return &countingRows{Rows: r, n: &rows}
+		}),
+		WithStmtMiddleware(func(ctx context.Context, query string, s *Stmt) driver.Stmt {
+			return &closeRecordingStmt{Stmt: s, closed: &closed}
+		}),
+	))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id INTEGER)")
+	require.NoError(t, err)
+	stmt, err := db.Prepare("INSERT INTO t VALUES (?)")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = stmt.Exec(i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, stmt.Close())
+	assert.Equal(t, []string{"INSERT INTO t VALUES (?)"}, closed)
+	assert.Len(t, execs, 4)
+
+	r, err := db.Query("SELECT id FROM t")
+	require.NoError(t, err)
+	types, err := r.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "INTEGER", types[0].DatabaseTypeName())
+	for r.Next() {
+	}
+	require.NoError(t, r.Close())
+	assert.Equal(t, 3, rows)
+}
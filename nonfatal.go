@@ -0,0 +1,44 @@
+package sqlhooks
+
+import (
+	"context"
+	"log"
+)
+
+// HookErrorHandler is called with the errors of the Before and After hooks
+// ignored by WithNonFatalHooks, hook being "Before" or "After"
+type HookErrorHandler func(ctx context.Context, hook string, err error, query string, args ...interface{})
+
+// WithNonFatalHooks makes the errors returned by the Before and After hooks
+// non-fatal: they are passed to the handlers, or logged with log.Printf
+// without any, and the operation goes on as if the hook returned no error,
+// with the context it returned if any. It suits hooks that only observe the
+// statements, whose failures must not break the traffic.
+//
+// The errors of OnError, of the ArgsMutator and QueryRewriter hooks, which
+// rather reject statements, and the panics recovered by WithPanicRecovery
+// still fail the operation.
+func WithNonFatalHooks(handlers ...HookErrorHandler) Option {
+	return func(o *options) {
+		if len(handlers) == 0 {
+			handlers = []HookErrorHandler{logHookError}
+		}
+		o.hookErrorHandlers = append([]HookErrorHandler(nil), handlers...)
+	}
+}
+
+func logHookError(ctx context.Context, hook string, err error, query string, args ...interface{}) {
+	log.Printf("sqlhooks: %s hook failed on %q: %v", hook, query, err)
+}
+
+// nonFatal passes err to the handlers of WithNonFatalHooks and returns nil,
+// or returns err if the option is not set
+func (conn *Conn) nonFatal(ctx context.Context, hook string, err error, query string, args []interface{}) error {
+	if err == nil || conn.opts.hookErrorHandlers == nil {
+		return err
+	}
+	for _, handle := range conn.opts.hookErrorHandlers {
+		handle(ctx, hook, err, query, args...)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonFatalHooks(t *testing.T) {
+	var (
+		reported []string
+		afterRan bool
+	)
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		return context.WithValue(ctx, ctxKey("before"), true), errors.New("before failed")
+	}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		afterRan = ctx.Value(ctxKey("before")) == true
+		return nil, errors.New("after failed")
+	}
+
+	driverName := fmt.Sprintf("sqlhooks-nonfatal-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithNonFatalHooks(
+		func(ctx context.Context, hook string, err error, query string, args ...interface{}) {
+			reported = append(reported, fmt.Sprintf("%s %s: %v", hook, query, err))
+		},
+	)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+	assert.Equal(t, 1, n)
+	assert.True(t, afterRan, "After gets the context returned by the failing Before")
+	assert.Equal(t, []string{"Before SELECT 1: before failed", "After SELECT 1: after failed"}, reported)
+
+	t.Run("OnError", func(t *testing.T) {
+		hooks.reset()
+		hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+			return errors.New("rejected")
+		}
+		_, err := db.Exec("SELECT 1")
+		assert.NoError(t, err)
+		_, err = db.Exec("SELECT * FROM missing")
+		assert.EqualError(t, err, "rejected", "OnError errors stay fatal")
+	})
+}
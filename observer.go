@@ -0,0 +1,73 @@
+package sqlhooks
+
+import "time"
+
+// PoolEvent describes a connection pool event, see PoolObserver
+type PoolEvent struct {
+	ConnID uint64
+	// Age is how long the connection has been open.
+	Age time.Duration
+	// Uses is the number of statements the connection served so far.
+	Uses int64
+	// Idle is, in OnConnReused, the time elapsed since the previous
+	// statement on the connection started.
+	Idle time.Duration
+	// Expired is set in OnConnRetired when the connection lived at least
+	// the lifetime given to WithConnMaxLifetime, meaning that database/sql
+	// most likely retired it because of its age.
+	Expired bool
+	// LastErr is, in OnConnRetired, the last error returned by the
+	// connection, which may explain why it was discarded.
+	LastErr error
+}
+
+// PoolObserver instances are notified of the events of the database/sql
+// connection pool. database/sql does not expose them, so they are
+// approximated, on a best-effort basis, from the lifecycle of the
+// connections as seen by the wrapper:
+//
+//   - OnConnCreated is called when the driver opens a connection.
+//   - OnConnReused is called when a statement runs outside of a transaction
+//     on a connection that already ran one, which database/sql had to take
+//     back from the pool. Statements run on a connection pinned with
+//     sql.DB.Conn are reported as reuses too.
+//   - OnConnRetired is called when a connection is closed.
+type PoolObserver interface {
+	OnConnCreated(event PoolEvent)
+	OnConnReused(event PoolEvent)
+	OnConnRetired(event PoolEvent)
+}
+
+// WithConnMaxLifetime tells the wrapper the value given to
+// sql.DB.SetConnMaxLifetime, so that PoolObserver instances can tell
+// connections retired because of their age from the other ones.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.connMaxLifetime = d
+	}
+}
+
+func (conn *Conn) observeCreated() {
+	if o := conn.ext.pool; o != nil {
+		o.OnConnCreated(PoolEvent{ConnID: conn.id, Age: time.Since(conn.openedAt)})
+	}
+}
+
+func (conn *Conn) observeReused(uses int64, idle time.Duration) {
+	if o := conn.ext.pool; o != nil {
+		o.OnConnReused(PoolEvent{ConnID: conn.id, Age: time.Since(conn.openedAt), Uses: uses, Idle: idle})
+	}
+}
+
+func (conn *Conn) observeRetired(event ConnEvent) {
+	if o := conn.ext.pool; o != nil {
+		lifetime := conn.opts.connMaxLifetime
+		o.OnConnRetired(PoolEvent{
+			ConnID:  event.ConnID,
+			Age:     event.Age,
+			Uses:    event.Uses,
+			Expired: lifetime > 0 && event.Age >= lifetime,
+			LastErr: event.LastErr,
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package sqlhooks
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type poolObserver struct {
+	testHooks
+	created, reused, retired []PoolEvent
+}
+
+func (o *poolObserver) OnConnCreated(event PoolEvent) { o.created = append(o.created, event) }
+func (o *poolObserver) OnConnReused(event PoolEvent)  { o.reused = append(o.reused, event) }
+func (o *poolObserver) OnConnRetired(event PoolEvent) { o.retired = append(o.retired, event) }
+
+func TestPoolObserver(t *testing.T) {
+	observer := &poolObserver{}
+	observer.reset()
+	driverName := fmt.Sprintf("sqlhooks-pool-observer-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(observer), WithConnMaxLifetime(time.Nanosecond)))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	require.Len(t, observer.created, 1)
+	assert.Empty(t, observer.reused)
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Len(t, observer.reused, 1)
+	assert.Equal(t, observer.created[0].ConnID, observer.reused[0].ConnID)
+	assert.Equal(t, int64(2), observer.reused[0].Uses)
+
+	// Statements in a transaction run on the connection taken by BEGIN
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO t VALUES (2)")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.Len(t, observer.reused, 1)
+
+	_, err = db.Exec("INSERT INTO t VALUES (3)")
+	require.NoError(t, err)
+	require.Len(t, observer.reused, 2)
+
+	require.NoError(t, db.Close())
+	require.Len(t, observer.retired, 1)
+	assert.True(t, observer.retired[0].Expired)
+	assert.Equal(t, int64(4), observer.retired[0].Uses)
+}
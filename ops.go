@@ -0,0 +1,71 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// opSet is a set of Ops
+type opSet uint32
+
+func newOpSet(ops ...Op) opSet {
+	var set opSet
+	for _, op := range ops {
+		set |= 1 << uint(op)
+	}
+	return set
+}
+
+func (s opSet) has(op Op) bool { return s&(1<<uint(op)) != 0 }
+
+// WithOps runs the hooks for operations other than queries and execs, which
+// are always hooked: OpPrepare, OpBegin, OpCommit, OpRollback and OpPing.
+// Hooks can tell operations apart with EventFromContext; use a Matrix to
+// route each operation to the hooks interested in it.
+func WithOps(ops ...Op) Option {
+	return func(o *options) {
+		o.ops |= newOpSet(ops...)
+	}
+}
+
+// hookOp runs fn, wrapped by the hooks if op is enabled
+func (conn *Conn) hookOp(ctx context.Context, op Op, query string, fn func(ctx context.Context) error) error {
+	if !conn.opts.ops.has(op) || HooksDisabled(ctx) {
+		return fn(ctx)
+	}
+
+	ctx = conn.opts.enrich(ctx)
+	event := conn.newEvent(ctx, op, query, nil)
+	defer conn.releaseEvent(event)
+	hookCtx, cancel := conn.opts.hookContext(contextWithEvent(ctx, event))
+
+	hookCtx, err := conn.before(hookCtx, query, nil)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = fn(conn.opts.callContext(hookCtx, ctx))
+	event.Duration = time.Since(start)
+	hookCtx, cancel = conn.opts.hookContext(hookCtx)
+	defer cancel()
+	if err != nil {
+		conn.setLastErr(err)
+		return conn.onError(hookCtx, err, query, nil)
+	}
+
+	_, err = conn.after(hookCtx, query, nil)
+	return err
+}
+
+// Ping implements driver.Pinger, connections of drivers that don't
+// implement it are always considered alive, as database/sql does
+func (conn *Conn) Ping(ctx context.Context) error {
+	pinger, ok := conn.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return conn.hookOp(ctx, OpPing, "", pinger.Ping)
+}
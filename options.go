@@ -0,0 +1,97 @@
+package sqlhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+)
+
+// Option configures the driver returned by Wrap
+type Option func(*options)
+
+type options struct {
+	detachHooks       bool
+	hookTimeout       time.Duration
+	stmtDiagnostics   bool
+	contextAudit      bool
+	leakDetection     bool
+	pooling           bool
+	ops               opSet
+	session           SessionConfig
+	rowsMiddleware    RowsMiddleware
+	stmtMiddleware    StmtMiddleware
+	connMaxLifetime   time.Duration
+	dialect           sqlutil.Dialect
+	queryCache        *sqlutil.Cache
+	strict            bool
+	idGenerator       IDGenerator
+	deadlines         *deadlineRecorder
+	enrichers         []Enricher
+	panicHandler      PanicHandler
+	hookErrorHandlers []HookErrorHandler
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{ops: newOpSet(OpQuery, OpExec)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithDetachedHookContext runs the hooks with a context that carries the
+// values of the query context but is not cancelled with it, so that
+// instrumentation such as ending a span or recording a metric completes even
+// when the caller cancels mid-query. If timeout is positive, each call to a
+// hook gets a context expiring after timeout.
+//
+// The underlying driver call still uses the caller's deadline and
+// cancellation, along with the values added by the Before hooks.
+func WithDetachedHookContext(timeout time.Duration) Option {
+	return func(o *options) {
+		o.detachHooks = true
+		o.hookTimeout = timeout
+	}
+}
+
+// hookContext returns the context to run a hook with
+func (o *options) hookContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !o.detachHooks {
+		return ctx, func() {}
+	}
+	ctx = detachedContext{ctx}
+	if o.hookTimeout > 0 {
+		return context.WithTimeout(ctx, o.hookTimeout)
+	}
+	return ctx, func() {}
+}
+
+// callContext returns the context to call the driver with, given the
+// context returned by the Before hooks and the caller's context.
+func (o *options) callContext(hookCtx, ctx context.Context) context.Context {
+	if !o.detachHooks {
+		return hookCtx
+	}
+	return reattachedContext{Context: ctx, values: hookCtx}
+}
+
+// detachedContext carries the values of its parent but not its deadline
+// nor its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// reattachedContext carries the values of one context and the deadline and
+// cancellation of another
+type reattachedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c reattachedContext) Value(key interface{}) interface{} { return c.values.Value(key) }
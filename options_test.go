@@ -0,0 +1,69 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey string
+
+func TestDetachedHookContext(t *testing.T) {
+	hooks := newTestHooks()
+	var cancelQuery context.CancelFunc
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		// The caller gives up while the hook runs
+		cancelQuery()
+		assert.NoError(t, ctx.Err())
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+		return context.WithValue(ctx, ctxKey("before"), "value"), nil
+	}
+	onErrorCalled := false
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		onErrorCalled = true
+		assert.NoError(t, ctx.Err())
+		assert.Equal(t, "value", ctx.Value(ctxKey("before")))
+		return err
+	}
+
+	var callErr error
+	interceptor := &Interceptors{
+		Query: func(ctx context.Context, next QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+			assert.Equal(t, "value", ctx.Value(ctxKey("before")))
+			// Behave like a driver honouring cancellation
+			if callErr = ctx.Err(); callErr != nil {
+				return nil, callErr
+			}
+			return next(ctx, query, args)
+		},
+	}
+
+	driverName := fmt.Sprintf("sqlhooks-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(hooks, interceptor), WithDetachedHookContext(time.Minute)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cancelQuery = cancel
+	_, err = conn.QueryContext(ctx, "SELECT 1")
+	require.Error(t, err)
+
+	// The driver call saw the caller's cancellation...
+	assert.Equal(t, context.Canceled, callErr)
+	// ...but the error hook did not
+	assert.True(t, onErrorCalled)
+}
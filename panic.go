@@ -0,0 +1,102 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicHandler handles the panic of a hook, recovered with value recovered,
+// see WithPanicRecovery
+type PanicHandler func(ctx context.Context, recovered interface{}, query string, args ...interface{}) error
+
+// PanicError is the error of the operations whose hook panicked, when no
+// PanicHandler is given to WithPanicRecovery
+type PanicError struct {
+	Recovered interface{}
+	// Stack is the stack of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("sqlhooks: hook panicked: %v", e.Recovered)
+}
+
+// WithPanicRecovery recovers the panics of the Before, After and OnError
+// hooks and of the QueryRewriter, ArgsMutator and ExecResultHooks callbacks,
+// so that a buggy hook fails an operation instead of crashing the process.
+// The panics are passed to handler, which runs in the deferred call and can
+// thus log debug.Stack(). The error it returns fails the operation; nil lets
+// it go on as if the hook returned no error, with the context and arguments
+// it was given. A nil handler fails the operation with a *PanicError.
+//
+// The panics of the driver, and of the Interceptors calling it, are not
+// recovered, nor those of the callbacks not tied to a statement such as
+// ConnHooks or TxHooks.
+func WithPanicRecovery(handler PanicHandler) Option {
+	return func(o *options) {
+		if handler == nil {
+			handler = func(ctx context.Context, recovered interface{}, query string, args ...interface{}) error {
+				return &PanicError{Recovered: recovered, Stack: debug.Stack()}
+			}
+		}
+		o.panicHandler = handler
+	}
+}
+
+// recoverHook, deferred by the callers of the hooks, turns their panic into
+// the error of the PanicHandler, stored in err
+func (conn *Conn) recoverHook(ctx context.Context, query string, args []interface{}, err *error) {
+	if conn.opts.panicHandler == nil {
+		return
+	}
+	if recovered := recover(); recovered != nil {
+		*err = conn.opts.panicHandler(ctx, recovered, query, args...)
+	}
+}
+
+// before runs the Before hooks, returning ctx if they return none or panic
+func (conn *Conn) before(ctx context.Context, query string, args []interface{}) (hookCtx context.Context, err error) {
+	hookCtx = ctx
+	defer conn.recoverHook(ctx, query, args, &err)
+	c, err := conn.hooks.Before(ctx, query, args...)
+	if err = conn.nonFatal(ctx, "Before", err, query, args); err == nil && c == nil {
+		return ctx, nil
+	}
+	return c, err
+}
+
+// after runs the After hooks, returning ctx if they return none or panic
+func (conn *Conn) after(ctx context.Context, query string, args []interface{}) (afterCtx context.Context, err error) {
+	afterCtx = ctx
+	defer conn.recoverHook(ctx, query, args, &err)
+	c, err := conn.hooks.After(ctx, query, args...)
+	if err = conn.nonFatal(ctx, "After", err, query, args); err == nil && c == nil {
+		return ctx, nil
+	}
+	return c, err
+}
+
+// onError runs the OnError hooks, returning cause if they panic and the
+// PanicHandler returns nil
+func (conn *Conn) onError(ctx context.Context, cause error, query string, args []interface{}) (err error) {
+	defer func() {
+		if err == nil {
+			err = cause
+		}
+	}()
+	defer conn.recoverHook(ctx, query, args, &err)
+	return handlerErr(ctx, conn.hooks, cause, query, args...)
+}
+
+// afterExec runs the ExecResultHooks, returning ctx if they return none or
+// panic
+func (conn *Conn) afterExec(h ExecResultHooks, ctx context.Context, result driver.Result, query string, args []interface{}) (afterCtx context.Context, err error) {
+	afterCtx = ctx
+	defer conn.recoverHook(ctx, query, args, &err)
+	if c, err := h.AfterExec(ctx, result, query, args...); c != nil || err != nil {
+		return c, err
+	}
+	return ctx, nil
+}
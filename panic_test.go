@@ -0,0 +1,70 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openWithPanicRecovery(t *testing.T, hooks Hooks, handler PanicHandler) *sql.DB {
+	driverName := fmt.Sprintf("sqlhooks-panic-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithPanicRecovery(handler)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPanicRecovery(t *testing.T) {
+	t.Run("Before", func(t *testing.T) {
+		hooks := newTestHooks()
+		hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+			panic("boom")
+		}
+		db := openWithPanicRecovery(t, hooks, nil)
+
+		_, err := db.Exec("SELECT 1")
+		var perr *PanicError
+		require.True(t, errors.As(err, &perr), "got %v", err)
+		assert.Equal(t, "boom", perr.Recovered)
+		assert.NotEmpty(t, perr.Stack)
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		var recovered []interface{}
+		hooks := newTestHooks()
+		hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+			panic("after")
+		}
+		db := openWithPanicRecovery(t, hooks, func(ctx context.Context, r interface{}, query string, args ...interface{}) error {
+			recovered = append(recovered, r, query, args)
+			return nil
+		})
+
+		rows, err := db.Query("SELECT ?", 1)
+		require.NoError(t, err, "a nil error lets the operation go on")
+		rows.Close()
+		assert.Equal(t, []interface{}{"after", "SELECT ?", []interface{}{int64(1)}}, recovered)
+	})
+
+	t.Run("OnError", func(t *testing.T) {
+		hooks := newTestHooks()
+		hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+			panic("onError")
+		}
+		db := openWithPanicRecovery(t, hooks, func(ctx context.Context, r interface{}, query string, args ...interface{}) error {
+			return nil
+		})
+
+		_, err := db.Exec("SELECT * FROM missing")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no such table", "the error of the driver is kept")
+	})
+}
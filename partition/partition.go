@@ -0,0 +1,201 @@
+// Package partition keeps a separate pool of connections per workload behind
+// one API, so that long batch queries or migrations cannot exhaust the
+// connections of latency-sensitive traffic. Each *sql.DB is usually opened
+// with a driver wrapped by sqlhooks, so queries stay instrumented whichever
+// pool they end up on:
+//
+//	db, err := partition.Open("postgres-hooked", dsn, map[partition.Workload]partition.PoolConfig{
+//		partition.OLTP:      {MaxOpenConns: 50},
+//		partition.Batch:     {MaxOpenConns: 5},
+//		partition.Migration: {MaxOpenConns: 1},
+//	}, partition.Options{})
+//
+//	ctx = partition.WithWorkload(ctx, partition.Batch)
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM events")
+//
+// The workload stays in the context for the hooks to read with
+// WorkloadFromContext, e.g. to label their metrics.
+package partition
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Workload tags the operations routed to a pool
+type Workload string
+
+// Workloads of the usual partitions, any other name can be used
+const (
+	OLTP      Workload = "oltp"
+	Batch     Workload = "batch"
+	Migration Workload = "migration"
+)
+
+type workloadKey struct{}
+
+// WithWorkload returns a context whose operations are routed to the pool of
+// workload
+func WithWorkload(ctx context.Context, workload Workload) context.Context {
+	return context.WithValue(ctx, workloadKey{}, workload)
+}
+
+// WorkloadFromContext returns the workload set with WithWorkload, if any
+func WorkloadFromContext(ctx context.Context) (Workload, bool) {
+	workload, ok := ctx.Value(workloadKey{}).(Workload)
+	return workload, ok
+}
+
+// PoolConfig sizes the pool of a workload, zero values keep the defaults of
+// database/sql
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Options configures a DB
+type Options struct {
+	// Default is the workload of the contexts without one, or with one
+	// that has no pool. Defaults to OLTP.
+	Default Workload
+}
+
+// DB routes the operations to the pool of their workload
+type DB struct {
+	pools map[Workload]*sql.DB
+	def   *sql.DB
+	opts  Options
+}
+
+// New returns a DB routing between pools, which must include the default
+// workload. The databases are owned by the caller, see Close.
+func New(pools map[Workload]*sql.DB, opts Options) (*DB, error) {
+	if opts.Default == "" {
+		opts.Default = OLTP
+	}
+	def, ok := pools[opts.Default]
+	if !ok {
+		return nil, fmt.Errorf("partition: no pool for the default workload %q", opts.Default)
+	}
+	db := &DB{pools: make(map[Workload]*sql.DB, len(pools)), def: def, opts: opts}
+	for workload, pool := range pools {
+		db.pools[workload] = pool
+	}
+	return db, nil
+}
+
+// Open opens a pool per workload of configs with sql.Open(driverName, dsn),
+// sized by its PoolConfig, and returns a DB routing between them
+func Open(driverName, dsn string, configs map[Workload]PoolConfig, opts Options) (*DB, error) {
+	pools := make(map[Workload]*sql.DB, len(configs))
+	for workload, cfg := range configs {
+		pool, err := sql.Open(driverName, dsn)
+		if err != nil {
+			for _, pool := range pools {
+				pool.Close()
+			}
+			return nil, err
+		}
+		if cfg.MaxOpenConns > 0 {
+			pool.SetMaxOpenConns(cfg.MaxOpenConns)
+		}
+		if cfg.MaxIdleConns > 0 {
+			pool.SetMaxIdleConns(cfg.MaxIdleConns)
+		}
+		if cfg.ConnMaxLifetime > 0 {
+			pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		}
+		pools[workload] = pool
+	}
+	db, err := New(pools, opts)
+	if err != nil {
+		for _, pool := range pools {
+			pool.Close()
+		}
+	}
+	return db, err
+}
+
+// DB returns the pool of workload, or the default one if it has none
+func (db *DB) DB(workload Workload) *sql.DB {
+	if pool, ok := db.pools[workload]; ok {
+		return pool
+	}
+	return db.def
+}
+
+// DBContext returns the pool of the workload of ctx
+func (db *DB) DBContext(ctx context.Context) *sql.DB {
+	workload, _ := WorkloadFromContext(ctx)
+	return db.DB(workload)
+}
+
+// Workloads returns the workloads with a pool, sorted
+func (db *DB) Workloads() []Workload {
+	workloads := make([]Workload, 0, len(db.pools))
+	for workload := range db.pools {
+		workloads = append(workloads, workload)
+	}
+	sort.Slice(workloads, func(i, j int) bool { return workloads[i] < workloads[j] })
+	return workloads
+}
+
+// Stats returns the statistics of every pool
+func (db *DB) Stats() map[Workload]sql.DBStats {
+	stats := make(map[Workload]sql.DBStats, len(db.pools))
+	for workload, pool := range db.pools {
+		stats[workload] = pool.Stats()
+	}
+	return stats
+}
+
+// QueryContext runs a query on the pool of the workload of ctx
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DBContext(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query returning at most one row on the pool of the
+// workload of ctx
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DBContext(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext runs a statement on the pool of the workload of ctx
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DBContext(ctx).ExecContext(ctx, query, args...)
+}
+
+// PrepareContext prepares a statement on the pool of the workload of ctx
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.DBContext(ctx).PrepareContext(ctx, query)
+}
+
+// BeginTx starts a transaction on the pool of the workload of ctx
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return db.DBContext(ctx).BeginTx(ctx, opts)
+}
+
+// PingContext pings every pool
+func (db *DB) PingContext(ctx context.Context) error {
+	for _, workload := range db.Workloads() {
+		if err := db.pools[workload].PingContext(ctx); err != nil {
+			return fmt.Errorf("partition: %s: %w", workload, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every pool, returning the first error
+func (db *DB) Close() error {
+	var first error
+	for _, workload := range db.Workloads() {
+		if err := db.pools[workload].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
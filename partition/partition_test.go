@@ -0,0 +1,72 @@
+package partition
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/qustavo/sqlhooks/v2/sqlhookstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workloadHooks records the workloads of the operations
+type workloadHooks struct {
+	workloads []Workload
+}
+
+func (h *workloadHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	workload, _ := WorkloadFromContext(ctx)
+	h.workloads = append(h.workloads, workload)
+	return ctx, nil
+}
+
+func (h *workloadHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func TestRouting(t *testing.T) {
+	hooks := &workloadHooks{}
+	driverName := fmt.Sprintf("partition-%s", time.Now().String())
+	sql.Register(driverName, sqlhooks.Wrap(&sqlhookstest.Driver{}, hooks))
+	db, err := Open(driverName, "", map[Workload]PoolConfig{
+		OLTP:  {MaxOpenConns: 4},
+		Batch: {MaxOpenConns: 1},
+	}, Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, []Workload{Batch, OLTP}, db.Workloads())
+	assert.Equal(t, 1, db.DB(Batch).Stats().MaxOpenConnections)
+	assert.Equal(t, db.DB(OLTP), db.DB(Migration), "workloads without a pool use the default one")
+
+	// A batch query holding the only batch connection does not block OLTP
+	batch := WithWorkload(context.Background(), Batch)
+	rows, err := db.QueryContext(batch, "SELECT 1")
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n))
+	require.NoError(t, rows.Close())
+
+	_, err = db.ExecContext(WithWorkload(context.Background(), Migration), "CREATE TABLE t (n int)")
+	require.NoError(t, err)
+	assert.Equal(t, []Workload{Batch, "", Migration}, hooks.workloads)
+
+	stats := db.Stats()
+	assert.Equal(t, 1, stats[Batch].OpenConnections)
+	assert.Equal(t, 1, stats[OLTP].OpenConnections)
+	require.NoError(t, db.PingContext(context.Background()))
+}
+
+func TestDefault(t *testing.T) {
+	_, err := New(map[Workload]*sql.DB{Batch: {}}, Options{})
+	assert.Error(t, err)
+
+	pool := &sql.DB{}
+	db, err := New(map[Workload]*sql.DB{Batch: pool}, Options{Default: Batch})
+	require.NoError(t, err)
+	assert.Equal(t, pool, db.DBContext(context.Background()))
+}
@@ -0,0 +1,102 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"time"
+)
+
+// WithPooling reuses the Event and argument slices of every operation
+// instead of allocating new ones, which reduces GC pressure on busy services.
+//
+// Ownership rules: the Event stored in the hook context and the args slice
+// passed to the hooks belong to the wrapper, and are recycled once the last
+// hook of the operation (After or OnError) returns. Hooks that keep them
+// beyond that point, e.g. to log asynchronously, must copy them first.
+// Drivers must not retain the []driver.Value passed to Exec, which
+// database/sql/driver implies but does not spell out.
+func WithPooling() Option {
+	return func(o *options) {
+		o.pooling = true
+	}
+}
+
+var (
+	eventPool  = sync.Pool{New: func() interface{} { return new(Event) }}
+	valuesPool = sync.Pool{New: func() interface{} { return new([]driver.Value) }}
+)
+
+// newEvent returns the Event of an operation run on conn, taken from the
+// pool when pooling is enabled
+func (conn *Conn) newEvent(ctx context.Context, op Op, query string, args []driver.NamedValue) *Event {
+	var event *Event
+	if conn.opts.pooling {
+		event = eventPool.Get().(*Event)
+	} else {
+		event = new(Event)
+	}
+	event.Op = op
+	event.Query = query
+	event.Args = namedToInterface(event.Args, args)
+	event.ConnID = conn.id
+	event.ConnAge = time.Since(conn.openedAt)
+	event.ConnUses, event.InTx, event.ConnIdle = conn.use(op)
+	event.IDs = IDs{Conn: conn.uid, Tx: conn.currentTxID(), Op: conn.opts.newID(ctx, IDOp, conn.id)}
+	event.Dialect = conn.opts.dialect
+	if conn.drv != nil {
+		event.Driver = conn.drv.info
+	}
+	event.cache = conn.opts.queryCache
+	return event
+}
+
+// releaseEvent recycles event if pooling is enabled, it must not be used
+// afterwards
+func (conn *Conn) releaseEvent(event *Event) {
+	if !conn.opts.pooling {
+		return
+	}
+	for i := range event.Args {
+		event.Args[i] = nil
+	}
+	*event = Event{Args: event.Args[:0]}
+	eventPool.Put(event)
+}
+
+// execValues converts args and calls exec with them, using a pooled slice
+// when pooling is enabled
+func (conn *Conn) execValues(args []driver.NamedValue, exec func([]driver.Value) (driver.Result, error)) (driver.Result, error) {
+	if !conn.opts.pooling {
+		values, err := namedValueToValue(nil, args)
+		if err != nil {
+			return nil, err
+		}
+		return exec(values)
+	}
+
+	buf := valuesPool.Get().(*[]driver.Value)
+	defer func() {
+		for i := range *buf {
+			(*buf)[i] = nil
+		}
+		*buf = (*buf)[:0]
+		valuesPool.Put(buf)
+	}()
+
+	values, err := namedValueToValue(*buf, args)
+	*buf = values
+	if err != nil {
+		return nil, err
+	}
+	return exec(values)
+}
+
+// detachEvent returns ctx with a copy of event when pooling is enabled, for
+// the rows and results notifying their hooks after event is recycled
+func (conn *Conn) detachEvent(ctx context.Context, event *Event) context.Context {
+	if !conn.opts.pooling {
+		return ctx
+	}
+	return contextWithEvent(ctx, event.clone())
+}
@@ -0,0 +1,140 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPooling(t *testing.T) {
+	var seen []Event
+	hooks := FromEventHooks(&eventFuncs{
+		after: func(ctx context.Context, event *Event) (context.Context, error) {
+			// Copy the event, it is recycled once the hook returns
+			e := *event
+			e.Args = append([]interface{}(nil), event.Args...)
+			seen = append(seen, e)
+			return ctx, nil
+		},
+	})
+	driverName := fmt.Sprintf("sqlhooks-pool-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithPooling()))
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t(a int, b text)")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("INSERT INTO t VALUES (?, ?)", i, "x")
+		require.NoError(t, err)
+	}
+
+	require.Len(t, seen, 4)
+	for i, e := range seen[1:] {
+		assert.Equal(t, []interface{}{int64(i), "x"}, e.Args)
+	}
+}
+
+// pooledEvents records the Event of the context the rows and results
+// hooks are notified with
+type pooledEvents struct {
+	*testHooks
+	events []Event
+}
+
+func (r *pooledEvents) record(ctx context.Context) {
+	if e := EventFromContext(ctx); e != nil {
+		r.events = append(r.events, *e)
+	}
+}
+
+func (r *pooledEvents) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	r.record(ctx)
+	return nil
+}
+
+func (r *pooledEvents) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	r.record(ctx)
+	return nil
+}
+
+func (r *pooledEvents) OnResult(ctx context.Context, event ResultEvent) error {
+	r.record(ctx)
+	return nil
+}
+
+func TestPoolingResultHooks(t *testing.T) {
+	rec := &pooledEvents{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-pool-result-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, rec, WithPooling()))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t(a int)")
+	require.NoError(t, err)
+	res, err := db.Exec("INSERT INTO t VALUES (?)", 1)
+	require.NoError(t, err)
+	// Another statement takes the recycled Event from the pool
+	_, err = db.Exec("SELECT 2")
+	require.NoError(t, err)
+	rec.events = nil
+
+	_, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Len(t, rec.events, 1)
+	assert.Equal(t, OpExec, rec.events[0].Op)
+	assert.Equal(t, "INSERT INTO t VALUES (?)", rec.events[0].Query)
+	assert.Equal(t, []interface{}{int64(1)}, rec.events[0].Args)
+}
+
+func TestPoolingRowsHooks(t *testing.T) {
+	rec := &pooledEvents{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-pool-rows-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, rec, WithPooling()))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT ?", 1).Scan(&n))
+	// Another statement takes the recycled Event from the pool
+	_, err = db.Exec("SELECT 2")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, rec.events, "the rows hooks see the Event of the query")
+	for _, e := range rec.events {
+		assert.Equal(t, OpQuery, e.Op)
+		assert.Equal(t, "SELECT ?", e.Query)
+		assert.Equal(t, []interface{}{int64(1)}, e.Args)
+	}
+}
+
+func TestNamedValueToValueAppends(t *testing.T) {
+	dst := make([]driver.Value, 0, 2)
+	dargs, err := namedValueToValue(dst, []driver.NamedValue{{Ordinal: 1, Value: 1}, {Ordinal: 2, Value: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []driver.Value{1, 2}, dargs)
+	assert.Equal(t, &dst[:1][0], &dargs[0], "dst is reused")
+}
+
+type eventFuncs struct {
+	after func(ctx context.Context, event *Event) (context.Context, error)
+}
+
+func (f *eventFuncs) BeforeEvent(ctx context.Context, event *Event) (context.Context, error) {
+	return ctx, nil
+}
+
+func (f *eventFuncs) AfterEvent(ctx context.Context, event *Event) (context.Context, error) {
+	return f.after(ctx, event)
+}
@@ -0,0 +1,40 @@
+package sqlhooks
+
+import (
+	"context"
+	"time"
+)
+
+// PrepareEvent describes the preparation of a statement
+type PrepareEvent struct {
+	ConnID uint64
+	Query  string
+	// Duration is how long the underlying driver took to prepare the
+	// statement, and Err the error it returned, in AfterPrepare.
+	Duration time.Duration
+	Err      error
+}
+
+// PrepareHooks instances will be notified of the preparation of statements,
+// so that its latency and errors can be told apart from the execution of
+// the statements. They run whether or not WithOps enables the OpPrepare
+// hooks, which also report prepare failures to OnError.
+type PrepareHooks interface {
+	BeforePrepare(ctx context.Context, event PrepareEvent)
+	AfterPrepare(ctx context.Context, event PrepareEvent)
+}
+
+// prepareWithHooks prepares query on conn, notifying the PrepareHooks
+func (conn *Conn) prepareWithHooks(ctx context.Context, query string) (*Stmt, error) {
+	h := conn.ext.prepare
+	if h == nil {
+		return conn.prepareContext(ctx, query)
+	}
+	event := PrepareEvent{ConnID: conn.id, Query: query}
+	h.BeforePrepare(ctx, event)
+	start := time.Now()
+	stmt, err := conn.prepareContext(ctx, query)
+	event.Duration, event.Err = time.Since(start), err
+	h.AfterPrepare(ctx, event)
+	return stmt, err
+}
@@ -0,0 +1,65 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type prepareRecorder struct {
+	*testHooks
+	before, after []PrepareEvent
+}
+
+func (r *prepareRecorder) BeforePrepare(ctx context.Context, e PrepareEvent) {
+	r.before = append(r.before, e)
+}
+
+func (r *prepareRecorder) AfterPrepare(ctx context.Context, e PrepareEvent) {
+	r.after = append(r.after, e)
+}
+
+func TestPrepareHooks(t *testing.T) {
+	rec := &prepareRecorder{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-prepare-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(NewMatrix().Add(rec, OpPrepare))))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, stmt.Close())
+	_, err = db.Prepare("SELECT nope")
+	require.Error(t, err)
+
+	require.Len(t, rec.before, 2)
+	require.Len(t, rec.after, 2)
+	assert.Equal(t, "SELECT 1", rec.before[0].Query)
+	assert.NotZero(t, rec.after[0].ConnID)
+	assert.NoError(t, rec.after[0].Err)
+	assert.True(t, rec.after[0].Duration > 0)
+	assert.Error(t, rec.after[1].Err)
+
+	// Preparation failures reach OnError with WithOps(OpPrepare)
+	var failed []string
+	hooks := newTestHooks()
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		failed = append(failed, query)
+		return err
+	}
+	driverName = fmt.Sprintf("sqlhooks-prepare-ops-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, hooks, WithOps(OpPrepare)))
+	db, err = sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Prepare("SELECT nope")
+	require.Error(t, err)
+	assert.Equal(t, []string{"SELECT nope"}, failed)
+}
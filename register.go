@@ -0,0 +1,55 @@
+package sqlhooks
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// registerMu serializes Register, so that concurrent calls registering the
+// same name do not both get past the check
+var registerMu sync.Mutex
+
+// Register wraps the driver registered as driverName with hooks and
+// registers the result as name, sparing the sql.Open and db.Driver() calls
+// needed to get at a driver registered by its package:
+//
+//	err := sqlhooks.Register("mysql:traced", "mysql", hooks)
+//	db, err := sql.Open("mysql:traced", dsn)
+//
+// It returns an error if driverName is not registered or name already is,
+// rather than panicking as sql.Register does.
+//
+// The driver is looked up with sql.Open and an empty DSN, which
+// database/sql passes to the OpenConnector method of the drivers
+// implementing driver.DriverContext: those rejecting an empty DSN are not
+// supported, and are wrapped with Wrap, or WrapConnector, instead.
+func Register(name, driverName string, hooks Hooks, opts ...Option) (err error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	for _, registered := range sql.Drivers() {
+		if registered == name {
+			return fmt.Errorf("sqlhooks: driver %q already registered", name)
+		}
+	}
+	// sql.Open looks the driver up without connecting
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return fmt.Errorf("sqlhooks: looking up driver %q: %w", driverName, err)
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	// sql.Register may still panic if name is registered concurrently
+	// without Register
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sqlhooks: registering driver %q: %v", name, r)
+		}
+	}()
+	sql.Register(name, Wrap(drv, hooks, opts...))
+	return nil
+}
@@ -0,0 +1,77 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	hooks := newTestHooks()
+	var queries []string
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		queries = append(queries, query)
+		return ctx, nil
+	}
+	name := fmt.Sprintf("sqlite3:hooked-%s", time.Now().String())
+	require.NoError(t, Register(name, "sqlite3", hooks))
+
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT 1"}, queries)
+	assert.IsType(t, &Driver{}, db.Driver())
+
+	assert.Error(t, Register(name, "sqlite3", hooks), "name is already registered")
+	assert.Error(t, Register(name+"-unknown", "no-such-driver", hooks))
+}
+
+func TestRegisterConcurrently(t *testing.T) {
+	name := fmt.Sprintf("sqlite3:concurrent-%s", time.Now().String())
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Register(name, "sqlite3", newTestHooks())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var registered int
+	for err := range errs {
+		if err == nil {
+			registered++
+		}
+	}
+	assert.Equal(t, 1, registered)
+}
+
+// dsnDriver is a driver.DriverContext rejecting empty DSNs
+type dsnDriver struct {
+	fakeDriver
+}
+
+func (d *dsnDriver) OpenConnector(name string) (driver.Connector, error) {
+	return nil, errors.New("empty dsn")
+}
+
+func TestRegisterDriverContext(t *testing.T) {
+	driverName := fmt.Sprintf("dsn-%s", time.Now().String())
+	sql.Register(driverName, &dsnDriver{})
+
+	err := Register(driverName+":hooked", driverName, newTestHooks())
+	assert.EqualError(t, err, fmt.Sprintf("sqlhooks: looking up driver %q: empty dsn", driverName))
+}
@@ -0,0 +1,85 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// ResultMethod identifies the driver.Result method being observed
+type ResultMethod int
+
+const (
+	// LastInsertID is driver.Result.LastInsertId
+	LastInsertID ResultMethod = iota
+	// RowsAffected is driver.Result.RowsAffected
+	RowsAffected
+)
+
+func (m ResultMethod) String() string {
+	if m == RowsAffected {
+		return "RowsAffected"
+	}
+	return "LastInsertId"
+}
+
+// ResultEvent describes a call to a method of the result of a statement
+type ResultEvent struct {
+	Query  string
+	Method ResultMethod
+	// Value is the value returned by the driver
+	Value int64
+	// Err is the error returned by the driver, if any
+	Err error
+}
+
+// ResultHooks instances will be notified when the application reads the
+// result of a successful Exec, with the context returned by the After hooks.
+// Audit hooks can use it to record the generated ID of an insert.
+//
+// OnResult may replace the error returned to the application, as OnError
+// does. Returning nil keeps event.Err.
+type ResultHooks interface {
+	OnResult(ctx context.Context, event ResultEvent) error
+}
+
+// ExecResultHooks instances receive the result of every successful Exec
+// right after the After hooks, with the context they returned, whether or not
+// the application reads it: metrics can count the rows affected by the
+// statements without a second query. Reading result does not notify the
+// ResultHooks. An error fails the Exec, as an error of After does.
+type ExecResultHooks interface {
+	AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error)
+}
+
+// Result implements a database/sql/driver.Result, it notifies the
+// ResultHooks when its methods are called
+type Result struct {
+	driver.Result
+	ctx   context.Context
+	hooks ResultHooks
+	query string
+	opts  *options
+}
+
+// Unwrap returns the wrapped result, see the Unwrap function
+func (r *Result) Unwrap() driver.Result { return r.Result }
+
+func (r *Result) LastInsertId() (int64, error) {
+	id, err := r.Result.LastInsertId()
+	return id, r.notify(LastInsertID, id, err)
+}
+
+func (r *Result) RowsAffected() (int64, error) {
+	n, err := r.Result.RowsAffected()
+	return n, r.notify(RowsAffected, n, err)
+}
+
+func (r *Result) notify(method ResultMethod, value int64, err error) error {
+	ctx, cancel := r.opts.hookContext(r.ctx)
+	defer cancel()
+
+	if hookErr := r.hooks.OnResult(ctx, ResultEvent{Query: r.query, Method: method, Value: value, Err: err}); hookErr != nil {
+		return hookErr
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type resultHooks struct {
+	*testHooks
+	events []ResultEvent
+	stages []interface{}
+	err    error
+}
+
+func (h *resultHooks) OnResult(ctx context.Context, event ResultEvent) error {
+	h.stages = append(h.stages, ctx.Value(ctxKey("stage")))
+	h.events = append(h.events, event)
+	return h.err
+}
+
+func TestResultHooks(t *testing.T) {
+	hooks := &resultHooks{testHooks: newTestHooks()}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		return context.WithValue(ctx, ctxKey("stage"), "after"), nil
+	}
+
+	db := openWithHooks(t, Compose(hooks))
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE t(id integer primary key, name text)")
+	require.NoError(t, err)
+	res, err := db.Exec("INSERT INTO t(name) VALUES (?)", "gus")
+	require.NoError(t, err)
+	assert.Empty(t, hooks.events, "results are only observed when read")
+
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+
+	assert.Equal(t, []ResultEvent{
+		{Query: "INSERT INTO t(name) VALUES (?)", Method: LastInsertID, Value: id},
+		{Query: "INSERT INTO t(name) VALUES (?)", Method: RowsAffected, Value: n},
+	}, hooks.events)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, []interface{}{"after", "after"}, hooks.stages)
+
+	hooks.err = errors.New("classified")
+	_, err = res.RowsAffected()
+	assert.Equal(t, hooks.err, err)
+	assert.Equal(t, "RowsAffected", RowsAffected.String())
+}
+
+type execResultHooks struct {
+	*resultHooks
+	affected []int64
+	stages   []interface{}
+	err      error
+}
+
+func (h *execResultHooks) AfterExec(ctx context.Context, result driver.Result, query string, args ...interface{}) (context.Context, error) {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return ctx, err
+	}
+	h.affected = append(h.affected, n)
+	h.stages = append(h.stages, ctx.Value(ctxKey("stage")))
+	return context.WithValue(ctx, ctxKey("stage"), "after exec"), h.err
+}
+
+func TestExecResultHooks(t *testing.T) {
+	hooks := &execResultHooks{resultHooks: &resultHooks{testHooks: newTestHooks()}}
+	hooks.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		return context.WithValue(ctx, ctxKey("stage"), "after"), nil
+	}
+
+	for name, wrap := range map[string]func(Hooks) Hooks{
+		"compose": func(h Hooks) Hooks { return Compose(h) },
+		"matrix":  func(h Hooks) Hooks { return NewMatrix().Add(h, OpExec) },
+		"when":    func(h Hooks) Hooks { return When(func(e *Event) bool { return e.Op == OpExec }, h) },
+		"events":  func(h Hooks) Hooks { return FromEventHooks(ToEventHooks(h)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			hooks.affected, hooks.stages, hooks.resultHooks.events, hooks.err = nil, nil, nil, nil
+			db := openWithHooks(t, wrap(hooks))
+			defer db.Close()
+
+			_, err := db.Exec("CREATE TABLE t(id integer primary key, name text)")
+			require.NoError(t, err)
+			res, err := db.Exec("INSERT INTO t(name) VALUES (?), (?)", "gus", "ana")
+			require.NoError(t, err)
+			assert.Equal(t, []int64{0, 2}, hooks.affected)
+			assert.Equal(t, []interface{}{"after", "after"}, hooks.stages)
+			assert.Empty(t, hooks.resultHooks.events, "reading the result in AfterExec does not notify the ResultHooks")
+
+			_, err = res.RowsAffected()
+			require.NoError(t, err)
+			require.Len(t, hooks.resultHooks.events, 1)
+			assert.Equal(t, []interface{}{"after exec"}, hooks.resultHooks.stages[len(hooks.resultHooks.stages)-1:])
+
+			hooks.err = errors.New("audit failed")
+			_, err = db.Exec("DELETE FROM t")
+			assert.Equal(t, hooks.err, err)
+		})
+	}
+}
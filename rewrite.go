@@ -0,0 +1,50 @@
+package sqlhooks
+
+import "context"
+
+// QueryRewriter instances can rewrite the queries before they reach the
+// driver, to inject sqlcommenter comments, add optimizer hints or append
+// tenant filters. The query returned by RewriteQuery is the one the driver
+// runs or prepares, and the one the hooks observe; Event.OriginalQuery keeps
+// the query as written by the application. An error fails the operation
+// before Before runs, as an error of Before does.
+//
+// Queries and statement executions are rewritten as they run, prepared
+// statements once, as they are prepared. ctx holds the Event of the
+// operation, whose Op tells them apart.
+type QueryRewriter interface {
+	RewriteQuery(ctx context.Context, query string) (string, error)
+}
+
+// rewriteQuery rewrites the query of event with the QueryRewriter hooks
+func (conn *Conn) rewriteQuery(ctx context.Context, event *Event) (err error) {
+	rw := conn.ext.rewriter
+	if rw == nil {
+		return nil
+	}
+	defer conn.recoverHook(ctx, event.Query, event.Args, &err)
+	query, err := rw.RewriteQuery(contextWithEvent(ctx, event), event.Query)
+	if err != nil {
+		return err
+	}
+	if query != event.Query {
+		event.OriginalQuery, event.Query = event.Query, query
+		// The rewriters may have looked at the query as written
+		event.fingerprint, event.classified = "", false
+	}
+	return nil
+}
+
+// rewritePrepare returns query as rewritten for its preparation
+func (conn *Conn) rewritePrepare(ctx context.Context, query string) (string, error) {
+	if conn.ext.rewriter == nil {
+		return query, nil
+	}
+	ctx = conn.opts.enrich(ctx)
+	event := conn.newEvent(ctx, OpPrepare, query, nil)
+	defer conn.releaseEvent(event)
+	if err := conn.rewriteQuery(ctx, event); err != nil {
+		return "", err
+	}
+	return event.Query, nil
+}
@@ -0,0 +1,95 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rewriter replaces the queries with SELECT 2, after recording them
+type rewriter struct {
+	*testHooks
+	rewritten []string
+	err       error
+}
+
+func (r *rewriter) RewriteQuery(ctx context.Context, query string) (string, error) {
+	r.rewritten = append(r.rewritten, EventFromContext(ctx).Op.String()+" "+query)
+	return strings.Replace(query, "SELECT 1", "SELECT 2", 1), r.err
+}
+
+func TestQueryRewriter(t *testing.T) {
+	var observed []Event
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		observed = append(observed, *EventFromContext(ctx))
+		return ctx, nil
+	}
+	rw := &rewriter{testHooks: newTestHooks()}
+	driverName := fmt.Sprintf("sqlhooks-rewrite-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, Compose(rw, hooks)))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+	assert.Equal(t, 2, n, "the driver runs the rewritten query")
+	require.Len(t, observed, 1)
+	assert.Equal(t, "SELECT 2", observed[0].Query)
+	assert.Equal(t, "SELECT 1", observed[0].OriginalQuery)
+
+	// Prepared statements are rewritten once, as they are prepared
+	stmt, err := db.Prepare("SELECT 1 + ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, stmt.QueryRow(1).Scan(&n))
+		assert.Equal(t, 3, n)
+	}
+	assert.Equal(t, []string{"query SELECT 1", "prepare SELECT 1 + ?"}, rw.rewritten)
+	require.Len(t, observed, 3)
+	assert.Equal(t, "SELECT 2 + ?", observed[2].Query)
+
+	// Errors fail the operation before the hooks run
+	rw.err = errors.New("no tenant")
+	_, err = db.Exec("SELECT 1")
+	assert.Equal(t, rw.err, err)
+	assert.Len(t, observed, 3)
+}
+
+func TestQueryRewriterRouting(t *testing.T) {
+	for name, wrap := range map[string]func(*rewriter) Hooks{
+		"matrix": func(rw *rewriter) Hooks { return NewMatrix().Add(rw, OpExec) },
+		"when": func(rw *rewriter) Hooks {
+			return When(func(e *Event) bool { return e.Op == OpExec }, rw)
+		},
+		"events": func(rw *rewriter) Hooks {
+			return When(func(e *Event) bool { return e.Op == OpExec }, FromEventHooks(ToEventHooks(rw)))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rw := &rewriter{testHooks: newTestHooks()}
+			driverName := fmt.Sprintf("sqlhooks-rewrite-%s-%s", name, time.Now().String())
+			sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, wrap(rw)))
+			db, err := sql.Open(driverName, ":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			_, err = db.Exec("SELECT 1")
+			require.NoError(t, err)
+			var n int
+			require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+			assert.Equal(t, 1, n)
+			assert.Equal(t, []string{"exec SELECT 1"}, rw.rewritten)
+		})
+	}
+}
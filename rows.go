@@ -0,0 +1,75 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// RowsEvent describes the iteration of the rows of a query
+type RowsEvent struct {
+	Query string
+	// Rows is the number of rows fetched so far
+	Rows int64
+	// FetchDuration is the time spent so far in the Next method of the
+	// driver's rows
+	FetchDuration time.Duration
+	// Err is the error returned by the driver, if any. Next returns io.EOF
+	// past the last row.
+	Err error
+}
+
+// RowsHooks instances will be notified as the application iterates over the
+// rows of a successful Query, with the context returned by the After hooks,
+// which run before any row is read. OnRowsNext is called after every call to
+// Next, OnRowsClose once the rows are closed, with the totals of the
+// iteration: hooks can alert on the queries streaming millions of rows, or
+// stop them by returning an error from OnRowsNext.
+//
+// As with ResultHooks, the callbacks may replace the error returned to the
+// application. Returning nil keeps event.Err.
+type RowsHooks interface {
+	OnRowsNext(ctx context.Context, event RowsEvent) error
+	OnRowsClose(ctx context.Context, event RowsEvent) error
+}
+
+// hookedRows notifies the RowsHooks of the iteration over the rows
+type hookedRows struct {
+	*Rows
+	ctx    context.Context
+	hooks  RowsHooks
+	opts   *options
+	event  RowsEvent
+	closed bool
+}
+
+func (r *hookedRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.Rows.Next(dest)
+	r.event.FetchDuration += time.Since(start)
+	if err == nil {
+		r.event.Rows++
+	}
+	return r.notify(r.hooks.OnRowsNext, err)
+}
+
+func (r *hookedRows) Close() error {
+	err := r.Rows.Close()
+	if r.closed {
+		return err
+	}
+	r.closed = true
+	return r.notify(r.hooks.OnRowsClose, err)
+}
+
+func (r *hookedRows) notify(hook func(context.Context, RowsEvent) error, err error) error {
+	ctx, cancel := r.opts.hookContext(r.ctx)
+	defer cancel()
+
+	event := r.event
+	event.Err = err
+	if hookErr := hook(ctx, event); hookErr != nil {
+		return hookErr
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowsRecorder records the RowsEvents, and stops the iteration past limit
+type rowsRecorder struct {
+	*testHooks
+	next, closed []RowsEvent
+	limit        int64
+}
+
+var errTooManyRows = errors.New("too many rows")
+
+func (r *rowsRecorder) OnRowsNext(ctx context.Context, event RowsEvent) error {
+	r.next = append(r.next, event)
+	if r.limit > 0 && event.Rows > r.limit {
+		return errTooManyRows
+	}
+	return nil
+}
+
+func (r *rowsRecorder) OnRowsClose(ctx context.Context, event RowsEvent) error {
+	r.closed = append(r.closed, event)
+	return nil
+}
+
+func TestRowsHooks(t *testing.T) {
+	rec := &rowsRecorder{testHooks: newTestHooks()}
+	var afterRows []int
+	rec.after = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		afterRows = append(afterRows, len(rec.next))
+		return ctx, nil
+	}
+	driverName := fmt.Sprintf("sqlhooks-rows-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, rec))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT i FROM n"
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	var sum int
+	for rows.Next() {
+		var i int
+		require.NoError(t, rows.Scan(&i))
+		sum += i
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, 6, sum)
+
+	assert.Equal(t, []int{0}, afterRows, "After runs before the rows are read")
+	require.Len(t, rec.next, 4)
+	assert.Equal(t, int64(3), rec.next[2].Rows)
+	assert.Equal(t, io.EOF, rec.next[3].Err)
+	require.Len(t, rec.closed, 1)
+	assert.Equal(t, query, rec.closed[0].Query)
+	assert.Equal(t, int64(3), rec.closed[0].Rows)
+	assert.NoError(t, rec.closed[0].Err)
+	assert.True(t, rec.closed[0].FetchDuration > 0)
+
+	// OnRowsNext can stop the iteration
+	rec.next, rec.closed, rec.limit = nil, nil, 1
+	rows, err = db.Query(query)
+	require.NoError(t, err)
+	var n int
+	for rows.Next() {
+		n++
+	}
+	assert.Equal(t, errTooManyRows, rows.Err())
+	assert.Equal(t, 1, n)
+	require.Len(t, rec.closed, 1)
+	assert.Equal(t, int64(2), rec.closed[0].Rows)
+}
+
+func TestRowsHooksRouting(t *testing.T) {
+	for name, wrap := range map[string]func(*rowsRecorder) Hooks{
+		"matrix": func(r *rowsRecorder) Hooks { return NewMatrix().Add(r, OpQuery) },
+		"when": func(r *rowsRecorder) Hooks {
+			return When(func(e *Event) bool { return e.Query == "SELECT 1" }, r)
+		},
+		"events": func(r *rowsRecorder) Hooks { return Compose(FromEventHooks(ToEventHooks(r))) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := &rowsRecorder{testHooks: newTestHooks()}
+			driverName := fmt.Sprintf("sqlhooks-rows-%s-%s", name, time.Now().String())
+			sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, wrap(rec)))
+			db, err := sql.Open(driverName, ":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			var n int
+			require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+			if name == "when" {
+				require.NoError(t, db.QueryRow("SELECT 2").Scan(&n))
+			}
+			require.Len(t, rec.closed, 1)
+			assert.Equal(t, "SELECT 1", rec.closed[0].Query)
+			assert.Equal(t, int64(1), rec.closed[0].Rows)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SessionSetting is a session variable enforced on every connection
+type SessionSetting struct {
+	Name string
+	// Set is the statement applying the setting, e.g.
+	// SET time_zone = 'UTC'
+	Set string
+	// Get is a query returning the current value of the setting as a single
+	// column, e.g. SELECT @@time_zone. If empty, the setting is applied but
+	// not verified.
+	Get string
+	// Want is the value Get must return.
+	Want string
+}
+
+// SessionConfig configures the session settings enforced by WithSession
+type SessionConfig struct {
+	Settings []SessionSetting
+	// VerifyOnReset verifies the settings every time database/sql resets a
+	// connection before reusing it, and applies them again on drift. It
+	// costs a query per setting on every reuse. The connections of drivers
+	// that do not implement driver.SessionResetter are verified as well.
+	VerifyOnReset bool
+}
+
+// SessionDrift describes a connection whose setting does not have the
+// expected value
+type SessionDrift struct {
+	ConnID  uint64
+	Setting SessionSetting
+	// Got is the value returned by Setting.Get.
+	Got string
+	// Err is the error returned by Setting.Get, or by Setting.Set when
+	// applying the setting again failed.
+	Err error
+}
+
+// SessionHooks instances will be notified of session drifts
+type SessionHooks interface {
+	OnSessionDrift(ctx context.Context, drift SessionDrift)
+}
+
+// WithSession applies the settings to every new connection and verifies
+// them. Connections on which a setting cannot be applied or verified are
+// closed, and Open fails. Drifts are reported to SessionHooks.
+func WithSession(cfg SessionConfig) Option {
+	return func(o *options) {
+		o.session = cfg
+	}
+}
+
+// applySession applies and verifies the session settings on a new connection
+func (conn *Conn) applySession(ctx context.Context) error {
+	for _, s := range conn.opts.session.Settings {
+		if err := rawExec(ctx, conn.Conn, s.Set); err != nil {
+			return fmt.Errorf("sqlhooks: applying session setting %s: %w", s.Name, err)
+		}
+		if s.Get == "" {
+			continue
+		}
+		got, err := rawQueryValue(ctx, conn.Conn, s.Get)
+		if err == nil && got != s.Want {
+			err = fmt.Errorf("got %q, want %q", got, s.Want)
+		}
+		if err != nil {
+			conn.reportDrift(ctx, SessionDrift{ConnID: conn.id, Setting: s, Got: got, Err: err})
+			return fmt.Errorf("sqlhooks: verifying session setting %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifySession verifies the session settings of a connection about to be
+// reused, and applies the drifting ones again. It returns driver.ErrBadConn
+// if that fails, so that database/sql discards the connection.
+func (conn *Conn) verifySession(ctx context.Context) error {
+	if !conn.opts.session.VerifyOnReset {
+		return nil
+	}
+	for _, s := range conn.opts.session.Settings {
+		if s.Get == "" {
+			continue
+		}
+		got, err := rawQueryValue(ctx, conn.Conn, s.Get)
+		if err == nil && got == s.Want {
+			continue
+		}
+
+		drift := SessionDrift{ConnID: conn.id, Setting: s, Got: got, Err: err}
+		if err == nil {
+			drift.Err = rawExec(ctx, conn.Conn, s.Set)
+		}
+		conn.reportDrift(ctx, drift)
+		if drift.Err != nil {
+			return driver.ErrBadConn
+		}
+	}
+	return nil
+}
+
+func (conn *Conn) reportDrift(ctx context.Context, drift SessionDrift) {
+	if h := conn.ext.session; h != nil {
+		h.OnSessionDrift(ctx, drift)
+	}
+}
+
+// rawExec runs query on the underlying connection, without hooks
+func rawExec(ctx context.Context, conn driver.Conn, query string) error {
+	if c, ok := conn.(driver.ExecerContext); ok {
+		_, err := c.ExecContext(ctx, query, nil)
+		if !errors.Is(err, driver.ErrSkip) {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// rawQueryValue returns the first column of the first row returned by query
+// on the underlying connection, without hooks
+func rawQueryValue(ctx context.Context, conn driver.Conn, query string) (string, error) {
+	var (
+		rows driver.Rows
+		err  = driver.ErrSkip
+	)
+	if c, ok := conn.(driver.QueryerContext); ok {
+		rows, err = c.QueryContext(ctx, query, nil)
+	}
+	if errors.Is(err, driver.ErrSkip) {
+		var stmt driver.Stmt
+		if stmt, err = conn.Prepare(query); err != nil {
+			return "", err
+		}
+		defer stmt.Close()
+		rows, err = stmt.Query(nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 {
+		return "", errors.New("no columns")
+	}
+	if err := rows.Next(dest); err == io.EOF {
+		return "", errors.New("no rows")
+	} else if err != nil {
+		return "", err
+	}
+	switch v := dest[0].(type) {
+	case []byte:
+		return string(v), nil
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,180 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionHooks struct {
+	*testHooks
+	drifts []SessionDrift
+}
+
+func (h *sessionHooks) OnSessionDrift(ctx context.Context, drift SessionDrift) {
+	h.drifts = append(h.drifts, drift)
+}
+
+func TestSessionSettings(t *testing.T) {
+	hooks := &sessionHooks{testHooks: newTestHooks()}
+	fk := SessionSetting{Name: "foreign_keys", Set: "PRAGMA foreign_keys = ON", Get: "PRAGMA foreign_keys", Want: "1"}
+	drv := Wrap(&sqlite3.SQLiteDriver{}, Compose(hooks), WithSession(SessionConfig{
+		Settings:      []SessionSetting{fk},
+		VerifyOnReset: true,
+	})).(*Driver)
+
+	c, err := drv.Open(":memory:")
+	require.NoError(t, err)
+	defer c.Close()
+	conn := c.(*ExecerQueryerContext).Conn
+
+	ctx := context.Background()
+	got, err := rawQueryValue(ctx, conn.Conn, fk.Get)
+	require.NoError(t, err)
+	assert.Equal(t, "1", got)
+
+	// Verification passes until the application changes the setting
+	require.NoError(t, conn.verifySession(ctx))
+	assert.Empty(t, hooks.drifts)
+
+	require.NoError(t, rawExec(ctx, conn.Conn, "PRAGMA foreign_keys = OFF"))
+	require.NoError(t, conn.verifySession(ctx))
+	require.Len(t, hooks.drifts, 1)
+	assert.Equal(t, "0", hooks.drifts[0].Got)
+	assert.NoError(t, hooks.drifts[0].Err)
+	assert.Equal(t, conn.id, hooks.drifts[0].ConnID)
+
+	got, err = rawQueryValue(ctx, conn.Conn, fk.Get)
+	require.NoError(t, err)
+	assert.Equal(t, "1", got, "the setting is applied again")
+}
+
+func TestSessionSettingsOpenFails(t *testing.T) {
+	hooks := &sessionHooks{testHooks: newTestHooks()}
+	drv := Wrap(&sqlite3.SQLiteDriver{}, hooks, WithSession(SessionConfig{
+		Settings: []SessionSetting{{Name: "tz", Set: "SELECT 1", Get: "SELECT 'Europe/Paris'", Want: "UTC"}},
+	})).(*Driver)
+
+	_, err := drv.Open(":memory:")
+	assert.Error(t, err)
+	require.Len(t, hooks.drifts, 1)
+	assert.Equal(t, "Europe/Paris", hooks.drifts[0].Got)
+	assert.Empty(t, drv.ConnStats())
+}
+
+// prepareOnlyConn only implements Prepare, its statements setting and
+// reading the variables of its session: "SET name value" and "GET name"
+type prepareOnlyConn struct {
+	vars     map[string]string
+	openRows int
+}
+
+func (c *prepareOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return &prepareOnlyStmt{conn: c, query: strings.Fields(query)}, nil
+}
+func (c *prepareOnlyConn) Close() error              { return nil }
+func (c *prepareOnlyConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+func (c *prepareOnlyConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+type prepareOnlyStmt struct {
+	conn  *prepareOnlyConn
+	query []string
+}
+
+func (s *prepareOnlyStmt) Close() error  { return nil }
+func (s *prepareOnlyStmt) NumInput() int { return 0 }
+
+func (s *prepareOnlyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.vars[s.query[1]] = s.query[2]
+	return driver.ResultNoRows, nil
+}
+
+func (s *prepareOnlyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.openRows++
+	return &valueRows{conn: s.conn, value: s.conn.vars[s.query[1]]}, nil
+}
+
+type valueRows struct {
+	conn  *prepareOnlyConn
+	value string
+	done  bool
+}
+
+func (r *valueRows) Columns() []string { return []string{"value"} }
+
+func (r *valueRows) Close() error {
+	r.conn.openRows--
+	return nil
+}
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.value, true
+	return nil
+}
+
+type prepareOnlyDriver struct {
+	conn *prepareOnlyConn
+}
+
+func (d *prepareOnlyDriver) Open(name string) (driver.Conn, error) { return d.conn, nil }
+
+func TestSessionSettingsPrepareOnly(t *testing.T) {
+	hooks := &sessionHooks{testHooks: newTestHooks()}
+	raw := &prepareOnlyConn{vars: map[string]string{"tz": "Europe/Paris"}}
+	drv := Wrap(&prepareOnlyDriver{conn: raw}, hooks, WithSession(SessionConfig{
+		Settings: []SessionSetting{{Name: "tz", Set: "SET tz UTC", Get: "GET tz", Want: "UTC"}},
+	}))
+
+	c, err := drv.Open("")
+	require.NoError(t, err, "the setting is verified through a prepared statement")
+	defer c.Close()
+	assert.Equal(t, "UTC", raw.vars["tz"])
+	assert.Empty(t, hooks.drifts)
+	assert.Zero(t, raw.openRows, "the rows are closed")
+}
+
+func TestSessionVerifyOnResetWithoutResetter(t *testing.T) {
+	hooks := &sessionHooks{testHooks: newTestHooks()}
+	raw := &prepareOnlyConn{vars: map[string]string{}}
+	drv := Wrap(&prepareOnlyDriver{conn: raw}, hooks, WithSession(SessionConfig{
+		Settings:      []SessionSetting{{Name: "tz", Set: "SET tz UTC", Get: "GET tz", Want: "UTC"}},
+		VerifyOnReset: true,
+	}))
+
+	c, err := drv.Open("")
+	require.NoError(t, err)
+	defer c.Close()
+	_, ok := interface{}(raw).(driver.SessionResetter)
+	require.False(t, ok)
+
+	raw.vars["tz"] = "Europe/Paris"
+	require.NoError(t, c.(driver.SessionResetter).ResetSession(context.Background()))
+	require.Len(t, hooks.drifts, 1, "the drift is reported after the reset")
+	assert.Equal(t, "Europe/Paris", hooks.drifts[0].Got)
+	assert.Equal(t, "UTC", raw.vars["tz"], "the setting is applied again")
+}
+
+func TestSessionSettingsOpenFailsObserved(t *testing.T) {
+	observer := &poolObserver{}
+	drv := Wrap(&sqlite3.SQLiteDriver{}, observer, WithSession(SessionConfig{
+		Settings: []SessionSetting{{Name: "tz", Set: "SELECT 1", Get: "SELECT 'Europe/Paris'", Want: "UTC"}},
+	})).(*Driver)
+
+	_, err := drv.Open(":memory:")
+	assert.Error(t, err)
+	assert.Len(t, observer.created, 1)
+	assert.Len(t, observer.retired, 1, "the retire matches a create")
+	assert.Empty(t, drv.ConnStats())
+}
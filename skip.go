@@ -0,0 +1,24 @@
+package sqlhooks
+
+import "context"
+
+type hooksDisabledKey struct{}
+
+// WithHooksDisabled returns a context running the operations it is passed to
+// straight on the wrapped driver, without any hook, Interceptor, diagnostic or
+// middleware: to exempt high frequency statements such as health checks, or
+// the statements run by the hooks themselves, which would otherwise hook
+// themselves recursively.
+//
+// The transactions begun with it skip the hooks of their commit or
+// rollback as well, but not the statements run in them with other
+// contexts.
+func WithHooksDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hooksDisabledKey{}, true)
+}
+
+// HooksDisabled reports whether ctx was returned by WithHooksDisabled
+func HooksDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(hooksDisabledKey{}).(bool)
+	return disabled
+}
@@ -0,0 +1,69 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHooksDisabled(t *testing.T) {
+	var queries []string
+	hooks := newTestHooks()
+	hooks.before = func(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+		queries = append(queries, query)
+		return ctx, nil
+	}
+	db := openWithHooks(t, hooks)
+	defer db.Close()
+
+	ctx := WithHooksDisabled(context.Background())
+	assert.True(t, HooksDisabled(ctx))
+	assert.False(t, HooksDisabled(context.Background()))
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT id FROM t")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	stmt, err := db.PrepareContext(ctx, "INSERT INTO t VALUES (?)")
+	require.NoError(t, err)
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, queries)
+
+	_, err = stmt.ExecContext(context.Background(), 2)
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"INSERT INTO t VALUES (?)", "SELECT 1"}, queries, "the other contexts are hooked")
+}
+
+func TestWithHooksDisabledDiagnostics(t *testing.T) {
+	hooks := &leakRecorder{testHooks: newTestHooks()}
+	conn, err := Wrap(&sqlite3.SQLiteDriver{}, hooks, WithLeakDetection(), WithStmtDiagnostics()).Open(":memory:")
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx := WithHooksDisabled(context.Background())
+
+	s, err := conn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT ?")
+	require.NoError(t, err)
+	stmt := s.(*Stmt)
+	assert.Nil(t, stmt.leak, "the statement is not tracked")
+	assert.Empty(t, stmt.callSite)
+
+	_, err = stmt.ExecContext(ctx, nil)
+	assert.NoError(t, err, "the arguments are not checked, sqlite binds NULL")
+	require.NoError(t, stmt.Close())
+
+	closed := &Stmt{Stmt: &fakeStmt{}, hooks: hooks, query: "SELECT 1", conn: stmt.conn}
+	require.NoError(t, closed.Close())
+	_, err = closed.ExecContext(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, hooks.kinds(), "no diagnostic is reported")
+}
@@ -0,0 +1,71 @@
+package splitter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresLag is a LagProbe for PostgreSQL streaming replicas. It reports
+// zero when the replica has replayed everything it received, and otherwise
+// the time since the last replayed transaction.
+func PostgresLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := db.QueryRowContext(ctx, `SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errors.New("splitter: not a replica")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// MySQLLag is a LagProbe for MySQL replicas based on the Seconds_Behind_Master
+// (or Seconds_Behind_Source) column of SHOW SLAVE STATUS.
+func MySQLLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("splitter: not a replica")
+	}
+
+	var (
+		seconds sql.NullInt64
+		dest    = make([]interface{}, len(columns))
+		found   bool
+	)
+	for i, c := range columns {
+		if c == "Seconds_Behind_Master" || c == "Seconds_Behind_Source" {
+			dest[i] = &seconds
+			found = true
+		} else {
+			dest[i] = new(sql.RawBytes)
+		}
+	}
+	if !found {
+		return 0, errors.New("splitter: Seconds_Behind_Master not found")
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errors.New("splitter: replication is not running")
+	}
+	return time.Duration(seconds.Int64) * time.Second, nil
+}
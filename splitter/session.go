@@ -0,0 +1,48 @@
+package splitter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type sessionKey struct{}
+
+type primaryKey struct{}
+
+type session struct {
+	mu    sync.Mutex
+	write time.Time
+}
+
+func (s *session) lastWrite() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write
+}
+
+// WithSession returns a context that tracks the writes made through it, so
+// that later reads with the same context see them. It is usually called once
+// per incoming request.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// MarkWrite records a write in the session of ctx. The DB calls it on
+// ExecContext and BeginTx; call it explicitly for writes made through other
+// handles, e.g. a *sql.Tx or the Primary() database. It is a no-op when ctx
+// has no session.
+func MarkWrite(ctx context.Context) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.write = time.Now()
+	s.mu.Unlock()
+}
+
+// WithPrimary returns a context whose reads always go to the primary
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
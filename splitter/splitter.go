@@ -0,0 +1,343 @@
+// Package splitter routes reads to replicas and everything else to the
+// primary. Each *sql.DB is usually opened with a driver wrapped by sqlhooks,
+// so queries stay instrumented whichever database they end up on.
+//
+// Replicas are health-checked in the background and taken out of rotation
+// while they are failing, slow or lagging too far behind the primary; reads
+// fall back to the primary when no replica is available.
+//
+// Contexts created with WithSession get read-your-writes consistency: after a
+// write, reads in the same session go to the primary until Options.StickyWindow
+// elapses or, when a LagProbe is configured, until a replica has caught up.
+package splitter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica is a named read-only database
+type Replica struct {
+	Name string
+	DB   *sql.DB
+}
+
+// TopologyEvent is passed to Options.OnTopologyChange whenever a replica is
+// removed from or restored to the rotation.
+type TopologyEvent struct {
+	Replica string
+	// Healthy reports whether the replica is now in rotation.
+	Healthy bool
+	// Err is the last health check error, if any.
+	Err error
+	// Latency is the duration of the last health check.
+	Latency time.Duration
+	// Lag is the last replication lag reported by Options.LagProbe.
+	Lag time.Duration
+}
+
+// LagProbe reports how far a replica is behind its primary
+type LagProbe func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+// Options configures a DB
+type Options struct {
+	// HealthCheckInterval is the time between health checks. Defaults to
+	// five seconds. A negative value disables health checking.
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout bounds each health check. Defaults to one second.
+	HealthCheckTimeout time.Duration
+	// MaxLatency marks a replica as failing when its health check takes
+	// longer. Zero means no limit.
+	MaxLatency time.Duration
+	// FailureThreshold is the number of consecutive failing checks before a
+	// replica is removed from rotation. Defaults to 3.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive passing checks before a
+	// removed replica is restored. Defaults to 2.
+	SuccessThreshold int
+	// LagProbe, if set, is run on every health check after a successful
+	// ping. Replicas lagging more than MaxLag are removed from rotation
+	// immediately and restored as soon as they catch up. A failing probe
+	// counts as a failed health check.
+	LagProbe LagProbe
+	MaxLag   time.Duration
+	// StickyWindow is how long reads in a session stay on the primary after
+	// a write. Replicas that the LagProbe reports as caught up with the
+	// write are used even within the window. Defaults to five seconds. A
+	// negative value disables read-your-writes.
+	StickyWindow time.Duration
+	// OnTopologyChange is called when a replica is removed or restored.
+	OnTopologyChange func(TopologyEvent)
+}
+
+type replica struct {
+	Replica
+
+	available  int32 // in rotation, accessed atomically
+	replayedAt int64 // unix nanos of the newest replayed write, accessed atomically
+	healthy    bool
+	lagging    bool
+	failures   int
+	successes  int
+}
+
+// DB routes queries between a primary and its replicas
+type DB struct {
+	primary  *sql.DB
+	replicas []*replica
+	opts     Options
+
+	next uint32 // round-robin counter, accessed atomically
+
+	mu   sync.Mutex // serializes health checks
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a DB routing between primary and replicas, and starts health
+// checking the replicas. Call Stop to stop the health checks; the databases
+// themselves are owned and closed by the caller.
+func New(primary *sql.DB, replicas []Replica, opts Options) *DB {
+	if opts.HealthCheckInterval == 0 {
+		opts.HealthCheckInterval = 5 * time.Second
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = time.Second
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+	if opts.SuccessThreshold <= 0 {
+		opts.SuccessThreshold = 2
+	}
+	if opts.StickyWindow == 0 {
+		opts.StickyWindow = 5 * time.Second
+	}
+
+	db := &DB{
+		primary: primary,
+		opts:    opts,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, r := range replicas {
+		db.replicas = append(db.replicas, &replica{Replica: r, available: 1, healthy: true})
+	}
+
+	if opts.HealthCheckInterval > 0 && len(replicas) > 0 {
+		go db.healthCheckLoop()
+	} else {
+		close(db.done)
+	}
+	return db
+}
+
+// Stop stops the background health checks
+func (db *DB) Stop() {
+	select {
+	case <-db.stop:
+	default:
+		close(db.stop)
+	}
+	<-db.done
+}
+
+// Primary returns the primary database
+func (db *DB) Primary() *sql.DB { return db.primary }
+
+// Replica returns the next healthy replica in rotation, or the primary if
+// there is none.
+func (db *DB) Replica() *sql.DB {
+	return db.pick(time.Time{})
+}
+
+// ReplicaContext is like Replica but honours the read-your-writes session
+// and WithPrimary markers in ctx.
+func (db *DB) ReplicaContext(ctx context.Context) *sql.DB {
+	if ctx.Value(primaryKey{}) != nil {
+		return db.primary
+	}
+
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return db.pick(time.Time{})
+	}
+	lastWrite := s.lastWrite()
+	if lastWrite.IsZero() || time.Since(lastWrite) >= db.opts.StickyWindow {
+		return db.pick(time.Time{})
+	}
+	if db.opts.LagProbe == nil {
+		return db.primary
+	}
+	return db.pick(lastWrite)
+}
+
+// pick returns the next replica in rotation that has replayed the writes
+// made up to since, or the primary if there is none.
+func (db *DB) pick(since time.Time) *sql.DB {
+	n := len(db.replicas)
+	if n == 0 {
+		return db.primary
+	}
+
+	start := int(atomic.AddUint32(&db.next, 1))
+	for i := 0; i < n; i++ {
+		r := db.replicas[(start+i)%n]
+		if atomic.LoadInt32(&r.available) != 1 {
+			continue
+		}
+		if !since.IsZero() && atomic.LoadInt64(&r.replayedAt) < since.UnixNano() {
+			continue
+		}
+		return r.DB
+	}
+	return db.primary
+}
+
+// Healthy returns the names of the replicas currently in rotation
+func (db *DB) Healthy() []string {
+	var names []string
+	for _, r := range db.replicas {
+		if atomic.LoadInt32(&r.available) == 1 {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// QueryContext runs a read query on a replica
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.ReplicaContext(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a read query returning at most one row on a replica
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.ReplicaContext(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext runs a statement on the primary and records the write in the
+// session, if any.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	MarkWrite(ctx)
+	return db.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTx starts a transaction on the primary. Unless the transaction is
+// read-only, it is recorded as a write in the session, if any.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if opts == nil || !opts.ReadOnly {
+		MarkWrite(ctx)
+	}
+	return db.primary.BeginTx(ctx, opts)
+}
+
+func (db *DB) healthCheckLoop() {
+	defer close(db.done)
+
+	ticker := time.NewTicker(db.opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			db.checkReplicas()
+		}
+	}
+}
+
+// checkReplicas runs one round of health checks
+func (db *DB) checkReplicas() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, r := range db.replicas {
+		db.record(r, db.check(r))
+	}
+}
+
+var (
+	errTooSlow = errors.New("splitter: health check exceeded MaxLatency")
+	errLagging = errors.New("splitter: replication lag exceeded MaxLag")
+)
+
+type checkResult struct {
+	start   time.Time
+	latency time.Duration
+	lag     time.Duration
+	err     error
+	lagging bool
+}
+
+func (db *DB) check(r *replica) checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), db.opts.HealthCheckTimeout)
+	defer cancel()
+
+	var res checkResult
+	start := time.Now()
+	res.start = start
+	res.err = r.DB.PingContext(ctx)
+	res.latency = time.Since(start)
+	if res.err == nil && db.opts.MaxLatency > 0 && res.latency > db.opts.MaxLatency {
+		res.err = errTooSlow
+	}
+
+	if res.err == nil && db.opts.LagProbe != nil {
+		res.lag, res.err = db.opts.LagProbe(ctx, r.DB)
+		res.lagging = res.err == nil && db.opts.MaxLag > 0 && res.lag > db.opts.MaxLag
+	}
+	return res
+}
+
+// record updates the replica state, it must be called with db.mu held
+func (db *DB) record(r *replica, res checkResult) {
+	if res.err != nil {
+		r.failures++
+		r.successes = 0
+	} else {
+		r.successes++
+		r.failures = 0
+	}
+
+	switch {
+	case r.healthy && r.failures >= db.opts.FailureThreshold:
+		r.healthy = false
+	case !r.healthy && r.successes >= db.opts.SuccessThreshold:
+		r.healthy = true
+	}
+	if res.err == nil {
+		r.lagging = res.lagging
+	}
+	if res.err == nil && db.opts.LagProbe != nil {
+		atomic.StoreInt64(&r.replayedAt, res.start.Add(-res.lag).UnixNano())
+	}
+
+	available := r.healthy && !r.lagging
+	if available == (atomic.LoadInt32(&r.available) == 1) {
+		return
+	}
+	if available {
+		atomic.StoreInt32(&r.available, 1)
+	} else {
+		atomic.StoreInt32(&r.available, 0)
+	}
+
+	err := res.err
+	if err == nil && r.lagging {
+		err = errLagging
+	}
+	if db.opts.OnTopologyChange != nil {
+		db.opts.OnTopologyChange(TopologyEvent{
+			Replica: r.Name,
+			Healthy: available,
+			Err:     err,
+			Latency: res.latency,
+			Lag:     res.lag,
+		})
+	}
+}
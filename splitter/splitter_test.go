@@ -0,0 +1,223 @@
+package splitter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopHooks struct{}
+
+func (noopHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (noopHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func init() {
+	sql.Register("splitter-sqlite3", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, noopHooks{}))
+}
+
+func openDB(t *testing.T, name string) *sql.DB {
+	db, err := sql.Open("splitter-sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE role(name text)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO role VALUES(?)", name)
+	require.NoError(t, err)
+	return db
+}
+
+func role(t *testing.T, db *DB) string {
+	var name string
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT name FROM role").Scan(&name))
+	return name
+}
+
+func TestRouting(t *testing.T) {
+	primary := openDB(t, "primary")
+	defer primary.Close()
+	r1, r2 := openDB(t, "r1"), openDB(t, "r2")
+	defer r1.Close()
+	defer r2.Close()
+
+	db := New(primary, []Replica{{"r1", r1}, {"r2", r2}}, Options{HealthCheckInterval: -1})
+	defer db.Stop()
+
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		seen[role(t, db)] = true
+	}
+	assert.Equal(t, map[string]bool{"r1": true, "r2": true}, seen)
+
+	_, err := db.ExecContext(context.Background(), "UPDATE role SET name = 'written'")
+	require.NoError(t, err)
+	var name string
+	require.NoError(t, primary.QueryRow("SELECT name FROM role").Scan(&name))
+	assert.Equal(t, "written", name)
+}
+
+func TestHealthChecks(t *testing.T) {
+	primary := openDB(t, "primary")
+	defer primary.Close()
+	r1 := openDB(t, "r1")
+	defer r1.Close()
+
+	var events []TopologyEvent
+	db := New(primary, []Replica{{"r1", r1}}, Options{
+		HealthCheckInterval: -1,
+		FailureThreshold:    2,
+		SuccessThreshold:    1,
+		OnTopologyChange:    func(e TopologyEvent) { events = append(events, e) },
+	})
+	defer db.Stop()
+
+	db.checkReplicas()
+	assert.Equal(t, []string{"r1"}, db.Healthy())
+	assert.Empty(t, events)
+
+	// A closed database fails its pings
+	require.NoError(t, r1.Close())
+	db.checkReplicas()
+	assert.Equal(t, []string{"r1"}, db.Healthy())
+	db.checkReplicas()
+	assert.Empty(t, db.Healthy())
+	require.Len(t, events, 1)
+	assert.Equal(t, "r1", events[0].Replica)
+	assert.False(t, events[0].Healthy)
+	assert.Error(t, events[0].Err)
+
+	// Reads fall back to the primary
+	assert.Equal(t, "primary", role(t, db))
+
+	// Restore the replica
+	db.replicas[0].DB = openDB(t, "r1")
+	defer db.replicas[0].DB.Close()
+	db.checkReplicas()
+	assert.Equal(t, []string{"r1"}, db.Healthy())
+	require.Len(t, events, 2)
+	assert.True(t, events[1].Healthy)
+	assert.Equal(t, "r1", role(t, db))
+}
+
+func TestLagProbe(t *testing.T) {
+	primary := openDB(t, "primary")
+	defer primary.Close()
+	r1 := openDB(t, "r1")
+	defer r1.Close()
+
+	lag := time.Duration(0)
+	var events []TopologyEvent
+	db := New(primary, []Replica{{"r1", r1}}, Options{
+		HealthCheckInterval: -1,
+		LagProbe: func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+			return lag, nil
+		},
+		MaxLag:           time.Second,
+		OnTopologyChange: func(e TopologyEvent) { events = append(events, e) },
+	})
+	defer db.Stop()
+
+	db.checkReplicas()
+	assert.Equal(t, "r1", role(t, db))
+
+	// Lagging replicas are removed straight away
+	lag = 5 * time.Second
+	db.checkReplicas()
+	assert.Equal(t, "primary", role(t, db))
+	require.Len(t, events, 1)
+	assert.False(t, events[0].Healthy)
+	assert.Equal(t, 5*time.Second, events[0].Lag)
+	assert.Equal(t, errLagging, events[0].Err)
+
+	// and restored as soon as they catch up
+	lag = 0
+	db.checkReplicas()
+	assert.Equal(t, "r1", role(t, db))
+	require.Len(t, events, 2)
+	assert.True(t, events[1].Healthy)
+}
+
+func TestReadYourWrites(t *testing.T) {
+	primary := openDB(t, "primary")
+	defer primary.Close()
+	r1 := openDB(t, "r1")
+	defer r1.Close()
+
+	var lag time.Duration
+	db := New(primary, []Replica{{"r1", r1}}, Options{
+		HealthCheckInterval: -1,
+		StickyWindow:        time.Hour,
+		LagProbe: func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+			return lag, nil
+		},
+	})
+	defer db.Stop()
+	db.checkReplicas()
+
+	read := func(ctx context.Context) string {
+		var name string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM role").Scan(&name))
+		return name
+	}
+
+	ctx := WithSession(context.Background())
+	assert.Equal(t, "r1", read(ctx))
+	assert.Equal(t, "primary", read(WithPrimary(ctx)))
+
+	_, err := db.ExecContext(ctx, "UPDATE role SET name = 'primary'")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", read(ctx))
+
+	// Other requests are not affected
+	assert.Equal(t, "r1", read(context.Background()))
+
+	// The replica has not replayed the write yet
+	lag = time.Hour
+	db.checkReplicas()
+	assert.Equal(t, "primary", read(ctx))
+
+	// Once it catches up it can serve the session again
+	lag = 0
+	db.checkReplicas()
+	assert.Equal(t, "r1", read(ctx))
+}
+
+func TestReadYourWritesDefaults(t *testing.T) {
+	primary := openDB(t, "primary")
+	defer primary.Close()
+	r1 := openDB(t, "r1")
+	defer r1.Close()
+
+	for name, tc := range map[string]struct {
+		window time.Duration
+		want   string
+	}{
+		"zero value": {0, "primary"},
+		"disabled":   {-1, "r1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := New(primary, []Replica{{"r1", r1}}, Options{
+				HealthCheckInterval: -1,
+				StickyWindow:        tc.window,
+			})
+			defer db.Stop()
+
+			ctx := WithSession(context.Background())
+			_, err := db.ExecContext(ctx, "UPDATE role SET name = 'primary'")
+			require.NoError(t, err)
+			var name string
+			require.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM role").Scan(&name))
+			assert.Equal(t, tc.want, name)
+		})
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Hook is the hook callback signature
@@ -23,6 +26,40 @@ type OnErrorer interface {
 	OnError(ctx context.Context, err error, query string, args ...interface{}) error
 }
 
+// ConnEvent describes a connection being opened or closed by the wrapped driver
+type ConnEvent struct {
+	// ConnID identifies the connection within the process.
+	ConnID uint64
+	// OpenedAt is the time the connection was opened.
+	OpenedAt time.Time
+	// Err is the error returned by the underlying driver when opening or
+	// closing the connection, if any.
+	Err error
+	// LastErr is the last error returned by the underlying driver on this
+	// connection before it was closed. database/sql discards connections
+	// that fail with driver.ErrBadConn, so this usually explains why a
+	// connection went away.
+	LastErr error
+	// Uses is the number of statements the connection served before it was
+	// closed.
+	Uses int64
+	// Age is how long the connection was open, when it is closed.
+	Age time.Duration
+}
+
+// ConnHooks instances will be notified when connections are opened and closed
+type ConnHooks interface {
+	OnConnOpen(event ConnEvent)
+	OnConnClose(event ConnEvent)
+}
+
+// ConnCloseErrorer instances will be notified when the underlying driver
+// fails to close a connection. database/sql discards these errors, which
+// may hide leaked server resources or a broken network.
+type ConnCloseErrorer interface {
+	OnConnCloseError(event ConnEvent)
+}
+
 func handlerErr(ctx context.Context, hooks Hooks, err error, query string, args ...interface{}) error {
 	h, ok := hooks.(OnErrorer)
 	if !ok {
@@ -36,52 +73,167 @@ func handlerErr(ctx context.Context, hooks Hooks, err error, query string, args
 	return err
 }
 
+// connSeq is the last connection ID, accessed atomically
+var connSeq uint64
+
 // Driver implements a database/sql/driver.Driver
 type Driver struct {
 	driver.Driver
 	hooks Hooks
+	ext   extensions
+	opts  *options
+	info  *DriverInfo
+
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
 }
 
 // Open opens a connection
 func (drv *Driver) Open(name string) (driver.Conn, error) {
-	conn, err := drv.Driver.Open(name)
-	if err != nil {
-		return conn, err
-	}
+	return drv.open(context.Background(), func() (driver.Conn, error) {
+		return drv.Driver.Open(name)
+	})
+}
 
+// checkConn returns an error if the wrapper does not support conn
+func (drv *Driver) checkConn(conn driver.Conn) error {
+	if drv.opts.strict {
+		if err := drv.opts.checkStrict(conn); err != nil {
+			return err
+		}
+	}
 	// Drivers that don't implement driver.ConnBeginTx are not supported.
 	if _, ok := conn.(driver.ConnBeginTx); !ok {
-		return nil, errors.New("driver must implement driver.ConnBeginTx")
+		return errors.New("driver must implement driver.ConnBeginTx")
+	}
+	return nil
+}
+
+// open wraps the connection opened by open, ctx being the context of the
+// caller if any
+func (drv *Driver) open(ctx context.Context, open func() (driver.Conn, error)) (driver.Conn, error) {
+	id := atomic.AddUint64(&connSeq, 1)
+	openedAt := time.Now()
+	conn, err := open()
+	if err == nil {
+		if err = drv.checkConn(conn); err != nil {
+			_ = conn.Close()
+			conn = nil
+		}
+	}
+	// The connections rejected by the checks are reported as failed opens
+	if h := drv.ext.conn; h != nil {
+		h.OnConnOpen(ConnEvent{ConnID: id, OpenedAt: openedAt, Err: err})
+	}
+	if err != nil {
+		return conn, err
 	}
 
-	wrapped := &Conn{conn, drv.hooks}
-	if isExecer(conn) && isQueryer(conn) && isSessionResetter(conn) {
+	wrapped := &Conn{Conn: conn, hooks: drv.hooks, ext: drv.ext, opts: drv.opts, drv: drv, id: id, openedAt: openedAt}
+	wrapped.uid = drv.opts.newID(ctx, IDConn, id)
+	// Tracked and reported created before the session is applied, as
+	// closing the connection on failure untracks and retires it
+	drv.track(wrapped)
+	wrapped.observeCreated()
+	if len(drv.opts.session.Settings) > 0 {
+		if err := wrapped.applySession(ctx); err != nil {
+			_ = wrapped.Close()
+			return nil, err
+		}
+	}
+	// The connections lacking driver.Execer or driver.Queryer run the queries
+	// and statements they cannot run directly prepared, as database/sql
+	// would, so that the failures to prepare them reach OnError with their
+	// arguments.
+	if isSessionResetter(conn) {
 		return &ExecerQueryerContextWithSessionResetter{wrapped,
 			&ExecerContext{wrapped}, &QueryerContext{wrapped},
 			&SessionResetter{wrapped}}, nil
-	} else if isExecer(conn) && isQueryer(conn) {
-		return &ExecerQueryerContext{wrapped, &ExecerContext{wrapped},
-			&QueryerContext{wrapped}}, nil
-	} else if isExecer(conn) {
-		// If conn implements an Execer interface, return a driver.Conn which
-		// also implements Execer
-		return &ExecerContext{wrapped}, nil
-	} else if isQueryer(conn) {
-		// If conn implements an Queryer interface, return a driver.Conn which
-		// also implements Queryer
-		return &QueryerContext{wrapped}, nil
 	}
-	return wrapped, nil
+	return &ExecerQueryerContext{wrapped, &ExecerContext{wrapped},
+		&QueryerContext{wrapped}}, nil
 }
 
 // Conn implements a database/sql.driver.Conn
 type Conn struct {
 	Conn  driver.Conn
 	hooks Hooks
+	ext   extensions
+	opts  *options
+	drv   *Driver
+
+	id       uint64
+	uid      string
+	openedAt time.Time
+
+	mu          sync.Mutex
+	lastErr     error
+	errors      int64
+	uses        int64
+	lastUsed    time.Time
+	txStartedAt time.Time
+	txID        string
+}
+
+// use counts an operation run on the connection if it is a statement, and
+// returns the number of statements served so far, including this one,
+// whether the connection is in a transaction and, for statements run outside
+// of a transaction after another one, how long the connection was idle.
+// These are reported to the PoolObserver as reuses of the connection.
+func (conn *Conn) use(op Op) (int64, bool, time.Duration) {
+	var (
+		reused bool
+		idle   time.Duration
+	)
+	conn.mu.Lock()
+	if op == OpQuery || op == OpExec {
+		now := time.Now()
+		reused, idle = conn.uses > 0 && conn.txStartedAt.IsZero(), now.Sub(conn.lastUsed)
+		conn.uses++
+		conn.lastUsed = now
+	}
+	uses, inTx := conn.uses, !conn.txStartedAt.IsZero()
+	conn.mu.Unlock()
+
+	if !reused {
+		return uses, inTx, 0
+	}
+	conn.observeReused(uses, idle)
+	return uses, inTx, idle
+}
+
+// setLastErr records an error returned by the underlying driver
+func (conn *Conn) setLastErr(err error) {
+	conn.mu.Lock()
+	conn.lastErr = err
+	conn.errors++
+	conn.mu.Unlock()
 }
 
 func (conn *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	return conn.prepareContext(ctx, query)
+	if HooksDisabled(ctx) {
+		return conn.prepareContext(ctx, query)
+	}
+	query, err := conn.rewritePrepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var stmt *Stmt
+	err = conn.hookOp(ctx, OpPrepare, query, func(ctx context.Context) error {
+		var err error
+		stmt, err = conn.prepareWithHooks(ctx, query)
+		return err
+	})
+	if err != nil {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+		return nil, err
+	}
+	if conn.opts.stmtMiddleware != nil {
+		return conn.opts.stmtMiddleware(ctx, query, stmt), nil
+	}
+	return stmt, nil
 }
 
 func (conn *Conn) prepareContext(ctx context.Context, query string) (*Stmt, error) {
@@ -93,21 +245,107 @@ func (conn *Conn) prepareContext(ctx context.Context, query string) (*Stmt, erro
 	if c, ok := conn.Conn.(driver.ConnPrepareContext); ok {
 		stmt, err = c.PrepareContext(ctx, query)
 	} else {
-		stmt, err = conn.Prepare(query)
+		stmt, err = conn.Conn.Prepare(query)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Stmt{stmt, conn.hooks, query}, nil
+	wrapped := &Stmt{Stmt: stmt, hooks: conn.hooks, query: query, conn: conn}
+	if HooksDisabled(ctx) {
+		return wrapped, nil
+	}
+	if conn.opts.stmtDiagnostics {
+		wrapped.callSite = callSite()
+	}
+	wrapped.leak = conn.trackLeak(LeakedStmt, query)
+	return wrapped, nil
+}
+
+// Prepare is hooked as PrepareContext, which database/sql calls instead
+func (conn *Conn) Prepare(query string) (driver.Stmt, error) {
+	return conn.PrepareContext(context.Background(), query)
 }
 
-func (conn *Conn) Prepare(query string) (driver.Stmt, error) { return conn.Conn.Prepare(query) }
-func (conn *Conn) Close() error                              { return conn.Conn.Close() }
-func (conn *Conn) Begin() (driver.Tx, error)                 { return conn.Conn.Begin() }
+// Unwrap returns the wrapped connection, see the Unwrap function
+func (conn *Conn) Unwrap() driver.Conn { return conn.Conn }
+
+// CheckNamedValue forwards to the wrapped connection if it is a
+// driver.NamedValueChecker, for drivers accepting custom types or output
+// parameters. Otherwise it returns driver.ErrSkip, for database/sql to
+// convert the argument as it would without the wrapper.
+func (conn *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := conn.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (conn *Conn) Begin() (driver.Tx, error) { return conn.Conn.Begin() }
 func (conn *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	return conn.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	var tx driver.Tx
+	txID := conn.opts.newID(ctx, IDTx, conn.id)
+	conn.mu.Lock()
+	conn.txID = txID
+	conn.mu.Unlock()
+
+	txHooks := conn.ext.tx
+	if HooksDisabled(ctx) {
+		txHooks = nil
+	}
+	event := TxEvent{ConnID: conn.id, TxID: txID, Opts: opts}
+	if txHooks != nil {
+		txHooks.BeforeBegin(ctx, event)
+	}
+	err := conn.hookOp(ctx, OpBegin, "BEGIN", func(ctx context.Context) error {
+		var err error
+		tx, err = conn.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+		return err
+	})
+	if err != nil {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+		conn.mu.Lock()
+		conn.txID = ""
+		conn.mu.Unlock()
+		if txHooks != nil {
+			event.Err = err
+			txHooks.AfterBegin(ctx, event)
+		}
+		return nil, err
+	}
+	startedAt := time.Now()
+	conn.mu.Lock()
+	conn.txStartedAt = startedAt
+	conn.mu.Unlock()
+	if txHooks != nil {
+		event.StartedAt = startedAt
+		txHooks.AfterBegin(ctx, event)
+	}
+	return &Tx{Tx: tx, conn: conn, ctx: ctx, id: txID, opts: opts, startedAt: startedAt}, nil
+}
+
+func (conn *Conn) Close() error {
+	err := conn.Conn.Close()
+	conn.drv.untrack(conn)
+	connHooks, closeErrorer := conn.ext.conn, conn.ext.closeErrorer
+	if connHooks == nil && conn.ext.pool == nil && (closeErrorer == nil || err == nil) {
+		return err
+	}
+
+	conn.mu.Lock()
+	event := ConnEvent{ConnID: conn.id, OpenedAt: conn.openedAt, Err: err, LastErr: conn.lastErr, Uses: conn.uses, Age: time.Since(conn.openedAt)}
+	conn.mu.Unlock()
+	if connHooks != nil {
+		connHooks.OnConnClose(event)
+	}
+	if closeErrorer != nil && err != nil {
+		closeErrorer.OnConnCloseError(event)
+	}
+	conn.observeRetired(event)
+	return err
 }
 
 // ExecerContext implements a database/sql.driver.ExecerContext
@@ -131,19 +369,17 @@ func (conn *ExecerContext) execContext(ctx context.Context, query string, args [
 	case driver.ExecerContext:
 		return c.ExecContext(ctx, query, args)
 	case driver.Execer:
-		dargs, err := namedValueToValue(args)
-		if err != nil {
-			return nil, err
-		}
-		return c.Exec(query, dargs)
+		return conn.execValues(args, func(dargs []driver.Value) (driver.Result, error) {
+			return c.Exec(query, dargs)
+		})
 	default:
-		// This should not happen
-		return nil, errors.New("ExecerContext created for a non Execer driver.Conn")
+		// Run prepared by ExecContext
+		return nil, driver.ErrSkip
 	}
 }
 
 func (conn *ExecerContext) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	return execWithHooks(ctx, query, args, conn.hooks, func(ctx context.Context) (driver.Result, error) {
+	return execWithHooks(ctx, conn.Conn, query, args, true, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 		results, err := conn.execContext(ctx, query, args)
 		if err == nil || !errors.Is(err, driver.ErrSkip) {
 			return results, err
@@ -151,6 +387,9 @@ func (conn *ExecerContext) ExecContext(ctx context.Context, query string, args [
 		// If driver.ErrSkip is returned, we fall back to using Prepare + Statement to handle the query.
 		// We need to avoid executing the hooks twice since they were already run in ExecContext.
 		// This matches the behavior in database/sql when ExecContext returns ErrSkip.
+		if event := EventFromContext(ctx); event != nil {
+			event.PreparedFallback = true
+		}
 		stmt, err := conn.prepareContext(ctx, query)
 		if err != nil {
 			return nil, err
@@ -160,26 +399,76 @@ func (conn *ExecerContext) ExecContext(ctx context.Context, query string, args [
 	})
 }
 
-func execWithHooks(ctx context.Context, query string, args []driver.NamedValue, hooks Hooks, execer func(context.Context) (driver.Result, error)) (driver.Result, error) {
+// execWithHooks runs execer with the hooks. Queries and statements run on the
+// connection are rewritten by the QueryRewriter hooks with rewrite, those of
+// prepared statements were when they were prepared.
+func execWithHooks(ctx context.Context, conn *Conn, query string, args []driver.NamedValue, rewrite bool, execer ExecFunc) (driver.Result, error) {
+	if HooksDisabled(ctx) {
+		return execer(ctx, query, args)
+	}
 	var err error
-
-	list := namedToInterface(args)
+	// Audit the caller's context, the enrichers derive it from Background
+	conn.auditContext(ctx, query)
+	ctx = conn.opts.enrich(ctx)
+
+	event := conn.newEvent(ctx, OpExec, query, args)
+	event.Attempt, event.attempts = beginAttempt(ctx)
+	defer conn.releaseEvent(event)
+	list := event.Args
+	if rewrite {
+		if err := conn.rewriteQuery(ctx, event); err != nil {
+			return nil, err
+		}
+		query = event.Query
+	}
+	hookCtx, cancel := conn.opts.hookContext(contextWithEvent(ctx, event))
+	hookCtx, args, err = conn.mutateArgs(hookCtx, event, query, args)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	list = event.Args
 
 	// Exec `Before` Hooks
-	if ctx, err = hooks.Before(ctx, query, list...); err != nil {
+	hookCtx, err = conn.before(hookCtx, query, list)
+	cancel()
+	if err != nil {
 		return nil, err
 	}
 
-	results, err := execer(ctx)
+	if interceptor := conn.ext.interceptor; interceptor != nil {
+		execer = chainExec(interceptor, execer)
+	}
+
+	start := time.Now()
+	conn.opts.deadlines.observeStart(ctx, start)
+	results, err := execer(conn.opts.callContext(hookCtx, ctx), query, args)
+	event.Duration = time.Since(start)
+	conn.opts.deadlines.observeEnd(ctx, start.Add(event.Duration))
+	hookCtx, cancel = conn.opts.hookContext(hookCtx)
+	defer cancel()
 	if err != nil {
-		return results, handlerErr(ctx, hooks, err, query, list...)
+		conn.setLastErr(err)
+		event.attempts.fail(err)
+		return results, conn.onError(hookCtx, err, query, list)
 	}
 
-	if _, err := hooks.After(ctx, query, list...); err != nil {
+	afterCtx, err := conn.after(hookCtx, query, list)
+	if err != nil {
 		return nil, err
 	}
 
-	return results, err
+	if h := conn.ext.execResult; h != nil && results != nil {
+		afterCtx, err = conn.afterExec(h, afterCtx, results, query, list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if h := conn.ext.result; h != nil && results != nil {
+		results = &Result{Result: results, ctx: conn.detachEvent(afterCtx, event), hooks: h, query: query, opts: conn.opts}
+	}
+	return results, nil
 }
 
 func (conn *ExecerContext) Exec(query string, args []driver.Value) (driver.Result, error) {
@@ -211,19 +500,19 @@ func (conn *QueryerContext) queryContext(ctx context.Context, query string, args
 	case driver.QueryerContext:
 		return c.QueryContext(ctx, query, args)
 	case driver.Queryer:
-		dargs, err := namedValueToValue(args)
+		dargs, err := namedValueToValue(nil, args)
 		if err != nil {
 			return nil, err
 		}
 		return c.Query(query, dargs)
 	default:
-		// This should not happen
-		return nil, errors.New("QueryerContext created for a non Queryer driver.Conn")
+		// Run prepared by QueryContext
+		return nil, driver.ErrSkip
 	}
 }
 
 func (conn *QueryerContext) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	return queryWithHooks(ctx, query, args, conn.hooks, func(ctx context.Context) (driver.Rows, error) {
+	return queryWithHooks(ctx, conn.Conn, query, args, true, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 		rows, err := conn.queryContext(ctx, query, args)
 		if err == nil || !errors.Is(err, driver.ErrSkip) {
 			return rows, err
@@ -231,6 +520,9 @@ func (conn *QueryerContext) QueryContext(ctx context.Context, query string, args
 		// If driver.ErrSkip is returned, we fall back to using Prepare + Statement to handle the query.
 		// We need to avoid executing the hooks twice since they were already run in QueryContext.
 		// This matches the behavior in database/sql when QueryContext returns ErrSkip.
+		if event := EventFromContext(ctx); event != nil {
+			event.PreparedFallback = true
+		}
 		stmt, err := conn.prepareContext(ctx, query)
 		if err != nil {
 			return nil, err
@@ -244,26 +536,78 @@ func (conn *QueryerContext) QueryContext(ctx context.Context, query string, args
 	})
 }
 
-func queryWithHooks(ctx context.Context, query string, args []driver.NamedValue, hooks Hooks, queryer func(context.Context) (driver.Rows, error)) (driver.Rows, error) {
+// queryWithHooks runs queryer with the hooks. Queries and statements run on the
+// connection are rewritten by the QueryRewriter hooks with rewrite, those of
+// prepared statements were when they were prepared.
+func queryWithHooks(ctx context.Context, conn *Conn, query string, args []driver.NamedValue, rewrite bool, queryer QueryFunc) (driver.Rows, error) {
+	if HooksDisabled(ctx) {
+		return queryer(ctx, query, args)
+	}
 	var err error
-
-	list := namedToInterface(args)
+	// Audit the caller's context, the enrichers derive it from Background
+	conn.auditContext(ctx, query)
+	ctx = conn.opts.enrich(ctx)
+
+	event := conn.newEvent(ctx, OpQuery, query, args)
+	event.Attempt, event.attempts = beginAttempt(ctx)
+	defer conn.releaseEvent(event)
+	list := event.Args
+	if rewrite {
+		if err := conn.rewriteQuery(ctx, event); err != nil {
+			return nil, err
+		}
+		query = event.Query
+	}
+	hookCtx, cancel := conn.opts.hookContext(contextWithEvent(ctx, event))
+	hookCtx, args, err = conn.mutateArgs(hookCtx, event, query, args)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	list = event.Args
 
 	// Query `Before` Hooks
-	if ctx, err = hooks.Before(ctx, query, list...); err != nil {
+	hookCtx, err = conn.before(hookCtx, query, list)
+	cancel()
+	if err != nil {
 		return nil, err
 	}
 
-	results, err := queryer(ctx)
+	if interceptor := conn.ext.interceptor; interceptor != nil {
+		queryer = chainQuery(interceptor, queryer)
+	}
+
+	start := time.Now()
+	conn.opts.deadlines.observeStart(ctx, start)
+	results, err := queryer(conn.opts.callContext(hookCtx, ctx), query, args)
+	event.Duration = time.Since(start)
+	conn.opts.deadlines.observeEnd(ctx, start.Add(event.Duration))
+	hookCtx, cancel = conn.opts.hookContext(hookCtx)
+	defer cancel()
 	if err != nil {
-		return results, handlerErr(ctx, hooks, err, query, list...)
+		conn.setLastErr(err)
+		event.attempts.fail(err)
+		return results, conn.onError(hookCtx, err, query, list)
 	}
 
-	if _, err := hooks.After(ctx, query, list...); err != nil {
+	afterCtx, err := conn.after(hookCtx, query, list)
+	if err != nil {
 		return nil, err
 	}
+	if results != nil {
+		afterCtx = conn.detachEvent(afterCtx, event)
+	}
 
-	return results, err
+	if h := conn.ext.rows; h != nil && results != nil {
+		results = &hookedRows{Rows: NewRows(results), ctx: afterCtx, hooks: h, opts: conn.opts, event: RowsEvent{Query: query}}
+	}
+	if l := conn.trackLeak(LeakedRows, query); l != nil && results != nil {
+		results = &trackedRows{Rows: NewRows(results), leak: l}
+	}
+	if conn.opts.rowsMiddleware != nil && results != nil {
+		results = conn.opts.rowsMiddleware(afterCtx, query, NewRows(results))
+	}
+	return results, nil
 }
 
 // ExecerQueryerContext implements database/sql.driver.ExecerContext and
@@ -283,6 +627,9 @@ type ExecerQueryerContextWithSessionResetter struct {
 	*SessionResetter
 }
 
+// SessionResetter implements database/sql.driver.SessionResetter, as every
+// Conn now does: ResetSession and IsValid are forwarded to the wrapped
+// connection when it implements driver.SessionResetter or driver.Validator
 type SessionResetter struct {
 	*Conn
 }
@@ -292,6 +639,11 @@ type Stmt struct {
 	Stmt  driver.Stmt
 	hooks Hooks
 	query string
+	conn  *Conn
+
+	callSite string
+	closed   bool
+	leak     *leak
 }
 
 func (stmt *Stmt) execContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
@@ -299,16 +651,24 @@ func (stmt *Stmt) execContext(ctx context.Context, args []driver.NamedValue) (dr
 		return s.ExecContext(ctx, args)
 	}
 
-	values := make([]driver.Value, len(args))
-	for _, arg := range args {
-		values[arg.Ordinal-1] = arg.Value
-	}
-
-	return stmt.Exec(values)
+	return stmt.conn.execValues(args, stmt.Exec)
 }
 
 func (stmt *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	return execWithHooks(ctx, stmt.query, args, stmt.hooks, func(ctx context.Context) (driver.Result, error) {
+	if err := stmt.checkArgs(ctx, len(args)); err != nil {
+		return nil, err
+	}
+	return execWithHooks(ctx, stmt.conn, stmt.query, args, false, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		if query != stmt.query {
+			// An Interceptor replaced the query, run it as a one-off
+			// statement on the same connection.
+			s, err := stmt.conn.prepareContext(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			defer s.Close()
+			return s.execContext(ctx, args)
+		}
 		return stmt.execContext(ctx, args)
 	})
 }
@@ -318,46 +678,100 @@ func (stmt *Stmt) queryContext(ctx context.Context, args []driver.NamedValue) (d
 		return s.QueryContext(ctx, args)
 	}
 
-	values := make([]driver.Value, len(args))
-	for _, arg := range args {
-		values[arg.Ordinal-1] = arg.Value
+	values, err := namedValueToValue(nil, args)
+	if err != nil {
+		return nil, err
 	}
 	return stmt.Query(values)
 }
 
 func (stmt *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	return queryWithHooks(ctx, stmt.query, args, stmt.hooks, func(ctx context.Context) (driver.Rows, error) {
+	if err := stmt.checkArgs(ctx, len(args)); err != nil {
+		return nil, err
+	}
+	return queryWithHooks(ctx, stmt.conn, stmt.query, args, false, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		if query != stmt.query {
+			// An Interceptor replaced the query, run it as a one-off
+			// statement on the same connection.
+			s, err := stmt.conn.prepareContext(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			rows, err := s.queryContext(ctx, args)
+			if err != nil {
+				_ = s.Close()
+				return nil, err
+			}
+			return &rowsWrapper{rows: rows, closeStmt: s}, nil
+		}
 		return stmt.queryContext(ctx, args)
 	})
 }
 
-func (stmt *Stmt) Close() error                                    { return stmt.Stmt.Close() }
-func (stmt *Stmt) NumInput() int                                   { return stmt.Stmt.NumInput() }
+func (stmt *Stmt) Close() error {
+	stmt.closed = true
+	stmt.leak.release()
+	return stmt.Stmt.Close()
+}
+
+// NumInput returns the number of placeholders of the statement. With
+// WithStmtDiagnostics it returns -1, so that database/sql leaves the check
+// to the wrapper.
+func (stmt *Stmt) NumInput() int {
+	if stmt.conn.opts.stmtDiagnostics {
+		return -1
+	}
+	return stmt.Stmt.NumInput()
+}
+
+// Unwrap returns the wrapped statement, see the Unwrap function
+func (stmt *Stmt) Unwrap() driver.Stmt { return stmt.Stmt }
+
+// CheckNamedValue forwards to the wrapped statement if it is a
+// driver.NamedValueChecker, or else to its connection, as database/sql
+// does
+func (stmt *Stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if s, ok := stmt.Stmt.(driver.NamedValueChecker); ok {
+		return s.CheckNamedValue(nv)
+	}
+	return stmt.conn.CheckNamedValue(nv)
+}
+
 func (stmt *Stmt) Exec(args []driver.Value) (driver.Result, error) { return stmt.Stmt.Exec(args) }
 func (stmt *Stmt) Query(args []driver.Value) (driver.Rows, error)  { return stmt.Stmt.Query(args) }
 
 // Wrap is used to create a new instrumented driver, it takes a vendor specific driver, and a Hooks instance to produce a new driver instance.
 // It's usually used inside a sql.Register() statement
-func Wrap(driver driver.Driver, hooks Hooks) driver.Driver {
-	return &Driver{driver, hooks}
+func Wrap(driver driver.Driver, hooks Hooks, opts ...Option) driver.Driver {
+	o := newOptions(opts)
+	if o.dialect == nil {
+		o.dialect, _ = DetectDialect(driver)
+	}
+	return &Driver{Driver: driver, hooks: hooks, ext: newExtensions(hooks), opts: o, info: newDriverInfo(driver)}
 }
 
-func namedToInterface(args []driver.NamedValue) []interface{} {
-	list := make([]interface{}, len(args))
-	for i, a := range args {
-		list[i] = a.Value
+// namedToInterface appends the values of args to list, which is allocated if nil
+func namedToInterface(list []interface{}, args []driver.NamedValue) []interface{} {
+	if list == nil {
+		list = make([]interface{}, 0, len(args))
+	}
+	for _, a := range args {
+		list = append(list, a.Value)
 	}
 	return list
 }
 
-// namedValueToValue copied from database/sql
-func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
-	dargs := make([]driver.Value, len(named))
-	for n, param := range named {
+// namedValueToValue adapted from database/sql, it appends the values to
+// dargs, which is allocated if nil
+func namedValueToValue(dargs []driver.Value, named []driver.NamedValue) ([]driver.Value, error) {
+	if dargs == nil {
+		dargs = make([]driver.Value, 0, len(named))
+	}
+	for _, param := range named {
 		if len(param.Name) > 0 {
-			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+			return dargs, errors.New("sql: driver does not support the use of Named Parameters")
 		}
-		dargs[n] = param.Value
+		dargs = append(dargs, param.Value)
 	}
 	return dargs, nil
 }
@@ -367,6 +781,8 @@ type rowsWrapper struct {
 	closeStmt driver.Stmt // if non-nil, statement to Close on close
 }
 
+func (r *rowsWrapper) Unwrap() driver.Rows { return r.rows }
+
 func (r *rowsWrapper) Close() error {
 	err := r.rows.Close()
 	if r.closeStmt != nil {
@@ -12,7 +12,15 @@ func isSessionResetter(conn driver.Conn) bool {
 	return ok
 }
 
-func (s *SessionResetter) ResetSession(ctx context.Context) error {
-	c := s.Conn.Conn.(driver.SessionResetter)
-	return c.ResetSession(ctx)
+// ResetSession resets the session of the wrapped connection if it is a
+// driver.SessionResetter, and verifies its session settings in any case, see
+// SessionConfig.VerifyOnReset. The other connections are otherwise reused as
+// they are, as database/sql would without the wrapper.
+func (conn *Conn) ResetSession(ctx context.Context) error {
+	if c, ok := conn.Conn.(driver.SessionResetter); ok {
+		if err := c.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+	return conn.verifySession(ctx)
 }
@@ -0,0 +1,16 @@
+//go:build go1.15
+// +build go1.15
+
+package sqlhooks
+
+import "database/sql/driver"
+
+// IsValid forwards to the wrapped connection if it is a driver.Validator,
+// so that database/sql discards the connections it reports broken, such as
+// the ones killed by the server. The other connections are valid.
+func (conn *Conn) IsValid() bool {
+	if v, ok := conn.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+//go:build go1.15
+// +build go1.15
+
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetValidatorConn is a connection killed by the server, as reported by
+// IsValid
+type resetValidatorConn struct {
+	FakeConnBasic
+	FakeConnExecerContext
+	resets int
+}
+
+func (c *resetValidatorConn) ResetSession(ctx context.Context) error {
+	c.resets++
+	return nil
+}
+
+func (c *resetValidatorConn) IsValid() bool { return false }
+
+type resetValidatorDriver struct {
+	conn driver.Conn
+}
+
+func (d resetValidatorDriver) Open(string) (driver.Conn, error) { return d.conn, nil }
+
+func TestSessionResetterValidator(t *testing.T) {
+	underlying := &resetValidatorConn{}
+	conn, err := Wrap(resetValidatorDriver{underlying}, newTestHooks()).Open("")
+	require.NoError(t, err)
+	require.Implements(t, (*driver.SessionResetter)(nil), conn)
+	require.Implements(t, (*driver.Validator)(nil), conn)
+	assert.NoError(t, conn.(driver.SessionResetter).ResetSession(context.Background()))
+	assert.Equal(t, 1, underlying.resets)
+	assert.False(t, conn.(driver.Validator).IsValid())
+
+	// Connections without them are reused as database/sql would
+	conn, err = Wrap(&fakeDriver{}, newTestHooks()).Open("Basic")
+	require.NoError(t, err)
+	assert.NoError(t, conn.(driver.SessionResetter).ResetSession(context.Background()))
+	assert.True(t, conn.(driver.Validator).IsValid())
+}
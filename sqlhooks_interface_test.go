@@ -2,9 +2,13 @@ package sqlhooks
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,6 +26,9 @@ var interfaceTestCases = []struct {
 	{"ExecerQueryerContext", []interface{}{
 		(*driver.ExecerContext)(nil),
 		(*driver.QueryerContext)(nil)}},
+	{"NamedValueChecker", []interface{}{
+		(*driver.ExecerContext)(nil),
+		(*driver.NamedValueChecker)(nil)}},
 }
 
 type fakeDriver struct{}
@@ -63,6 +70,11 @@ func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
 			*FakeConnQueryer
 			*FakeConnSessionResetter
 		}{}, nil
+	case "NamedValueChecker":
+		return &struct {
+			*FakeConnBasic
+			*FakeConnNamedValueChecker
+		}{FakeConnNamedValueChecker: &FakeConnNamedValueChecker{}}, nil
 	case "NonConnBeginTx":
 		return &FakeConnUnsupported{}, nil
 	}
@@ -116,6 +128,32 @@ func (*FakeConnSessionResetter) ResetSession(ctx context.Context) error {
 	return errors.New("Not implemented")
 }
 
+// outParam is a custom argument type, only accepted by
+// FakeConnNamedValueChecker
+type outParam struct {
+	dest *int64
+}
+
+// FakeConnNamedValueChecker accepts outParam arguments, which it sets when
+// executing statements
+type FakeConnNamedValueChecker struct{}
+
+func (*FakeConnNamedValueChecker) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(outParam); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (*FakeConnNamedValueChecker) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	for _, arg := range args {
+		if out, ok := arg.Value.(outParam); ok {
+			*out.dest = 42
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
 // FakeConnUnsupported implements a database/sql.driver.Conn but doesn't implement
 // driver.ConnBeginTx.
 type FakeConnUnsupported struct{}
@@ -143,8 +181,215 @@ func TestInterfaces(t *testing.T) {
 	}
 }
 
+func TestNamedValueChecker(t *testing.T) {
+	driverName := fmt.Sprintf("sqlhooks-checker-%s", time.Now().String())
+	sql.Register(driverName, Wrap(&fakeDriver{}, newTestHooks()))
+
+	db, err := sql.Open(driverName, "NamedValueChecker")
+	require.NoError(t, err)
+	defer db.Close()
+	var out int64
+	_, err = db.Exec("CALL p(?, ?)", outParam{dest: &out}, "x")
+	require.NoError(t, err, "custom types are checked by the driver")
+	assert.Equal(t, int64(42), out)
+
+	db, err = sql.Open(driverName, "ExecerContext")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CALL p(?)", outParam{dest: &out})
+	assert.Contains(t, err.Error(), "unsupported type", "drivers without checker keep the default conversion")
+}
+
+// unsupportedDriver opens connections not implementing driver.ConnBeginTx,
+// and records whether they were closed
+type unsupportedDriver struct {
+	closed bool
+}
+
+func (d *unsupportedDriver) Open(name string) (driver.Conn, error) {
+	return closeFunc(func() error { d.closed = true; return nil }), nil
+}
+
+// closeFunc is a driver.Conn only implementing Close, not driver.ConnBeginTx
+type closeFunc func() error
+
+func (f closeFunc) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("Not implemented")
+}
+func (f closeFunc) Close() error              { return f() }
+func (f closeFunc) Begin() (driver.Tx, error) { return nil, errors.New("Not implemented") }
+
 func TestUnsupportedDrivers(t *testing.T) {
 	drv := Wrap(&fakeDriver{}, &testHooks{})
 	_, err := drv.Open("NonConnBeginTx")
 	require.EqualError(t, err, "driver must implement driver.ConnBeginTx")
+
+	hooks := &connHooks{}
+	unsupported := &unsupportedDriver{}
+	_, err = Wrap(unsupported, hooks).Open("")
+	require.Error(t, err)
+	assert.True(t, unsupported.closed, "the rejected connection is closed")
+	require.Len(t, hooks.opened, 1)
+	assert.Equal(t, err, hooks.opened[0].Err, "the rejected connection is reported as a failed open")
+	assert.Empty(t, hooks.closed)
+}
+
+type connHooks struct {
+	testHooks
+	opened, closed, closeErrors []ConnEvent
+}
+
+func (h *connHooks) OnConnOpen(event ConnEvent)       { h.opened = append(h.opened, event) }
+func (h *connHooks) OnConnClose(event ConnEvent)      { h.closed = append(h.closed, event) }
+func (h *connHooks) OnConnCloseError(event ConnEvent) { h.closeErrors = append(h.closeErrors, event) }
+
+func TestConnHooks(t *testing.T) {
+	hooks := &connHooks{}
+	hooks.reset()
+	drv := Wrap(&fakeDriver{}, Compose(hooks))
+
+	_, err := drv.Open("Unknown")
+	require.Error(t, err)
+	require.Len(t, hooks.opened, 1)
+	assert.Equal(t, err, hooks.opened[0].Err)
+
+	conn, err := drv.Open("ExecerContext")
+	require.NoError(t, err)
+	require.Len(t, hooks.opened, 2)
+	assert.NoError(t, hooks.opened[1].Err)
+	assert.False(t, hooks.opened[1].OpenedAt.IsZero())
+
+	_, execErr := conn.(driver.ExecerContext).ExecContext(context.Background(), "SELECT 1", nil)
+	require.Error(t, execErr)
+
+	closeErr := conn.Close()
+	require.Len(t, hooks.closed, 1)
+	assert.Equal(t, closeErr, hooks.closed[0].Err)
+	assert.Equal(t, execErr, hooks.closed[0].LastErr)
+	assert.Equal(t, int64(1), hooks.closed[0].Uses)
+	assert.Equal(t, hooks.opened[1].OpenedAt, hooks.closed[0].OpenedAt)
+	assert.Equal(t, hooks.closed, hooks.closeErrors)
+	assert.Equal(t, hooks.opened[1].ConnID, hooks.closeErrors[0].ConnID)
+	assert.True(t, hooks.closeErrors[0].Age > 0)
+}
+
+// skipConn declines to run queries and statements directly
+type skipConn struct {
+	prepared   []string
+	prepareErr error
+}
+
+func (c *skipConn) Prepare(query string) (driver.Stmt, error) {
+	c.prepared = append(c.prepared, query)
+	if c.prepareErr != nil {
+		return nil, c.prepareErr
+	}
+	return skipStmt{}, nil
+}
+func (c *skipConn) Close() error              { return nil }
+func (c *skipConn) Begin() (driver.Tx, error) { return nil, errors.New("Not implemented") }
+func (c *skipConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return nil, errors.New("Not implemented")
+}
+func (c *skipConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+func (c *skipConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+
+type skipStmt struct{}
+
+func (skipStmt) Close() error                                    { return nil }
+func (skipStmt) NumInput() int                                   { return -1 }
+func (skipStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (skipStmt) Query(args []driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+type skipDriver struct {
+	conn *skipConn
+}
+
+func (d *skipDriver) Open(dsn string) (driver.Conn, error) { return d.conn, nil }
+
+func TestErrSkipFallback(t *testing.T) {
+	rec := &eventRecorder{}
+	d := &skipDriver{conn: &skipConn{}}
+	conn, err := Wrap(d, FromEventHooks(rec)).Open("")
+	require.NoError(t, err)
+
+	_, err = conn.(driver.ExecerContext).ExecContext(context.Background(), "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	rows, err := conn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{"UPDATE t SET a = 1", "SELECT 1"}, d.conn.prepared)
+	require.Len(t, rec.before, 2)
+	require.Len(t, rec.after, 2)
+	assert.False(t, rec.before[0].PreparedFallback)
+	assert.True(t, rec.after[0].PreparedFallback)
+	assert.True(t, rec.after[1].PreparedFallback)
+}
+
+func TestFallbackPrepareErrors(t *testing.T) {
+	var failed []Event
+	hooks := newTestHooks()
+	hooks.onError = func(ctx context.Context, err error, query string, args ...interface{}) error {
+		failed = append(failed, *EventFromContext(ctx))
+		return err
+	}
+
+	// The statements of drivers without Execer nor Queryer are run prepared
+	conn, err := Wrap(&fakeDriver{}, hooks).Open("Basic")
+	require.NoError(t, err)
+	args := []driver.NamedValue{{Ordinal: 1, Value: 1}}
+	_, err = conn.(driver.ExecerContext).ExecContext(context.Background(), "UPDATE t SET a = ?", args)
+	require.Error(t, err)
+	_, err = conn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT a FROM t WHERE a = ?", args)
+	require.Error(t, err)
+	require.Len(t, failed, 2)
+	for i, op := range []Op{OpExec, OpQuery} {
+		assert.Equal(t, op, failed[i].Op)
+		assert.Equal(t, []interface{}{1}, failed[i].Args)
+		assert.True(t, failed[i].PreparedFallback)
+	}
+
+	// Explicit prepares are reported with WithOps(OpPrepare)
+	failed = nil
+	_, err = conn.Prepare("UPDATE t SET a = 1")
+	require.Error(t, err)
+	assert.Empty(t, failed)
+	conn, err = Wrap(&fakeDriver{}, hooks, WithOps(OpPrepare)).Open("Basic")
+	require.NoError(t, err)
+	_, err = conn.Prepare("UPDATE t SET a = 1")
+	require.Error(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, OpPrepare, failed[0].Op)
+
+	// Drivers running statements directly report them as such
+	failed = nil
+	conn, err = Wrap(&fakeDriver{}, hooks).Open("ExecerQueryerContext")
+	require.NoError(t, err)
+	_, err = conn.Prepare("UPDATE t SET a = 1")
+	require.Error(t, err)
+	assert.Empty(t, failed)
+
+	// The prepare of the ErrSkip fallback fails the statement
+	failed = nil
+	prepareErr := errors.New("prepare failed")
+	d := &skipDriver{conn: &skipConn{prepareErr: prepareErr}}
+	conn, err = Wrap(d, hooks).Open("")
+	require.NoError(t, err)
+	_, err = conn.(driver.ExecerContext).ExecContext(context.Background(), "UPDATE t SET a = ?", []driver.NamedValue{{Ordinal: 1, Value: 1}})
+	assert.Equal(t, prepareErr, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, OpExec, failed[0].Op)
+	assert.Equal(t, []interface{}{1}, failed[0].Args)
+	assert.True(t, failed[0].PreparedFallback)
 }
@@ -5,13 +5,12 @@ package sqlhooks
 import (
 	"context"
 	"database/sql/driver"
-	"errors"
 )
 
 func isSessionResetter(conn driver.Conn) bool {
 	return false
 }
 
-func (s *SessionResetter) ResetSession(ctx context.Context) error {
-	return errors.New("SessionResetter not implemented")
+func (conn *Conn) ResetSession(ctx context.Context) error {
+	return nil
 }
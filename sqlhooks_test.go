@@ -201,7 +201,7 @@ func TestNamedValueToValue(t *testing.T) {
 		{Ordinal: 2, Value: 42},
 	}
 	want := []driver.Value{"foo", 42}
-	dargs, err := namedValueToValue(named)
+	dargs, err := namedValueToValue(nil, named)
 	require.NoError(t, err)
 	assert.Equal(t, want, dargs)
 }
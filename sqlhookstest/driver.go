@@ -0,0 +1,143 @@
+package sqlhookstest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFail is returned by Driver for statements starting with FAIL
+var ErrFail = errors.New("sqlhookstest: statement failed")
+
+// Driver is an in-memory driver.Driver for tests. It stores nothing: every
+// statement waits up to Latency, honouring context cancellation, and then
+// succeeds, unless it starts with FAIL. Queries return a single row with a
+// single column holding int64(1).
+type Driver struct {
+	Latency time.Duration
+	// FailCommit makes Commit return ErrFail
+	FailCommit bool
+	// FailPing makes Ping return ErrFail
+	FailPing bool
+
+	mu  sync.Mutex
+	txs []*Tx
+}
+
+// Open returns a new connection, name is ignored
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	return &conn{drv: d, latency: d.Latency}, nil
+}
+
+type conn struct {
+	drv     *Driver
+	latency time.Duration
+}
+
+func (c *conn) run(ctx context.Context, query string) error {
+	if c.latency > 0 {
+		t := time.NewTimer(time.Duration(rand.Int63n(int64(c.latency))))
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.HasPrefix(query, "FAIL") {
+		return ErrFail
+	}
+	return nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Ping(ctx context.Context) error {
+	if err := c.run(ctx, ""); err != nil {
+		return err
+	}
+	if c.drv.FailPing {
+		return ErrFail
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.drv.begin(opts), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.run(ctx, query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.run(ctx, query); err != nil {
+		return nil, err
+	}
+	return &rows{}, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+type rows struct {
+	done bool
+}
+
+func (r *rows) Columns() []string { return []string{"n"} }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
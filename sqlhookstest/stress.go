@@ -0,0 +1,301 @@
+// Package sqlhookstest provides utilities to test Hooks implementations.
+//
+// Stress hammers a driver wrapped with the hooks under test with concurrent
+// queries, transactions and cancellations, and checks that the wrapper kept
+// its promises to the hooks. Run it with the race detector to check that the
+// hooks themselves are race-free:
+//
+//	func TestHooksRace(t *testing.T) {
+//		sqlhookstest.Stress(t, myhooks.New(), sqlhookstest.StressConfig{})
+//	}
+//
+//	go test -race ./...
+//
+// Open wraps the in-memory Driver with the hooks under test for unit tests
+// that need no real database; the transactions it begins record how they
+// ended.
+package sqlhookstest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// StressConfig configures Stress
+type StressConfig struct {
+	// Workers is the number of concurrent goroutines. Defaults to 8.
+	Workers int
+	// Iterations is the number of operations run by each worker. Defaults
+	// to 200.
+	Iterations int
+	// MaxOpenConns limits the pool, so that workers contend for
+	// connections. Defaults to half the workers.
+	MaxOpenConns int
+	// Latency is the maximum latency of the statements. Defaults to one
+	// millisecond.
+	Latency time.Duration
+}
+
+// StressResult counts the hook calls made during Stress
+type StressResult struct {
+	Before  int64
+	After   int64
+	OnError int64
+	// BeforeErrors is the number of Before calls that returned an error,
+	// which the wrapper does not follow with After or OnError.
+	BeforeErrors int64
+}
+
+var driverSeq int64
+
+// Stress runs a concurrent workload through a driver wrapped with hooks and
+// reports an error on t if:
+//
+//   - a successful Before is not followed by exactly one After or OnError
+//   - After or OnError do not receive the context returned by Before
+//   - After or OnError are called for a different query than Before
+//
+// Errors returned by the hooks are tolerated: they are counted and returned
+// to the workload like any other error.
+func Stress(t testing.TB, hooks sqlhooks.Hooks, cfg StressConfig) StressResult {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = 200
+	}
+	if cfg.MaxOpenConns <= 0 {
+		cfg.MaxOpenConns = (cfg.Workers + 1) / 2
+	}
+	if cfg.Latency <= 0 {
+		cfg.Latency = time.Millisecond
+	}
+
+	c := &checker{t: t, hooks: hooks}
+	db := Open(t, &Driver{Latency: cfg.Latency}, c)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for j := 0; j < cfg.Iterations; j++ {
+				step(db, rnd, cfg.Latency)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	if err := db.Close(); err != nil {
+		t.Errorf("sqlhookstest: closing db: %v", err)
+	}
+
+	res := StressResult{
+		Before:       atomic.LoadInt64(&c.before),
+		After:        atomic.LoadInt64(&c.after),
+		OnError:      atomic.LoadInt64(&c.onError),
+		BeforeErrors: atomic.LoadInt64(&c.beforeErrors),
+	}
+	if started := res.Before - res.BeforeErrors; started != res.After+res.OnError {
+		t.Errorf("sqlhookstest: %d operations started, but %d After and %d OnError calls", started, res.After, res.OnError)
+	}
+	return res
+}
+
+// step runs a random operation. Errors are expected, e.g. from cancellations
+// or from the hooks under test, and are ignored.
+func step(db *sql.DB, rnd *rand.Rand, latency time.Duration) {
+	ctx := context.Background()
+	switch rnd.Intn(6) {
+	case 0:
+		_, _ = db.ExecContext(ctx, "UPDATE t SET n = ?", rnd.Int())
+	case 1:
+		if rows, err := db.QueryContext(ctx, "SELECT n FROM t WHERE n = ?", rnd.Int()); err == nil {
+			for rows.Next() {
+			}
+			rows.Close()
+		}
+	case 2:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return
+		}
+		_, _ = tx.ExecContext(ctx, "INSERT INTO t VALUES (?)", rnd.Int())
+		if rnd.Intn(2) == 0 {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	case 3:
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(rnd.Int63n(int64(latency))))
+		_, _ = db.ExecContext(ctx, "UPDATE t SET n = ?", rnd.Int())
+		cancel()
+	case 4:
+		_, _ = db.ExecContext(ctx, "FAIL")
+	case 5:
+		if stmt, err := db.PrepareContext(ctx, "SELECT n FROM t WHERE n = ?"); err == nil {
+			_ = stmt.QueryRowContext(ctx, rnd.Int()).Scan(new(int64))
+			stmt.Close()
+		}
+	}
+}
+
+type checkerKey struct{}
+
+// checker wraps the hooks under test, counts their calls and forwards the
+// OnErrorer, ConnHooks, Interceptor, TxHooks, RowsHooks, ResultHooks and
+// SessionHooks callbacks if they implement them
+type checker struct {
+	t     testing.TB
+	hooks sqlhooks.Hooks
+
+	before, after, onError, beforeErrors int64
+}
+
+// token is stored in the context by Before, and must come back to After or
+// OnError exactly once
+type token struct {
+	query string
+	done  int32
+}
+
+func (c *checker) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	atomic.AddInt64(&c.before, 1)
+	ctx, err := c.hooks.Before(ctx, query, args...)
+	if err != nil {
+		atomic.AddInt64(&c.beforeErrors, 1)
+		return ctx, err
+	}
+	if ctx == nil {
+		c.t.Errorf("sqlhookstest: Before returned a nil context for %q", query)
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, checkerKey{}, &token{query: query}), nil
+}
+
+func (c *checker) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	atomic.AddInt64(&c.after, 1)
+	c.finish(ctx, "After", query)
+	return c.hooks.After(ctx, query, args...)
+}
+
+func (c *checker) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	atomic.AddInt64(&c.onError, 1)
+	c.finish(ctx, "OnError", query)
+	if onErrorer, ok := c.hooks.(sqlhooks.OnErrorer); ok {
+		return onErrorer.OnError(ctx, err, query, args...)
+	}
+	return err
+}
+
+func (c *checker) finish(ctx context.Context, hook, query string) {
+	tok, ok := ctx.Value(checkerKey{}).(*token)
+	if !ok {
+		c.t.Errorf("sqlhookstest: %s called without the context returned by Before for %q", hook, query)
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&tok.done, 0, 1) {
+		c.t.Errorf("sqlhookstest: %s called twice for %q", hook, query)
+	}
+	if tok.query != query {
+		c.t.Errorf("sqlhookstest: %s called for %q, Before was called for %q", hook, query, tok.query)
+	}
+}
+
+func (c *checker) OnConnOpen(event sqlhooks.ConnEvent) {
+	if h, ok := c.hooks.(sqlhooks.ConnHooks); ok {
+		h.OnConnOpen(event)
+	}
+}
+
+func (c *checker) OnConnClose(event sqlhooks.ConnEvent) {
+	if h, ok := c.hooks.(sqlhooks.ConnHooks); ok {
+		h.OnConnClose(event)
+	}
+}
+
+func (c *checker) InterceptQuery(ctx context.Context, next sqlhooks.QueryFunc, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if interceptor, ok := c.hooks.(sqlhooks.Interceptor); ok {
+		return interceptor.InterceptQuery(ctx, next, query, args)
+	}
+	return next(ctx, query, args)
+}
+
+func (c *checker) InterceptExec(ctx context.Context, next sqlhooks.ExecFunc, query string, args []driver.NamedValue) (driver.Result, error) {
+	if interceptor, ok := c.hooks.(sqlhooks.Interceptor); ok {
+		return interceptor.InterceptExec(ctx, next, query, args)
+	}
+	return next(ctx, query, args)
+}
+
+func (c *checker) BeforeBegin(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.BeforeBegin(ctx, event)
+	}
+}
+
+func (c *checker) AfterBegin(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.AfterBegin(ctx, event)
+	}
+}
+
+func (c *checker) BeforeCommit(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.BeforeCommit(ctx, event)
+	}
+}
+
+func (c *checker) AfterCommit(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.AfterCommit(ctx, event)
+	}
+}
+
+func (c *checker) BeforeRollback(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.BeforeRollback(ctx, event)
+	}
+}
+
+func (c *checker) AfterRollback(ctx context.Context, event sqlhooks.TxEvent) {
+	if h, ok := c.hooks.(sqlhooks.TxHooks); ok {
+		h.AfterRollback(ctx, event)
+	}
+}
+
+func (c *checker) OnRowsNext(ctx context.Context, event sqlhooks.RowsEvent) error {
+	if h, ok := c.hooks.(sqlhooks.RowsHooks); ok {
+		return h.OnRowsNext(ctx, event)
+	}
+	return event.Err
+}
+
+func (c *checker) OnRowsClose(ctx context.Context, event sqlhooks.RowsEvent) error {
+	if h, ok := c.hooks.(sqlhooks.RowsHooks); ok {
+		return h.OnRowsClose(ctx, event)
+	}
+	return event.Err
+}
+
+func (c *checker) OnResult(ctx context.Context, event sqlhooks.ResultEvent) error {
+	if h, ok := c.hooks.(sqlhooks.ResultHooks); ok {
+		return h.OnResult(ctx, event)
+	}
+	return event.Err
+}
+
+func (c *checker) OnSessionDrift(ctx context.Context, drift sqlhooks.SessionDrift) {
+	if h, ok := c.hooks.(sqlhooks.SessionHooks); ok {
+		h.OnSessionDrift(ctx, drift)
+	}
+}
@@ -0,0 +1,122 @@
+package sqlhookstest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingHooks struct {
+	inFlight int64
+	fail     int64
+}
+
+func (h *countingHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if atomic.AddInt64(&h.fail, 1)%50 == 0 {
+		return ctx, errors.New("rejected")
+	}
+	atomic.AddInt64(&h.inFlight, 1)
+	return ctx, nil
+}
+
+func (h *countingHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	atomic.AddInt64(&h.inFlight, -1)
+	return ctx, nil
+}
+
+func (h *countingHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	atomic.AddInt64(&h.inFlight, -1)
+	return err
+}
+
+func TestStress(t *testing.T) {
+	hooks := &countingHooks{}
+	res := Stress(t, hooks, StressConfig{Workers: 4, Iterations: 100})
+
+	assert.Zero(t, hooks.inFlight)
+	assert.NotZero(t, res.After)
+	assert.NotZero(t, res.OnError)
+	assert.NotZero(t, res.BeforeErrors)
+	assert.Equal(t, res.Before-res.BeforeErrors, res.After+res.OnError)
+}
+
+func TestCheckerDetectsLostContext(t *testing.T) {
+	ft := &fakeT{TB: t}
+	c := &checker{t: ft, hooks: &countingHooks{}}
+
+	ctx, err := c.Before(context.Background(), "SELECT 1")
+	assert.NoError(t, err)
+	_, _ = c.After(context.Background(), "SELECT 1")
+	assert.Equal(t, 1, ft.errors)
+
+	_, _ = c.After(ctx, "SELECT 2")
+	assert.Equal(t, 2, ft.errors)
+	_ = c.OnError(ctx, errors.New("boom"), "SELECT 1")
+	assert.Equal(t, 3, ft.errors)
+}
+
+// optionalHooks counts the calls to the optional interfaces
+type optionalHooks struct {
+	countingHooks
+	begins, ends, rows, results, drifts int64
+}
+
+func (h *optionalHooks) BeforeBegin(ctx context.Context, event sqlhooks.TxEvent)    {}
+func (h *optionalHooks) BeforeCommit(ctx context.Context, event sqlhooks.TxEvent)   {}
+func (h *optionalHooks) BeforeRollback(ctx context.Context, event sqlhooks.TxEvent) {}
+
+func (h *optionalHooks) AfterBegin(ctx context.Context, event sqlhooks.TxEvent) {
+	atomic.AddInt64(&h.begins, 1)
+}
+
+func (h *optionalHooks) AfterCommit(ctx context.Context, event sqlhooks.TxEvent) {
+	atomic.AddInt64(&h.ends, 1)
+}
+
+func (h *optionalHooks) AfterRollback(ctx context.Context, event sqlhooks.TxEvent) {
+	atomic.AddInt64(&h.ends, 1)
+}
+
+func (h *optionalHooks) OnRowsNext(ctx context.Context, event sqlhooks.RowsEvent) error {
+	return event.Err
+}
+
+func (h *optionalHooks) OnRowsClose(ctx context.Context, event sqlhooks.RowsEvent) error {
+	atomic.AddInt64(&h.rows, 1)
+	return event.Err
+}
+
+func (h *optionalHooks) OnResult(ctx context.Context, event sqlhooks.ResultEvent) error {
+	atomic.AddInt64(&h.results, 1)
+	return event.Err
+}
+
+func (h *optionalHooks) OnSessionDrift(ctx context.Context, drift sqlhooks.SessionDrift) {
+	atomic.AddInt64(&h.drifts, 1)
+}
+
+func TestStressForwardsOptionalHooks(t *testing.T) {
+	hooks := &optionalHooks{}
+	Stress(t, hooks, StressConfig{Workers: 2, Iterations: 50})
+	assert.NotZero(t, hooks.begins)
+	assert.NotZero(t, hooks.ends)
+	assert.NotZero(t, hooks.rows)
+
+	// Stress reads no result and configures no session, call the checker
+	c := &checker{t: t, hooks: hooks}
+	assert.NoError(t, c.OnResult(context.Background(), sqlhooks.ResultEvent{}))
+	c.OnSessionDrift(context.Background(), sqlhooks.SessionDrift{})
+	assert.Equal(t, int64(1), hooks.results)
+	assert.Equal(t, int64(1), hooks.drifts)
+}
+
+type fakeT struct {
+	testing.TB
+	errors int
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) { t.errors++ }
@@ -0,0 +1,122 @@
+package sqlhookstest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// TxState tells how a Tx ended
+type TxState int
+
+const (
+	// TxOpen transactions have not ended yet
+	TxOpen TxState = iota
+	// TxCommitted transactions ended with Commit, even if it failed
+	TxCommitted
+	// TxRolledBack transactions ended with Rollback
+	TxRolledBack
+)
+
+func (s TxState) String() string {
+	switch s {
+	case TxCommitted:
+		return "committed"
+	case TxRolledBack:
+		return "rolled back"
+	default:
+		return "open"
+	}
+}
+
+// Tx is a transaction of Driver. It records how it ended, so that tests can
+// check what the hooks under test did to it:
+//
+//	drv := &sqlhookstest.Driver{}
+//	db := sqlhookstest.Open(t, drv, myhooks.New())
+//	defer db.Close()
+//	... run a transaction through db ...
+//	assert.Equal(t, sqlhookstest.TxCommitted, drv.Txs()[0].State())
+type Tx struct {
+	// Opts are the options the transaction was begun with
+	Opts driver.TxOptions
+
+	fail bool
+
+	mu    sync.Mutex
+	state TxState
+	ends  int
+}
+
+// Commit ends the transaction, it returns ErrFail if the Driver has
+// FailCommit set
+func (tx *Tx) Commit() error {
+	tx.end(TxCommitted)
+	if tx.fail {
+		return ErrFail
+	}
+	return nil
+}
+
+// Rollback ends the transaction
+func (tx *Tx) Rollback() error {
+	tx.end(TxRolledBack)
+	return nil
+}
+
+func (tx *Tx) end(state TxState) {
+	tx.mu.Lock()
+	if tx.ends == 0 {
+		tx.state = state
+	}
+	tx.ends++
+	tx.mu.Unlock()
+}
+
+// State returns how the transaction ended
+func (tx *Tx) State() TxState {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.state
+}
+
+// Ends returns the number of calls to Commit and Rollback, which
+// database/sql makes at most once
+func (tx *Tx) Ends() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.ends
+}
+
+func (d *Driver) begin(opts driver.TxOptions) *Tx {
+	tx := &Tx{Opts: opts, fail: d.FailCommit}
+	d.mu.Lock()
+	d.txs = append(d.txs, tx)
+	d.mu.Unlock()
+	return tx
+}
+
+// Txs returns the transactions begun so far, in order
+func (d *Driver) Txs() []*Tx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Tx(nil), d.txs...)
+}
+
+// Open registers drv wrapped with hooks and opts under a unique name and
+// opens a database with it. The caller closes the database.
+func Open(t testing.TB, drv *Driver, hooks sqlhooks.Hooks, opts ...sqlhooks.Option) *sql.DB {
+	driverName := fmt.Sprintf("sqlhookstest-%d", atomic.AddInt64(&driverSeq, 1))
+	sql.Register(driverName, sqlhooks.Wrap(drv, hooks, opts...))
+
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatalf("sqlhookstest: %v", err)
+	}
+	return db
+}
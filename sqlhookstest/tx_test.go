@@ -0,0 +1,60 @@
+package sqlhookstest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type opHooks struct {
+	ops []string
+}
+
+func (h *opHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *opHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.ops = append(h.ops, query)
+	return ctx, nil
+}
+
+func (h *opHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.ops = append(h.ops, "error "+query)
+	return err
+}
+
+func TestTx(t *testing.T) {
+	hooks := &opHooks{}
+	drv := &Driver{}
+	db := Open(t, drv, hooks, sqlhooks.WithOps(sqlhooks.OpExec, sqlhooks.OpCommit, sqlhooks.OpRollback))
+	defer db.Close()
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	_, err = tx.Exec("UPDATE t SET a = 1")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	drv.FailCommit = true
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	assert.Equal(t, ErrFail, tx.Commit())
+
+	txs := drv.Txs()
+	require.Len(t, txs, 3)
+	assert.True(t, txs[0].Opts.ReadOnly)
+	assert.Equal(t, TxCommitted, txs[0].State())
+	assert.Equal(t, TxRolledBack, txs[1].State())
+	assert.Equal(t, TxCommitted, txs[2].State())
+	assert.Equal(t, 1, txs[2].Ends())
+	assert.Equal(t, []string{"UPDATE t SET a = 1", "COMMIT", "ROLLBACK", "error COMMIT"}, hooks.ops)
+}
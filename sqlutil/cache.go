@@ -0,0 +1,142 @@
+package sqlutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Parsed holds the results of the helpers of this package for a query
+type Parsed struct {
+	Query       string
+	Tokens      []Token
+	Normalized  string
+	Fingerprint string
+	Kind        Kind
+}
+
+// Parse tokenizes, normalizes and classifies query
+func Parse(query string) *Parsed {
+	tokens := Tokenize(query)
+	normalized := Normalize(query)
+	return &Parsed{
+		Query:       query,
+		Tokens:      tokens,
+		Normalized:  normalized,
+		Fingerprint: fingerprint(normalized),
+		Kind:        classify(tokens),
+	}
+}
+
+// CacheConfig configures a Cache
+type CacheConfig struct {
+	// Size is the maximum number of queries kept, the least recently used
+	// ones being evicted first. Defaults to 1000.
+	Size int
+	// TTL is how long a query is kept after it was parsed. Defaults to ten
+	// minutes.
+	TTL time.Duration
+}
+
+// CacheStats are the counters of a Cache
+type CacheStats struct {
+	Hits, Misses int64
+	// Evictions counts the queries removed to make room for others, and
+	// Expirations the ones removed because they outlived the TTL.
+	Evictions, Expirations int64
+	// Size is the number of queries currently cached.
+	Size int
+}
+
+// HitRate returns the ratio of hits over lookups, 0 if there were none
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache caches the results of Parse keyed by query text. ORM workloads run
+// a small set of query texts over and over, which a Cache spares from being
+// parsed on every call. Applications interpolating values into their
+// queries do not benefit from it.
+type Cache struct {
+	cfg CacheConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	parsed  *Parsed
+	expires time.Time
+}
+
+// NewCache returns an empty Cache
+func NewCache(cfg CacheConfig) *Cache {
+	if cfg.Size <= 0 {
+		cfg.Size = 1000
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	return &Cache{cfg: cfg, now: time.Now, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Parse returns the cached Parse results of query, parsing it on a miss.
+// The result is shared and must not be modified. A nil *Cache parses every
+// query.
+func (c *Cache) Parse(query string) *Parsed {
+	if c == nil {
+		return Parse(query)
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[query]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if now.Before(entry.expires) {
+			c.stats.Hits++
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.parsed
+		}
+		c.remove(elem)
+		c.stats.Expirations++
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	parsed := Parse(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[query]; ok {
+		// Parsed concurrently
+		return parsed
+	}
+	c.entries[query] = c.lru.PushFront(&cacheEntry{parsed: parsed, expires: now.Add(c.cfg.TTL)})
+	for c.lru.Len() > c.cfg.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	return parsed
+}
+
+// remove must be called with c.mu held
+func (c *Cache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).parsed.Query)
+}
+
+// Stats returns the counters of the cache
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
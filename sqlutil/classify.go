@@ -0,0 +1,121 @@
+package sqlutil
+
+import "strings"
+
+// Kind is the kind of a SQL statement
+type Kind int
+
+const (
+	// Unknown is returned for empty or unrecognized statements
+	Unknown Kind = iota
+	// Select statements read data, including SHOW, EXPLAIN and friends
+	Select
+	// Insert statements, including REPLACE and COPY/LOAD DATA
+	Insert
+	// Update statements
+	Update
+	// Delete statements
+	Delete
+	// DDL statements change the schema: CREATE, ALTER, DROP, TRUNCATE, RENAME...
+	DDL
+	// Transaction control: BEGIN, COMMIT, ROLLBACK, SAVEPOINT...
+	Transaction
+	// Other statements such as SET, CALL or GRANT
+	Other
+)
+
+var kindNames = [...]string{"UNKNOWN", "SELECT", "INSERT", "UPDATE", "DELETE", "DDL", "TRANSACTION", "OTHER"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[Unknown]
+	}
+	return kindNames[k]
+}
+
+var kinds = map[string]Kind{
+	"SELECT":    Select,
+	"SHOW":      Select,
+	"EXPLAIN":   Select,
+	"DESCRIBE":  Select,
+	"DESC":      Select,
+	"VALUES":    Select,
+	"TABLE":     Select,
+	"INSERT":    Insert,
+	"REPLACE":   Insert,
+	"COPY":      Insert,
+	"LOAD":      Insert,
+	"MERGE":     Insert,
+	"UPSERT":    Insert,
+	"UPDATE":    Update,
+	"DELETE":    Delete,
+	"CREATE":    DDL,
+	"ALTER":     DDL,
+	"DROP":      DDL,
+	"TRUNCATE":  DDL,
+	"RENAME":    DDL,
+	"COMMENT":   DDL,
+	"BEGIN":     Transaction,
+	"START":     Transaction,
+	"COMMIT":    Transaction,
+	"END":       Transaction,
+	"ROLLBACK":  Transaction,
+	"SAVEPOINT": Transaction,
+	"RELEASE":   Transaction,
+}
+
+// Classify returns the kind of the statement in query. Leading comments
+// and parentheses are skipped, and for statements starting with a WITH
+// clause the kind of the main statement is returned.
+func Classify(query string) Kind {
+	return classify(Tokenize(query))
+}
+
+func classify(tokens []Token) Kind {
+	depth := 0
+	with := false
+	for _, t := range tokens {
+		switch t.Type {
+		case Punct:
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			continue
+		case Word:
+		default:
+			continue
+		}
+
+		if depth > 0 && (with || t.IsKeyword("WITH")) {
+			continue
+		}
+
+		kw := strings.ToUpper(t.Text)
+		if kw == "WITH" {
+			with = true
+			continue
+		}
+		if k, ok := kinds[kw]; ok {
+			return k
+		}
+		if !with {
+			return Other
+		}
+	}
+	return Unknown
+}
+
+// IsDDL reports whether query changes the schema
+func IsDDL(query string) bool {
+	return Classify(query) == DDL
+}
+
+// IsWrite reports whether query may modify data or schema. Unknown and
+// Other statements are considered writes, since they may call functions or
+// procedures with side effects.
+func IsWrite(query string) bool {
+	return Classify(query) != Select
+}
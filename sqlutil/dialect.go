@@ -0,0 +1,422 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlaceholderStyle is the syntax of the bind parameters of a dialect
+type PlaceholderStyle int
+
+const (
+	// Question placeholders are positional: ?
+	Question PlaceholderStyle = iota
+	// Dollar placeholders are numbered: $1, $2...
+	Dollar
+	// AtP placeholders are numbered: @p1, @p2...
+	AtP
+	// Colon placeholders are numbered: :1, :2...
+	Colon
+)
+
+// Placeholder returns the placeholder of the n-th argument, counting from 1
+func (s PlaceholderStyle) Placeholder(n int) string {
+	switch s {
+	case Dollar:
+		return "$" + strconv.Itoa(n)
+	case AtP:
+		return "@p" + strconv.Itoa(n)
+	case Colon:
+		return ":" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// Timeout describes how a dialect bounds the execution time of statements
+// on the server. The statements have a %d verb for the timeout in
+// milliseconds; empty ones are not supported by the dialect.
+type Timeout struct {
+	// Hint is an optimizer hint added after the SELECT keyword.
+	Hint string
+	// Set sets the timeout of the connection and Reset restores it.
+	Set, Reset string
+}
+
+// ErrorClass is the class of a database error, as returned by
+// Dialect.ClassifyError
+type ErrorClass int
+
+const (
+	// ErrorOther is any error that is not classified otherwise
+	ErrorOther ErrorClass = iota
+	// ErrorConnection errors come from a broken or refused connection
+	ErrorConnection
+	// ErrorTimeout errors come from a statement cancelled by the client or
+	// the server
+	ErrorTimeout
+	// ErrorRetryable errors, such as deadlocks and serialization failures,
+	// go away when the transaction is retried
+	ErrorRetryable
+	// ErrorConstraint errors come from unique, foreign key, not null or
+	// check constraint violations
+	ErrorConstraint
+	// ErrorSyntax errors come from invalid statements or references to
+	// unknown tables and columns
+	ErrorSyntax
+)
+
+var errorClassNames = [...]string{"other", "connection", "timeout", "retryable", "constraint", "syntax"}
+
+func (c ErrorClass) String() string {
+	if c < 0 || int(c) >= len(errorClassNames) {
+		return errorClassNames[ErrorOther]
+	}
+	return errorClassNames[c]
+}
+
+// Dialect describes the flavour of SQL of a database, so that the helpers of
+// this package and the hooks building statements work with any driver.
+// Custom dialects usually embed the built-in one closest to them and
+// override what differs:
+//
+//	type cockroach struct{ sqlutil.Dialect }
+//
+//	func (cockroach) Name() string { return "cockroach" }
+//
+//	sqlutil.RegisterDialect(cockroach{sqlutil.Postgres})
+type Dialect interface {
+	// Name is the name the dialect is registered under
+	Name() string
+	// Placeholders returns the placeholder style of the dialect
+	Placeholders() PlaceholderStyle
+	// Literal formats v as a SQL literal
+	Literal(v interface{}) string
+	// Comment returns text as a comment that can be appended to a
+	// statement, or an empty string if the dialect has no comments. text
+	// must not contain the end of comment sequence.
+	Comment(text string) string
+	// Timeout returns the timeout mechanism of the dialect
+	Timeout() Timeout
+	// ClassifyError returns the class of an error returned by the driver
+	ClassifyError(err error) ErrorClass
+}
+
+// The built-in dialects
+var (
+	Postgres Dialect = &dialect{
+		name:        "postgres",
+		style:       Dollar,
+		timeout:     Timeout{Set: "SET statement_timeout = %d", Reset: "SET statement_timeout = DEFAULT"},
+		bytesPrefix: `'\x`,
+		bytesSuffix: "'",
+		classify:    classifySQLState,
+	}
+	MySQL Dialect = &dialect{
+		name:  "mysql",
+		style: Question,
+		timeout: Timeout{
+			Hint:  "/*+ MAX_EXECUTION_TIME(%d) */",
+			Set:   "SET SESSION MAX_EXECUTION_TIME = %d",
+			Reset: "SET SESSION MAX_EXECUTION_TIME = DEFAULT",
+		},
+		backslashes: true,
+		bytesPrefix: "X'",
+		bytesSuffix: "'",
+		classify:    classifyMySQL,
+	}
+	SQLite Dialect = &dialect{
+		name:        "sqlite",
+		style:       Question,
+		bytesPrefix: "X'",
+		bytesSuffix: "'",
+		classify:    classifySQLite,
+	}
+	SQLServer Dialect = &dialect{
+		name:        "sqlserver",
+		style:       AtP,
+		numericBool: true,
+		bytesPrefix: "0x",
+		timeFormat:  "2006-01-02T15:04:05.9999999Z07:00",
+		classify:    classifySQLServer,
+	}
+)
+
+var dialects = struct {
+	sync.RWMutex
+	byName map[string]Dialect
+}{byName: make(map[string]Dialect)}
+
+func init() {
+	RegisterDialect(Postgres, "postgresql", "pgx", "pq")
+	RegisterDialect(MySQL, "mariadb")
+	RegisterDialect(SQLite, "sqlite3")
+	RegisterDialect(SQLServer, "mssql")
+}
+
+// RegisterDialect makes d available to LookupDialect under its name and the
+// given aliases, replacing the dialects previously registered under them
+func RegisterDialect(d Dialect, aliases ...string) {
+	dialects.Lock()
+	defer dialects.Unlock()
+	for _, name := range append([]string{d.Name()}, aliases...) {
+		dialects.byName[strings.ToLower(name)] = d
+	}
+}
+
+// LookupDialect returns the dialect registered under name, ignoring case
+func LookupDialect(name string) (Dialect, bool) {
+	dialects.RLock()
+	defer dialects.RUnlock()
+	d, ok := dialects.byName[strings.ToLower(name)]
+	return d, ok
+}
+
+// dialect implements the built-in dialects
+type dialect struct {
+	name    string
+	style   PlaceholderStyle
+	timeout Timeout
+	// backslashes are escaped in strings
+	backslashes bool
+	// numericBool formats booleans as 1 and 0
+	numericBool              bool
+	bytesPrefix, bytesSuffix string
+	// timeFormat overrides the default RFC 3339 format of times
+	timeFormat string
+	classify   func(err error) ErrorClass
+}
+
+func (d *dialect) Name() string                   { return d.name }
+func (d *dialect) Placeholders() PlaceholderStyle { return d.style }
+func (d *dialect) Timeout() Timeout               { return d.timeout }
+func (d *dialect) Comment(text string) string     { return "/* " + text + " */" }
+
+func (d *dialect) Literal(v interface{}) string {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			v = value
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		if d.backslashes {
+			v = strings.Replace(v, `\`, `\\`, -1)
+		}
+		return quote(v)
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		return d.bytesPrefix + hex.EncodeToString(v) + d.bytesSuffix
+	case bool:
+		if !d.numericBool {
+			break
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		if d.timeFormat != "" {
+			return quote(v.Format(d.timeFormat))
+		}
+	}
+	return Literal(v)
+}
+
+func (d *dialect) ClassifyError(err error) ErrorClass {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ErrorOther
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return ErrorTimeout
+	case errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		return ErrorConnection
+	}
+	return d.classify(err)
+}
+
+// classifySQLState classifies the errors of drivers exposing the SQLSTATE
+// code of their errors, such as pgx and lib/pq
+func classifySQLState(err error) ErrorClass {
+	var e interface{ SQLState() string }
+	if !errors.As(err, &e) {
+		return ErrorOther
+	}
+	state := e.SQLState()
+	switch {
+	case state == "40001", state == "40P01", state == "55P03":
+		return ErrorRetryable
+	case state == "57014":
+		return ErrorTimeout
+	case strings.HasPrefix(state, "08"), state == "57P01":
+		return ErrorConnection
+	case strings.HasPrefix(state, "23"):
+		return ErrorConstraint
+	case strings.HasPrefix(state, "42"):
+		return ErrorSyntax
+	}
+	return ErrorOther
+}
+
+var mysqlErrorNumber = regexp.MustCompile(`^Error (\d+)`)
+
+// classifyMySQL classifies the errors of go-sql-driver/mysql, whose message
+// starts with the error number
+func classifyMySQL(err error) ErrorClass {
+	if err.Error() == "invalid connection" {
+		return ErrorConnection
+	}
+	m := mysqlErrorNumber.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ErrorOther
+	}
+	switch m[1] {
+	case "1205", "1213":
+		return ErrorRetryable
+	case "1317", "3024":
+		return ErrorTimeout
+	case "1040", "1053", "2006", "2013":
+		return ErrorConnection
+	case "1048", "1062", "1216", "1217", "1451", "1452", "3819":
+		return ErrorConstraint
+	case "1054", "1064", "1146":
+		return ErrorSyntax
+	}
+	return ErrorOther
+}
+
+// classifySQLite classifies the errors of SQLite drivers by their message
+func classifySQLite(err error) ErrorClass {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "database is locked"), strings.Contains(msg, "database table is locked"):
+		return ErrorRetryable
+	case strings.Contains(msg, "interrupted"):
+		return ErrorTimeout
+	case strings.Contains(msg, "constraint failed"):
+		return ErrorConstraint
+	case strings.Contains(msg, "syntax error"), strings.Contains(msg, "no such table"), strings.Contains(msg, "no such column"):
+		return ErrorSyntax
+	}
+	return ErrorOther
+}
+
+// classifySQLServer classifies the errors of drivers exposing the number of
+// their errors, such as go-mssqldb
+func classifySQLServer(err error) ErrorClass {
+	var e interface{ SQLErrorNumber() int32 }
+	if !errors.As(err, &e) {
+		return ErrorOther
+	}
+	switch e.SQLErrorNumber() {
+	case 1205, 3960:
+		return ErrorRetryable
+	case 547, 2601, 2627, 515:
+		return ErrorConstraint
+	case 102, 207, 208:
+		return ErrorSyntax
+	}
+	return ErrorOther
+}
+
+// InterpolateFor is Interpolate for the placeholders and literals of d
+func InterpolateFor(d Dialect, query string, args []interface{}) (string, error) {
+	var (
+		b      strings.Builder
+		next   int
+		style  = d.Placeholders()
+		tokens = Tokenize(query)
+	)
+	for i := 0; i < len(tokens); i++ {
+		n, width := placeholder(style, tokens[i:])
+		if width == 0 {
+			b.WriteString(tokens[i].Text)
+			continue
+		}
+		text := concat(tokens[i : i+width])
+		i += width - 1
+
+		if n == 0 {
+			n = next + 1
+			next++
+		}
+		if n < 1 || n > len(args) {
+			return "", fmt.Errorf("%w %s", ErrMissingArg, text)
+		}
+		b.WriteString(d.Literal(args[n-1]))
+	}
+	return b.String(), nil
+}
+
+// placeholder returns the number of the placeholder of style starting
+// tokens, 0 for positional ones, and the number of tokens it spans, 0 if
+// tokens do not start with a placeholder
+func placeholder(style PlaceholderStyle, tokens []Token) (int, int) {
+	t := tokens[0]
+	switch style {
+	case Question:
+		if t.Type == Placeholder && t.Text == "?" {
+			return 0, 1
+		}
+	case Dollar:
+		if t.Type == Placeholder && strings.HasPrefix(t.Text, "$") {
+			if n, err := strconv.Atoi(t.Text[1:]); err == nil && n > 0 {
+				return n, 1
+			}
+			return -1, 1
+		}
+	case AtP:
+		if t.Type == Word && len(t.Text) > 2 && t.Text[0] == '@' && (t.Text[1] == 'p' || t.Text[1] == 'P') {
+			if n, err := strconv.Atoi(t.Text[2:]); err == nil {
+				return n, 1
+			}
+		}
+	case Colon:
+		if t.Text == ":" && len(tokens) > 1 && tokens[1].Type == Number {
+			if n, err := strconv.Atoi(tokens[1].Text); err == nil {
+				return n, 2
+			}
+		}
+	}
+	return 0, 0
+}
+
+func concat(tokens []Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+// AppendComment returns query with comment, as returned by Dialect.Comment,
+// appended after its last token, before any trailing semicolon or line
+// comment that would swallow it. query is returned unchanged if comment is
+// empty.
+func AppendComment(query, comment string) string {
+	if comment == "" {
+		return query
+	}
+	tokens := Tokenize(query)
+	end := len(tokens)
+	for end > 0 {
+		t := tokens[end-1]
+		if t.Type != Space && t.Type != Comment && t.Text != ";" {
+			break
+		}
+		end--
+	}
+	return concat(tokens[:end]) + " " + comment + concat(tokens[end:])
+}
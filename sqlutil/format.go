@@ -0,0 +1,152 @@
+package sqlutil
+
+import "strings"
+
+// clauses start a new line when formatting
+var clauses = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true,
+	"LIMIT": true, "OFFSET": true, "FETCH": true, "WINDOW": true, "UNION": true, "EXCEPT": true,
+	"INTERSECT": true, "SET": true, "VALUES": true, "RETURNING": true, "INSERT": true,
+	"UPDATE": true, "DELETE": true, "WITH": true,
+}
+
+// subclauses start a new indented line when formatting
+var subclauses = map[string]bool{
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true,
+	"NATURAL": true, "STRAIGHT_JOIN": true, "AND": true, "OR": true,
+}
+
+// joinModifiers precede JOIN, which then stays on their line
+var joinModifiers = map[string]bool{
+	"INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true, "NATURAL": true, "OUTER": true,
+}
+
+// Format reformats query for humans: clauses start a new line, joins and
+// boolean conditions an indented one, and subqueries are indented. The
+// tokens are kept as written, only whitespace changes:
+//
+//	SELECT a, b FROM t JOIN u ON u.id = t.uid WHERE x = 1 AND y IN (SELECT y FROM v)
+//
+// becomes
+//
+//	SELECT a, b
+//	FROM t
+//	  JOIN u ON u.id = t.uid
+//	WHERE x = 1
+//	  AND y IN (
+//	    SELECT y
+//	    FROM v
+//	  )
+//
+// Parentheses that do not hold a subquery, such as function calls and
+// lists, are kept on one line.
+func Format(query string) string {
+	var (
+		b       strings.Builder
+		tokens  = Tokenize(query)
+		indent  int // of the clauses
+		line    int // indentation of the current line
+		parens  []paren
+		space   bool
+		newline bool
+		between bool
+		prev    string
+	)
+	for i, t := range tokens {
+		if t.Type == Space {
+			space = true
+			continue
+		}
+
+		word := ""
+		if t.Type == Word {
+			word = strings.ToUpper(t.Text)
+		}
+		breaks := len(parens) == 0 || parens[len(parens)-1].subquery
+		switch {
+		case t.Text == ")" && len(parens) > 0:
+			p := parens[len(parens)-1]
+			if p.subquery {
+				b.WriteString("\n")
+				b.WriteString(strings.Repeat("  ", p.line))
+				indent, line = p.indent, p.line
+				space, newline = false, false
+			}
+			parens = parens[:len(parens)-1]
+		case !breaks || b.Len() == 0:
+		case clauses[word] && !continuesClause(prev, word):
+			newline = true
+		case word == "AND" && between:
+			between = false
+		case subclauses[word] && !(word == "JOIN" && joinModifiers[prev]):
+			b.WriteString("\n")
+			b.WriteString(strings.Repeat("  ", indent+1))
+			line = indent + 1
+			space, newline = false, false
+		}
+
+		if newline && b.Len() > 0 {
+			b.WriteString("\n")
+			b.WriteString(strings.Repeat("  ", indent))
+			line = indent
+		} else if space && b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		space, newline = false, false
+		b.WriteString(t.Text)
+
+		switch {
+		case t.Text == "(":
+			p := paren{subquery: isSubquery(tokens[i+1:]), indent: indent, line: line}
+			parens = append(parens, p)
+			if p.subquery {
+				indent = line + 1
+				newline = true
+			}
+		case word == "BETWEEN":
+			between = true
+		case t.Type == Comment && strings.HasPrefix(t.Text, "--"):
+			newline = true
+		}
+		if t.Type != Comment {
+			prev = word
+		}
+	}
+	return b.String()
+}
+
+// paren is an open parenthesis. The clauses of a subquery are indented
+// relative to the line holding its parenthesis, where the closing one goes.
+type paren struct {
+	subquery     bool
+	indent, line int
+}
+
+// continuesClause reports whether the clause keyword word continues the one
+// before it instead of starting a new clause, as in DELETE FROM, FOR UPDATE
+// or IS DISTINCT FROM
+func continuesClause(prev, word string) bool {
+	switch word {
+	case "FROM":
+		return prev == "DELETE" || prev == "DISTINCT"
+	case "UPDATE":
+		return prev == "FOR" || prev == "DO" || prev == "KEY"
+	case "SELECT":
+		return prev == "ALL" || prev == "DISTINCT"
+	case "SET":
+		return prev == "CHARACTER"
+	}
+	return false
+}
+
+// isSubquery reports whether tokens, following an open parenthesis, start
+// with SELECT or WITH
+func isSubquery(tokens []Token) bool {
+	for _, t := range tokens {
+		if t.Type == Space || t.Type == Comment {
+			continue
+		}
+		return t.IsKeyword("SELECT") || t.IsKeyword("WITH")
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlutil
+
+import (
+	"strings"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x' -- lookup",
+	"SELECT $1::int, :name, 1.5e-3, 0xFF, .5 /* comment */",
+	"SELECT $$dollar ' quoted$$, $tag$x$tag$, E'\\n', 'unterminated",
+	"INSERT INTO t VALUES (?, ?), (?, ?)",
+	"/* unterminated",
+	"IN (",
+	"$0 $99999999999999999999",
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		var b strings.Builder
+		for _, tok := range Tokenize(query) {
+			if tok.Text == "" {
+				t.Fatalf("empty token in %q", query)
+			}
+			b.WriteString(tok.Text)
+		}
+		if b.String() != query {
+			t.Fatalf("tokens of %q do not round-trip", query)
+		}
+		Classify(query)
+	})
+}
+
+func FuzzNormalize(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		normalized := Normalize(query)
+		if again := Normalize(normalized); again != normalized {
+			t.Fatalf("Normalize is not idempotent: %q -> %q -> %q", query, normalized, again)
+		}
+		Fingerprint(query)
+	})
+}
+
+func FuzzInterpolate(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, "it's", int64(1), []byte{0xff})
+	}
+	f.Fuzz(func(t *testing.T, query string, s string, n int64, b []byte) {
+		_, _ = Interpolate(query, []interface{}{s, n, b, nil, true})
+	})
+}
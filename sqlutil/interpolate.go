@@ -0,0 +1,93 @@
+package sqlutil
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissingArg is returned by Interpolate when a placeholder has no
+// matching argument
+var ErrMissingArg = errors.New("sqlutil: missing argument for placeholder")
+
+// Interpolate returns query with its ? and $N placeholders replaced by the
+// SQL literals of args, for logging and debugging. The result must not be
+// run against a database: quoting rules vary between dialects and the
+// literals are only approximate.
+//
+// Named placeholders such as :name are left untouched. Extra arguments are
+// ignored.
+func Interpolate(query string, args []interface{}) (string, error) {
+	var (
+		b    strings.Builder
+		next int
+	)
+	for _, t := range Tokenize(query) {
+		if t.Type != Placeholder || strings.HasPrefix(t.Text, ":") {
+			b.WriteString(t.Text)
+			continue
+		}
+
+		i := next
+		if t.Text == "?" {
+			next++
+		} else {
+			n, err := strconv.Atoi(t.Text[1:])
+			if err != nil || n < 1 {
+				return "", fmt.Errorf("%w %s", ErrMissingArg, t.Text)
+			}
+			i = n - 1
+		}
+		if i >= len(args) {
+			return "", fmt.Errorf("%w %s", ErrMissingArg, t.Text)
+		}
+		b.WriteString(Literal(args[i]))
+	}
+	return b.String(), nil
+}
+
+// Literal formats v as a SQL literal
+func Literal(v interface{}) string {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return quote(fmt.Sprint(v))
+		}
+		v = value
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quote(v)
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return quote(v.Format(time.RFC3339Nano))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return quote(fmt.Sprint(v))
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
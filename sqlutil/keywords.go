@@ -0,0 +1,29 @@
+package sqlutil
+
+import "strings"
+
+// reserved are the common keywords of the major dialects. It is not
+// exhaustive: words missing from it are taken as identifiers.
+var reserved = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		ADD ALL ALTER AND ANY AS ASC BEGIN BETWEEN BY CASCADE CASE CAST CHECK
+		COLLATE COLUMN COMMIT CONFLICT CONSTRAINT CREATE CROSS CURRENT_DATE
+		CURRENT_TIME CURRENT_TIMESTAMP DATABASE DEFAULT DELETE DESC DISTINCT DO
+		DROP ELSE END ESCAPE EXCEPT EXISTS EXPLAIN FALSE FETCH FIRST FOR FOREIGN
+		FROM FULL GROUP HAVING IF ILIKE IN INDEX INNER INSERT INTERSECT INTERVAL
+		INTO IS JOIN KEY LAST LATERAL LEFT LIKE LIMIT NATURAL NOT NOTHING NULL
+		NULLS OFFSET ON OR ORDER OUTER OVER PARTITION PRIMARY RECURSIVE
+		REFERENCES REPLACE RETURNING RIGHT ROLLBACK ROW ROWS SAVEPOINT SELECT SET
+		SHOW SOME TABLE THEN TO TRUE TRUNCATE UNION UNIQUE UPDATE USING VALUES
+		VIEW WHEN WHERE WINDOW WITH`) {
+		reserved[kw] = true
+	}
+}
+
+// IsReserved reports whether t is a common SQL keyword, such as SELECT,
+// WHERE or NULL
+func IsReserved(t Token) bool {
+	return t.Type == Word && reserved[strings.ToUpper(t.Text)]
+}
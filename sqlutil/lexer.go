@@ -0,0 +1,210 @@
+// Package sqlutil contains small, dialect-tolerant helpers to inspect SQL
+// statements from hooks. It does not parse SQL; it tokenizes it, which is
+// enough to classify statements and to find literals and keywords without
+// being fooled by comments or quoted strings.
+package sqlutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType identifies the kind of a Token
+type TokenType int
+
+const (
+	// Space is a run of whitespace
+	Space TokenType = iota
+	// Comment is a -- line comment or a /* block */ comment
+	Comment
+	// Word is a keyword or an unquoted identifier
+	Word
+	// QuotedIdent is an identifier quoted with double quotes, backticks or brackets
+	QuotedIdent
+	// String is a quoted string literal, including dollar-quoted strings
+	String
+	// Number is a numeric literal
+	Number
+	// Placeholder is a bind parameter such as ?, $1 or :name
+	Placeholder
+	// Punct is any other character: operators, parentheses, commas...
+	Punct
+)
+
+// Token is a lexical token of a SQL statement. Concatenating the Text of all
+// the tokens returned by Tokenize yields the original statement.
+type Token struct {
+	Type TokenType
+	Text string
+}
+
+// IsKeyword reports whether t is the Word kw, ignoring case
+func (t Token) IsKeyword(kw string) bool {
+	return t.Type == Word && strings.EqualFold(t.Text, kw)
+}
+
+// Tokenize splits query into tokens. It never fails: unterminated strings and
+// comments extend to the end of the input.
+func Tokenize(query string) []Token {
+	var tokens []Token
+	for i := 0; i < len(query); {
+		typ, n := next(query[i:])
+		tokens = append(tokens, Token{Type: typ, Text: query[i : i+n]})
+		i += n
+	}
+	return tokens
+}
+
+// next returns the type and length of the token at the start of s
+func next(s string) (TokenType, int) {
+	r, size := utf8.DecodeRuneInString(s)
+	switch {
+	case unicode.IsSpace(r):
+		n := size
+		for n < len(s) {
+			r, size := utf8.DecodeRuneInString(s[n:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			n += size
+		}
+		return Space, n
+	case strings.HasPrefix(s, "--"):
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			return Comment, i
+		}
+		return Comment, len(s)
+	case strings.HasPrefix(s, "/*"):
+		if i := strings.Index(s[2:], "*/"); i >= 0 {
+			return Comment, i + 4
+		}
+		return Comment, len(s)
+	case r == '\'':
+		return String, quoted(s, '\'', true)
+	case r == '"':
+		return QuotedIdent, quoted(s, '"', false)
+	case r == '`':
+		return QuotedIdent, quoted(s, '`', false)
+	case r == '[':
+		if i := strings.IndexByte(s, ']'); i >= 0 {
+			return QuotedIdent, i + 1
+		}
+		return QuotedIdent, len(s)
+	case r == '?':
+		return Placeholder, 1
+	case r == '$':
+		if n := digits(s[1:]); n > 0 {
+			return Placeholder, n + 1
+		}
+		if n := dollarQuoted(s); n > 0 {
+			return String, n
+		}
+		return Punct, 1
+	case r == ':' && len(s) > 1 && s[1] == ':':
+		return Punct, 2
+	case r == ':' && len(s) > 1 && isIdentStart(rune(s[1])):
+		return Placeholder, 1 + ident(s[1:])
+	case r >= '0' && r <= '9', r == '.' && len(s) > 1 && s[1] >= '0' && s[1] <= '9':
+		return Number, number(s)
+	case (r == 'x' || r == 'X' || r == 'b' || r == 'B' || r == 'e' || r == 'E' || r == 'n' || r == 'N') &&
+		len(s) > 1 && s[1] == '\'':
+		return String, 1 + quoted(s[1:], '\'', true)
+	case isIdentStart(r):
+		return Word, ident(s)
+	default:
+		return Punct, size
+	}
+}
+
+// quoted returns the length of the quoted token at the start of s. A doubled
+// quote is an escaped quote; backslash escapes are honoured if escapes is set.
+func quoted(s string, quote byte, escapes bool) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// dollarQuoted returns the length of the PostgreSQL $tag$...$tag$ string at
+// the start of s, or 0 if there is none.
+func dollarQuoted(s string) int {
+	end := strings.IndexByte(s[1:], '$')
+	if end < 0 {
+		return 0
+	}
+	tag := s[:end+2]
+	for _, r := range tag[1 : len(tag)-1] {
+		if !isIdentPart(r) {
+			return 0
+		}
+	}
+	if i := strings.Index(s[len(tag):], tag); i >= 0 {
+		return len(tag) + i + len(tag)
+	}
+	return len(s)
+}
+
+func digits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+func number(s string) int {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		n := 2
+		for n < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[n]) >= 0 {
+			n++
+		}
+		return n
+	}
+
+	n := digits(s)
+	if n < len(s) && s[n] == '.' {
+		n += 1 + digits(s[n+1:])
+	}
+	if n+1 < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if s[m] == '+' || s[m] == '-' {
+			m++
+		}
+		if d := digits(s[m:]); d > 0 {
+			n = m + d
+		}
+	}
+	return n
+}
+
+func ident(s string) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !isIdentPart(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '@' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
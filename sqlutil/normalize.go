@@ -0,0 +1,79 @@
+package sqlutil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Normalize returns query with its literals and placeholders replaced by ?,
+// comments removed and whitespace collapsed, so that statements differing
+// only by their values normalize to the same string. Lists of values in IN
+// clauses are collapsed to a single ?:
+//
+//	SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x' -- lookup
+//	SELECT * FROM t WHERE id IN (?) AND name = ?
+func Normalize(query string) string {
+	var out []string
+	space := false
+	for _, t := range Tokenize(query) {
+		switch t.Type {
+		case Space, Comment:
+			space = len(out) > 0
+			continue
+		}
+
+		text := t.Text
+		switch t.Type {
+		case String, Number, Placeholder:
+			text = "?"
+		}
+		if text == ")" && collapseList(&out) {
+			space = false
+		}
+		if space && !noSpaceBefore(text) && !noSpaceAfter(out[len(out)-1]) {
+			out = append(out, " ")
+		}
+		out = append(out, text)
+		space = false
+	}
+	return strings.Join(out, "")
+}
+
+// collapseList rewrites the end of out from "IN (?, ?, ?" to "IN (?" and
+// reports whether it did
+func collapseList(out *[]string) bool {
+	s := *out
+	i := len(s) - 1
+	for i >= 0 && (s[i] == "?" || s[i] == "," || s[i] == " ") {
+		i--
+	}
+	if i < 1 || s[i] != "(" || i == len(s)-1 {
+		return false
+	}
+	j := i - 1
+	if s[j] == " " {
+		j--
+	}
+	if j < 0 || !strings.EqualFold(s[j], "IN") {
+		return false
+	}
+	*out = append(s[:i+1], "?")
+	return true
+}
+
+func noSpaceBefore(text string) bool { return text == ")" || text == "," || text == ";" }
+func noSpaceAfter(text string) bool  { return text == "(" }
+
+// Fingerprint returns a short hash of the normalized query, suitable as a
+// metric label or a cache key identifying the statement regardless of its
+// values.
+func Fingerprint(query string) string {
+	return fingerprint(Normalize(query))
+}
+
+func fingerprint(normalized string) string {
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,50 @@
+package sqlutil
+
+import "sync"
+
+// Sampler decides which statements to sample, keyed by their Fingerprint:
+// the first First occurrences of every fingerprint are sampled, then one in
+// Every. Rare statements are thus always captured while the ones run in hot
+// loops are downsampled.
+//
+// A nil *Sampler samples everything. A Sampler keeps a counter per
+// fingerprint, which is fine for applications with a bounded set of
+// statements but not for ones interpolating values into their queries.
+type Sampler struct {
+	first, every uint64
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewSampler returns a Sampler sampling the first occurrences of every
+// fingerprint, then one in every. If every is zero or negative nothing is
+// sampled after the first occurrences.
+func NewSampler(first, every int) *Sampler {
+	s := &Sampler{counts: make(map[string]uint64)}
+	if first > 0 {
+		s.first = uint64(first)
+	}
+	if every > 0 {
+		s.every = uint64(every)
+	}
+	return s
+}
+
+// Sample counts an occurrence of query and reports whether it is sampled
+func (s *Sampler) Sample(query string) bool {
+	if s == nil {
+		return true
+	}
+	fp := Fingerprint(query)
+
+	s.mu.Lock()
+	n := s.counts[fp] + 1
+	s.counts[fp] = n
+	s.mu.Unlock()
+
+	if n <= s.first {
+		return true
+	}
+	return s.every > 0 && (n-s.first)%s.every == 0
+}
@@ -0,0 +1,256 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, query := range []string{
+		"SELECT * FROM users WHERE id = ?",
+		"SELECT 'it''s', 'a\\'b', \"col\", `col`, [col] FROM t -- trailing",
+		"SELECT $1::int, :name, 1.5e-3, 0xFF, .5 /* comment */",
+		"SELECT $$dollar ' quoted$$, $tag$x$tag$, E'\\n', 'unterminated",
+		"/* unterminated",
+		"SELECT ünïcode FROM t",
+	} {
+		var b strings.Builder
+		for _, tok := range Tokenize(query) {
+			b.WriteString(tok.Text)
+		}
+		assert.Equal(t, query, b.String())
+	}
+
+	tokens := Tokenize("SELECT 'a -- b', $1::int -- c")
+	var types []TokenType
+	for _, tok := range tokens {
+		types = append(types, tok.Type)
+	}
+	assert.Equal(t, []TokenType{Word, Space, String, Punct, Space, Placeholder, Punct, Word, Space, Comment}, types)
+}
+
+func TestClassify(t *testing.T) {
+	for query, want := range map[string]Kind{
+		"":                                   Unknown,
+		"  select 1":                         Select,
+		"/* hint */ SELECT 1":                Select,
+		"(SELECT 1) UNION (SELECT 2)":        Select,
+		"SHOW TABLES":                        Select,
+		"INSERT INTO t VALUES (1)":           Insert,
+		"-- comment\nUPDATE t SET a = 1":     Update,
+		"DELETE FROM t":                      Delete,
+		"WITH x AS (SELECT 1) DELETE FROM t": Delete,
+		"WITH RECURSIVE x AS (SELECT 1) SELECT 1": Select,
+		"CREATE TABLE t (id int)":                 DDL,
+		"alter table t add column c int":          DDL,
+		"DROP INDEX i":                            DDL,
+		"TRUNCATE t":                              DDL,
+		"BEGIN":                                   Transaction,
+		"SET search_path TO x":                    Other,
+	} {
+		assert.Equal(t, want, Classify(query), query)
+	}
+
+	assert.True(t, IsDDL("create index i on t(c)"))
+	assert.False(t, IsDDL("SELECT 'CREATE TABLE'"))
+	assert.True(t, IsWrite("UPDATE t SET a = 1"))
+	assert.False(t, IsWrite("SELECT 1"))
+}
+
+func TestNormalize(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x' -- lookup": "SELECT * FROM t WHERE id IN (?) AND name = ?",
+		"select  a,b\n from t where id=$1":                               "select a,b from t where id=?",
+		"/* hint */ INSERT INTO t (a, b) VALUES (1, 'x')":                "INSERT INTO t (a, b) VALUES (?, ?)",
+		"SELECT f(x) FROM t WHERE id in(?,?)":                            "SELECT f(x) FROM t WHERE id in(?)",
+		"SELECT 1 /* a */ ; ":                                            "SELECT ?;",
+		"":                                                               "",
+	} {
+		assert.Equal(t, want, Normalize(query), query)
+	}
+
+	assert.Equal(t, Fingerprint("SELECT * FROM t WHERE id = 1"), Fingerprint("SELECT *  FROM t WHERE id = 42"))
+	assert.NotEqual(t, Fingerprint("SELECT * FROM t WHERE id = 1"), Fingerprint("SELECT * FROM u WHERE id = 1"))
+	assert.Len(t, Fingerprint("SELECT 1"), 16)
+}
+
+func TestInterpolate(t *testing.T) {
+	got, err := Interpolate("SELECT ?, ?, ?, ?, ? -- ?", []interface{}{nil, "it's", []byte("a"), true, 1.5})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT NULL, 'it''s', X'61', TRUE, 1.5 -- ?", got)
+
+	got, err = Interpolate("SELECT $2, $1, :name", []interface{}{1, "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 'x', 1, :name", got)
+
+	for _, query := range []string{"SELECT ?, ?", "SELECT $3", "SELECT $0"} {
+		_, err = Interpolate(query, []interface{}{1})
+		assert.True(t, errors.Is(err, ErrMissingArg), query)
+	}
+}
+
+func TestTables(t *testing.T) {
+	type ref struct{ keyword, schema, name, alias string }
+	for query, want := range map[string][]ref{
+		"SELECT * FROM users": {{"FROM", "", "users", ""}},
+		"SELECT * FROM public.users AS u JOIN orders o ON o.user_id = u.id": {
+			{"FROM", "public", "users", "u"}, {"JOIN", "", "orders", "o"},
+		},
+		`SELECT * FROM "my ""t""", b WHERE x IN (SELECT id FROM c)`: {
+			{"FROM", "", `my "t"`, ""}, {"FROM", "", "b", ""}, {"FROM", "", "c", ""},
+		},
+		"SELECT EXTRACT(YEAR FROM created_at) FROM t":                {{"FROM", "", "t", ""}},
+		"SELECT * FROM generate_series(1, 10) g":                     nil,
+		"SELECT * FROM (SELECT 1) sub":                               nil,
+		"UPDATE LOW_PRIORITY t SET a = 1":                            {{"UPDATE", "", "t", ""}},
+		"SELECT * FROM t FOR UPDATE NOWAIT":                          {{"FROM", "", "t", ""}},
+		"INSERT INTO t (a) VALUES (1) ON DUPLICATE KEY UPDATE a = 2": {{"INTO", "", "t", ""}},
+		"DELETE FROM ONLY t USING u WHERE t.id = u.id":               {{"FROM", "", "t", ""}, {"USING", "", "u", ""}},
+		"SELECT 1": nil,
+	} {
+		var got []ref
+		for _, r := range Tables(Tokenize(query)) {
+			got = append(got, ref{r.Keyword, r.Schema, r.Name, r.Alias})
+		}
+		assert.Equal(t, want, got, query)
+	}
+
+	tokens := Tokenize("SELECT * FROM s.t WHERE 1")
+	refs := Tables(tokens)
+	assert.Equal(t, "s.t", refs[0].Qualifier(tokens))
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(2, 3)
+	var got []bool
+	for i := 0; i < 8; i++ {
+		got = append(got, s.Sample(fmt.Sprintf("SELECT * FROM t WHERE id = %d", i)))
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true}, got)
+	assert.True(t, s.Sample("SELECT * FROM u"))
+
+	s = NewSampler(1, 0)
+	assert.True(t, s.Sample("SELECT 1"))
+	assert.False(t, s.Sample("SELECT 2"))
+
+	var all *Sampler
+	assert.True(t, all.Sample("SELECT 1"))
+}
+
+func TestIsReserved(t *testing.T) {
+	tokens := Tokenize(`select "select", name from t where x is not null`)
+	var got []string
+	for _, tok := range tokens {
+		if IsReserved(tok) {
+			got = append(got, tok.Text)
+		}
+	}
+	assert.Equal(t, []string{"select", "from", "where", "is", "not", "null"}, got)
+}
+
+func TestFormat(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT a, b FROM t JOIN u ON u.id = t.uid WHERE x = 1 AND y IN (SELECT y FROM v)":                                      "SELECT a, b\nFROM t\n  JOIN u ON u.id = t.uid\nWHERE x = 1\n  AND y IN (\n    SELECT y\n    FROM v\n  )",
+		"select count(*) from t left outer join u using (id) where a between 1 and 2 or b = 'x' order by f(a, b) desc limit 10": "select count(*)\nfrom t\n  left outer join u using (id)\nwhere a between 1 and 2\n  or b = 'x'\norder by f(a, b) desc\nlimit 10",
+		"DELETE FROM t WHERE id = $1 RETURNING id":                                                                              "DELETE FROM t\nWHERE id = $1\nRETURNING id",
+		"UPDATE t SET a = 1 -- note\n WHERE b = 2":                                                                              "UPDATE t\nSET a = 1 -- note\nWHERE b = 2",
+		"SELECT 1 UNION ALL SELECT 2":                                                                                           "SELECT 1\nUNION ALL SELECT 2",
+		"SELECT id FROM t WHERE a = 1 FOR UPDATE":                                                                               "SELECT id\nFROM t\nWHERE a = 1 FOR UPDATE",
+		"SELECT row_number() OVER (PARTITION BY a ORDER BY b) FROM t":                                                           "SELECT row_number() OVER (PARTITION BY a ORDER BY b)\nFROM t",
+		"SELECT * FROM t WHERE a IN (SELECT a FROM u) ORDER BY a":                                                               "SELECT *\nFROM t\nWHERE a IN (\n  SELECT a\n  FROM u\n)\nORDER BY a",
+		"": "",
+	} {
+		assert.Equal(t, want, Format(query), query)
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type cockroach struct{ Dialect }
+
+func (cockroach) Name() string { return "cockroach" }
+
+func TestDialects(t *testing.T) {
+	args := []interface{}{`it's \`, []byte("a"), true}
+	for d, want := range map[Dialect]string{
+		Postgres:  `SELECT 'it''s \', '\x61', TRUE`,
+		MySQL:     `SELECT 'it''s \\', X'61', TRUE`,
+		SQLite:    `SELECT 'it''s \', X'61', TRUE`,
+		SQLServer: `SELECT 'it''s \', 0x61, 1`,
+	} {
+		style := d.Placeholders()
+		got, err := InterpolateFor(d, "SELECT "+style.Placeholder(1)+", "+style.Placeholder(2)+", "+style.Placeholder(3), args)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, d.Name())
+	}
+	_, err := InterpolateFor(SQLServer, "SELECT @p2", []interface{}{1})
+	assert.True(t, errors.Is(err, ErrMissingArg))
+
+	for name, want := range map[string]Dialect{"pgx": Postgres, "SQLite3": SQLite, "mssql": SQLServer} {
+		d, ok := LookupDialect(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, d, name)
+	}
+	_, ok := LookupDialect("cockroach")
+	assert.False(t, ok)
+	RegisterDialect(cockroach{Postgres}, "crdb")
+	d, ok := LookupDialect("crdb")
+	assert.True(t, ok)
+	assert.Equal(t, "cockroach", d.Name())
+	assert.Equal(t, Dollar, d.Placeholders())
+
+	for err, want := range map[error]ErrorClass{
+		sqlStateError("40001"):                           ErrorRetryable,
+		fmt.Errorf("insert: %w", sqlStateError("23505")): ErrorConstraint,
+		context.DeadlineExceeded:                         ErrorTimeout,
+		driver.ErrBadConn:                                ErrorConnection,
+		errors.New("boom"):                               ErrorOther,
+	} {
+		assert.Equal(t, want, Postgres.ClassifyError(err), err.Error())
+	}
+	assert.Equal(t, ErrorRetryable, MySQL.ClassifyError(errors.New("Error 1213: Deadlock found when trying to get lock")))
+	assert.Equal(t, ErrorConstraint, SQLite.ClassifyError(errors.New("UNIQUE constraint failed: t.id")))
+	assert.Equal(t, "retryable", ErrorRetryable.String())
+}
+
+func TestAppendComment(t *testing.T) {
+	assert.Equal(t, "SELECT 1 /* x */;", AppendComment("SELECT 1;", "/* x */"))
+	assert.Equal(t, "SELECT 1 /* x */ -- y", AppendComment("SELECT 1 -- y", "/* x */"))
+	assert.Equal(t, "SELECT 1", AppendComment("SELECT 1", ""))
+}
+
+func TestCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewCache(CacheConfig{Size: 2, TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	p := c.Parse("SELECT * FROM t WHERE id = 1")
+	assert.Equal(t, "SELECT * FROM t WHERE id = ?", p.Normalized)
+	assert.Equal(t, Fingerprint(p.Query), p.Fingerprint)
+	assert.Equal(t, Select, p.Kind)
+	assert.True(t, p == c.Parse("SELECT * FROM t WHERE id = 1"))
+
+	c.Parse("SELECT 2")
+	c.Parse("SELECT 3") // evicts the least recently used query
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}, c.Stats())
+	assert.Equal(t, 0.25, c.Stats().HitRate())
+
+	now = now.Add(time.Minute)
+	c.Parse("SELECT 3")
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats.Expirations)
+	assert.Equal(t, int64(4), stats.Misses)
+
+	var nilCache *Cache
+	assert.Equal(t, Insert, nilCache.Parse("INSERT INTO t VALUES (1)").Kind)
+}
@@ -0,0 +1,188 @@
+package sqlutil
+
+import "strings"
+
+// TableRef is a table referenced by a statement, as found by Tables
+type TableRef struct {
+	// Schema and Name are the unquoted parts of the table name. Schema is
+	// empty for unqualified names.
+	Schema string
+	Name   string
+	// Alias is the alias of the table, if any.
+	Alias string
+	// Keyword introduces the reference: FROM, JOIN, UPDATE, INTO or USING.
+	Keyword string
+	// Depth is the parenthesis depth of the reference, 0 for the main
+	// statement.
+	Depth int
+	// Start and NameEnd delimit the tokens of the name, and End is the
+	// index of the token following the reference, alias included.
+	Start, NameEnd, End int
+}
+
+// Qualifier returns the alias of the table, or its name as written in the
+// statement, to qualify column names with
+func (r TableRef) Qualifier(tokens []Token) string {
+	if r.Alias != "" {
+		return r.Alias
+	}
+	var b strings.Builder
+	for _, t := range tokens[r.Start:r.NameEnd] {
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+var tableKeywords = map[string]bool{"FROM": true, "JOIN": true, "UPDATE": true, "INTO": true, "USING": true}
+
+// tableModifiers may appear between a table keyword and the table name
+var tableModifiers = map[string]bool{"ONLY": true, "LOW_PRIORITY": true, "IGNORE": true, "QUICK": true}
+
+// subqueryKeywords may precede a parenthesis that is not a function call
+var subqueryKeywords = map[string]bool{
+	"IN": true, "EXISTS": true, "FROM": true, "JOIN": true, "AS": true, "ANY": true, "ALL": true,
+	"SOME": true, "LATERAL": true, "ON": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"SELECT": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "USING": true, "VALUES": true,
+	"INTO": true, "SET": true, "WITH": true, "RETURNING": true, "THEN": true, "ELSE": true, "WHEN": true,
+}
+
+// clauseKeywords end an expression or a list of tables at the same depth
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "SET": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true,
+	"OUTER": true, "CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true, "ON": true, "USING": true,
+	"GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "UNION": true, "EXCEPT": true,
+	"INTERSECT": true, "RETURNING": true, "VALUES": true, "SELECT": true, "FOR": true, "WINDOW": true,
+	"OFFSET": true, "FETCH": true, "LATERAL": true, "DEFAULT": true, "PARTITION": true,
+}
+
+// IsClauseKeyword reports whether t is a keyword starting a clause, such as
+// WHERE, JOIN or ORDER, which ends the expression or the list of tables
+// before it
+func IsClauseKeyword(t Token) bool {
+	return t.Type == Word && clauseKeywords[strings.ToUpper(t.Text)]
+}
+
+// Tables returns the tables referenced by the statement in tokens, in order
+// of appearance. Subqueries are inspected, but derived tables, table
+// functions and common table expressions are not tables and are skipped;
+// references to common table expressions are returned like tables.
+func Tables(tokens []Token) []TableRef {
+	var (
+		refs []TableRef
+		// funcs tells, for every open parenthesis, whether it is a function
+		// call: FROM in EXTRACT(YEAR FROM d) does not introduce a table
+		funcs []bool
+		prev  = -1
+	)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Type == Space || t.Type == Comment:
+			continue
+		case t.Type == Punct && t.Text == "(":
+			isFunc := prev >= 0 && (tokens[prev].Type == Word || tokens[prev].Type == QuotedIdent) &&
+				!subqueryKeywords[strings.ToUpper(tokens[prev].Text)]
+			funcs = append(funcs, isFunc)
+		case t.Type == Punct && t.Text == ")":
+			if len(funcs) > 0 {
+				funcs = funcs[:len(funcs)-1]
+			}
+		case t.Type == Word && tableKeywords[strings.ToUpper(t.Text)]:
+			if len(funcs) > 0 && funcs[len(funcs)-1] {
+				break
+			}
+			kw := strings.ToUpper(t.Text)
+			if kw == "UPDATE" && prev >= 0 && (tokens[prev].IsKeyword("FOR") || tokens[prev].IsKeyword("KEY")) {
+				// SELECT ... FOR UPDATE, ON DUPLICATE KEY UPDATE
+				break
+			}
+			for j := i + 1; ; {
+				ref, ok := tableRef(tokens, j, kw)
+				if !ok {
+					break
+				}
+				ref.Keyword, ref.Depth = kw, len(funcs)
+				refs = append(refs, ref)
+				// FROM a, b
+				j = skipSpace(tokens, ref.End)
+				if (kw != "FROM" && kw != "USING") || j >= len(tokens) || tokens[j].Text != "," {
+					break
+				}
+				j++
+			}
+		}
+		prev = i
+	}
+	return refs
+}
+
+// tableRef parses the table reference introduced by kw starting at tokens[i]
+func tableRef(tokens []Token, i int, kw string) (TableRef, bool) {
+	var ref TableRef
+	i = skipSpace(tokens, i)
+	for i < len(tokens) && tokens[i].Type == Word && tableModifiers[strings.ToUpper(tokens[i].Text)] {
+		i = skipSpace(tokens, i+1)
+	}
+	if i >= len(tokens) || !isName(tokens[i]) || IsClauseKeyword(tokens[i]) {
+		return ref, false
+	}
+
+	ref.Start = i
+	var parts []string
+	for {
+		parts = append(parts, unquote(tokens[i]))
+		i++
+		if i+1 < len(tokens) && tokens[i].Text == "." && isName(tokens[i+1]) {
+			i++
+			continue
+		}
+		break
+	}
+	ref.NameEnd = i
+	ref.Name = parts[len(parts)-1]
+	if len(parts) > 1 {
+		ref.Schema = parts[len(parts)-2]
+	}
+	ref.End = i
+
+	j := skipSpace(tokens, i)
+	if j < len(tokens) && tokens[j].Text == "(" {
+		// The column list of INSERT INTO t (a, b), or a table function
+		return ref, kw == "INTO"
+	}
+	if j < len(tokens) && tokens[j].IsKeyword("AS") {
+		j = skipSpace(tokens, j+1)
+	}
+	if j < len(tokens) && isName(tokens[j]) && !IsClauseKeyword(tokens[j]) {
+		ref.Alias = unquote(tokens[j])
+		ref.End = j + 1
+	}
+	return ref, true
+}
+
+func isName(t Token) bool {
+	return t.Type == Word || t.Type == QuotedIdent
+}
+
+func unquote(t Token) string {
+	if t.Type != QuotedIdent || len(t.Text) < 2 {
+		return t.Text
+	}
+	q := t.Text[0]
+	if q == '[' {
+		return strings.TrimSuffix(t.Text[1:], "]")
+	}
+	if t.Text[len(t.Text)-1] != q {
+		return t.Text[1:]
+	}
+	return strings.Replace(t.Text[1:len(t.Text)-1], string([]byte{q, q}), string(q), -1)
+}
+
+// skipSpace returns the index of the first token from i that is not a space
+// or a comment
+func skipSpace(tokens []Token, i int) int {
+	for i < len(tokens) && (tokens[i].Type == Space || tokens[i].Type == Comment) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,122 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"sort"
+	"time"
+)
+
+// ConnStats is a snapshot of the activity of a live connection
+type ConnStats struct {
+	// ID identifies the connection, see ConnEvent.
+	ID uint64
+	// OpenedAt is the time the connection was opened.
+	OpenedAt time.Time
+	// LastUsed is the time the connection last ran a statement, or the
+	// zero time if it never did.
+	LastUsed time.Time
+	// Queries is the number of statements the connection has served, and
+	// Errors how many of them failed.
+	Queries int64
+	Errors  int64
+	// LastErr is the last error returned by the underlying driver, if any.
+	LastErr error
+	// TxStartedAt is the time the current transaction began, or the zero
+	// time if the connection is not in a transaction.
+	TxStartedAt time.Time
+}
+
+// ConnStats returns a snapshot of every live connection opened by drv, oldest
+// first. Wrap returns a *Driver, keep it to call ConnStats:
+//
+//	drv := sqlhooks.Wrap(&pq.Driver{}, hooks).(*sqlhooks.Driver)
+//	sql.Register("postgres-hooked", drv)
+//
+// A connection in a transaction for long, or idle while the pool is starved,
+// usually points to a leaked *sql.Tx or *sql.Rows.
+func (drv *Driver) ConnStats() []ConnStats {
+	drv.mu.Lock()
+	conns := make([]*Conn, 0, len(drv.conns))
+	for conn := range drv.conns {
+		conns = append(conns, conn)
+	}
+	drv.mu.Unlock()
+
+	stats := make([]ConnStats, len(conns))
+	for i, conn := range conns {
+		stats[i] = conn.stats()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].OpenedAt.Before(stats[j].OpenedAt) })
+	return stats
+}
+
+func (drv *Driver) track(conn *Conn) {
+	drv.mu.Lock()
+	if drv.conns == nil {
+		drv.conns = make(map[*Conn]struct{})
+	}
+	drv.conns[conn] = struct{}{}
+	drv.mu.Unlock()
+}
+
+func (drv *Driver) untrack(conn *Conn) {
+	drv.mu.Lock()
+	delete(drv.conns, conn)
+	drv.mu.Unlock()
+}
+
+func (conn *Conn) stats() ConnStats {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return ConnStats{
+		ID:          conn.id,
+		OpenedAt:    conn.openedAt,
+		LastUsed:    conn.lastUsed,
+		Queries:     conn.uses,
+		Errors:      conn.errors,
+		LastErr:     conn.lastErr,
+		TxStartedAt: conn.txStartedAt,
+	}
+}
+
+// Tx implements a database/sql/driver.Tx, it records the end of the
+// transaction in the connection stats and runs the OpCommit and OpRollback
+// hooks, and the TxHooks
+type Tx struct {
+	driver.Tx
+	conn *Conn
+	ctx  context.Context
+
+	id        string
+	opts      driver.TxOptions
+	startedAt time.Time
+}
+
+// Unwrap returns the wrapped transaction, see the Unwrap function
+func (tx *Tx) Unwrap() driver.Tx { return tx.Tx }
+
+func (tx *Tx) Commit() error {
+	defer tx.end()
+	return tx.endTx(OpCommit, func() error {
+		return tx.conn.hookOp(tx.ctx, OpCommit, "COMMIT", func(context.Context) error {
+			return tx.Tx.Commit()
+		})
+	})
+}
+
+func (tx *Tx) Rollback() error {
+	defer tx.end()
+	return tx.endTx(OpRollback, func() error {
+		return tx.conn.hookOp(tx.ctx, OpRollback, "ROLLBACK", func(context.Context) error {
+			return tx.Tx.Rollback()
+		})
+	})
+}
+
+func (tx *Tx) end() {
+	tx.conn.mu.Lock()
+	tx.conn.txStartedAt = time.Time{}
+	tx.conn.txID = ""
+	tx.conn.mu.Unlock()
+}
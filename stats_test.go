@@ -0,0 +1,45 @@
+package sqlhooks
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnStats(t *testing.T) {
+	drv := Wrap(&sqlite3.SQLiteDriver{}, newTestHooks()).(*Driver)
+	driverName := fmt.Sprintf("sqlhooks-stats-%s", time.Now().String())
+	sql.Register(driverName, drv)
+
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	assert.Empty(t, drv.ConnStats())
+
+	_, err = db.Exec("CREATE TABLE t(id int)")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT nope")
+	require.Error(t, err)
+
+	stats := drv.ConnStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(2), stats[0].Queries)
+	assert.Equal(t, int64(1), stats[0].Errors)
+	assert.Error(t, stats[0].LastErr)
+	assert.False(t, stats[0].LastUsed.Before(stats[0].OpenedAt))
+	assert.True(t, stats[0].TxStartedAt.IsZero())
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	assert.False(t, drv.ConnStats()[0].TxStartedAt.IsZero())
+	require.NoError(t, tx.Rollback())
+	assert.True(t, drv.ConnStats()[0].TxStartedAt.IsZero())
+
+	require.NoError(t, db.Close())
+	assert.Empty(t, drv.ConnStats())
+}
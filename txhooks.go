@@ -0,0 +1,62 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// TxEvent describes a transaction beginning, committing or rolling back
+type TxEvent struct {
+	ConnID uint64
+	// TxID identifies the transaction, see IDs.Tx.
+	TxID string
+	// Opts are the options the transaction was begun with.
+	Opts driver.TxOptions
+	// StartedAt is the time the transaction began, zero before it did.
+	StartedAt time.Time
+	// Duration is how long the transaction has been open when the callback
+	// runs. In AfterCommit and AfterRollback it is the duration of the
+	// whole transaction.
+	Duration time.Duration
+	// Err is the error returned by the underlying driver, in the After
+	// callbacks.
+	Err error
+}
+
+// TxHooks instances will be notified of the lifecycle of transactions, so
+// that their duration can be measured and long-running transactions
+// detected. They run whether or not WithOps enables the OpBegin, OpCommit
+// and OpRollback hooks, with the context passed to BeginTx.
+type TxHooks interface {
+	BeforeBegin(ctx context.Context, event TxEvent)
+	AfterBegin(ctx context.Context, event TxEvent)
+	BeforeCommit(ctx context.Context, event TxEvent)
+	AfterCommit(ctx context.Context, event TxEvent)
+	BeforeRollback(ctx context.Context, event TxEvent)
+	AfterRollback(ctx context.Context, event TxEvent)
+}
+
+// endTx commits or rolls back tx, notifying the TxHooks
+func (tx *Tx) endTx(op Op, end func() error) error {
+	h := tx.conn.ext.tx
+	if h == nil || HooksDisabled(tx.ctx) {
+		return end()
+	}
+	before, after := h.BeforeCommit, h.AfterCommit
+	if op == OpRollback {
+		before, after = h.BeforeRollback, h.AfterRollback
+	}
+	event := TxEvent{
+		ConnID:    tx.conn.id,
+		TxID:      tx.id,
+		Opts:      tx.opts,
+		StartedAt: tx.startedAt,
+		Duration:  time.Since(tx.startedAt),
+	}
+	before(tx.ctx, event)
+	err := end()
+	event.Duration, event.Err = time.Since(tx.startedAt), err
+	after(tx.ctx, event)
+	return err
+}
@@ -0,0 +1,73 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type txRecorder struct {
+	*testHooks
+	calls  []string
+	events []TxEvent
+}
+
+func (r *txRecorder) record(name string, event TxEvent) {
+	r.calls = append(r.calls, name)
+	r.events = append(r.events, event)
+}
+
+func (r *txRecorder) BeforeBegin(ctx context.Context, e TxEvent)    { r.record("BeforeBegin", e) }
+func (r *txRecorder) AfterBegin(ctx context.Context, e TxEvent)     { r.record("AfterBegin", e) }
+func (r *txRecorder) BeforeCommit(ctx context.Context, e TxEvent)   { r.record("BeforeCommit", e) }
+func (r *txRecorder) AfterCommit(ctx context.Context, e TxEvent)    { r.record("AfterCommit", e) }
+func (r *txRecorder) BeforeRollback(ctx context.Context, e TxEvent) { r.record("BeforeRollback", e) }
+func (r *txRecorder) AfterRollback(ctx context.Context, e TxEvent)  { r.record("AfterRollback", e) }
+
+func TestTxHooks(t *testing.T) {
+	for name, wrap := range map[string]func(*txRecorder) Hooks{
+		"plain":   func(r *txRecorder) Hooks { return r },
+		"compose": func(r *txRecorder) Hooks { return Compose(newTestHooks(), r) },
+		"matrix": func(r *txRecorder) Hooks {
+			return NewMatrix().Add(r, OpBegin, OpCommit, OpRollback)
+		},
+		"events": func(r *txRecorder) Hooks { return FromEventHooks(ToEventHooks(r)) },
+		"when":   func(r *txRecorder) Hooks { return When(func(*Event) bool { return false }, r) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := &txRecorder{testHooks: newTestHooks()}
+			driverName := fmt.Sprintf("sqlhooks-txhooks-%s-%s", name, time.Now().String())
+			sql.Register(driverName, Wrap(&sqlite3.SQLiteDriver{}, wrap(rec)))
+			db, err := sql.Open(driverName, ":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+			require.NoError(t, err)
+			time.Sleep(time.Millisecond)
+			require.NoError(t, tx.Commit())
+			tx, err = db.Begin()
+			require.NoError(t, err)
+			require.NoError(t, tx.Rollback())
+
+			assert.Equal(t, []string{
+				"BeforeBegin", "AfterBegin", "BeforeCommit", "AfterCommit",
+				"BeforeBegin", "AfterBegin", "BeforeRollback", "AfterRollback",
+			}, rec.calls)
+			before, commit := rec.events[0], rec.events[3]
+			assert.True(t, before.StartedAt.IsZero())
+			assert.True(t, before.Opts.ReadOnly)
+			assert.NotEmpty(t, before.TxID)
+			assert.Equal(t, before.TxID, commit.TxID)
+			assert.Equal(t, rec.events[1].StartedAt, commit.StartedAt)
+			assert.True(t, commit.Duration >= time.Millisecond)
+			assert.NotEqual(t, commit.TxID, rec.events[7].TxID)
+		})
+	}
+}
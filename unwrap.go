@@ -0,0 +1,33 @@
+package sqlhooks
+
+import "database/sql/driver"
+
+// Unwrap returns the driver object wrapped by v, a connection, statement,
+// transaction, rows or result of a wrapped driver, unwrapping the objects
+// wrapped several times. Other values are returned as they are. It gives
+// access to the methods specific to a driver, such as from sql.Conn.Raw:
+//
+//	err := conn.Raw(func(dc interface{}) error {
+//		_, err := sqlhooks.Unwrap(dc).(*sqlite3.SQLiteConn).Backup("main", dst, "main")
+//		return err
+//	})
+//
+// Calls made on the unwrapped objects are not hooked.
+func Unwrap(v interface{}) interface{} {
+	for {
+		switch w := v.(type) {
+		case interface{ Unwrap() driver.Conn }:
+			v = w.Unwrap()
+		case interface{ Unwrap() driver.Stmt }:
+			v = w.Unwrap()
+		case interface{ Unwrap() driver.Tx }:
+			v = w.Unwrap()
+		case interface{ Unwrap() driver.Rows }:
+			v = w.Unwrap()
+		case interface{ Unwrap() driver.Result }:
+			v = w.Unwrap()
+		default:
+			return v
+		}
+	}
+}
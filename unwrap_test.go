@@ -0,0 +1,54 @@
+package sqlhooks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnwrap(t *testing.T) {
+	driverName := fmt.Sprintf("sqlhooks-unwrap-%s", time.Now().String())
+	sql.Register(driverName, Wrap(Wrap(&sqlite3.SQLiteDriver{}, newTestHooks()), newTestHooks()))
+	db, err := sql.Open(driverName, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(dc interface{}) error {
+		_, ok := dc.(*sqlite3.SQLiteConn)
+		assert.False(t, ok)
+		_, ok = Unwrap(dc).(*sqlite3.SQLiteConn)
+		assert.True(t, ok, "the connections wrapped twice are unwrapped")
+		return nil
+	}))
+
+	dc, err := Wrap(&sqlite3.SQLiteDriver{}, newTestHooks()).Open(":memory:")
+	require.NoError(t, err)
+	defer dc.Close()
+	stmt, err := dc.Prepare("SELECT 1")
+	require.NoError(t, err)
+	defer stmt.Close()
+	_, ok := Unwrap(stmt).(*sqlite3.SQLiteStmt)
+	assert.True(t, ok)
+	rows, err := stmt.(driver.StmtQueryContext).QueryContext(context.Background(), nil)
+	require.NoError(t, err)
+	defer rows.Close()
+	_, ok = Unwrap(rows).(*sqlite3.SQLiteRows)
+	assert.True(t, ok)
+	tx, err := dc.(driver.ConnBeginTx).BeginTx(context.Background(), driver.TxOptions{})
+	require.NoError(t, err)
+	_, ok = Unwrap(tx).(*sqlite3.SQLiteTx)
+	assert.True(t, ok)
+	require.NoError(t, tx.Rollback())
+
+	assert.Equal(t, "not wrapped", Unwrap("not wrapped"))
+}
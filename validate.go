@@ -0,0 +1,207 @@
+package sqlhooks
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Capability reports whether the wrapped driver implements an optional
+// interface sqlhooks relies on
+type Capability struct {
+	// Interface is the name of the interface, such as
+	// "driver.ExecerContext".
+	Interface string
+	Supported bool
+	// Required capabilities make Open fail when they are missing.
+	Required bool
+	// Degraded describes what sqlhooks does without the capability.
+	Degraded string
+	// Requested reports whether the options of the validated Driver enable
+	// a feature relying on the capability.
+	Requested bool
+}
+
+// Report describes the capabilities of a driver, as returned by Validate
+type Report struct {
+	// Driver is the type of the wrapped driver, such as "*pq.Driver".
+	Driver string
+	// Dialect is the name of the dialect of the driver, empty if unknown.
+	Dialect      string
+	Capabilities []Capability
+}
+
+// OK reports whether every required capability is supported
+func (r *Report) OK() bool {
+	for _, c := range r.Capabilities {
+		if c.Required && !c.Supported {
+			return false
+		}
+	}
+	return true
+}
+
+// Degraded returns the capabilities the driver does not support
+func (r *Report) Degraded() []Capability {
+	var degraded []Capability
+	for _, c := range r.Capabilities {
+		if !c.Supported {
+			degraded = append(degraded, c)
+		}
+	}
+	return degraded
+}
+
+// String returns a one line summary of the report, suitable for logging
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "driver %s", r.Driver)
+	if r.Dialect != "" {
+		fmt.Fprintf(&b, " (%s)", r.Dialect)
+	}
+	degraded := r.Degraded()
+	if len(degraded) == 0 {
+		b.WriteString(": all capabilities supported")
+		return b.String()
+	}
+	for i, c := range degraded {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "no %s, %s", c.Interface, c.Degraded)
+	}
+	return b.String()
+}
+
+// capabilityChecks are the optional interfaces checked by Validate and by
+// strict drivers. requested reports whether the options enable a feature
+// relying on the interface, named by feature.
+var capabilityChecks = []struct {
+	iface     string
+	required  bool
+	degraded  string
+	feature   string
+	supported func(driver.Conn) bool
+	requested func(*options) bool
+}{
+	{
+		iface:     "driver.ConnBeginTx",
+		required:  true,
+		degraded:  "connections cannot be opened",
+		feature:   "transactions",
+		supported: func(c driver.Conn) bool { _, ok := c.(driver.ConnBeginTx); return ok },
+		requested: func(*options) bool { return true },
+	},
+	{
+		iface:     "driver.ExecerContext or driver.Execer",
+		degraded:  "Exec calls are run on a prepared statement",
+		supported: isExecer,
+		requested: func(*options) bool { return false },
+	},
+	{
+		iface:     "driver.QueryerContext or driver.Queryer",
+		degraded:  "Query calls are run on a prepared statement",
+		supported: isQueryer,
+		requested: func(*options) bool { return false },
+	},
+	{
+		iface:     "driver.ConnPrepareContext",
+		degraded:  "Prepare ignores context cancellation",
+		feature:   "WithOps(OpPrepare)",
+		supported: func(c driver.Conn) bool { _, ok := c.(driver.ConnPrepareContext); return ok },
+		requested: func(o *options) bool { return o.ops.has(OpPrepare) },
+	},
+	{
+		iface:     "driver.SessionResetter",
+		degraded:  "sessions are reused without being reset",
+		supported: isSessionResetter,
+		requested: func(*options) bool { return false },
+	},
+	{
+		iface:     "driver.Pinger",
+		degraded:  "Ping is not hooked and always succeeds",
+		feature:   "WithOps(OpPing)",
+		supported: func(c driver.Conn) bool { _, ok := c.(driver.Pinger); return ok },
+		requested: func(o *options) bool { return o.ops.has(OpPing) },
+	},
+}
+
+// UnsupportedError is returned by the Open method of strict drivers whose
+// connections lack an interface required by a requested feature
+type UnsupportedError struct {
+	// Interface is the missing interface, see Capability.Interface.
+	Interface string
+	// Feature is the feature requiring it, such as "WithOps(OpPing)".
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("sqlhooks: %s requires a driver implementing %s", e.Feature, e.Interface)
+}
+
+// WithStrict makes Open return an *UnsupportedError, rather than silently
+// degrading, when the connections of the wrapped driver lack an interface
+// required by the features requested through the other options, such as
+// driver.Pinger for WithOps(OpPing). Connections are checked as they are
+// opened, which sql.DB.Ping forces at startup.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// checkStrict returns the error of the first requested capability conn
+// does not support
+func (o *options) checkStrict(conn driver.Conn) error {
+	for _, check := range capabilityChecks {
+		if check.requested(o) && !check.supported(conn) {
+			return &UnsupportedError{Interface: check.iface, Feature: check.feature}
+		}
+	}
+	return nil
+}
+
+// Validate opens a connection to name with drv to report which of the
+// optional interfaces sqlhooks relies on the driver implements, and thus
+// which features are degraded. It is meant to be called, and its report
+// logged, at startup:
+//
+//	report, err := sqlhooks.Validate(&pq.Driver{}, dsn)
+//	if err != nil {
+//		return err
+//	}
+//	log.Print(report)
+//
+// If drv is a Driver returned by Wrap, the driver it wraps is validated and
+// the connection is opened without running the hooks.
+func Validate(drv driver.Driver, name string) (*Report, error) {
+	report := &Report{}
+	if d, ok := DetectDialect(drv); ok {
+		report.Dialect = d.Name()
+	}
+	opts := newOptions(nil)
+	if wrapped, ok := drv.(*Driver); ok {
+		drv, opts = wrapped.Driver, wrapped.opts
+	}
+	report.Driver = reflect.TypeOf(drv).String()
+
+	conn, err := drv.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, check := range capabilityChecks {
+		report.Capabilities = append(report.Capabilities, Capability{
+			Interface: check.iface,
+			Supported: check.supported(conn),
+			Required:  check.required,
+			Degraded:  check.degraded,
+			Requested: check.requested(opts),
+		})
+	}
+	return report, nil
+}
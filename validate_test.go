@@ -0,0 +1,63 @@
+package sqlhooks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qustavo/sqlhooks/v2/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	report, err := Validate(&fakeDriver{}, "ExecerQueryerContext")
+	require.NoError(t, err)
+	assert.Equal(t, "*sqlhooks.fakeDriver", report.Driver)
+	assert.True(t, report.OK())
+
+	var degraded []string
+	for _, c := range report.Degraded() {
+		degraded = append(degraded, c.Interface)
+	}
+	assert.Equal(t, []string{"driver.ConnPrepareContext", "driver.SessionResetter", "driver.Pinger"}, degraded)
+	assert.Contains(t, report.String(), "no driver.Pinger, Ping is not hooked and always succeeds")
+
+	report, err = Validate(&fakeDriver{}, "NonConnBeginTx")
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+
+	_, err = Validate(&fakeDriver{}, "unknown")
+	assert.Error(t, err)
+}
+
+func TestValidateWrapped(t *testing.T) {
+	report, err := Validate(Wrap(&fakeDriver{}, &testHooks{}, WithDialect(sqlutil.MySQL)), "Basic")
+	require.NoError(t, err)
+	assert.Equal(t, "*sqlhooks.fakeDriver", report.Driver)
+	assert.Equal(t, "mysql", report.Dialect)
+	assert.Len(t, report.Degraded(), 5)
+}
+
+func TestStrict(t *testing.T) {
+	drv := Wrap(&fakeDriver{}, &testHooks{}, WithStrict(), WithOps(OpPing))
+	_, err := drv.Open("ExecerQueryerContext")
+	var unsupported *UnsupportedError
+	require.True(t, errors.As(err, &unsupported))
+	assert.Equal(t, "driver.Pinger", unsupported.Interface)
+	assert.Equal(t, "sqlhooks: WithOps(OpPing) requires a driver implementing driver.Pinger", err.Error())
+
+	// Not strict, the driver degrades silently
+	_, err = Wrap(&fakeDriver{}, &testHooks{}, WithOps(OpPing)).Open("ExecerQueryerContext")
+	assert.NoError(t, err)
+
+	// VerifyOnReset verifies the sessions of every driver
+	drv = Wrap(&fakeDriver{}, &testHooks{}, WithStrict(), WithSession(SessionConfig{VerifyOnReset: true}))
+	_, err = drv.Open("ExecerQueryerContext")
+	assert.NoError(t, err)
+
+	report, err := Validate(drv, "ExecerQueryerContext")
+	require.NoError(t, err)
+	for _, c := range report.Capabilities {
+		assert.Equal(t, c.Interface == "driver.ConnBeginTx", c.Requested, c.Interface)
+	}
+}